@@ -0,0 +1,106 @@
+package main
+
+import (
+	"edgeflow/internal/backtest"
+	"edgeflow/pkg/exchange"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+)
+
+/*
+离线回测：读取 trend.genCSV 格式的K线文件，回放趋势 → 信号 → 决策
+
+	# 先从交易所下载K线到 data 目录（只需一次）
+	go run ./cmd/backtest -symbol BTC/USDT -data data -fetch -since 2025-01-01
+	# 之后可以离线反复回测
+	go run ./cmd/backtest -symbol BTC/USDT -data data -start 2025-03-01 -out logs/backtest
+	# 用规则文件回测，调整阈值不需要重新编译
+	go run ./cmd/backtest -symbol BTC/USDT -data data -rules conf/rules.yaml
+*/
+func main() {
+	symbol := flag.String("symbol", "BTC/USDT", "交易对")
+	dataDir := flag.String("data", "logs", "K线CSV目录，文件名如 BTCUSDT_15min.csv，缺少的大周期由15min聚合")
+	fetch := flag.Bool("fetch", false, "先从OKX下载K线并写入 data 目录")
+	since := flag.String("since", "", "下载K线的起始日期 2006-01-02")
+	start := flag.String("start", "", "开始交易日期 2006-01-02，之前的数据只用于预热")
+	end := flag.String("end", "", "结束日期 2006-01-02")
+	capital := flag.Float64("capital", 10000, "初始资金 USDT")
+	leverage := flag.Int("leverage", 20, "杠杆倍数")
+	fee := flag.Float64("fee", 0.0005, "单边手续费率")
+	slippage := flag.Float64("slippage", 0.0002, "滑点比例")
+	outDir := flag.String("out", "logs/backtest", "成交记录和权益曲线输出目录")
+	rules := flag.String("rules", "", "YAML 决策规则文件，为空时使用内置逻辑")
+	flag.Parse()
+
+	if *fetch {
+		if err := download(*symbol, *dataDir, *since); err != nil {
+			log.Fatalf("下载K线失败: %v", err)
+		}
+	}
+
+	data, err := backtest.LoadDir(*dataDir, *symbol)
+	if err != nil {
+		log.Fatalf("读取K线失败: %v", err)
+	}
+
+	cfg := backtest.DefaultConfig(*symbol)
+	cfg.InitialCapital = *capital
+	cfg.Leverage = *leverage
+	cfg.FeeRate = *fee
+	cfg.Slippage = *slippage
+	cfg.RulesFile = *rules
+	if cfg.Start, err = parseDate(*start); err != nil {
+		log.Fatalf("start 格式错误: %v", err)
+	}
+	if cfg.End, err = parseDate(*end); err != nil {
+		log.Fatalf("end 格式错误: %v", err)
+	}
+
+	engine, err := backtest.NewEngine(cfg, data)
+	if err != nil {
+		log.Fatalf("创建回测失败: %v", err)
+	}
+	result, err := engine.Run()
+	if err != nil {
+		log.Fatalf("回测失败: %v", err)
+	}
+
+	fmt.Println(result.Summary())
+	if err := result.WriteFiles(*outDir); err != nil {
+		log.Fatalf("写入回测结果失败: %v", err)
+	}
+	log.Printf("成交记录和权益曲线已写入 %s", *outDir)
+}
+
+// download 下载回测需要的全部周期，公共行情接口不需要API Key
+func download(symbol, dir, since string) error {
+	from, err := parseDate(since)
+	if err != nil {
+		return err
+	}
+	if from.IsZero() {
+		from = time.Now().AddDate(0, -3, 0)
+	}
+	ex := exchange.NewOkxExchange("", "", "")
+	for _, period := range backtest.Periods() {
+		lines, err := backtest.Fetch(ex, symbol, period, from)
+		if err != nil {
+			return err
+		}
+		path := backtest.CSVPath(dir, symbol, period)
+		if err := backtest.SaveCSV(path, lines); err != nil {
+			return err
+		}
+		log.Printf("已下载 %s %s %d 根K线 -> %s", symbol, period, len(lines), path)
+	}
+	return nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.UTC)
+}
@@ -5,6 +5,7 @@ import (
 	"edgeflow/conf"
 	"edgeflow/internal/dao"
 	"edgeflow/internal/dao/query"
+	"edgeflow/internal/handler/admin"
 	"edgeflow/internal/handler/alert"
 	"edgeflow/internal/handler/hyperliquid"
 	"edgeflow/internal/handler/insight"
@@ -15,11 +16,13 @@ import (
 	"edgeflow/internal/handler/user"
 	"edgeflow/internal/router"
 	"edgeflow/internal/service"
+	"edgeflow/internal/signal"
 	"edgeflow/pkg/cache"
 	"edgeflow/pkg/exchange"
 	"edgeflow/pkg/kafka"
 	"fmt"
 	"os"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -30,18 +33,76 @@ func InitRouter(db *gorm.DB) Router {
 
 	appCfg := conf.AppConfig
 
-	okxEx := exchange.NewOkxExchange(appCfg.Okx.ApiKey, appCfg.Okx.SecretKey, appCfg.Okx.Password)
+	// 按 okx 的接口限频排队，限频和临时错误退避重试，所有服务共用
+	okxEx := exchange.NewRateLimitedExchange(exchange.NewOkxExchange(appCfg.Okx.ApiKey, appCfg.Okx.SecretKey, appCfg.Okx.Password), exchange.OkxRateLimits())
 
-	//d := dao.NewOrderDao(db)
+	// 交易规则：定时从 okx 同步 tick/lot/最小数量/最大杠杆，下单前按规则取整和校验
+	instrumentRules := exchange.NewInstrumentRegistry(exchange.NewOkxInstrumentSource(nil))
+	go instrumentRules.Run(context.Background(), time.Hour)
+
+	// 永续合约资金费率、持仓量和主动买卖比，写入 derivative_metrics，用于行情排序、详情和趋势快照
+	derivativesInterval := appCfg.Derivatives.Interval
+	if derivativesInterval <= 0 {
+		derivativesInterval = 5 * time.Minute
+	}
+	derivatives := service.NewDerivativesCollector(nil, query.NewDerivativeMetricDao(db)).WithTop(appCfg.Derivatives.Top)
+	go derivatives.Run(context.Background(), derivativesInterval)
+
+	d := dao.NewOrderDao(db)
 	//rc := service.NewRiskService(d)
 
+	// 模拟盘：paper.enabled 时下单走本地撮合，行情仍然来自 okx
+	//var tradeEx exchange.Exchange = okxEx
+	//if appCfg.Paper.Enabled {
+	//	paperEx := exchange.NewPaperExchange(okxEx, exchange.PaperConfig{
+	//		Balance:      appCfg.Paper.Balance,
+	//		TakerFeeRate: appCfg.Paper.TakerFee,
+	//		MakerFeeRate: appCfg.Paper.MakerFee,
+	//		Slippage:     appCfg.Paper.Slippage,
+	//	})
+	//	go paperEx.Run(context.Background(), 3*time.Second)
+	//	tradeEx = paperEx
+	//}
+	// 币安：把 okxEx 换成 binanceEx 即可切换下单交易所
+	//binanceEx := exchange.NewBinanceExchange(appCfg.Binance.ApiKey, appCfg.Binance.SecretKey)
+	//tradeEx = binanceEx
+	// hyperliquid：只支持永续合约，testnet 时再加上 hyperliquid.WithTestnet()
+	//hlEx, err := exchange.NewHyperliquidExchange(appCfg.Hyperliquid.PrivateKey, hyperliquid.WithAccountAddress(appCfg.Hyperliquid.AccountAddress))
+	//if err != nil {
+	//	log.Fatalf("init hyperliquid: %v", err)
+	//}
+	//tradeEx = hlEx
+	// 幂等下单：webhook 重复推送或者重试时返回已有订单
+	//tradeEx = exchange.NewIdempotentExchange(tradeEx, dao.NewOrderDedupeRepository(cache.GetRedisClient(), 24*time.Hour))
+	// 按交易规则取整价格和数量，不符合规则的订单直接拒绝
+	//tradeEx = exchange.NewRulesExchange(tradeEx, instrumentRules)
+
 	// 仓位管理服务
-	//ps := position.NewPositionService(okxEx, d)
+	//ps := position.NewPositionService(tradeEx, d)
+	// 订单跟踪：轮询订单状态，记录成交、手续费和已实现盈亏
+	//orderTracker := position.NewOrderTracker(tradeEx, d, 5*time.Second)
+	//ps.WithTracker(orderTracker)
+	//go orderTracker.Run(context.Background())
+	// 私有频道：订单和仓位变化实时推送，轮询和定时对账作为兜底
+	//okxPrivate := service.NewOKXPrivateService(appCfg.Okx.ApiKey, appCfg.Okx.SecretKey, appCfg.Okx.Password)
+	//go okxPrivate.Run(context.Background())
+	//go orderTracker.Consume(context.Background(), okxPrivate.Orders())
+	// 限价开仓单：按策略配置超时撤单、追价或改市价
+	//ps.WithEntryManager(position.NewEntryManager(tradeEx, appCfg.Entry))
+
+	// 多周期K线缓存，实时K线来自 okxCandleService 推送到 kafka 的已收盘K线
+	//symbols := []string{"BTC/USDT", "ETH/USDT", "SOL/USDT"}
+	//klineManger := kline.NewKlineManager(okxEx, okxCandleService, kafConsumer, symbols)
+	//tm := trend.NewManager(okxEx, symbols, klineManger)
+	// 趋势快照附带资金费率、持仓量和主动买卖比
+	//tm.WithDerivatives(derivatives)
+	// 仓位计算：按策略配置选择固定风险、ATR 或凯利模型
+	//ps.WithSizer(position.NewPositionSizer(appCfg.Sizing, tm, d))
 
 	// k线策略
 	//engine := kline.NewSignalStrategy(tm, ps, klineManger)
 
-	//klineManger.RunScheduled(func() {
+	//klineManger.RunScheduled(context.Background(), func() {
 	//	tm.RunScheduled()
 	//	//engine.Run(symbols)
 	//})
@@ -52,11 +113,30 @@ func InitRouter(db *gorm.DB) Router {
 	//
 	//go tm.RunScheduled()
 
+	// 每一次交易决策都写入 signal_decisions，用于复盘
+	decisionDao := query.NewSignalDecisionDao(db)
+	decisionRecorder := service.NewSignalDecisionRecorder(decisionDao)
+	go decisionRecorder.Run(context.Background())
+
+	// 信号状态保存在 redis，重启后恢复
+	//sm, err := signal.NewPersistentSignalManager(context.Background(), appCfg.Strategy, signal.NewRedisStateStore(cache.GetRedisClient()), decisionRecorder)
+	//if err != nil {
+	//	log.Fatalf("init signal manager: %v", err)
+	//}
+
+	// 交易频次限制，计数保存在 redis
+	tradeLimiter := signal.NewPersistentTradeLimiter(appCfg.Strategy.TradeLimit, signal.NewRedisTradeLimiterStore(cache.GetRedisClient()))
+
+	// 紧急开关：暂停全部策略、单个策略或交易对，保存在 redis，多个实例共享
+	killSwitch := signal.NewKillSwitch(signal.NewRedisKillSwitchStore(cache.GetRedisClient()))
+	go killSwitch.Run(context.Background(), 2*time.Second)
+	//ps.WithKillSwitch(killSwitch)
+
 	// 策略分发器：根据级别分发不同的策略
-	//dispatcher := strategy.NewStrategyDispatcher()
-	//dispatcher.Register("tv-level", tradingview.NewTVLevelStrategy(sm, ps, tm))
+	//dispatcher := strategy.NewStrategyDispatcher().WithKillSwitch(killSwitch)
+	//dispatcher.Register("tv-level", tradingview.NewTVLevelStrategy(sm, tradeLimiter, ps, tm))
 
-	//wh := webhook.NewHandler(dispatcher, rc, sm, ps)
+	//wh := webhook.NewHandler(dispatcher, rc, sm, ps, killSwitch)
 
 	kafHost := os.Getenv("KAFKA_HOST")
 	kafPort := os.Getenv("KAFKA_PORT")
@@ -71,19 +151,37 @@ func InitRouter(db *gorm.DB) Router {
 
 	signalDao := query.NewSignalDao(db)
 	insightDao := query.NewInsightDao(db)
-	signalService := service.NewSignalProcessorService(signalDao, okxEx)
+	signalService := service.NewSignalProcessorService(signalDao, decisionDao, okxEx)
 	insightService := service.NewInsightService(insightDao)
+	// 定时评估最近7天信号的止盈止损结果，写入 signal_outcomes
+	outcomeEvaluator := service.NewSignalOutcomeEvaluator(signalDao, okxEx)
+	go outcomeEvaluator.Run(context.Background(), 15*time.Minute, 7*24*time.Hour)
 	hyperDao := query.NewHyperLiquidDao(db)
 	alertDao := query.NewAlertDAO(db)
 	defaultsCoins := []string{"BTC", "ETH", "SOL", "DOGE", "XPL", "OKB", "XRP", "LTC", "BNB", "AAVE", "AVAX", "ADA", "LINK", "TRX"}
 	tickerService := service.NewOKXTickerService(defaultsCoins)
 	alertServcice := service.NewAlertService(kafProducer, alertDao)
+	// 仓位对账：本地仓位元信息与交易所不一致时修复，差异较大时发送策略提醒
+	//reconciler := position.NewReconciler(ps, alertServcice, appCfg.Reconcile)
+	//go reconciler.Run(context.Background())
+	//go reconciler.Consume(context.Background(), okxPrivate.Positions())
+	// 组合风控：敞口、持仓数量和当日亏损超过限制时停止开仓，可选平掉全部仓位
+	//portfolioRisk := position.NewPortfolioRisk(ps, d, alertServcice, appCfg.Risk)
+	//ps.WithRisk(portfolioRisk)
+	//go portfolioRisk.Run(context.Background())
 	boundaryRepo := dao.NewAlertBoundaryRepository()
-	marketService := service.NewMarketDataService(tickerService, instrumentDao, okxEx, signalDao, kafProducer, alertServcice, boundaryRepo)
+	marketService := service.NewMarketDataService(tickerService, instrumentDao, okxEx, signalDao, kafProducer, alertServcice, boundaryRepo).
+		WithDerivatives(derivatives)
 	err := marketService.InitializeBaseInstruments(context.Background(), 1)
 	if err != nil {
 		panic(err)
 	}
+	// 移动止损：按实时价格收紧持仓的止损单
+	//trailing := position.NewTrailingStopManager(ps, marketService, tm, appCfg.Trailing)
+	//go trailing.Run(context.Background())
+	// 分批止盈：信号带 tp_ladder 时按档位部分平仓
+	//tpLadder := position.NewTakeProfitLadder(ps, marketService, 5*time.Second)
+	//go tpLadder.Run(context.Background())
 
 	rds := cache.GetRedisClient()
 	hyperService := service.NewHyperLiquidService(hyperDao, rds, marketService)
@@ -102,14 +200,22 @@ func InitRouter(db *gorm.DB) Router {
 	userHandler := user.NewUserHandler(userService, deviceService)
 	insightHandler := insight.NewHandler(insightService)
 
-	signalHandler := signal3.NewSignalHandler(signalService, okxEx)
+	// 幂等下单：clOrdId 对应的订单保存在 redis，重复请求返回已有订单
+	orderDedupe := dao.NewOrderDedupeRepository(cache.GetRedisClient(), 24*time.Hour)
+	userTradingService := service.NewUserTradingService(query.NewUserTradingDao(db), signalService, userService, appCfg.UserTrading).
+		WithDedupeStore(orderDedupe).
+		WithInstrumentRules(instrumentRules)
+	signalHandler := signal3.NewSignalHandler(signalService, userTradingService)
 
 	tickerGw := ticker.NewTickerGateway(marketService, kafConsumer)
 	subscriptionGw := market.NewSubscriptionGateway(okxCandleService, kafConsumer)
 
 	alertHandler := alert.NewAlertGateway(alertServcice, kafConsumer)
 
-	apiRouter := router.NewApiRouter(coinH, marketHandler, hyperHandler, insightHandler, userHandler, signalHandler, tickerGw, subscriptionGw, alertHandler)
+	tradingHandler := admin.NewTradingHandler(tradeLimiter, d, nil, killSwitch, nil) // 启用交易管线后传入 portfolioRisk 和 ps
+	tradingHandler.WithExchangeStats(okxEx)
+
+	apiRouter := router.NewApiRouter(coinH, marketHandler, hyperHandler, insightHandler, userHandler, signalHandler, tickerGw, subscriptionGw, alertHandler, tradingHandler)
 
 	return apiRouter
 }
@@ -57,6 +57,24 @@ func main() {
 	if err := db.RunSQLFile(datasource, "script/sql/signal_refactor_cut1.sql"); err != nil {
 		log.Fatalf("Failed to run signal refactor cut1 migration: %v", err)
 	}
+	if err := db.RunSQLFile(datasource, "script/sql/signal_decisions.sql"); err != nil {
+		log.Fatalf("Failed to run signal decisions migration: %v", err)
+	}
+	if err := db.RunSQLFile(datasource, "script/sql/position_discrepancy.sql"); err != nil {
+		log.Fatalf("Failed to run position discrepancy migration: %v", err)
+	}
+	if err := db.RunSQLFile(datasource, "script/sql/order_tracking.sql"); err != nil {
+		log.Fatalf("Failed to run order tracking migration: %v", err)
+	}
+	if err := db.RunSQLFile(datasource, "script/sql/user_trading.sql"); err != nil {
+		log.Fatalf("Failed to run user trading migration: %v", err)
+	}
+	if err := db.RunSQLFile(datasource, "script/sql/order_entry.sql"); err != nil {
+		log.Fatalf("Failed to run order entry migration: %v", err)
+	}
+	if err := db.RunSQLFile(datasource, "script/sql/derivative_metrics.sql"); err != nil {
+		log.Fatalf("Failed to run derivative metrics migration: %v", err)
+	}
 
 	redisHost := os.Getenv("REDIS_HOST")
 	redisPort := os.Getenv("REDIS_PORT")
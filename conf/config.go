@@ -20,6 +20,126 @@ type Okx struct {
 	Simulated bool   `yaml:"simulated"`
 }
 
+// Binance 币安现货和 U 本位合约，合约账户需要开启双向持仓
+type Binance struct {
+	ApiKey    string `yaml:"apiKey"`
+	SecretKey string `yaml:"secretKey"`
+}
+
+// Hyperliquid 永续合约，privateKey 为签名私钥，使用 API 钱包时 accountAddress 填主账户地址
+type Hyperliquid struct {
+	PrivateKey     string `yaml:"privateKey"`
+	AccountAddress string `yaml:"accountAddress"`
+	Testnet        bool   `yaml:"testnet"`
+}
+
+// PaperConfig 模拟盘，开启后交易管线使用本地撮合而不是真实账户
+type PaperConfig struct {
+	Enabled  bool    `yaml:"enabled"`
+	Balance  float64 `yaml:"balance"`   // 初始 USDT 余额
+	TakerFee float64 `yaml:"taker-fee"` // 吃单手续费率
+	MakerFee float64 `yaml:"maker-fee"` // 挂单手续费率
+	Slippage float64 `yaml:"slippage"`  // 市价单滑点比例
+}
+
+// ReconcileConfig 本地仓位与交易所仓位对账
+type ReconcileConfig struct {
+	Interval      time.Duration `yaml:"interval"`        // 对账间隔
+	Symbols       []string      `yaml:"symbols"`         // 没有本地仓位时也要检查的交易对，用于发现手动开的仓位
+	SizeTolerance float64       `yaml:"size-tolerance"`  // 数量相对偏差在此范围内视为一致
+	AlertSizeDiff float64       `yaml:"alert-size-diff"` // 数量相对偏差超过此值时发送提醒
+	AlertUserID   string        `yaml:"alert-user-id"`   // 接收提醒的用户，为空时只记录不推送
+}
+
+// RiskConfig 组合风控，为 0 的限制不生效
+type RiskConfig struct {
+	Interval          time.Duration `yaml:"interval"`            // 刷新权益和仓位的间隔
+	Symbols           []string      `yaml:"symbols"`             // 没有本地仓位时也要统计的交易对
+	TradeType         string        `yaml:"trade-type"`          // 查询权益和仓位使用的交易类型，默认 swap
+	MaxGrossExposure  float64       `yaml:"max-gross-exposure"`  // 多空名义价值之和 / 权益
+	MaxNetExposure    float64       `yaml:"max-net-exposure"`    // |多头名义价值 - 空头名义价值| / 权益
+	MaxPositions      int           `yaml:"max-positions"`       // 最多同时持有的交易对数量
+	MaxSymbolLeverage float64       `yaml:"max-symbol-leverage"` // 单个交易对名义价值 / 权益
+	MaxDailyDrawdown  float64       `yaml:"max-daily-drawdown"`  // 当日亏损 / 日初权益，超过后停止开仓
+	RiskUnit          float64       `yaml:"risk-unit"`           // 1R 占日初权益的比例，用于计算 DailyR，默认 0.01
+	FlattenOnBreach   bool          `yaml:"flatten-on-breach"`   // 触发风控后是否平掉全部仓位
+	AlertUserID       string        `yaml:"alert-user-id"`       // 接收提醒的用户，为空时只记录不推送
+}
+
+// TrailingConfig 移动止损，modes 可以组合，取其中最紧的止损价
+type TrailingConfig struct {
+	Interval           time.Duration `yaml:"interval"`            // 检查仓位和价格的间隔
+	Symbols            []string      `yaml:"symbols"`             // 没有本地仓位时也要检查的交易对
+	Modes              []string      `yaml:"modes"`               // percent: 最优价回撤百分比, atr: 最新价 - ATR*倍数, break-even: 浮盈达到 N 个 R 后移到开仓价, chandelier: 开仓后最优价 - ATR*倍数
+	Percent            float64       `yaml:"percent"`             // percent 模式的回撤比例，如 0.02
+	AtrMultiple        float64       `yaml:"atr-multiple"`        // atr 模式的 ATR 倍数
+	ChandelierMultiple float64       `yaml:"chandelier-multiple"` // chandelier 模式的 ATR 倍数
+	BreakEvenR         float64       `yaml:"break-even-r"`        // 浮盈达到多少个 R 后移到开仓价，默认 1
+	BreakEvenOffset    float64       `yaml:"break-even-offset"`   // 保本止损在开仓价基础上多锁定的比例，用于覆盖手续费
+	InitialStopPct     float64       `yaml:"initial-stop-pct"`    // 没有记录开仓止损时按此比例推算 1R，默认 0.015
+	MinStep            float64       `yaml:"min-step"`            // 新止损相对当前止损至少移动的比例，避免频繁修改
+	MinInterval        time.Duration `yaml:"min-interval"`        // 同一仓位两次修改止损的最短间隔
+}
+
+// EntryConfig 限价单入场策略，按策略名选择，没有配置的策略使用 default
+type EntryConfig struct {
+	Default    EntryPolicyConfig            `yaml:"default"`
+	Strategies map[string]EntryPolicyConfig `yaml:"strategies"`
+}
+
+// EntryPolicyConfig 限价单未成交时的处理
+type EntryPolicyConfig struct {
+	Policy        string        `yaml:"policy"`         // none（挂单不管）/ cancel（超时撤单）/ chase（向盘口追价）/ market（超时改市价）
+	Timeout       time.Duration `yaml:"timeout"`        // 挂单超时时间，chase 超时后撤单
+	CheckInterval time.Duration `yaml:"check-interval"` // 查询订单状态的间隔
+	ChaseInterval time.Duration `yaml:"chase-interval"` // 两次追价的最短间隔
+	MaxSlippage   float64       `yaml:"max-slippage"`   // 追价相对原委托价的最大偏离比例，超过后撤单
+}
+
+// UserTradingConfig 用户绑定自己的交易所 API Key 执行信号
+type UserTradingConfig struct {
+	SecretKey string            `yaml:"secret-key"` // 加密 API Key 的 AES 密钥，长度必须为 16/24/32
+	Plans     map[int]PlanLimit `yaml:"plans"`      // key 为用户角色（1 标准用户, 2 Plus），没有配置的角色不能执行信号
+}
+
+// DerivativesConfig 永续合约资金费率、持仓量和主动买卖比的采集
+type DerivativesConfig struct {
+	Interval time.Duration `yaml:"interval"` // 采集间隔，默认 5m
+	Top      int           `yaml:"top"`      // 按持仓价值取前 N 个 USDT 永续，默认 50
+}
+
+// PlanLimit 订阅计划允许的下单参数
+type PlanLimit struct {
+	MaxLeverage    int     `yaml:"max-leverage"`     // 最大杠杆倍数
+	MaxQuantityPct float64 `yaml:"max-quantity-pct"` // 单笔最多使用可用余额的比例
+}
+
+// SizingConfig 仓位计算，按策略名选择模型，没有配置的策略使用 default
+type SizingConfig struct {
+	Default    SizerConfig            `yaml:"default"`
+	Strategies map[string]SizerConfig `yaml:"strategies"`
+}
+
+// SizerConfig 单个策略的仓位计算参数
+type SizerConfig struct {
+	Model          string  `yaml:"model"`            // level: 按信号级别的固定比例（默认）, fixed: 固定风险比例, atr: ATR 止损距离, kelly: 凯利公式
+	RiskPct        float64 `yaml:"risk-pct"`         // 每笔交易最多亏损权益的比例，如 0.01
+	AtrMultiple    float64 `yaml:"atr-multiple"`     // atr 模型止损距离 = ATR * 倍数
+	KellyFraction  float64 `yaml:"kelly-fraction"`   // 凯利比例打折，如 0.5 为半凯利
+	KellyCap       float64 `yaml:"kelly-cap"`        // 凯利模型单笔风险比例上限
+	MinTrades      int     `yaml:"min-trades"`       // 历史平仓次数不足时凯利模型退回 risk-pct
+	MaxNotional    float64 `yaml:"max-notional"`     // 单笔最大名义价值（USDT），0 表示不限制
+	MaxNotionalPct float64 `yaml:"max-notional-pct"` // 单笔名义价值占权益的上限，0 表示不限制
+}
+
+// For 返回策略使用的仓位计算参数
+func (c SizingConfig) For(strategy string) SizerConfig {
+	if cfg, ok := c.Strategies[strategy]; ok {
+		return cfg
+	}
+	return c.Default
+}
+
 type Db struct {
 	DbName   string `yaml:"dbname"`
 	Host     string `yaml:"host"`
@@ -41,10 +161,28 @@ type StrategyConfig struct {
 	L3ReduceAtRMultiple float64       `yaml:"L3ReduceAtRMultiple"` // 浮盈多少倍R触发减仓
 	L3ReducePercent     float64       `yaml:"L3ReducePercent"`     // 减仓比例 (0~1)
 
+	// ---- 决策规则 ----
+	RulesFile string `yaml:"RulesFile"` // YAML 决策规则文件，修改后自动重新加载，为空时使用内置逻辑
+
+	// ---- 交易频次限制 ----
+	TradeLimit TradeLimiterConfig `yaml:"TradeLimit"`
+
 	// ---- 日志 / 调试 ----
 	EnableDebugLog bool `yaml:"EnableDebugLog"` // 是否打印 Debug 日志
 }
 
+// TradeLimiterConfig 交易频次限制，为 0 的字段使用默认值
+type TradeLimiterConfig struct {
+	MaxConsecutiveOpens int           `yaml:"MaxConsecutiveOpens" json:"max_consecutive_opens"` // 最大连续开仓次数
+	MaxConsecutiveAdds  int           `yaml:"MaxConsecutiveAdds" json:"max_consecutive_adds"`   // 最大连续加仓次数
+	OpenCooldownPeriod  time.Duration `yaml:"OpenCooldownPeriod" json:"open_cooldown_period"`   // 开仓冷却时间
+	AddCooldownPeriod   time.Duration `yaml:"AddCooldownPeriod" json:"add_cooldown_period"`     // 加仓冷却时间
+	MaxOpensPerDay      int           `yaml:"MaxOpensPerDay" json:"max_opens_per_day"`          // 每日最大开仓次数
+	MaxAddsPerDay       int           `yaml:"MaxAddsPerDay" json:"max_adds_per_day"`            // 每日最大加仓次数
+	HistoryKeepCount    int           `yaml:"HistoryKeepCount" json:"history_keep_count"`       // 保留历史记录数量
+	ResetHour           int           `yaml:"ResetHour" json:"reset_hour"`                      // 每日重置时间（小时）
+}
+
 type LogConfig struct {
 	Level      string `yaml:"level"`
 	FileName   string `yaml:"file-name"`
@@ -122,16 +260,26 @@ type Config struct {
 	MaxPingCount int    `yaml:"max-ping-count"`
 	ExternalURL  string `yaml:"external_url"`
 
-	Webhook  WebhookConfig `yaml:"webhook"`
-	Okx      `yaml:"okx"`
-	Db       `yaml:"database"`
-	Strategy StrategyConfig `yaml:"strategy"`
-	Log      LogConfig      `yaml:"log"`
-	Jwt      JwtConfig      `yaml:"jwt"`
-	Redis    RedisConfig    `yaml:"redis"`
-	Email    EmailCofig     `yaml:"email"`
-	Apple    AppleConfig    `yaml:"apple"`
-	Kafka    KafkaConfig    `yaml:"kafka"`
+	Webhook     WebhookConfig `yaml:"webhook"`
+	Okx         `yaml:"okx"`
+	Binance     Binance     `yaml:"binance"`
+	Hyperliquid Hyperliquid `yaml:"hyperliquid"`
+	Db          `yaml:"database"`
+	Paper       PaperConfig       `yaml:"paper"`
+	Reconcile   ReconcileConfig   `yaml:"reconcile"`
+	Sizing      SizingConfig      `yaml:"sizing"`
+	Risk        RiskConfig        `yaml:"risk"`
+	Trailing    TrailingConfig    `yaml:"trailing"`
+	Entry       EntryConfig       `yaml:"entry"`
+	UserTrading UserTradingConfig `yaml:"user-trading"`
+	Derivatives DerivativesConfig `yaml:"derivatives"`
+	Strategy    StrategyConfig    `yaml:"strategy"`
+	Log         LogConfig         `yaml:"log"`
+	Jwt         JwtConfig         `yaml:"jwt"`
+	Redis       RedisConfig       `yaml:"redis"`
+	Email       EmailCofig        `yaml:"email"`
+	Apple       AppleConfig       `yaml:"apple"`
+	Kafka       KafkaConfig       `yaml:"kafka"`
 }
 
 var AppConfig Config
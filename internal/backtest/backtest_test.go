@@ -0,0 +1,185 @@
+package backtest
+
+import (
+	model2 "edgeflow/internal/model"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nntaoli-project/goex/v2/model"
+)
+
+// 生成一段先涨后跌带波动的 15m K线，最后 300 根转为下跌
+func genKlines(n int) []model2.Kline {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	lines := make([]model2.Kline, n)
+	price := 100.0
+	for i := 0; i < n; i++ {
+		drift := 0.002
+		if i > n-300 {
+			drift = -0.002
+		}
+		open := price
+		price = price * (1 + drift + 0.004*math.Sin(float64(i)/3))
+		lines[i] = model2.Kline{
+			Timestamp: base.Add(time.Duration(i) * 15 * time.Minute),
+			Open:      open,
+			Close:     price,
+			High:      math.Max(open, price) * 1.001,
+			Low:       math.Min(open, price) * 0.999,
+			Vol:       1,
+		}
+	}
+	return lines
+}
+
+func TestResample(t *testing.T) {
+	lines := genKlines(10)
+	out := Resample(lines, 15*time.Minute, time.Hour)
+	// 10根15m只能组成2根完整的1h
+	if len(out) != 2 {
+		t.Fatalf("期望2根1h K线, got %d", len(out))
+	}
+	first := out[0]
+	if !first.Timestamp.Equal(lines[0].Timestamp) || first.Open != lines[0].Open || first.Close != lines[3].Close || first.Vol != 4 {
+		t.Fatalf("聚合结果错误: %+v", first)
+	}
+	for _, k := range lines[:4] {
+		if k.High > first.High || k.Low < first.Low {
+			t.Fatalf("最高/最低价聚合错误: %+v", first)
+		}
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	lines := genKlines(20)
+	path := CSVPath(dir, "BTC/USDT", model.Kline_15min)
+	if filepath.Base(path) != "BTCUSDT_15min.csv" {
+		t.Fatalf("文件名应与 genCSV 一致: %s", path)
+	}
+	if err := SaveCSV(path, lines); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(lines) || !got[5].Timestamp.Equal(lines[5].Timestamp) || got[5].Close != lines[5].Close {
+		t.Fatalf("读写不一致")
+	}
+
+	data, err := LoadDir(dir, "BTC/USDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data[model.Kline_1h]) != 5 || len(data[model.Kline_4h]) != 1 {
+		t.Fatalf("缺失的周期应由15m聚合: 1h=%d 4h=%d", len(data[model.Kline_1h]), len(data[model.Kline_4h]))
+	}
+
+	if _, err := LoadDir(filepath.Join(dir, "none"), "BTC/USDT"); !os.IsNotExist(err) {
+		t.Fatalf("缺少15m文件应报错: %v", err)
+	}
+}
+
+func TestReplayFeedNoLookahead(t *testing.T) {
+	lines := genKlines(8)
+	feed := newReplayFeed("BTC/USDT", map[model.KlinePeriod][]model2.Kline{model.Kline_15min: lines})
+
+	// 第3根K线的开盘时刻，只有前2根已收盘
+	feed.setNow(lines[2].Timestamp)
+	got, ok := feed.Get("BTC/USDT", model.Kline_15min)
+	if !ok || len(got) != 2 {
+		t.Fatalf("期望2根已收盘K线, got %d", len(got))
+	}
+	feed.setNow(lines[2].Timestamp.Add(15 * time.Minute))
+	got, _ = feed.Get("BTC/USDT", model.Kline_15min)
+	if len(got) != 3 {
+		t.Fatalf("期望3根已收盘K线, got %d", len(got))
+	}
+	if _, ok := feed.Get("ETH/USDT", model.Kline_15min); ok {
+		t.Fatalf("其他币种不应有数据")
+	}
+}
+
+func TestBroker(t *testing.T) {
+	at := time.Now()
+	b := newBroker("BTC/USDT", 1000, 10, 0.001, 0)
+	b.open(model2.OrderPosSideLong, 100, at, 0.1, 2, 1)
+	// 名义价值 1000*0.1*10=1000，手续费1
+	if b.pos.qty != 10 || b.cash != 999 {
+		t.Fatalf("开仓错误: qty=%v cash=%v", b.pos.qty, b.cash)
+	}
+	if b.pos.tp != 102 || b.pos.sl != 99 {
+		t.Fatalf("止盈止损错误: tp=%v sl=%v", b.pos.tp, b.pos.sl)
+	}
+	info := b.positionInfo(101)
+	if info.UplRatio != "0.10000000" {
+		t.Fatalf("UplRatio 应按杠杆计算: %s", info.UplRatio)
+	}
+
+	// 减半仓，盈利 (101-100)*5=5，平仓手续费0.505，分摊开仓手续费0.5
+	b.close(101, at, 0.5, "reduce")
+	tr := b.trades[0]
+	if math.Abs(tr.PnL-(5-0.505-0.5)) > 1e-9 || b.pos.qty != 5 {
+		t.Fatalf("减仓错误: %+v qty=%v", tr, b.pos.qty)
+	}
+
+	// 触发止损
+	b.checkStops(model2.Kline{Timestamp: at, Open: 100, High: 100.5, Low: 98})
+	if b.pos != nil || b.trades[1].Reason != "sl" || b.trades[1].ExitPrice != 99 {
+		t.Fatalf("止损未触发: %+v", b.trades)
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	trades := []Trade{{PnL: 30}, {PnL: -10}, {PnL: 20}, {PnL: -20}}
+	equity := []EquityPoint{{Equity: 1000}, {Equity: 1200}, {Equity: 900}, {Equity: 1020}}
+	st := computeStats(1000, trades, equity)
+	if st.WinRate != 0.5 || st.ProfitFactor != 50.0/30.0 {
+		t.Fatalf("胜率或盈亏比错误: %+v", st)
+	}
+	if math.Abs(st.MaxDrawdown-0.25) > 1e-9 || math.Abs(st.TotalReturn-0.02) > 1e-9 {
+		t.Fatalf("回撤或收益率错误: %+v", st)
+	}
+}
+
+func TestEngineRun(t *testing.T) {
+	// 4h 预热需要200根，即 200*16 根15m
+	base := genKlines(200*16 + 600)
+	data := map[model.KlinePeriod][]model2.Kline{model.Kline_15min: base}
+	for _, period := range periods[1:] {
+		data[period] = Resample(base, 15*time.Minute, periodDuration(period))
+	}
+
+	cfg := DefaultConfig("BTCUSDT")
+	engine, err := NewEngine(cfg, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := engine.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Equity) == 0 {
+		t.Fatalf("预热完成后应有权益曲线")
+	}
+	total := 0
+	for _, n := range result.Decisions {
+		total += n
+	}
+	if total == 0 || len(result.Trades) == 0 {
+		t.Fatalf("预热完成后应有决策和成交: %v", result.Decisions)
+	}
+	// 所有仓位在结束时已平掉，最终权益等于初始资金加上全部成交盈亏
+	pnl := 0.0
+	for _, tr := range result.Trades {
+		pnl += tr.PnL
+	}
+	if math.Abs(result.Stats.FinalEquity-(cfg.InitialCapital+pnl)) > 1e-6 {
+		t.Fatalf("权益与成交记录不一致: final=%v pnl=%v", result.Stats.FinalEquity, pnl)
+	}
+	t.Log(result.Summary())
+}
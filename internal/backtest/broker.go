@@ -0,0 +1,204 @@
+package backtest
+
+import (
+	"edgeflow/internal/model"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Trade 一笔已平仓（或部分平仓）的成交记录
+type Trade struct {
+	Symbol     string
+	Side       model.OrderPosSide
+	EntryTime  time.Time
+	ExitTime   time.Time
+	EntryPrice float64
+	ExitPrice  float64
+	Qty        float64 // 平掉的币数量
+	Fee        float64 // 分摊的开仓手续费 + 平仓手续费
+	PnL        float64 // 扣除手续费后的净盈亏
+	Reason     string  // 平仓原因：close/reduce/tp/sl/end
+}
+
+// EquityPoint 权益曲线上的一个点，按收盘价计算浮动盈亏
+type EquityPoint struct {
+	Time   time.Time
+	Equity float64
+}
+
+// 模拟仓位，同一时间只持有一个方向
+type simPosition struct {
+	side     model.OrderPosSide
+	qty      float64
+	avgPrice float64
+	openTime time.Time
+	entryFee float64 // 尚未分摊到成交记录的开仓手续费
+	tp       float64
+	sl       float64
+}
+
+// broker 模拟撮合和账户，按市价成交并收取手续费
+type broker struct {
+	symbol   string
+	cash     float64 // 已实现权益
+	leverage int
+	feeRate  float64
+	slippage float64
+
+	pos    *simPosition
+	trades []Trade
+}
+
+func newBroker(symbol string, capital float64, leverage int, feeRate, slippage float64) *broker {
+	return &broker{
+		symbol:   symbol,
+		cash:     capital,
+		leverage: leverage,
+		feeRate:  feeRate,
+		slippage: slippage,
+	}
+}
+
+// equity 按给定价格计算的总权益
+func (b *broker) equity(price float64) float64 {
+	return b.cash + b.unrealized(price)
+}
+
+func (b *broker) unrealized(price float64) float64 {
+	if b.pos == nil {
+		return 0
+	}
+	return dirSign(b.pos.side) * (price - b.pos.avgPrice) * b.pos.qty
+}
+
+// positionInfo 转换为决策层使用的仓位信息，UplRatio 与 OKX 一致按保证金计算
+func (b *broker) positionInfo(price float64) *model.PositionInfo {
+	if b.pos == nil {
+		return nil
+	}
+	uplRatio := dirSign(b.pos.side) * (price - b.pos.avgPrice) / b.pos.avgPrice * float64(b.leverage)
+	return &model.PositionInfo{
+		Symbol:        b.symbol,
+		Dir:           b.pos.side,
+		Amount:        b.pos.qty,
+		AvgPrice:      b.pos.avgPrice,
+		UnrealizedPnl: strconv.FormatFloat(b.unrealized(price), 'f', 8, 64),
+		UplRatio:      strconv.FormatFloat(uplRatio, 'f', 8, 64),
+		MarkPx:        strconv.FormatFloat(price, 'f', -1, 64),
+		Lever:         strconv.Itoa(b.leverage),
+		Last:          price,
+	}
+}
+
+// open 开仓或加仓，marginPct 为本次使用的保证金占当前权益的比例
+func (b *broker) open(side model.OrderPosSide, price float64, at time.Time, marginPct, tpPct, slPct float64) {
+	if b.pos != nil && b.pos.side != side {
+		// 决策层不会在持仓时反向开仓，这里兜底先平掉
+		b.close(price, at, 1, "reverse")
+	}
+	fill := b.fillPrice(side, price, true)
+	notional := b.equity(price) * marginPct * float64(b.leverage)
+	if notional <= 0 {
+		return
+	}
+	qty := notional / fill
+	fee := notional * b.feeRate
+	b.cash -= fee
+
+	if b.pos == nil {
+		b.pos = &simPosition{side: side, openTime: at}
+	}
+	p := b.pos
+	p.avgPrice = (p.avgPrice*p.qty + fill*qty) / (p.qty + qty)
+	p.qty += qty
+	p.entryFee += fee
+	// 与 PositionService.Open 一致，止盈止损按本次成交价计算
+	p.tp = applyPct(side, fill, tpPct)
+	p.sl = applyPct(side, fill, -slPct)
+}
+
+// close 平掉 fraction 比例的仓位
+func (b *broker) close(price float64, at time.Time, fraction float64, reason string) {
+	p := b.pos
+	if p == nil || fraction <= 0 {
+		return
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	fill := b.fillPrice(p.side, price, false)
+	qty := p.qty * fraction
+	exitFee := qty * fill * b.feeRate
+	entryFee := p.entryFee * fraction
+	gross := dirSign(p.side) * (fill - p.avgPrice) * qty
+
+	b.cash += gross - exitFee
+	b.trades = append(b.trades, Trade{
+		Symbol:     b.symbol,
+		Side:       p.side,
+		EntryTime:  p.openTime,
+		ExitTime:   at,
+		EntryPrice: p.avgPrice,
+		ExitPrice:  fill,
+		Qty:        qty,
+		Fee:        entryFee + exitFee,
+		PnL:        gross - exitFee - entryFee,
+		Reason:     reason,
+	})
+
+	p.entryFee -= entryFee
+	p.qty -= qty
+	if fraction == 1 || p.qty <= 0 {
+		b.pos = nil
+	}
+}
+
+// checkStops 用K线的最高/最低价检查止盈止损
+// 同一根K线同时触及时按止损处理，回测结果偏保守
+func (b *broker) checkStops(bar model.Kline) {
+	p := b.pos
+	if p == nil {
+		return
+	}
+	at := bar.Timestamp
+	if p.side == model.OrderPosSideLong {
+		switch {
+		case p.sl > 0 && bar.Low <= p.sl:
+			b.close(math.Min(p.sl, bar.Open), at, 1, "sl")
+		case p.tp > 0 && bar.High >= p.tp:
+			b.close(math.Max(p.tp, bar.Open), at, 1, "tp")
+		}
+		return
+	}
+	switch {
+	case p.sl > 0 && bar.High >= p.sl:
+		b.close(math.Max(p.sl, bar.Open), at, 1, "sl")
+	case p.tp > 0 && bar.Low <= p.tp:
+		b.close(math.Min(p.tp, bar.Open), at, 1, "tp")
+	}
+}
+
+// fillPrice 加上滑点后的成交价，开多/平空买入价格上浮，开空/平多卖出价格下调
+func (b *broker) fillPrice(side model.OrderPosSide, price float64, opening bool) float64 {
+	buy := (side == model.OrderPosSideLong) == opening
+	if buy {
+		return price * (1 + b.slippage)
+	}
+	return price * (1 - b.slippage)
+}
+
+// applyPct 按方向计算相对价格，pct 为百分比，正数为有利方向
+func applyPct(side model.OrderPosSide, price, pct float64) float64 {
+	if pct == 0 {
+		return 0
+	}
+	return price * (1 + dirSign(side)*pct/100)
+}
+
+func dirSign(side model.OrderPosSide) float64 {
+	if side == model.OrderPosSideShort {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,264 @@
+package backtest
+
+import (
+	model2 "edgeflow/internal/model"
+	"edgeflow/pkg/exchange"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nntaoli-project/goex/v2/model"
+)
+
+// 回测使用的周期，15m 为驱动周期，其余周期用于趋势评分
+var periods = []model.KlinePeriod{model.Kline_15min, model.Kline_30min, model.Kline_1h, model.Kline_4h}
+
+// 每次提供给趋势/信号计算的K线数量，与线上 KlineManager 的窗口保持一致
+const windowSize = 300
+
+// Periods 回测需要的全部周期
+func Periods() []model.KlinePeriod {
+	return append([]model.KlinePeriod(nil), periods...)
+}
+
+func periodDuration(period model.KlinePeriod) time.Duration {
+	switch period {
+	case model.Kline_15min:
+		return 15 * time.Minute
+	case model.Kline_30min:
+		return 30 * time.Minute
+	case model.Kline_1h, model.Kline_60min:
+		return time.Hour
+	case model.Kline_4h:
+		return 4 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// CSVPath 与 trend.genCSV 的命名保持一致：logs/BTCUSDT_15min.csv
+func CSVPath(dir, symbol string, period model.KlinePeriod) string {
+	sy := strings.ReplaceAll(symbol, "/", "")
+	return filepath.Join(dir, fmt.Sprintf("%v_%v.csv", sy, period))
+}
+
+// LoadCSV 读取 trend.genCSV 格式的K线文件
+// 表头: timestamp(毫秒),datetime,open,high,low,close,volume，返回顺序为 从旧到新
+func LoadCSV(path string) ([]model2.Kline, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadCSV(file)
+}
+
+func ReadCSV(r io.Reader) ([]model2.Kline, error) {
+	reader := csv.NewReader(r)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []model2.Kline
+	for i, record := range records {
+		if i == 0 && record[0] == "timestamp" {
+			continue
+		}
+		if len(record) < 7 {
+			return nil, fmt.Errorf("第%d行字段不足: %v", i+1, record)
+		}
+		ts, err := strconv.ParseInt(record[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行时间戳错误: %w", i+1, err)
+		}
+		values := make([]float64, 5)
+		for j := range values {
+			values[j], err = strconv.ParseFloat(record[j+2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("第%d行数值错误: %w", i+1, err)
+			}
+		}
+		lines = append(lines, model2.Kline{
+			Timestamp: time.UnixMilli(ts),
+			Open:      values[0],
+			High:      values[1],
+			Low:       values[2],
+			Close:     values[3],
+			Vol:       values[4],
+		})
+	}
+	sortKlines(lines)
+	return lines, nil
+}
+
+// SaveCSV 按 trend.genCSV 的格式写入K线，便于离线复现
+func SaveCSV(path string, lines []model2.Kline) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"timestamp", "datetime", "open", "high", "low", "close", "volume"})
+	for _, k := range lines {
+		writer.Write([]string{
+			strconv.FormatInt(k.Timestamp.UnixMilli(), 10),
+			k.Timestamp.Format("2006-01-02 15:04:05"),
+			strconv.FormatFloat(k.Open, 'f', -1, 64),
+			strconv.FormatFloat(k.High, 'f', -1, 64),
+			strconv.FormatFloat(k.Low, 'f', -1, 64),
+			strconv.FormatFloat(k.Close, 'f', -1, 64),
+			strconv.FormatFloat(k.Vol, 'f', -1, 64),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// LoadDir 从目录中读取某币种各周期的K线
+// 15m 文件必须存在，其余周期缺失时由 15m 聚合得到
+func LoadDir(dir, symbol string) (map[model.KlinePeriod][]model2.Kline, error) {
+	data := make(map[model.KlinePeriod][]model2.Kline, len(periods))
+	for _, period := range periods {
+		lines, err := LoadCSV(CSVPath(dir, symbol, period))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && period != model.Kline_15min {
+				continue
+			}
+			return nil, err
+		}
+		data[period] = lines
+	}
+	for _, period := range periods {
+		if _, ok := data[period]; !ok {
+			data[period] = Resample(data[model.Kline_15min], periodDuration(model.Kline_15min), periodDuration(period))
+		}
+	}
+	return data, nil
+}
+
+// Resample 把 step 周期的K线聚合为 dur 周期，缺少K线的桶会被丢弃
+// 输入需按 从旧到新 排列
+func Resample(lines []model2.Kline, step, dur time.Duration) []model2.Kline {
+	if step <= 0 || dur < step || dur%step != 0 {
+		return nil
+	}
+	expected := int(dur / step)
+
+	var out []model2.Kline
+	var cur model2.Kline
+	count := 0
+	flush := func() {
+		if count == expected {
+			out = append(out, cur)
+		}
+	}
+	for _, k := range lines {
+		bucket := k.Timestamp.Truncate(dur)
+		if count > 0 && !bucket.Equal(cur.Timestamp) {
+			flush()
+			count = 0
+		}
+		if count == 0 {
+			cur = model2.Kline{Timestamp: bucket, Open: k.Open, High: k.High, Low: k.Low}
+		}
+		if k.High > cur.High {
+			cur.High = k.High
+		}
+		if k.Low < cur.Low {
+			cur.Low = k.Low
+		}
+		cur.Close = k.Close
+		cur.Vol += k.Vol
+		cur.VolCcy += k.VolCcy
+		count++
+	}
+	flush()
+	return out
+}
+
+// Fetch 从交易所向前分页拉取 since 之后的已收盘永续合约K线，返回顺序为 从旧到新
+// 交易所能提供的历史长度有限，实际返回的起点可能晚于 since
+func Fetch(ex exchange.Exchange, symbol string, period model.KlinePeriod, since time.Time) ([]model2.Kline, error) {
+	var all []model2.Kline
+	var end int64
+	for {
+		lines, err := ex.GetKlineRecords(symbol, period, windowSize, 0, end, model2.OrderTradeSwap, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) == 0 {
+			break
+		}
+		sortKlines(lines)
+		all = append(lines, all...)
+		oldest := lines[0].Timestamp
+		if !oldest.After(since) {
+			break
+		}
+		end = oldest.UnixMilli()
+		time.Sleep(200 * time.Millisecond) // 避免触发限频
+	}
+
+	// 裁掉 since 之前的部分
+	start := sort.Search(len(all), func(i int) bool {
+		return !all[i].Timestamp.Before(since)
+	})
+	return all[start:], nil
+}
+
+func sortKlines(lines []model2.Kline) {
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].Timestamp.Before(lines[j].Timestamp)
+	})
+}
+
+// replayFeed 按模拟时钟回放K线，实现 trend.KlineSource
+// 只返回在 now 之前已经收盘的K线，避免未来函数
+type replayFeed struct {
+	symbol string
+	data   map[model.KlinePeriod][]model2.Kline
+	now    time.Time
+}
+
+func newReplayFeed(symbol string, data map[model.KlinePeriod][]model2.Kline) *replayFeed {
+	return &replayFeed{symbol: symbol, data: data}
+}
+
+func (f *replayFeed) setNow(now time.Time) {
+	f.now = now
+}
+
+func (f *replayFeed) Get(symbol string, period model.KlinePeriod) ([]model2.Kline, bool) {
+	if symbol != f.symbol {
+		return nil, false
+	}
+	lines := f.data[period]
+	dur := periodDuration(period)
+	// 第一根收盘时间晚于 now 的位置
+	n := sort.Search(len(lines), func(i int) bool {
+		return lines[i].Timestamp.Add(dur).After(f.now)
+	})
+	if n == 0 {
+		return nil, false
+	}
+	start := n - windowSize
+	if start < 0 {
+		start = 0
+	}
+	out := make([]model2.Kline, n-start)
+	copy(out, lines[start:n])
+	return out, true
+}
@@ -0,0 +1,255 @@
+package backtest
+
+import (
+	model2 "edgeflow/internal/model"
+	"edgeflow/internal/signal"
+	"edgeflow/internal/trend"
+	"edgeflow/pkg/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nntaoli-project/goex/v2/model"
+)
+
+// ScoreForPeriod 至少需要200根K线，所有周期满足后才开始交易
+const warmupBars = 200
+
+// Config 回测参数，默认值与 PositionService.ApplyAction 保持一致
+type Config struct {
+	Symbol         string
+	InitialCapital float64
+	Leverage       int
+	FeeRate        float64 // 单边手续费率
+	Slippage       float64 // 成交滑点比例
+
+	OpenMarginPct float64 // 开仓保证金占权益比例
+	OpenTPPct     float64 // 开仓止盈百分比
+	OpenSLPct     float64 // 开仓止损百分比
+	AddMarginPct  float64
+	AddTPPct      float64
+	AddSLPct      float64
+	ReducePct     float64 // 减仓比例
+
+	// 决策规则文件，为空时使用内置逻辑
+	RulesFile string
+
+	// 只在该时间段内交易，之前的数据只用于预热，零值表示不限制
+	Start time.Time
+	End   time.Time
+}
+
+func DefaultConfig(symbol string) Config {
+	return Config{
+		Symbol:         utils.FormatSymbol(symbol),
+		InitialCapital: 10000,
+		Leverage:       20,
+		FeeRate:        0.0005,
+		Slippage:       0.0002,
+		OpenMarginPct:  0.21 * 0.6,
+		OpenTPPct:      1.3,
+		OpenSLPct:      1.5,
+		AddMarginPct:   0.18,
+		AddTPPct:       1.1,
+		AddSLPct:       1.3,
+		ReducePct:      0.5 * 0.5,
+	}
+}
+
+// Result 回测结果
+type Result struct {
+	Config    Config
+	Trades    []Trade
+	Equity    []EquityPoint
+	Decisions map[string]int // 各类决策出现的次数
+	Stats     Stats
+}
+
+// Engine 在模拟时钟上依次回放 15m K线：
+// 每根30m收盘更新趋势，每根15m收盘生成信号并交给 DecisionEngine 决策，
+// 决策在下一根K线开盘价成交
+type Engine struct {
+	cfg    Config
+	data   map[model.KlinePeriod][]model2.Kline
+	feed   *replayFeed
+	trend  *trend.Manager
+	sg     *trend.SignalGenerator
+	broker *broker
+	rules  *signal.RuleEngine
+}
+
+func NewEngine(cfg Config, data map[model.KlinePeriod][]model2.Kline) (*Engine, error) {
+	cfg.Symbol = utils.FormatSymbol(cfg.Symbol)
+	if cfg.Symbol == "" {
+		return nil, errors.New("symbol 不能为空")
+	}
+	if cfg.InitialCapital <= 0 || cfg.Leverage <= 0 {
+		return nil, errors.New("初始资金和杠杆必须大于0")
+	}
+	for _, period := range periods {
+		if len(data[period]) == 0 {
+			return nil, fmt.Errorf("缺少 %s K线数据", period)
+		}
+	}
+
+	var rules *signal.RuleEngine
+	if cfg.RulesFile != "" {
+		var err error
+		if rules, err = signal.NewRuleEngine(cfg.RulesFile); err != nil {
+			return nil, err
+		}
+	}
+
+	feed := newReplayFeed(cfg.Symbol, data)
+	return &Engine{
+		cfg:    cfg,
+		data:   data,
+		feed:   feed,
+		trend:  trend.NewManager(nil, []string{cfg.Symbol}, feed),
+		sg:     trend.NewSignalGenerator(),
+		broker: newBroker(cfg.Symbol, cfg.InitialCapital, cfg.Leverage, cfg.FeeRate, cfg.Slippage),
+		rules:  rules,
+	}, nil
+}
+
+type pendingAction struct {
+	action signal.Action
+	sig    trend.Signal
+}
+
+func (e *Engine) Run() (*Result, error) {
+	base := e.data[model.Kline_15min]
+	step := periodDuration(model.Kline_15min)
+	result := &Result{
+		Config:    e.cfg,
+		Decisions: make(map[string]int),
+	}
+
+	var pending *pendingAction
+	var lastSig *trend.Signal
+	var last model2.Kline
+
+	for _, bar := range base {
+		if !e.cfg.End.IsZero() && bar.Timestamp.After(e.cfg.End) {
+			break
+		}
+		last = bar
+
+		// 1. 上一根K线的决策在本根开盘成交，然后检查本根K线内的止盈止损
+		if pending != nil {
+			e.execute(*pending, bar.Open, bar.Timestamp)
+			pending = nil
+		}
+		e.broker.checkStops(bar)
+
+		// 2. 推进模拟时钟到本根K线收盘
+		closeAt := bar.Timestamp.Add(step)
+		e.feed.setNow(closeAt)
+		if !e.ready() {
+			continue
+		}
+		if closeAt.Truncate(periodDuration(model.Kline_30min)).Equal(closeAt) {
+			if err := e.updateTrend(); err != nil {
+				return nil, err
+			}
+		}
+
+		trading := e.cfg.Start.IsZero() || !bar.Timestamp.Before(e.cfg.Start)
+		if trading {
+			result.Equity = append(result.Equity, EquityPoint{Time: closeAt, Equity: e.broker.equity(bar.Close)})
+		}
+
+		state := e.trend.GetState(e.cfg.Symbol)
+		if state == nil || !trading {
+			continue
+		}
+
+		// 3. 生成信号并决策
+		klines, _ := e.feed.Get(e.cfg.Symbol, model.Kline_15min)
+		sig, err := e.sg.Generate(klines, e.cfg.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		ctx := signal.Context{
+			Trend:   *state,
+			Sig:     *sig,
+			Pos:     e.broker.positionInfo(bar.Close),
+			Line:    bar,
+			LastSig: lastSig,
+		}
+		action := signal.NewDecisionEngine(ctx).WithRules(e.rules).Run()
+		result.Decisions[action.String()]++
+		if action == signal.ActIgnore {
+			continue
+		}
+		pending = &pendingAction{action: action, sig: *sig}
+		if action == signal.ActOpen || action == signal.ActAdd {
+			lastSig = sig
+		}
+	}
+
+	// 回测结束，按最后收盘价平掉剩余仓位
+	if e.broker.pos != nil {
+		end := last.Timestamp.Add(step)
+		e.broker.close(last.Close, end, 1, "end")
+		if n := len(result.Equity); n > 0 {
+			result.Equity[n-1] = EquityPoint{Time: end, Equity: e.broker.cash}
+		}
+	}
+
+	result.Trades = e.broker.trades
+	result.Stats = computeStats(e.cfg.InitialCapital, result.Trades, result.Equity)
+	return result, nil
+}
+
+// ready 各周期都有足够的已收盘K线才开始计算
+func (e *Engine) ready() bool {
+	for _, period := range periods {
+		lines, ok := e.feed.Get(e.cfg.Symbol, period)
+		if !ok || len(lines) < warmupBars {
+			return false
+		}
+	}
+	return true
+}
+
+// updateTrend 与 trend.Manager.computeTrend 相同：生成趋势后驱动状态机
+func (e *Engine) updateTrend() error {
+	state, slope, err := e.trend.GenerateTrend(e.cfg.Symbol)
+	if err != nil {
+		return err
+	}
+	if machine := e.trend.GetStateMachine(e.cfg.Symbol); machine != nil {
+		machine.Update(state.Scores.FinalScore, state.Scores.TrendScore, slope)
+	}
+	return nil
+}
+
+func (e *Engine) execute(p pendingAction, price float64, at time.Time) {
+	cfg := e.cfg
+	b := e.broker
+	switch p.action {
+	case signal.ActOpen:
+		if b.pos != nil || (p.sig.Side != string(model2.Buy) && p.sig.Side != string(model2.Sell)) {
+			return
+		}
+		b.open(sideToPos(p.sig.Side), price, at, cfg.OpenMarginPct, cfg.OpenTPPct, cfg.OpenSLPct)
+	case signal.ActAdd:
+		// 下单前仓位可能已经止盈止损
+		if b.pos == nil {
+			return
+		}
+		b.open(b.pos.side, price, at, cfg.AddMarginPct, cfg.AddTPPct, cfg.AddSLPct)
+	case signal.ActReduce:
+		b.close(price, at, cfg.ReducePct, "reduce")
+	case signal.ActClose:
+		b.close(price, at, 1, "close")
+	}
+}
+
+func sideToPos(side string) model2.OrderPosSide {
+	if side == string(model2.Sell) {
+		return model2.OrderPosSideShort
+	}
+	return model2.OrderPosSideLong
+}
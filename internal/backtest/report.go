@@ -0,0 +1,139 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats 回测汇总指标
+type Stats struct {
+	InitialCapital float64
+	FinalEquity    float64
+	TotalReturn    float64 // 总收益率
+	TotalTrades    int
+	Wins           int
+	Losses         int
+	WinRate        float64
+	GrossProfit    float64
+	GrossLoss      float64 // 亏损合计，正数
+	ProfitFactor   float64 // 盈利合计/亏损合计，没有亏损时为 +Inf
+	TotalFee       float64
+	MaxDrawdown    float64 // 最大回撤比例
+	MaxDrawdownAt  time.Time
+}
+
+func computeStats(capital float64, trades []Trade, equity []EquityPoint) Stats {
+	st := Stats{
+		InitialCapital: capital,
+		FinalEquity:    capital,
+		TotalTrades:    len(trades),
+	}
+	for _, t := range trades {
+		st.TotalFee += t.Fee
+		if t.PnL > 0 {
+			st.Wins++
+			st.GrossProfit += t.PnL
+		} else {
+			st.Losses++
+			st.GrossLoss += -t.PnL
+		}
+	}
+	if st.TotalTrades > 0 {
+		st.WinRate = float64(st.Wins) / float64(st.TotalTrades)
+	}
+	switch {
+	case st.GrossLoss > 0:
+		st.ProfitFactor = st.GrossProfit / st.GrossLoss
+	case st.GrossProfit > 0:
+		st.ProfitFactor = math.Inf(1)
+	}
+
+	peak := capital
+	for _, p := range equity {
+		if p.Equity > peak {
+			peak = p.Equity
+		}
+		if peak <= 0 {
+			continue
+		}
+		if dd := (peak - p.Equity) / peak; dd > st.MaxDrawdown {
+			st.MaxDrawdown = dd
+			st.MaxDrawdownAt = p.Time
+		}
+	}
+	if n := len(equity); n > 0 {
+		st.FinalEquity = equity[n-1].Equity
+	}
+	st.TotalReturn = (st.FinalEquity - capital) / capital
+	return st
+}
+
+// Summary 可读的汇总文本
+func (r *Result) Summary() string {
+	st := r.Stats
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Backtest %s] 初始资金: %.2f 最终权益: %.2f 收益率: %.2f%%\n",
+		r.Config.Symbol, st.InitialCapital, st.FinalEquity, st.TotalReturn*100)
+	fmt.Fprintf(&b, "交易次数: %d 盈利: %d 亏损: %d 胜率: %.2f%%\n",
+		st.TotalTrades, st.Wins, st.Losses, st.WinRate*100)
+	fmt.Fprintf(&b, "盈利合计: %.2f 亏损合计: %.2f 盈亏比(Profit Factor): %.2f 手续费: %.2f\n",
+		st.GrossProfit, st.GrossLoss, st.ProfitFactor, st.TotalFee)
+	fmt.Fprintf(&b, "最大回撤: %.2f%% (%s)\n", st.MaxDrawdown*100, st.MaxDrawdownAt.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "决策分布: %v", r.Decisions)
+	return b.String()
+}
+
+// WriteFiles 把成交记录和权益曲线写入目录：trades.csv、equity.csv
+func (r *Result) WriteFiles(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	trades := [][]string{{"symbol", "side", "entry_time", "exit_time", "entry_price", "exit_price", "qty", "fee", "pnl", "reason"}}
+	for _, t := range r.Trades {
+		trades = append(trades, []string{
+			t.Symbol,
+			string(t.Side),
+			t.EntryTime.Format(time.RFC3339),
+			t.ExitTime.Format(time.RFC3339),
+			formatFloat(t.EntryPrice),
+			formatFloat(t.ExitPrice),
+			formatFloat(t.Qty),
+			formatFloat(t.Fee),
+			formatFloat(t.PnL),
+			t.Reason,
+		})
+	}
+	if err := writeCSV(filepath.Join(dir, "trades.csv"), trades); err != nil {
+		return err
+	}
+
+	equity := [][]string{{"time", "equity"}}
+	for _, p := range r.Equity {
+		equity = append(equity, []string{p.Time.Format(time.RFC3339), formatFloat(p.Equity)})
+	}
+	return writeCSV(filepath.Join(dir, "equity.csv"), equity)
+}
+
+func writeCSV(path string, records [][]string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(records); err != nil {
+		return err
+	}
+	return nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
@@ -0,0 +1,14 @@
+package dao
+
+import (
+	"context"
+	"edgeflow/internal/model/entity"
+	"time"
+)
+
+type DerivativeMetricDao interface {
+	// 批量保存一轮采集的结果
+	SaveMetrics(ctx context.Context, metrics []entity.DerivativeMetric) error
+	// 查询 [start, end] 内的数据，按时间从旧到新，最多 limit 条
+	GetMetrics(ctx context.Context, instID string, start, end time.Time, limit int) ([]entity.DerivativeMetric, error)
+}
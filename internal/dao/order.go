@@ -3,7 +3,10 @@ package dao
 import (
 	"context"
 	"edgeflow/internal/model"
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderDao struct {
@@ -19,6 +22,80 @@ func (d *OrderDao) OrderCreateNew(ctx context.Context, record *model.OrderRecord
 	return d.db.WithContext(ctx).Create(record).Error
 }
 
+// 更新限价单的入场结果
+func (d *OrderDao) OrderRecordUpdateEntry(ctx context.Context, orderId string, entry map[string]interface{}) error {
+	return d.db.WithContext(ctx).Model(&model.OrderRecord{}).
+		Where("order_id = ?", orderId).
+		Updates(entry).Error
+}
+
+// 保存仓位对账差异
+func (d *OrderDao) PositionDiscrepancyCreate(ctx context.Context, record *model.PositionDiscrepancy) error {
+	return d.db.WithContext(ctx).Create(record).Error
+}
+
+// 保存订单跟踪状态，按 order_id 覆盖
+func (d *OrderDao) OrderTrackingSave(ctx context.Context, record *model.OrderTracking) error {
+	return d.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "order_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"status", "filled_qty", "avg_price", "fee", "fee_ccy", "ct_val", "realized_pnl", "updated_at", "finished_at",
+			}),
+		}).
+		Create(record).Error
+}
+
+// 插入订单状态变化记录
+func (d *OrderDao) OrderStatusHistoryCreate(ctx context.Context, record *model.OrderStatusHistory) error {
+	return d.db.WithContext(ctx).Create(record).Error
+}
+
+// 未结束的订单，服务重启后继续跟踪
+func (d *OrderDao) OrderTrackingGetActive(ctx context.Context) (list []model.OrderTracking, err error) {
+	err = d.db.WithContext(ctx).Model(&model.OrderTracking{}).
+		Where("status NOT IN ?", []string{model.OrderStatusFilled, model.OrderStatusCanceled}).
+		Order("created_at ASC").
+		Find(&list).Error
+	return
+}
+
+// 订单的状态变化记录
+func (d *OrderDao) OrderStatusHistoryGet(ctx context.Context, orderId string) (list []model.OrderStatusHistory, err error) {
+	err = d.db.WithContext(ctx).Model(&model.OrderStatusHistory{}).
+		Where("order_id = ?", orderId).
+		Order("id ASC").
+		Find(&list).Error
+	return
+}
+
+// 按策略汇总 since 之后创建的订单的已实现盈亏
+func (d *OrderDao) StrategyPnlGet(ctx context.Context, since time.Time) (list []model.StrategyPnl, err error) {
+	err = d.db.WithContext(ctx).Model(&model.OrderTracking{}).
+		Select("strategy, COUNT(*) AS orders, SUM(fee) AS fee, SUM(realized_pnl) AS realized_pnl").
+		Where("created_at >= ?", since).
+		Where("filled_qty > 0").
+		Group("strategy").
+		Order("realized_pnl DESC").
+		Find(&list).Error
+	return
+}
+
+// 策略 since 之后已完成的平仓单的胜负统计
+func (d *OrderDao) StrategyWinStats(ctx context.Context, strategy string, since time.Time) (stats model.WinStats, err error) {
+	err = d.db.WithContext(ctx).Model(&model.OrderTracking{}).
+		Select("COUNT(*) AS trades, "+
+			"COALESCE(SUM(CASE WHEN realized_pnl > 0 THEN 1 ELSE 0 END), 0) AS wins, "+
+			"COALESCE(AVG(CASE WHEN realized_pnl > 0 THEN realized_pnl END), 0) AS avg_win, "+
+			"COALESCE(AVG(CASE WHEN realized_pnl <= 0 THEN -realized_pnl END), 0) AS avg_loss").
+		Where("strategy = ?", strategy).
+		Where("role = ?", model.OrderRoleClose).
+		Where("status = ?", model.OrderStatusFilled).
+		Where("created_at >= ?", since).
+		Scan(&stats).Error
+	return
+}
+
 // 判断是否已存在
 func (d *OrderDao) ExistsOrderHash(ctx context.Context, hash string) (bool, error) {
 	var count int64
@@ -0,0 +1,55 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 订单提交中的占用时间，进程在提交过程中退出时到期后允许重试
+const orderDedupePendingTTL = time.Minute
+
+// OrderDedupeRepository 幂等下单的去重记录，key 为 order:clordid:{clOrdId}，value 为订单ID，提交中为空
+type OrderDedupeRepository struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+func NewOrderDedupeRepository(rdb *redis.Client, ttl time.Duration) *OrderDedupeRepository {
+	return &OrderDedupeRepository{rdb: rdb, ttl: ttl}
+}
+
+func (r *OrderDedupeRepository) getKey(clOrdId string) string {
+	return "order:clordid:" + clOrdId
+}
+
+// Reserve SETNX 占用，已经存在时返回记录的订单ID
+func (r *OrderDedupeRepository) Reserve(ctx context.Context, clOrdId string) (bool, string, error) {
+	key := r.getKey(clOrdId)
+	ok, err := r.rdb.SetNX(ctx, key, "", orderDedupePendingTTL).Result()
+	if err != nil {
+		return false, "", err
+	}
+	if ok {
+		return true, "", nil
+	}
+	orderId, err := r.rdb.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		// 刚好过期，按提交中处理，由调用方重试
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	return false, orderId, nil
+}
+
+func (r *OrderDedupeRepository) Commit(ctx context.Context, clOrdId, orderId string) error {
+	return r.rdb.Set(ctx, r.getKey(clOrdId), orderId, r.ttl).Err()
+}
+
+func (r *OrderDedupeRepository) Release(ctx context.Context, clOrdId string) error {
+	return r.rdb.Del(ctx, r.getKey(clOrdId)).Err()
+}
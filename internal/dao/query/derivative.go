@@ -0,0 +1,43 @@
+package query
+
+import (
+	"context"
+	"edgeflow/internal/dao"
+	"edgeflow/internal/model/entity"
+	"gorm.io/gorm"
+	"time"
+)
+
+type derivativeMetricDao struct {
+	db *gorm.DB
+}
+
+func NewDerivativeMetricDao(db *gorm.DB) dao.DerivativeMetricDao {
+	return &derivativeMetricDao{
+		db: db,
+	}
+}
+
+func (r *derivativeMetricDao) SaveMetrics(ctx context.Context, metrics []entity.DerivativeMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(metrics, 100).Error
+}
+
+func (r *derivativeMetricDao) GetMetrics(ctx context.Context, instID string, start, end time.Time, limit int) ([]entity.DerivativeMetric, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 300
+	}
+	// 取最新的 limit 条，再按时间正序返回
+	var list []entity.DerivativeMetric
+	err := r.db.WithContext(ctx).
+		Where("inst_id = ? AND ts BETWEEN ? AND ?", instID, start, end).
+		Order("ts DESC").
+		Limit(limit).
+		Find(&list).Error
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return list, err
+}
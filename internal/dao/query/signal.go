@@ -174,6 +174,53 @@ func (dao *signalDao) SaveSignalOutcome(ctx context.Context, outcome *entity.Sig
 	return nil
 }
 
+// GetSignalsWithoutOutcome 查找时间范围内还没有写入 signal_outcomes 的信号，供结果评估器使用。
+func (dao *signalDao) GetSignalsWithoutOutcome(ctx context.Context, start, end time.Time, limit int) ([]entity.Signal, error) {
+	var signals []entity.Signal
+	result := dao.db.WithContext(ctx).
+		Table("signals AS s").
+		Select("s.*").
+		Joins("LEFT JOIN signal_outcomes o ON o.signal_id = s.id").
+		Where("o.id IS NULL").
+		Where("s.timestamp >= ? AND s.timestamp <= ?", start, end).
+		Order("s.timestamp ASC").
+		Limit(limit).
+		Find(&signals)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get signals without outcome: %w", result.Error)
+	}
+	return signals, nil
+}
+
+// SaveOutcomeAndExpireSignal 在一个事务内写入信号结果，并把仍为 ACTIVE 的信号改为 EXPIRED。
+// signal_id 唯一约束冲突时不更新任何数据，保证重复回填是幂等的。
+func (dao *signalDao) SaveOutcomeAndExpireSignal(ctx context.Context, outcome *entity.SignalOutcome) (bool, error) {
+	if outcome.ClosedAt.IsZero() {
+		outcome.ClosedAt = time.Now()
+	}
+
+	saved := false
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit("Signal").Clauses(clause.OnConflict{DoNothing: true}).Create(outcome)
+		if result.Error != nil {
+			return fmt.Errorf("failed to save signal outcome for signal ID %d: %w", outcome.SignalID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			// 已经评估过
+			return nil
+		}
+		saved = true
+
+		if err := tx.Model(&entity.Signal{}).
+			Where("id = ? AND status = ?", outcome.SignalID, "ACTIVE").
+			Update("status", "EXPIRED").Error; err != nil {
+			return fmt.Errorf("failed to expire signal %d: %w", outcome.SignalID, err)
+		}
+		return nil
+	})
+	return saved, err
+}
+
 // 一个辅助结构，用于扫描数据库中的聚合结果。
 // WinRateResult 是用于接收聚合查询结果的辅助结构。
 type winRateResult struct {
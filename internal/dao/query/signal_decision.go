@@ -0,0 +1,71 @@
+package query
+
+import (
+	"context"
+	"edgeflow/internal/dao"
+	"edgeflow/internal/model"
+	"edgeflow/internal/model/entity"
+	"gorm.io/gorm"
+	"time"
+)
+
+type signalDecisionDao struct {
+	db *gorm.DB
+}
+
+func NewSignalDecisionDao(db *gorm.DB) dao.SignalDecisionDao {
+	return &signalDecisionDao{
+		db: db,
+	}
+}
+
+func (r *signalDecisionDao) SaveDecisions(ctx context.Context, decisions []entity.SignalDecision) error {
+	if len(decisions) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(decisions, 100).Error
+}
+
+func (r *signalDecisionDao) GetDecisionList(ctx context.Context, req model.SignalDecisionListReq) (total int64, list []entity.SignalDecision, err error) {
+	limit := req.Limit
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	tx := r.db.WithContext(ctx).Model(&entity.SignalDecision{})
+	if req.Symbol != "" {
+		tx = tx.Where("symbol = ?", req.Symbol)
+	}
+	if req.Strategy != "" {
+		tx = tx.Where("strategy = ?", req.Strategy)
+	}
+	if req.Action != "" {
+		tx = tx.Where("action = ?", req.Action)
+	}
+	if req.SignalID > 0 {
+		tx = tx.Where("signal_id = ?", req.SignalID)
+	}
+
+	if err = tx.Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+	if total == 0 {
+		return 0, []entity.SignalDecision{}, nil
+	}
+
+	err = tx.Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(req.Offset).
+		Find(&list).Error
+	return total, list, err
+}
+
+func (r *signalDecisionDao) GetDecisionsBySignal(ctx context.Context, signalID uint64, symbol string, signalTime time.Time) ([]entity.SignalDecision, error) {
+	var list []entity.SignalDecision
+	err := r.db.WithContext(ctx).
+		// 没有关联 signal_id 的决策才按交易对和信号时间匹配，避免带上其他信号的决策
+		Where("signal_id = ? OR (signal_id IS NULL AND symbol = ? AND signal_time = ?)", signalID, symbol, signalTime).
+		Order("created_at ASC, id ASC").
+		Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,65 @@
+package query
+
+import (
+	"context"
+	"edgeflow/internal/dao"
+	"edgeflow/internal/model/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type userTradingDao struct {
+	db *gorm.DB
+}
+
+func NewUserTradingDao(db *gorm.DB) dao.UserTradingDao {
+	return &userTradingDao{
+		db: db,
+	}
+}
+
+func (d *userTradingDao) CredentialGet(ctx context.Context, userId int64, exchange string) (*entity.UserExchangeCredential, error) {
+	var cred entity.UserExchangeCredential
+	err := d.db.WithContext(ctx).
+		Where("user_id = ? AND exchange = ?", userId, exchange).
+		First(&cred).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+func (d *userTradingDao) CredentialSave(ctx context.Context, cred *entity.UserExchangeCredential) error {
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "exchange"}},
+		DoUpdates: clause.AssignmentColumns([]string{"api_key", "secret_key", "passphrase", "api_key_mask", "updated_at"}),
+	}).Create(cred).Error
+}
+
+func (d *userTradingDao) CredentialDelete(ctx context.Context, userId int64, exchange string) error {
+	return d.db.WithContext(ctx).
+		Where("user_id = ? AND exchange = ?", userId, exchange).
+		Delete(&entity.UserExchangeCredential{}).Error
+}
+
+func (d *userTradingDao) UserOrderSave(ctx context.Context, order *entity.UserOrder) error {
+	return d.db.WithContext(ctx).Create(order).Error
+}
+
+func (d *userTradingDao) UserOrderGetList(ctx context.Context, userId int64, limit, offset int) (total int64, list []entity.UserOrder, err error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	tx := d.db.WithContext(ctx).Model(&entity.UserOrder{}).Where("user_id = ?", userId)
+	if err = tx.Count(&total).Error; err != nil {
+		return 0, nil, err
+	}
+	if total == 0 {
+		return 0, []entity.UserOrder{}, nil
+	}
+	err = tx.Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&list).Error
+	return total, list, err
+}
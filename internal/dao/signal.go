@@ -23,6 +23,10 @@ type SignalDao interface {
 
 	// 保存一个信号的盈亏
 	SaveSignalOutcome(ctx context.Context, outcome *entity.SignalOutcome) error
+	// 查找时间范围内还没有盈亏结果的信号，按信号时间正序
+	GetSignalsWithoutOutcome(ctx context.Context, start, end time.Time, limit int) ([]entity.Signal, error)
+	// 保存信号的盈亏并将 ACTIVE 信号标记为 EXPIRED，结果已存在时不做任何修改并返回 false
+	SaveOutcomeAndExpireSignal(ctx context.Context, outcome *entity.SignalOutcome) (bool, error)
 	// GetSymbolWinRate 计算给定交易对的历史策略胜率 (盈亏率百分比)。
 	GetSymbolWinRate(ctx context.Context, symbol string) (float64, error)
 	// 计算给定交易对的总收益率百分比（FinalPnlPct 的总和）。
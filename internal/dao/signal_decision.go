@@ -0,0 +1,17 @@
+package dao
+
+import (
+	"context"
+	"edgeflow/internal/model"
+	"edgeflow/internal/model/entity"
+	"time"
+)
+
+type SignalDecisionDao interface {
+	// 批量保存决策记录
+	SaveDecisions(ctx context.Context, decisions []entity.SignalDecision) error
+	// 按条件分页查询决策记录，按决策时间倒序
+	GetDecisionList(ctx context.Context, req model.SignalDecisionListReq) (total int64, list []entity.SignalDecision, err error)
+	// 查找某个信号触发的决策：signal_id 关联的记录，或者没有 signal_id 但交易对、信号时间相同的记录
+	GetDecisionsBySignal(ctx context.Context, signalID uint64, symbol string, signalTime time.Time) ([]entity.SignalDecision, error)
+}
@@ -0,0 +1,18 @@
+package dao
+
+import (
+	"context"
+	"edgeflow/internal/model/entity"
+)
+
+type UserTradingDao interface {
+	// 查询用户在某个交易所的 API Key，不存在时返回 gorm.ErrRecordNotFound
+	CredentialGet(ctx context.Context, userId int64, exchange string) (*entity.UserExchangeCredential, error)
+	// 保存 API Key，同一个用户同一个交易所只保留一条
+	CredentialSave(ctx context.Context, cred *entity.UserExchangeCredential) error
+	CredentialDelete(ctx context.Context, userId int64, exchange string) error
+
+	UserOrderSave(ctx context.Context, order *entity.UserOrder) error
+	// 按下单时间倒序分页查询
+	UserOrderGetList(ctx context.Context, userId int64, limit, offset int) (total int64, list []entity.UserOrder, err error)
+}
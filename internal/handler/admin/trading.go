@@ -0,0 +1,203 @@
+package admin
+
+import (
+	"edgeflow/internal/dao"
+	"edgeflow/internal/model"
+	"edgeflow/internal/position"
+	"edgeflow/internal/signal"
+	"edgeflow/pkg/errors"
+	"edgeflow/pkg/errors/ecode"
+	"edgeflow/pkg/exchange"
+	"edgeflow/pkg/response"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TradingHandler 交易运维接口，只对管理员开放
+type TradingHandler struct {
+	limiter *signal.TradeLimiter
+	orders  *dao.OrderDao
+	risk    *position.PortfolioRisk // 为空时组合风控接口返回零值
+	ks      *signal.KillSwitch
+	ps      *position.PositionService     // 为空时不支持清仓
+	ex      *exchange.RateLimitedExchange // 为空时接口统计为空
+}
+
+func NewTradingHandler(limiter *signal.TradeLimiter, orders *dao.OrderDao, risk *position.PortfolioRisk, ks *signal.KillSwitch, ps *position.PositionService) *TradingHandler {
+	return &TradingHandler{limiter: limiter, orders: orders, risk: risk, ks: ks, ps: ps}
+}
+
+// WithExchangeStats 查看交易所接口的限频和重试统计
+func (h *TradingHandler) WithExchangeStats(ex *exchange.RateLimitedExchange) *TradingHandler {
+	h.ex = ex
+	return h
+}
+
+type tradeLimitStatsRes struct {
+	Config signal.TradeLimiterConfig      `json:"config"`
+	Stats  map[string]*signal.SymbolStats `json:"stats"`
+}
+
+// 查看交易频次统计
+func (h *TradingHandler) TradeLimitStatsGet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.TradeLimitStatsReq
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			response.JSON(ctx, errors.WithCode(ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+
+		res := tradeLimitStatsRes{Config: h.limiter.Config()}
+		if req.Symbol == "" {
+			res.Stats = h.limiter.GetAllStats()
+		} else {
+			res.Stats = make(map[string]*signal.SymbolStats)
+			if stats := h.limiter.GetSymbolStats(req.Symbol); stats != nil {
+				res.Stats[req.Symbol] = stats
+			}
+		}
+		response.JSON(ctx, nil, res)
+	}
+}
+
+// 重置交易对的频次统计，连续计数、冷却时间和今日次数都会清零
+func (h *TradingHandler) TradeLimitReset() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.TradeLimitResetReq
+		if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+			response.JSON(ctx, errors.WithCode(ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+
+		h.limiter.ResetSymbol(req.Symbol)
+		response.JSON(ctx, nil, h.limiter.GetSymbolStats(req.Symbol))
+	}
+}
+
+// 按策略统计已实现盈亏
+func (h *TradingHandler) StrategyPnlGet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.StrategyPnlReq
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			response.JSON(ctx, errors.WithCode(ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+		if req.Days <= 0 {
+			req.Days = 30
+		}
+
+		since := time.Now().AddDate(0, 0, -req.Days)
+		list, err := h.orders.StrategyPnlGet(ctx, since)
+		if err != nil {
+			response.JSON(ctx, errors.Wrap(err, ecode.Unknown, "接口调用失败"), nil)
+			return
+		}
+		response.JSON(ctx, nil, list)
+	}
+}
+
+// 查看订单的状态变化记录
+func (h *TradingHandler) OrderHistoryGet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.OrderHistoryReq
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			response.JSON(ctx, errors.WithCode(ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+
+		list, err := h.orders.OrderStatusHistoryGet(ctx, req.OrderId)
+		if err != nil {
+			response.JSON(ctx, errors.Wrap(err, ecode.Unknown, "接口调用失败"), nil)
+			return
+		}
+		response.JSON(ctx, nil, list)
+	}
+}
+
+// 查看组合风控状态：当日盈亏、敞口以及是否停止开仓
+func (h *TradingHandler) RiskGet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		response.JSON(ctx, nil, h.risk.Snapshot())
+	}
+}
+
+// 手动恢复开仓，触发风控的原因仍然存在时下一次刷新会再次停止
+func (h *TradingHandler) RiskResume() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		h.risk.Resume()
+		response.JSON(ctx, nil, h.risk.Snapshot())
+	}
+}
+
+// 查看交易所接口的调用、重试、限频和排队统计
+func (h *TradingHandler) ExchangeStatsGet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		response.JSON(ctx, nil, h.ex.Stats())
+	}
+}
+
+// 查看当前生效的暂停
+func (h *TradingHandler) KillSwitchGet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		response.JSON(ctx, nil, h.ks.Pauses())
+	}
+}
+
+// 暂停全部策略、单个策略或者单个交易对，暂停期间不处理信号也不开仓，平仓不受影响
+func (h *TradingHandler) KillSwitchPause() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.KillSwitchPauseReq
+		if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+			response.JSON(ctx, errors.WithCode(ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+
+		p, err := h.ks.Pause(ctx, signal.PauseScope(req.Scope), req.Target, req.Reason)
+		if err != nil {
+			response.JSON(ctx, errors.Wrap(err, ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+		response.JSON(ctx, nil, p)
+	}
+}
+
+// 解除暂停
+func (h *TradingHandler) KillSwitchResume() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.KillSwitchResumeReq
+		if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+			response.JSON(ctx, errors.WithCode(ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+
+		if err := h.ks.Resume(ctx, signal.PauseScope(req.Scope), req.Target); err != nil {
+			response.JSON(ctx, errors.Wrap(err, ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+		response.JSON(ctx, nil, h.ks.Pauses())
+	}
+}
+
+// 平掉全部仓位，返回每个交易对的结果
+func (h *TradingHandler) Flatten() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.FlattenReq
+		if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+			response.JSON(ctx, errors.WithCode(ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+		if h.ps == nil {
+			response.JSON(ctx, errors.WithCode(ecode.Unknown, "交易管线未启用"), nil)
+			return
+		}
+
+		if req.Pause {
+			if _, err := h.ks.Pause(ctx, signal.PauseAll, "", "flatten"); err != nil {
+				response.JSON(ctx, errors.Wrap(err, ecode.Unknown, "暂停交易失败"), nil)
+				return
+			}
+		}
+		response.JSON(ctx, nil, h.ps.FlattenAll(ctx, req.Symbols))
+	}
+}
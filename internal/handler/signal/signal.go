@@ -1,11 +1,11 @@
 package signal
 
 import (
+	"edgeflow/internal/consts"
 	"edgeflow/internal/model"
 	"edgeflow/internal/service"
 	"edgeflow/pkg/errors"
 	"edgeflow/pkg/errors/ecode"
-	"edgeflow/pkg/exchange"
 	"edgeflow/pkg/response"
 	"github.com/gin-gonic/gin"
 	"strconv"
@@ -13,13 +13,13 @@ import (
 
 type SignalHandler struct {
 	signalService *service.SignalProcessorService
-	ex            exchange.Exchange
+	userTrading   *service.UserTradingService
 }
 
-func NewSignalHandler(signalService *service.SignalProcessorService, ex exchange.Exchange) *SignalHandler {
+func NewSignalHandler(signalService *service.SignalProcessorService, userTrading *service.UserTradingService) *SignalHandler {
 	return &SignalHandler{
 		signalService: signalService,
-		ex:            ex,
+		userTrading:   userTrading,
 	}
 }
 
@@ -57,7 +57,7 @@ func (sh *SignalHandler) GetSignalDetailByID() gin.HandlerFunc {
 	}
 }
 
-// 执行信号
+// 使用当前用户绑定的 API Key 执行信号
 func (h *SignalHandler) ExecuteSignal() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var req model.SignalExecutionReq
@@ -72,11 +72,92 @@ func (h *SignalHandler) ExecuteSignal() gin.HandlerFunc {
 			return
 		}
 
-		err = h.signalService.ExecuteOrder(ctx, sid, h.ex)
+		userId := ctx.GetInt64(consts.UserID)
+		res, err := h.userTrading.ExecuteSignal(ctx, userId, sid, req.Leverage, req.QuantityPct)
 		if err != nil {
 			response.JSON(ctx, errors.Wrap(err, ecode.Unknown, "接口调用失败"), nil)
+		} else {
+			response.JSON(ctx, nil, res)
+		}
+	}
+}
+
+// 绑定交易所 API Key，重复绑定时覆盖
+func (h *SignalHandler) CredentialSave() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.UserCredentialSaveReq
+		if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+			response.JSON(ctx, errors.WithCode(ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+
+		userId := ctx.GetInt64(consts.UserID)
+		res, err := h.userTrading.CredentialSave(ctx, userId, req)
+		if err != nil {
+			response.JSON(ctx, errors.Wrap(err, ecode.Unknown, "接口调用失败"), nil)
+		} else {
+			response.JSON(ctx, nil, res)
+		}
+	}
+}
+
+// 查询绑定的 API Key，只返回脱敏后的内容
+func (h *SignalHandler) CredentialGet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userId := ctx.GetInt64(consts.UserID)
+		res, err := h.userTrading.CredentialGet(ctx, userId)
+		if err != nil {
+			response.JSON(ctx, errors.WithCode(ecode.NotFoundErr, err.Error()), nil)
+		} else {
+			response.JSON(ctx, nil, res)
+		}
+	}
+}
+
+func (h *SignalHandler) CredentialDelete() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userId := ctx.GetInt64(consts.UserID)
+		if err := h.userTrading.CredentialDelete(ctx, userId); err != nil {
+			response.JSON(ctx, errors.Wrap(err, ecode.Unknown, "接口调用失败"), nil)
 		} else {
 			response.JSON(ctx, nil, nil)
 		}
 	}
 }
+
+// 当前用户的信号下单记录
+func (h *SignalHandler) UserOrderGetList() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.UserOrderListReq
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			response.JSON(ctx, errors.WithCode(ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+
+		userId := ctx.GetInt64(consts.UserID)
+		res, err := h.userTrading.UserOrderGetList(ctx, userId, req)
+		if err != nil {
+			response.JSON(ctx, errors.Wrap(err, ecode.Unknown, "接口调用失败"), nil)
+		} else {
+			response.JSON(ctx, nil, res)
+		}
+	}
+}
+
+// 交易决策记录，支持按交易对、策略、动作和信号筛选
+func (sh *SignalHandler) SignalDecisionGetList() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.SignalDecisionListReq
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			response.JSON(ctx, errors.WithCode(ecode.ValidateErr, err.Error()), nil)
+			return
+		}
+
+		res, err := sh.signalService.DecisionGetList(ctx, req)
+		if err != nil {
+			response.JSON(ctx, errors.Wrap(err, ecode.Unknown, "接口调用失败"), nil)
+		} else {
+			response.JSON(ctx, nil, res)
+		}
+	}
+}
@@ -24,14 +24,15 @@ func NewHandler(
 	d *strategy.StrategyDispatcher,
 	rc *service.RiskService,
 	sm signal.Manager,
-	ps *position.PositionService) *Handler {
+	ps *position.PositionService,
+	ks *signal.KillSwitch) *Handler {
 	h := &Handler{
 		dispatcher: d,
 		rc:         rc,
 		sm:         sm,
 		ps:         ps,
 	}
-	h.whHandler = webhook.NewWebhookHandler(d, rc, sm, ps)
+	h.whHandler = webhook.NewWebhookHandler(d, rc, sm, ps).WithKillSwitch(ks)
 	return h
 }
 
@@ -3,6 +3,8 @@ package middleware
 import (
 	"edgeflow/conf"
 	"edgeflow/internal/consts"
+	"edgeflow/pkg/errors"
+	"edgeflow/pkg/errors/ecode"
 	"edgeflow/pkg/jwt"
 	"edgeflow/pkg/response"
 	"fmt"
@@ -42,6 +44,39 @@ func AuthToken() gin.HandlerFunc {
 	}
 }
 
+// AuthAdmin 鉴权，只允许管理员访问
+func AuthAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenStr, err := getJwtFromHeader(c)
+		if err != nil {
+			response.RequireAuthErr(c, err)
+			c.Abort()
+			return
+		}
+		if jwt.IsInBlackList(c, tokenStr) {
+			response.RequireAuthErr(c, err)
+			c.Abort()
+			return
+		}
+
+		claims, err := jwt.ParseToken(tokenStr, conf.AppConfig.Jwt.Secret)
+		if err != nil {
+			response.RequireAuthErr(c, err)
+			c.Abort()
+			return
+		}
+		if !claims.IsAdministrator() {
+			response.JSON(c, errors.WithCode(ecode.PermissionErr, "需要管理员权限"), nil)
+			c.Abort()
+			return
+		}
+
+		c.Set(consts.UserID, claims.UserId)
+		c.Set(consts.JWTTokenCtx, tokenStr)
+		c.Next()
+	}
+}
+
 func getJwtFromHeader(c *gin.Context) (string, error) {
 	aHeader := c.Request.Header.Get(authorizationHeader)
 	if len(aHeader) == 0 {
@@ -88,4 +88,8 @@ type MarketDetail struct {
 	HistoryKlines  []Kline         `json:"history_klines"`  // 历史k线
 	HistorySignals []SignalHistory `json:"history_signals"` // 历史信号
 	PricePrecision string          `json:"price_precision"`
+
+	// 永续合约最新的资金费率、持仓量和主动买卖比，以及与K线同一时间段的历史数据，没有采集时为空
+	Derivatives       *entity.DerivativeMetric  `json:"derivatives,omitempty"`
+	DerivativeHistory []entity.DerivativeMetric `json:"derivative_history,omitempty"`
 }
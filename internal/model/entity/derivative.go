@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+// DerivativeMetric 永续合约的资金费率、持仓量和主动买卖比，按采集时间保存为时间序列
+type DerivativeMetric struct {
+	ID     uint64 `gorm:"primaryKey;autoIncrement" json:"id"`
+	InstID string `gorm:"column:inst_id;type:varchar(30);not null;index:idx_derivative_inst_ts" json:"inst_id"` // BTC-USDT-SWAP
+
+	FundingRate     float64    `gorm:"column:funding_rate;type:decimal(16,10)" json:"funding_rate"`
+	NextFundingRate float64    `gorm:"column:next_funding_rate;type:decimal(16,10)" json:"next_funding_rate"` // 预测的下一期资金费率，交易所没有给出时为 0
+	FundingTime     *time.Time `gorm:"column:funding_time;type:timestamp" json:"funding_time"`
+
+	OpenInterest    float64 `gorm:"column:open_interest;type:decimal(30,8)" json:"open_interest"`         // 张
+	OpenInterestCcy float64 `gorm:"column:open_interest_ccy;type:decimal(30,8)" json:"open_interest_ccy"` // 币
+	OpenInterestUsd float64 `gorm:"column:open_interest_usd;type:decimal(30,4)" json:"open_interest_usd"`
+
+	// 最近一个周期的主动买入量 / 主动卖出量，大于 1 表示主动买盘更多
+	LongShortRatio float64 `gorm:"column:long_short_ratio;type:decimal(12,6)" json:"long_short_ratio"`
+
+	Ts        time.Time `gorm:"column:ts;type:timestamp;not null;index:idx_derivative_inst_ts" json:"ts"`
+	CreatedAt time.Time `gorm:"type:timestamp;not null;default:current_timestamp" json:"-"`
+}
+
+func (DerivativeMetric) TableName() string {
+	return "derivative_metrics"
+}
+
+// Indicators 作为趋势计算的附加输入，写入 TrendState.IndicatorSnapshot
+func (m DerivativeMetric) Indicators() map[string]float64 {
+	return map[string]float64{
+		"funding_rate":      m.FundingRate,
+		"next_funding_rate": m.NextFundingRate,
+		"open_interest":     m.OpenInterest,
+		"open_interest_usd": m.OpenInterestUsd,
+		"long_short_ratio":  m.LongShortRatio,
+	}
+}
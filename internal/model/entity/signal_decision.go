@@ -0,0 +1,45 @@
+package entity
+
+import "time"
+
+// SignalDecision 交易决策审计记录，Manager.Decide / DecisionEngine.Run 的每一次输出都会保存
+type SignalDecision struct {
+	ID uint64 `gorm:"primaryKey;autoIncrement" json:"id"`
+
+	// 决策来源: manager / engine
+	Source   string `gorm:"type:varchar(16);not null" json:"source"`
+	Strategy string `gorm:"type:varchar(64);not null;default:'';index:idx_decision_strategy_created" json:"strategy"`
+	Symbol   string `gorm:"type:varchar(30);not null;index:idx_decision_symbol_created" json:"symbol"`
+
+	// 关联 signals 表的信号，没有时为空
+	SignalID   *uint64   `gorm:"column:signal_id;index:idx_decision_signal_id" json:"signal_id"`
+	Level      int       `gorm:"type:int;not null;default:0" json:"level"`
+	Side       string    `gorm:"type:varchar(10);not null" json:"side"`
+	Price      float64   `gorm:"type:decimal(20,8);not null" json:"price"`
+	SignalTime time.Time `gorm:"column:signal_time;type:timestamp;not null" json:"signal_time"`
+	SignalJSON *string   `gorm:"column:signal_json;type:json" json:"signal_json"`
+
+	// 趋势快照
+	TrendDirection string  `gorm:"column:trend_direction;type:varchar(16);not null" json:"trend_direction"`
+	FinalScore     float64 `gorm:"column:final_score;type:decimal(8,4)" json:"final_score"`
+	TrendScore     float64 `gorm:"column:trend_score;type:decimal(8,4)" json:"trend_score"`
+	SignalScore    float64 `gorm:"column:signal_score;type:decimal(8,4)" json:"signal_score"`
+	Score30m       float64 `gorm:"column:score_30m;type:decimal(8,4)" json:"score_30m"`
+	Score1h        float64 `gorm:"column:score_1h;type:decimal(8,4)" json:"score_1h"`
+	Score4h        float64 `gorm:"column:score_4h;type:decimal(8,4)" json:"score_4h"`
+	Slope          float64 `gorm:"column:slope;type:decimal(12,6)" json:"slope"`
+
+	// 仓位快照，没有持仓时为空
+	PositionJSON *string `gorm:"column:position_json;type:json" json:"position_json"`
+
+	Action        string  `gorm:"type:varchar(16);not null;index:idx_decision_action" json:"action"`
+	Reason        string  `gorm:"type:varchar(128);not null" json:"reason"`
+	ReducePercent float64 `gorm:"column:reduce_percent;type:decimal(6,4)" json:"reduce_percent"`
+	ConfigVersion string  `gorm:"column:config_version;type:varchar(64);not null" json:"config_version"`
+
+	CreatedAt time.Time `gorm:"type:timestamp;not null;default:current_timestamp" json:"created_at"`
+}
+
+func (SignalDecision) TableName() string {
+	return "signal_decisions"
+}
@@ -0,0 +1,42 @@
+package entity
+
+import "time"
+
+// UserExchangeCredential 用户绑定的交易所 API Key，密钥字段均为 AES 加密后的 base64
+type UserExchangeCredential struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     int64     `gorm:"column:user_id;not null;uniqueIndex:uk_user_exchange" json:"user_id"`
+	Exchange   string    `gorm:"type:varchar(20);not null;uniqueIndex:uk_user_exchange" json:"exchange"`
+	ApiKey     string    `gorm:"column:api_key;type:varchar(255);not null" json:"-"`
+	SecretKey  string    `gorm:"column:secret_key;type:varchar(255);not null" json:"-"`
+	Passphrase string    `gorm:"column:passphrase;type:varchar(255);not null" json:"-"`
+	ApiKeyMask string    `gorm:"column:api_key_mask;type:varchar(64);not null" json:"api_key_mask"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (UserExchangeCredential) TableName() string {
+	return "user_exchange_credentials"
+}
+
+// UserOrder 用户通过信号下单的记录，下单失败时 order_id 为空并记录错误
+type UserOrder struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      int64     `gorm:"column:user_id;not null;index:idx_user_order_created" json:"user_id"`
+	Exchange    string    `gorm:"type:varchar(20);not null" json:"exchange"`
+	SignalID    int64     `gorm:"column:signal_id;not null" json:"signal_id"`
+	OrderID     string    `gorm:"column:order_id;type:varchar(100);not null;default:''" json:"order_id"`
+	Symbol      string    `gorm:"type:varchar(30);not null" json:"symbol"`
+	Side        string    `gorm:"type:varchar(10);not null" json:"side"`
+	Price       float64   `gorm:"type:decimal(20,8);not null" json:"price"`
+	TPPrice     float64   `gorm:"column:tp_price;type:decimal(20,8)" json:"tp_price"`
+	SLPrice     float64   `gorm:"column:sl_price;type:decimal(20,8)" json:"sl_price"`
+	Leverage    int       `gorm:"not null" json:"leverage"`
+	QuantityPct float64   `gorm:"column:quantity_pct;type:decimal(6,4);not null" json:"quantity_pct"`
+	Error       string    `gorm:"type:varchar(255);not null;default:''" json:"error"`
+	CreatedAt   time.Time `gorm:"index:idx_user_order_created" json:"created_at"`
+}
+
+func (UserOrder) TableName() string {
+	return "user_orders"
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"math"
 	"time"
 )
@@ -25,6 +27,8 @@ type OrderResponse struct {
 	OrderId string
 	Status  int
 	Message string
+	// 相同 ClientOrderId 的订单已经提交过，OrderId 为已有订单
+	Duplicate bool
 }
 
 type OrderStatus struct {
@@ -32,6 +36,73 @@ type OrderStatus struct {
 	Status    string
 	Filled    float64
 	Remaining float64
+	AvgPrice  float64 // 成交均价
+	Fee       float64 // 手续费，扣费为负数
+	FeeCcy    string  // 手续费币种
+	CtVal     float64 // 合约面值，数量单位为张时每张代表的币数量，现货为 0
+}
+
+// OrderUpdate 私有频道推送的订单变化
+type OrderUpdate struct {
+	OrderStatus
+	InstId        string
+	TradeType     OrderTradeType
+	ClientOrderID string
+	Side          OrderSide
+	PosSide       OrderPosSide
+	UpdatedAt     time.Time
+}
+
+// PositionUpdate 私有频道推送的仓位变化，Amount 为 0 表示该方向已经平仓
+type PositionUpdate struct {
+	PositionInfo
+	InstId    string
+	TradeType OrderTradeType
+	UpdatedAt time.Time
+}
+
+// BalanceUpdate 私有频道推送的币种余额
+type BalanceUpdate struct {
+	Currency  string
+	CashBal   float64 // 币种余额
+	UpdatedAt time.Time
+}
+
+// AlgoOrderUpdate 私有频道推送的策略委托（止盈止损）变化
+type AlgoOrderUpdate struct {
+	AlgoId      string
+	InstId      string
+	TradeType   OrderTradeType
+	OrdType     string // conditional / oco / trigger 等
+	State       string // live / effective(已触发) / canceled / order_failed
+	OrderID     string // 触发后生成的订单
+	TpTriggerPx float64
+	SlTriggerPx float64
+	UpdatedAt   time.Time
+}
+
+// LotRules 交易对的下单精度，数量单位与下单时的 Quantity 一致（合约为张）
+type LotRules struct {
+	LotSize  float64 // 数量步长
+	TickSize float64 // 价格步长
+	MinQty   float64 // 最小下单数量
+	CtVal    float64 // 合约面值，每张代表的币数量，为 0 时数量单位就是币
+
+	MaxLeverage int     // 最大杠杆倍数，为 0 时不检查
+	MaxMktQty   float64 // 市价单的最大数量，为 0 时不检查
+}
+
+// 订单状态，与 goex OrderStatus.String() 一致
+const (
+	OrderStatusPending    = "pending"
+	OrderStatusPartFilled = "part-finished"
+	OrderStatusFilled     = "finished"
+	OrderStatusCanceled   = "canceled"
+)
+
+// 订单是否已结束（全部成交或已撤销）
+func (s *OrderStatus) Done() bool {
+	return s.Status == OrderStatusFilled || s.Status == OrderStatusCanceled
 }
 
 type Order struct {
@@ -51,6 +122,18 @@ type Order struct {
 	Level       int
 	Score       int
 	Timestamp   time.Time // 信号触发时间
+	// 客户端订单ID，同一个信号、策略和动作生成的ID相同，为空时不做幂等
+	ClientOrderId string
+}
+
+// NewClientOrderId 根据信号、策略和动作生成确定的客户端订单ID。
+// okx 的 clOrdId 最长 32 位字母和数字，这里取 "ef" + sha1 的前 30 位
+func NewClientOrderId(signalKey, strategy, action string) string {
+	if signalKey == "" {
+		return ""
+	}
+	sum := sha1.Sum([]byte(strategy + "|" + signalKey + "|" + action))
+	return "ef" + hex.EncodeToString(sum[:])[:30]
 }
 
 // 交易类型
@@ -104,6 +187,14 @@ type OrderRecord struct {
 	Score     int            `gorm:"column:score" json:"score"`
 	Timestamp time.Time      `gorm:"column:timestamp" json:"timestamp"` // 信号触发时间
 
+	// 限价单入场结果，由 EntryManager 在撤单、追价或改市价后更新
+	EntryPolicy   string  `gorm:"column:entry_policy" json:"entry_policy"`
+	EntryOutcome  string  `gorm:"column:entry_outcome" json:"entry_outcome"`
+	EntryReason   string  `gorm:"column:entry_reason" json:"entry_reason"`
+	EntryAttempts int     `gorm:"column:entry_attempts" json:"entry_attempts"`
+	EntryOrderId  string  `gorm:"column:entry_order_id" json:"entry_order_id"` // 最后一笔订单
+	EntryFilled   float64 `gorm:"column:entry_filled" json:"entry_filled"`
+	EntryAvgPrice float64 `gorm:"column:entry_avg_price" json:"entry_avg_price"`
 }
 
 func (OrderRecord) TableName() string {
@@ -118,10 +209,11 @@ type PositionInfo struct {
 	MgnMode       string       // 保证金模式
 	LiqPx         string       // 强平价
 	AlgoId        string
-	PositionId    string // 仓位id
-	UnrealizedPnl string // 未实现的盈亏
-	UplRatio      string // 未实现的收益率
-	MarkPx        string // 当前价格
+	SlTriggerPx   float64 // 当前止损触发价，交易所没有返回时为 0
+	PositionId    string  // 仓位id
+	UnrealizedPnl string  // 未实现的盈亏
+	UplRatio      string  // 未实现的收益率
+	MarkPx        string  // 当前价格
 	Margin        string
 	Lever         string  // 杠杆倍数
 	NotionalUsd   string  // 仓位名义价值
@@ -227,3 +319,93 @@ func GetKLinePowerCorrection(high, low, open, close float64) float64 {
 		return 1.0 // 中性 K 线，VOL 确认力度不变
 	}
 }
+
+// PositionDiscrepancy 本地仓位元信息与交易所仓位的差异，由对账任务写入
+type PositionDiscrepancy struct {
+	ID           uint           `gorm:"column:id;primary_key;" json:"id"`
+	Symbol       string         `gorm:"column:symbol" json:"symbol"`
+	TradeType    OrderTradeType `gorm:"column:trade_type" json:"trade_type"`
+	Kind         string         `gorm:"column:kind" json:"kind"`                   // 差异类型
+	LocalSide    string         `gorm:"column:local_side" json:"local_side"`       // 本地方向 buy/sell
+	LocalSize    float64        `gorm:"column:local_size" json:"local_size"`       // 本地各级别数量之和
+	ExchangeSide string         `gorm:"column:exchange_side" json:"exchange_side"` // 交易所方向 long/short
+	ExchangeSize float64        `gorm:"column:exchange_size" json:"exchange_size"` // 交易所持仓数量
+	Action       string         `gorm:"column:action" json:"action"`               // 对本地元信息做的修复
+	Alerted      bool           `gorm:"column:alerted" json:"alerted"`             // 是否发送了提醒
+	CreatedAt    time.Time      `gorm:"column:created_at" json:"created_at"`
+}
+
+func (PositionDiscrepancy) TableName() string {
+	return "position_discrepancy"
+}
+
+// 跟踪订单的用途
+const (
+	OrderRoleOpen  = "open"  // 开仓、加仓
+	OrderRoleClose = "close" // 平仓、减仓
+)
+
+// OrderTracking 订单生命周期，下单后由订单跟踪器按交易所状态持续更新
+type OrderTracking struct {
+	ID          uint           `gorm:"column:id;primary_key;" json:"id"`
+	OrderId     string         `gorm:"column:order_id" json:"order_id"`
+	Symbol      string         `gorm:"column:symbol" json:"symbol"`
+	TradeType   OrderTradeType `gorm:"column:trade_type" json:"trade_type"`
+	Strategy    string         `gorm:"column:strategy" json:"strategy"`
+	SignalID    uint64         `gorm:"column:signal_id" json:"signal_id"` // 关联 signals 表，没有时为 0
+	Level       int            `gorm:"column:level" json:"level"`
+	Role        string         `gorm:"column:role" json:"role"`               // open/close
+	PosSide     OrderPosSide   `gorm:"column:pos_side" json:"pos_side"`       // 仓位方向 long/short
+	Status      string         `gorm:"column:status" json:"status"`           // 交易所订单状态
+	FilledQty   float64        `gorm:"column:filled_qty" json:"filled_qty"`   // 已成交数量
+	AvgPrice    float64        `gorm:"column:avg_price" json:"avg_price"`     // 成交均价
+	Fee         float64        `gorm:"column:fee" json:"fee"`                 // 手续费，扣费为负数
+	FeeCcy      string         `gorm:"column:fee_ccy" json:"fee_ccy"`         // 手续费币种
+	EntryPrice  float64        `gorm:"column:entry_price" json:"entry_price"` // 平仓单对应的开仓均价
+	CtVal       float64        `gorm:"column:ct_val" json:"ct_val"`           // 合约面值，为 0 时数量单位就是币
+	RealizedPnl float64        `gorm:"column:realized_pnl" json:"realized_pnl"`
+	CreatedAt   time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	FinishedAt  *time.Time     `gorm:"column:finished_at" json:"finished_at"`
+}
+
+func (OrderTracking) TableName() string {
+	return "order_tracking"
+}
+
+// 订单是否已结束
+func (o *OrderTracking) Done() bool {
+	return o.Status == OrderStatusFilled || o.Status == OrderStatusCanceled
+}
+
+// OrderStatusHistory 订单状态变化记录
+type OrderStatusHistory struct {
+	ID        uint      `gorm:"column:id;primary_key;" json:"id"`
+	OrderId   string    `gorm:"column:order_id" json:"order_id"`
+	Status    string    `gorm:"column:status" json:"status"`
+	FilledQty float64   `gorm:"column:filled_qty" json:"filled_qty"`
+	AvgPrice  float64   `gorm:"column:avg_price" json:"avg_price"`
+	Fee       float64   `gorm:"column:fee" json:"fee"`
+	Source    string    `gorm:"column:source" json:"source"` // poll: 轮询, push: 私有频道推送
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
+
+// WinStats 策略平仓单的胜负统计
+type WinStats struct {
+	Trades  int64   `gorm:"column:trades" json:"trades"`
+	Wins    int64   `gorm:"column:wins" json:"wins"`
+	AvgWin  float64 `gorm:"column:avg_win" json:"avg_win"`   // 盈利单的平均盈利
+	AvgLoss float64 `gorm:"column:avg_loss" json:"avg_loss"` // 亏损单的平均亏损，取正数
+}
+
+// StrategyPnl 按策略汇总的已实现盈亏
+type StrategyPnl struct {
+	Strategy    string  `gorm:"column:strategy" json:"strategy"`
+	Orders      int64   `gorm:"column:orders" json:"orders"`
+	Fee         float64 `gorm:"column:fee" json:"fee"`
+	RealizedPnl float64 `gorm:"column:realized_pnl" json:"realized_pnl"`
+}
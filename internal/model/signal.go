@@ -1,6 +1,7 @@
 package model
 
 import (
+	"edgeflow/internal/model/entity"
 	"time"
 )
 
@@ -29,6 +30,22 @@ type SignalDetailReq struct {
 	SignalID string `json:"signal_id" form:"signal_id"`
 }
 
+// 决策记录查询条件，signal_id 用于从信号详情跳转
+type SignalDecisionListReq struct {
+	Symbol   string `form:"symbol" json:"symbol,omitempty"`
+	Strategy string `form:"strategy" json:"strategy,omitempty"`
+	Action   string `form:"action" json:"action,omitempty"` // ignore / open / add / reduce / tighten_sl / close
+	SignalID uint64 `form:"signal_id" json:"signal_id,omitempty"`
+
+	Limit  int `form:"limit" json:"limit"`   // 每页数量
+	Offset int `form:"offset" json:"offset"` // 偏移量
+}
+
+type SignalDecisionListRes struct {
+	Total     int64                   `json:"total"`
+	Decisions []entity.SignalDecision `json:"decisions"`
+}
+
 type SignalDetail struct {
 	ID int64 `gorm:"column:id" json:"signal_id"` // 唯一的信号 ID
 
@@ -56,6 +73,29 @@ type SignalDetail struct {
 
 	Klines          []Kline         `gorm:"-" json:"klines"`
 	SignalHistories []SignalHistory `gorm:"-" json:"signal_histories"` // 历史信号
+	// 该信号触发的交易决策，只包含动作和原因，完整的决策记录通过管理接口查询
+	Decisions []SignalDecisionBrief `gorm:"-" json:"decisions"`
+}
+
+// SignalDecisionBrief 信号详情中展示的决策摘要，不包含仓位和配置等审计字段
+type SignalDecisionBrief struct {
+	Strategy      string    `json:"strategy"`
+	Side          string    `json:"side"`
+	Action        string    `json:"action"`
+	Reason        string    `json:"reason"`
+	ReducePercent float64   `json:"reduce_percent"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func NewSignalDecisionBrief(d entity.SignalDecision) SignalDecisionBrief {
+	return SignalDecisionBrief{
+		Strategy:      d.Strategy,
+		Side:          d.Side,
+		Action:        d.Action,
+		Reason:        d.Reason,
+		ReducePercent: d.ReducePercent,
+		CreatedAt:     d.CreatedAt,
+	}
 }
 
 func (SignalDetail) TableName() string {
@@ -116,7 +156,39 @@ type ActiveSignalsRes struct {
 	Signals []Signal `json:"signals"`
 }
 
-// 信号下单的请求
+// 信号下单的请求，杠杆和仓位比例为 0 时使用默认值，不能超过用户订阅计划的上限
 type SignalExecutionReq struct {
-	SignalID string `json:"signal_id" form:"signal_id"`
+	SignalID    string  `json:"signal_id" form:"signal_id"`
+	Leverage    int     `json:"leverage" form:"leverage"`
+	QuantityPct float64 `json:"quantity_pct" form:"quantity_pct"` // 使用可用余额的比例 (0, 1]
+}
+
+type SignalExecutionRes struct {
+	OrderID     string  `json:"order_id"`
+	Leverage    int     `json:"leverage"`
+	QuantityPct float64 `json:"quantity_pct"`
+}
+
+// 用户绑定交易所 API Key
+type UserCredentialSaveReq struct {
+	ApiKey     string `json:"api_key" binding:"required"`
+	SecretKey  string `json:"secret_key" binding:"required"`
+	Passphrase string `json:"passphrase" binding:"required"`
+}
+
+// 返回给客户端的 API Key 只包含脱敏后的内容
+type UserCredentialRes struct {
+	Exchange   string    `json:"exchange"`
+	ApiKeyMask string    `json:"api_key_mask"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type UserOrderListReq struct {
+	Limit  int `form:"limit" json:"limit"`   // 每页数量
+	Offset int `form:"offset" json:"offset"` // 偏移量
+}
+
+type UserOrderListRes struct {
+	Total  int64              `json:"total"`
+	Orders []entity.UserOrder `json:"orders"`
 }
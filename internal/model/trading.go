@@ -0,0 +1,37 @@
+package model
+
+// 查询交易频次统计，symbol 为空时返回全部交易对
+type TradeLimitStatsReq struct {
+	Symbol string `form:"symbol" json:"symbol"`
+}
+
+type TradeLimitResetReq struct {
+	Symbol string `json:"symbol" binding:"required"`
+}
+
+// 按策略统计已实现盈亏，统计最近 days 天创建的订单，默认 30 天
+type StrategyPnlReq struct {
+	Days int `form:"days" json:"days"`
+}
+
+type OrderHistoryReq struct {
+	OrderId string `form:"order_id" json:"order_id" binding:"required"`
+}
+
+// 暂停交易，scope: all / strategy / symbol
+type KillSwitchPauseReq struct {
+	Scope  string `json:"scope" binding:"required,oneof=all strategy symbol"`
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+}
+
+type KillSwitchResumeReq struct {
+	Scope  string `json:"scope" binding:"required,oneof=all strategy symbol"`
+	Target string `json:"target"`
+}
+
+// 平掉全部仓位，symbols 为额外需要检查的交易对，pause 为 true 时先暂停全部策略
+type FlattenReq struct {
+	Symbols []string `json:"symbols"`
+	Pause   bool     `json:"pause"`
+}
@@ -0,0 +1,324 @@
+package position
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	"edgeflow/pkg/exchange"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// EntryPolicy 限价单未成交时的处理方式
+type EntryPolicy string
+
+const (
+	EntryNone   EntryPolicy = "none"   // 挂单后不再处理
+	EntryCancel EntryPolicy = "cancel" // 超时撤单
+	EntryChase  EntryPolicy = "chase"  // 向买一/卖一追价，超过最大滑点或超时后撤单
+	EntryMarket EntryPolicy = "market" // 超时后撤单，剩余数量改市价
+)
+
+// 入场结果
+const (
+	EntryFilled       = "filled"        // 限价单（含追价）全部成交
+	EntryMarketFilled = "market-filled" // 超时后剩余数量市价成交
+	EntryPartial      = "partial"       // 部分成交后撤单
+	EntryCanceled     = "canceled"      // 没有成交，已撤单
+	EntryFailed       = "failed"        // 撤单、下单或查询失败，订单状态未知
+)
+
+const (
+	defaultEntryTimeout       = 2 * time.Minute
+	defaultEntryCheckInterval = 2 * time.Second
+)
+
+// EntryResult 一次限价入场的结果，写入订单记录
+type EntryResult struct {
+	Policy   EntryPolicy `json:"policy"`
+	Outcome  string      `json:"outcome"`
+	Reason   string      `json:"reason"`   // timeout / slippage / external / 错误信息
+	OrderId  string      `json:"order_id"` // 最后一笔订单
+	Attempts int         `json:"attempts"` // 下单次数，含首单
+	Filled   float64     `json:"filled"`
+	AvgPrice float64     `json:"avg_price"`
+}
+
+// EntryManager 跟踪限价开仓单，按策略配置超时撤单、追价或改市价，
+// 只依赖 GetOrderStatus、CancelOrder 和 PlaceOrder
+type EntryManager struct {
+	ex  exchange.Exchange
+	cfg conf.EntryConfig
+	now func() time.Time
+}
+
+func NewEntryManager(ex exchange.Exchange, cfg conf.EntryConfig) *EntryManager {
+	return &EntryManager{ex: ex, cfg: cfg, now: time.Now}
+}
+
+// Policy 策略的入场配置，m 为 nil 时挂单不处理
+func (m *EntryManager) Policy(strategy string) conf.EntryPolicyConfig {
+	if m == nil {
+		return conf.EntryPolicyConfig{Policy: string(EntryNone)}
+	}
+	cfg, ok := m.cfg.Strategies[strategy]
+	if !ok {
+		cfg = m.cfg.Default
+	}
+	if cfg.Policy == "" {
+		cfg.Policy = string(EntryNone)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultEntryTimeout
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = defaultEntryCheckInterval
+	}
+	if cfg.ChaseInterval <= 0 {
+		cfg.ChaseInterval = cfg.CheckInterval
+	}
+	return cfg
+}
+
+// Enabled 限价单是否需要跟踪
+func (m *EntryManager) Enabled(order model.Order) bool {
+	return order.OrderType == model.Limit && EntryPolicy(m.Policy(order.Strategy).Policy) != EntryNone
+}
+
+// entryFills 累计已经结束的订单的成交
+type entryFills struct {
+	qty      float64
+	notional float64
+}
+
+func (f *entryFills) add(st *model.OrderStatus) {
+	if st == nil || st.Filled <= 0 {
+		return
+	}
+	f.qty += st.Filled
+	f.notional += st.Filled * st.AvgPrice
+}
+
+// Manage 跟踪限价单直到成交、撤单或者改市价。order 为已经下单的订单（Quantity 为实际下单数量），
+// onPlace 在追价或改市价的新订单提交后调用
+func (m *EntryManager) Manage(ctx context.Context, order model.Order, orderId string, onPlace func(order model.Order, orderId string)) EntryResult {
+	cfg := m.Policy(order.Strategy)
+	policy := EntryPolicy(cfg.Policy)
+	res := EntryResult{Policy: policy, OrderId: orderId, Attempts: 1}
+	if !m.Enabled(order) {
+		return res
+	}
+
+	var fills entryFills
+	finish := func(outcome, reason string) EntryResult {
+		res.Outcome = outcome
+		res.Reason = reason
+		res.Filled = fills.qty
+		if fills.qty > 0 {
+			res.AvgPrice = fills.notional / fills.qty
+		}
+		return res
+	}
+	// 撤单后按最终状态结算，撤单时可能已经成交
+	settle := func() (EntryResult, bool) {
+		st, err := m.cancel(order, res.OrderId)
+		if err != nil {
+			return finish(EntryFailed, err.Error()), true
+		}
+		fills.add(st)
+		if st.Status == model.OrderStatusFilled {
+			return finish(EntryFilled, ""), true
+		}
+		return EntryResult{}, false
+	}
+	canceled := func(reason string) EntryResult {
+		if fills.qty > 0 {
+			return finish(EntryPartial, reason)
+		}
+		return finish(EntryCanceled, reason)
+	}
+
+	rules := m.lotRules(order)
+	anchor := order.Price
+	price := order.Price
+	deadline := m.now().Add(cfg.Timeout)
+	lastPlaced := m.now()
+
+	ticker := time.NewTicker(cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return finish(EntryFailed, ctx.Err().Error())
+		case <-ticker.C:
+		}
+
+		st, err := m.ex.GetOrderStatus(res.OrderId, order.Symbol, order.TradeType)
+		if err != nil {
+			log.Printf("[EntryManager] 查询订单 %s 失败: %v", res.OrderId, err)
+			continue
+		}
+		if order.Quantity <= 0 && res.Attempts == 1 {
+			// 按比例下单时数量由交易所计算，以首单的委托数量为准
+			order.Quantity = st.Filled + st.Remaining
+		}
+		switch st.Status {
+		case model.OrderStatusFilled:
+			fills.add(st)
+			return finish(EntryFilled, "")
+		case model.OrderStatusCanceled:
+			// 被手动或交易所撤单
+			fills.add(st)
+			return canceled("external")
+		}
+
+		now := m.now()
+		if !now.Before(deadline) {
+			if r, done := settle(); done {
+				return r
+			}
+			if policy != EntryMarket {
+				return canceled("timeout")
+			}
+			return m.market(ctx, order, rules, &res, &fills, onPlace, finish)
+		}
+
+		if policy != EntryChase || now.Sub(lastPlaced) < cfg.ChaseInterval {
+			continue
+		}
+		target, err := m.chasePrice(order, rules)
+		if err != nil {
+			log.Printf("[EntryManager] %s 查询盘口失败: %v", order.Symbol, err)
+			continue
+		}
+		buy := order.Side == model.Buy
+		if (buy && target <= price) || (!buy && target >= price) {
+			// 委托价已经在盘口，继续等待
+			continue
+		}
+		if cfg.MaxSlippage > 0 && math.Abs(target-anchor)/anchor > cfg.MaxSlippage {
+			if r, done := settle(); done {
+				return r
+			}
+			return canceled("slippage")
+		}
+
+		// 撤掉旧单，剩余数量按新价格重新挂单
+		if r, done := settle(); done {
+			return r
+		}
+		next := order
+		next.Price = target
+		next.QuantityPct = 0
+		next.Quantity = exchange.FloorToStep(order.Quantity-fills.qty, rules.LotSize)
+		if next.Quantity <= 0 || next.Quantity < rules.MinQty {
+			return canceled("chase")
+		}
+		if order.ClientOrderId != "" {
+			next.ClientOrderId = model.NewClientOrderId(order.ClientOrderId, "", fmt.Sprintf("chase-%d", res.Attempts))
+		}
+		resp, err := m.ex.PlaceOrder(ctx, &next)
+		if err != nil {
+			return finish(EntryFailed, err.Error())
+		}
+		res.Attempts++
+		res.OrderId = resp.OrderId
+		price = target
+		lastPlaced = now
+		log.Printf("[EntryManager] %s %s 追价 %v -> %v，剩余 %v，订单 %s", order.Strategy, order.Symbol, anchor, target, next.Quantity, resp.OrderId)
+		if onPlace != nil {
+			onPlace(next, resp.OrderId)
+		}
+	}
+}
+
+// market 剩余数量市价成交
+func (m *EntryManager) market(ctx context.Context, order model.Order, rules model.LotRules, res *EntryResult, fills *entryFills,
+	onPlace func(order model.Order, orderId string), finish func(outcome, reason string) EntryResult) EntryResult {
+	next := order
+	next.OrderType = model.Market
+	next.QuantityPct = 0
+	next.Quantity = exchange.FloorToStep(order.Quantity-fills.qty, rules.LotSize)
+	if next.Quantity <= 0 || next.Quantity < rules.MinQty {
+		if fills.qty > 0 {
+			return finish(EntryPartial, "timeout")
+		}
+		return finish(EntryCanceled, "timeout")
+	}
+	if order.ClientOrderId != "" {
+		next.ClientOrderId = model.NewClientOrderId(order.ClientOrderId, "", "market")
+	}
+	if last, err := m.ex.GetLastPrice(order.Symbol, order.TradeType); err == nil && last > 0 {
+		next.Price = last
+	}
+	resp, err := m.ex.PlaceOrder(ctx, &next)
+	if err != nil {
+		return finish(EntryFailed, err.Error())
+	}
+	res.Attempts++
+	res.OrderId = resp.OrderId
+	if onPlace != nil {
+		onPlace(next, resp.OrderId)
+	}
+	// 市价单一般立即成交，查询不到时按委托数量和最新价记录
+	if st, err := m.ex.GetOrderStatus(resp.OrderId, order.Symbol, order.TradeType); err == nil && st.Done() {
+		fills.add(st)
+	} else {
+		fills.add(&model.OrderStatus{Filled: next.Quantity, AvgPrice: next.Price})
+	}
+	log.Printf("[EntryManager] %s %s 限价单超时，剩余 %v 改市价，订单 %s", order.Strategy, order.Symbol, next.Quantity, resp.OrderId)
+	return finish(EntryMarketFilled, "timeout")
+}
+
+// cancel 撤单并返回最终状态，撤单失败时（通常是已经成交）以查询结果为准
+func (m *EntryManager) cancel(order model.Order, orderId string) (*model.OrderStatus, error) {
+	cancelErr := m.ex.CancelOrder(orderId, order.Symbol, order.TradeType)
+	st, err := m.ex.GetOrderStatus(orderId, order.Symbol, order.TradeType)
+	if err != nil {
+		if cancelErr != nil {
+			return nil, fmt.Errorf("撤单 %s 失败: %v", orderId, cancelErr)
+		}
+		return nil, err
+	}
+	if cancelErr != nil && !st.Done() {
+		return nil, fmt.Errorf("撤单 %s 失败: %v", orderId, cancelErr)
+	}
+	return st, nil
+}
+
+// chasePrice 买单追卖一，卖单追买一，不支持盘口时使用最新价
+func (m *EntryManager) chasePrice(order model.Order, rules model.LotRules) (float64, error) {
+	var bid, ask float64
+	if provider, ok := m.ex.(exchange.QuoteProvider); ok {
+		b, a, err := provider.GetBestQuote(order.Symbol, order.TradeType)
+		if err != nil {
+			return 0, err
+		}
+		bid, ask = b, a
+	} else {
+		last, err := m.ex.GetLastPrice(order.Symbol, order.TradeType)
+		if err != nil {
+			return 0, err
+		}
+		bid, ask = last, last
+	}
+	price := ask
+	if order.Side == model.Sell {
+		price = bid
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("invalid quote for %s: %v/%v", order.Symbol, bid, ask)
+	}
+	return exchange.RoundToStep(price, rules.TickSize), nil
+}
+
+func (m *EntryManager) lotRules(order model.Order) model.LotRules {
+	if provider, ok := m.ex.(exchange.LotRulesProvider); ok {
+		if rules, err := provider.GetLotRules(order.Symbol, order.TradeType); err == nil && rules != nil {
+			return *rules
+		}
+	}
+	return model.LotRules{}
+}
@@ -0,0 +1,99 @@
+package position
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	"edgeflow/internal/signal"
+	"edgeflow/pkg/exchange"
+	"testing"
+	"time"
+)
+
+func TestEntryManager(t *testing.T) {
+	cases := []struct {
+		name     string
+		cfg      conf.EntryPolicyConfig
+		outcome  string
+		reason   string
+		attempts int
+		avgPrice float64
+	}{
+		{"超时撤单", conf.EntryPolicyConfig{Policy: "cancel"}, EntryCanceled, "timeout", 1, 0},
+		{"追价成交", conf.EntryPolicyConfig{Policy: "chase", ChaseInterval: 10 * time.Millisecond}, EntryFilled, "", 2, 100},
+		{"超过最大滑点", conf.EntryPolicyConfig{Policy: "chase", ChaseInterval: 10 * time.Millisecond, MaxSlippage: 0.01}, EntryCanceled, "slippage", 1, 0},
+		{"超时改市价", conf.EntryPolicyConfig{Policy: "market"}, EntryMarketFilled, "timeout", 2, 100},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			market := &stubMarket{price: 100}
+			ex := exchange.NewPaperExchange(market, exchange.PaperConfig{Balance: 1000, DefaultLeverage: 10})
+			c.cfg.Timeout = 50 * time.Millisecond
+			c.cfg.CheckInterval = 5 * time.Millisecond
+			m := NewEntryManager(ex, conf.EntryConfig{Strategies: map[string]conf.EntryPolicyConfig{"tv-level": c.cfg}})
+
+			order := model.Order{
+				Symbol:    "BTC/USDT",
+				Side:      model.Buy,
+				Price:     95,
+				Quantity:  1,
+				OrderType: model.Limit,
+				Strategy:  "tv-level",
+				TradeType: model.OrderTradeSwap,
+			}
+			resp, err := ex.PlaceOrder(ctx, &order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			placed := 0
+			res := m.Manage(ctx, order, resp.OrderId, func(model.Order, string) { placed++ })
+			if res.Outcome != c.outcome || res.Reason != c.reason || res.Attempts != c.attempts || placed != c.attempts-1 {
+				t.Fatalf("入场结果: %+v placed=%d", res, placed)
+			}
+			if !almostEqual(res.AvgPrice, c.avgPrice) {
+				t.Fatalf("成交均价: %v", res.AvgPrice)
+			}
+			long, _, _ := ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+			if c.avgPrice > 0 && (long == nil || !almostEqual(long.Amount, res.Filled)) {
+				t.Fatalf("持仓与成交不一致: %+v %v", long, res.Filled)
+			}
+			if c.avgPrice == 0 && long != nil {
+				t.Fatalf("撤单后不应有持仓: %+v", long)
+			}
+		})
+	}
+}
+
+func TestOpenLimitEntryCanceled(t *testing.T) {
+	ctx := context.Background()
+	market := &stubMarket{price: 100}
+	ex := exchange.NewPaperExchange(market, exchange.PaperConfig{Balance: 1000, DefaultLeverage: 10})
+	m := NewEntryManager(ex, conf.EntryConfig{Default: conf.EntryPolicyConfig{Policy: "cancel", Timeout: 30 * time.Millisecond, CheckInterval: 5 * time.Millisecond}})
+	ps := NewPositionService(ex, nil).WithEntryManager(m)
+
+	sig := signal.Signal{
+		Strategy:  "tv-level",
+		Symbol:    "BTC/USDT",
+		Side:      "buy",
+		Price:     95,
+		OrderType: "limit",
+		TradeType: string(model.OrderTradeSwap),
+		Level:     2,
+	}
+	if err := ps.ApplyAction(ctx, signal.ActOpen, sig, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ps.GetPositionByLevel("BTC/USDT", 2) == nil {
+		t.Fatal("挂单后应记录本地仓位")
+	}
+
+	// 超时撤单后没有成交，本地仓位删除
+	deadline := time.Now().Add(time.Second)
+	for ps.GetPositionByLevel("BTC/USDT", 2) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("撤单后应删除本地仓位")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,279 @@
+package position
+
+import (
+	"context"
+	"edgeflow/internal/model"
+	"edgeflow/internal/signal"
+	"edgeflow/pkg/exchange"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+const defaultLadderInterval = 5 * time.Second
+
+// LadderTier 分批止盈中的一档，价格在开仓时按止损距离计算
+type LadderTier struct {
+	R        float64    `json:"r"`
+	Pct      float64    `json:"pct"` // 占开仓数量的比例
+	Price    float64    `json:"price"`
+	Filled   bool       `json:"filled"`
+	Qty      float64    `json:"qty"` // 实际平仓数量
+	OrderId  string     `json:"order_id"`
+	FilledAt *time.Time `json:"filled_at"`
+}
+
+// LadderFill 一次分批止盈的结果
+type LadderFill struct {
+	Symbol  string  `json:"symbol"`
+	Level   int     `json:"level"`
+	R       float64 `json:"r"`
+	Price   float64 `json:"price"`
+	Qty     float64 `json:"qty"`
+	OrderId string  `json:"order_id"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// validateLadder 每档 R 和比例必须为正，比例之和不超过 1，剩余部分作为移动止损的底仓
+func validateLadder(tiers []signal.TPTier) error {
+	total := 0.0
+	for _, t := range tiers {
+		if t.R <= 0 || t.Pct <= 0 || t.Pct > 1 {
+			return fmt.Errorf("无效的止盈档位: r=%v pct=%v", t.R, t.Pct)
+		}
+		total += t.Pct
+	}
+	if total > 1+1e-9 {
+		return fmt.Errorf("止盈档位比例之和 %.2f 超过 1", total)
+	}
+	return nil
+}
+
+// newLadder 按开仓价和止损价计算每档的止盈价，档位按 R 从小到大排列
+func newLadder(side model.OrderSide, entry, stop float64, tiers []signal.TPTier) []*LadderTier {
+	risk := math.Abs(entry - stop)
+	if risk == 0 {
+		return nil
+	}
+	dir := 1.0
+	if side == model.Sell {
+		dir = -1
+	}
+	ladder := make([]*LadderTier, 0, len(tiers))
+	for _, t := range tiers {
+		ladder = append(ladder, &LadderTier{R: t.R, Pct: t.Pct, Price: round(entry + dir*t.R*risk)})
+	}
+	sort.Slice(ladder, func(i, j int) bool { return ladder[i].R < ladder[j].R })
+	return ladder
+}
+
+// TakeProfitLadder 按实时价格执行分批止盈：价格达到某一档时通过 ClosePosition 平掉对应数量，
+// 更新本地仓位数量；平仓失败的档位保持待触发，下一轮重试
+type TakeProfitLadder struct {
+	ps       *PositionService
+	prices   PriceSource
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewTakeProfitLadder(ps *PositionService, prices PriceSource, interval time.Duration) *TakeProfitLadder {
+	if interval <= 0 {
+		interval = defaultLadderInterval
+	}
+	return &TakeProfitLadder{ps: ps, prices: prices, interval: interval, now: time.Now}
+}
+
+// Run 按间隔检查，直到 ctx 结束
+func (l *TakeProfitLadder) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		l.CheckOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckOnce 检查所有带止盈阶梯的仓位，返回本轮触发的档位
+func (l *TakeProfitLadder) CheckOnce(ctx context.Context) []LadderFill {
+	var prices map[string]float64
+	if l.prices != nil {
+		prices = l.prices.GetPrices()
+	}
+
+	// 先复制需要检查的元信息，下单时不持有锁
+	l.ps.mu.Lock()
+	var metas []*LocalPositionMeta
+	for _, levels := range l.ps.metas {
+		for _, meta := range levels {
+			if pendingTier(meta.Ladder) != nil {
+				metas = append(metas, meta)
+			}
+		}
+	}
+	l.ps.mu.Unlock()
+	sort.Slice(metas, func(i, j int) bool {
+		if metas[i].Symbol != metas[j].Symbol {
+			return metas[i].Symbol < metas[j].Symbol
+		}
+		return metas[i].Level < metas[j].Level
+	})
+
+	var fills []LadderFill
+	for _, meta := range metas {
+		tradeType := meta.TradeType
+		if tradeType == "" {
+			tradeType = defaultReconcileTradeType
+		}
+		long, short, err := l.ps.Exchange.GetPosition(meta.Symbol, tradeType)
+		if err != nil {
+			log.Printf("[TakeProfitLadder] 查询 %s 仓位失败: %v", meta.Symbol, err)
+			continue
+		}
+		pos := activePosition(long)
+		if meta.Side == string(model.Sell) {
+			pos = activePosition(short)
+		}
+		if pos == nil {
+			// 仓位已经不存在，由对账清理本地元信息
+			continue
+		}
+		price := lookupPrice(prices, meta.Symbol)
+		if price <= 0 {
+			price = pos.Last
+		}
+		if price <= 0 {
+			continue
+		}
+		fills = append(fills, l.execute(ctx, meta, pos, tradeType, price)...)
+	}
+	return fills
+}
+
+// execute 依次平掉价格已经达到的档位
+func (l *TakeProfitLadder) execute(ctx context.Context, meta *LocalPositionMeta, pos *model.PositionInfo, tradeType model.OrderTradeType, price float64) []LadderFill {
+	long := meta.Side != string(model.Sell)
+	rules := l.lotRules(meta.Symbol, tradeType)
+	remaining := pos.Amount
+
+	var fills []LadderFill
+	for {
+		l.ps.mu.Lock()
+		tier := pendingTier(meta.Ladder)
+		if tier == nil || (long && price < tier.Price) || (!long && price > tier.Price) {
+			l.ps.mu.Unlock()
+			return fills
+		}
+		// 本级别剩余数量。数量未知时不能按交易所仓位计算，那样会平掉同一交易对其他级别的仓位
+		size := meta.Size
+		if size <= 0 {
+			l.ps.mu.Unlock()
+			log.Printf("[TakeProfitLadder] %s L%d 本地仓位数量未知，跳过止盈阶梯", meta.Symbol, meta.Level)
+			return fills
+		}
+		if size > remaining {
+			size = remaining
+		}
+		qty := size * tier.Pct / remainingPct(meta.Ladder)
+		l.ps.mu.Unlock()
+
+		qty = math.Min(exchange.FloorToStep(qty, rules.LotSize), remaining)
+		fill := LadderFill{Symbol: meta.Symbol, Level: meta.Level, R: tier.R, Price: price, Qty: qty}
+		if qty <= 0 || qty < rules.MinQty {
+			// 数量不足最小下单量，跳过该档，这部分按比例分给后面的档位和底仓
+			log.Printf("[TakeProfitLadder] %s L%d %.1fR 数量 %v 不足最小下单量，跳过", meta.Symbol, meta.Level, tier.R, qty)
+			l.markFilled(meta, tier, size, 0, "")
+			continue
+		}
+
+		partial := *pos
+		partial.Amount = qty
+		resp, err := l.closePartial(ctx, meta, &partial, tradeType)
+		if err != nil {
+			fill.Error = err.Error()
+			log.Printf("[TakeProfitLadder] %s L%d %.1fR 平仓 %v 失败，等待下次重试: %v", meta.Symbol, meta.Level, tier.R, qty, err)
+			return append(fills, fill)
+		}
+		if resp != nil {
+			fill.OrderId = resp.OrderId
+		}
+		l.markFilled(meta, tier, size, qty, fill.OrderId)
+		remaining -= qty
+		log.Printf("[TakeProfitLadder] %s L%d 价格 %v 达到 %.1fR 止盈价 %v，平仓 %v", meta.Symbol, meta.Level, price, tier.R, tier.Price, qty)
+		fills = append(fills, fill)
+		if remaining <= 0 {
+			return fills
+		}
+	}
+}
+
+// closePartial 平掉部分仓位，平仓单关联到开仓的策略
+func (l *TakeProfitLadder) closePartial(ctx context.Context, meta *LocalPositionMeta, item *model.PositionInfo, tradeType model.OrderTradeType) (*model.OrderResponse, error) {
+	resp, err := l.ps.Exchange.ClosePosition(item.Symbol, string(item.Dir), item.Amount, item.MgnMode, tradeType)
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil {
+		l.ps.tracker.Track(ctx, &model.OrderTracking{
+			OrderId:    resp.OrderId,
+			Symbol:     meta.Symbol,
+			TradeType:  tradeType,
+			Strategy:   meta.Strategy,
+			Level:      meta.Level,
+			Role:       model.OrderRoleClose,
+			PosSide:    item.Dir,
+			EntryPrice: item.AvgPrice,
+		})
+	}
+	return resp, nil
+}
+
+// markFilled 记录档位成交，本地仓位数量更新为 size - qty
+func (l *TakeProfitLadder) markFilled(meta *LocalPositionMeta, tier *LadderTier, size, qty float64, orderId string) {
+	now := l.now()
+	l.ps.mu.Lock()
+	defer l.ps.mu.Unlock()
+	tier.Filled = true
+	tier.Qty = qty
+	tier.OrderId = orderId
+	tier.FilledAt = &now
+	meta.Size = math.Max(size-qty, 0)
+}
+
+func (l *TakeProfitLadder) lotRules(symbol string, tradeType model.OrderTradeType) model.LotRules {
+	if provider, ok := l.ps.Exchange.(exchange.LotRulesProvider); ok {
+		if rules, err := provider.GetLotRules(symbol, tradeType); err == nil && rules != nil {
+			return *rules
+		}
+	}
+	return model.LotRules{}
+}
+
+// pendingTier 下一个待触发的档位
+func pendingTier(ladder []*LadderTier) *LadderTier {
+	for _, t := range ladder {
+		if !t.Filled {
+			return t
+		}
+	}
+	return nil
+}
+
+// remainingPct 还没有平掉的比例（含底仓），用于把档位比例换算成当前剩余数量的比例
+func remainingPct(ladder []*LadderTier) float64 {
+	rest := 1.0
+	for _, t := range ladder {
+		if t.Filled {
+			rest -= t.Pct
+		}
+	}
+	if rest <= 0 {
+		return 1
+	}
+	return rest
+}
@@ -0,0 +1,85 @@
+package position
+
+import (
+	"context"
+	"edgeflow/internal/model"
+	"edgeflow/internal/signal"
+	"edgeflow/pkg/exchange"
+	"testing"
+)
+
+func TestTakeProfitLadder(t *testing.T) {
+	ctx := context.Background()
+	market := &stubMarket{price: 100}
+	ex := exchange.NewPaperExchange(market, exchange.PaperConfig{Balance: 1000, DefaultLeverage: 10})
+	ps := NewPositionService(ex, nil)
+
+	sig := signal.Signal{
+		Strategy:  "tv-level",
+		Symbol:    "BTC/USDT",
+		Side:      "buy",
+		OrderType: "market",
+		TradeType: string(model.OrderTradeSwap),
+		Level:     2,
+		TPLadder:  []signal.TPTier{{R: 2, Pct: 0.3}, {R: 1, Pct: 0.4}},
+	}
+	bad := sig
+	bad.TPLadder = []signal.TPTier{{R: 1, Pct: 0.6}, {R: 2, Pct: 0.6}}
+	if err := ps.ApplyAction(ctx, signal.ActOpen, bad, nil); err == nil {
+		t.Fatalf("比例之和超过 1 应报错")
+	}
+
+	// 开仓止损 1.5%，1R = 1.5
+	if err := ps.ApplyAction(ctx, signal.ActOpen, sig, nil); err != nil {
+		t.Fatal(err)
+	}
+	meta := ps.GetPositionByLevel("BTC/USDT", 2)
+	if len(meta.Ladder) != 2 || meta.Ladder[0].Price != 101.5 || meta.Ladder[1].Price != 103 {
+		t.Fatalf("止盈阶梯: %+v %+v", meta.Ladder[0], meta.Ladder[1])
+	}
+	long, _, _ := ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	total := long.Amount
+
+	prices := stubPrices{"BTC-USDT": 101}
+	ladder := NewTakeProfitLadder(ps, prices, 0)
+	if fills := ladder.CheckOnce(ctx); len(fills) != 0 {
+		t.Fatalf("未达到止盈价: %+v", fills)
+	}
+
+	// 本级别数量未知时不按交易所仓位平仓
+	prices["BTC-USDT"] = 102
+	market.price = 102
+	size := meta.Size
+	meta.Size = 0
+	if fills := ladder.CheckOnce(ctx); len(fills) != 0 || meta.Ladder[0].Filled {
+		t.Fatalf("数量未知时应跳过: %+v", fills)
+	}
+	meta.Size = size
+
+	// 达到 1R，平掉 40%
+	fills := ladder.CheckOnce(ctx)
+	if len(fills) != 1 || fills[0].R != 1 || !almostEqual(fills[0].Qty, total*0.4) || fills[0].OrderId == "" {
+		t.Fatalf("应平掉第一档: %+v", fills)
+	}
+	if !almostEqual(meta.Size, total*0.6) || !meta.Ladder[0].Filled {
+		t.Fatalf("本地仓位数量应扣减: %v", meta.Size)
+	}
+	if fills := ladder.CheckOnce(ctx); len(fills) != 0 {
+		t.Fatalf("同一档不应重复平仓: %+v", fills)
+	}
+
+	// 达到 2R，再平掉开仓数量的 30%，剩余 30% 作为底仓
+	prices["BTC-USDT"] = 103.5
+	market.price = 103.5
+	fills = ladder.CheckOnce(ctx)
+	if len(fills) != 1 || !almostEqual(fills[0].Qty, total*0.3) {
+		t.Fatalf("应平掉第二档: %+v", fills)
+	}
+	long, _, _ = ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	if !almostEqual(long.Amount, total*0.3) || !almostEqual(meta.Size, total*0.3) {
+		t.Fatalf("剩余底仓: %v %v", long.Amount, meta.Size)
+	}
+	if fills := ladder.CheckOnce(ctx); len(fills) != 0 {
+		t.Fatalf("档位全部成交后不再检查: %+v", fills)
+	}
+}
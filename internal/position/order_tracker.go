@@ -0,0 +1,280 @@
+package position
+
+import (
+	"context"
+	"edgeflow/internal/dao"
+	"edgeflow/internal/model"
+	"edgeflow/pkg/exchange"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 订单状态的来源
+const (
+	OrderSourcePlace = "place" // 下单
+	OrderSourcePoll  = "poll"  // 轮询 GetOrderStatus
+	OrderSourcePush  = "push"  // 私有频道推送
+)
+
+// 超过这个时长仍未结束的订单不再轮询，避免长期挂单一直占用请求
+const orderTrackMaxAge = 24 * time.Hour
+
+// OrderTracker 跟踪下单后的订单状态，记录成交数量、均价、手续费和状态变化，
+// 平仓单按开仓均价计算已实现盈亏，用于按策略统计收益
+type OrderTracker struct {
+	ex       exchange.Exchange
+	d        *dao.OrderDao
+	interval time.Duration
+
+	mu     sync.Mutex
+	orders map[string]*model.OrderTracking // 未结束的订单
+	now    func() time.Time
+
+	// 状态在 mu 下按顺序编号，保存在 mu 之外进行，同一个订单的保存由 orderSave 串行，
+	// 晚到的旧状态不会覆盖已经保存的新状态
+	seq   uint64
+	saves map[string]*orderSave
+}
+
+// orderSave 单个订单已经保存到数据库的状态编号
+type orderSave struct {
+	mu  sync.Mutex
+	seq uint64
+}
+
+// trackedSnapshot 待保存的订单状态
+type trackedSnapshot struct {
+	order model.OrderTracking
+	seq   uint64
+	save  *orderSave
+}
+
+func NewOrderTracker(ex exchange.Exchange, d *dao.OrderDao, interval time.Duration) *OrderTracker {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &OrderTracker{
+		ex:       ex,
+		d:        d,
+		interval: interval,
+		orders:   make(map[string]*model.OrderTracking),
+		now:      time.Now,
+		saves:    make(map[string]*orderSave),
+	}
+}
+
+// Restore 从数据库恢复未结束的订单，服务重启后继续跟踪
+func (t *OrderTracker) Restore(ctx context.Context) error {
+	if t.d == nil {
+		return nil
+	}
+	list, err := t.d.OrderTrackingGetActive(ctx)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range list {
+		o := list[i]
+		t.orders[o.OrderId] = &o
+	}
+	return nil
+}
+
+// Run 按间隔轮询未结束的订单，直到 ctx 结束
+func (t *OrderTracker) Run(ctx context.Context) {
+	if err := t.Restore(ctx); err != nil {
+		log.Printf("[OrderTracker] 恢复未结束订单失败: %v", err)
+	}
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.PollOnce(ctx)
+		}
+	}
+}
+
+// Consume 应用私有频道推送的订单状态，直到 ctx 结束或通道关闭。
+// 推送与轮询同时运行，状态没有变化的推送会被忽略
+func (t *OrderTracker) Consume(ctx context.Context, updates <-chan *model.OrderUpdate) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u, ok := <-updates:
+			if !ok {
+				return
+			}
+			t.Update(ctx, &u.OrderStatus, OrderSourcePush)
+		}
+	}
+}
+
+// Track 开始跟踪一个新下的订单，t 为空时不做任何事
+func (t *OrderTracker) Track(ctx context.Context, o *model.OrderTracking) {
+	if t == nil || o == nil || o.OrderId == "" {
+		return
+	}
+	now := t.now()
+	if o.Status == "" {
+		o.Status = model.OrderStatusPending
+	}
+	o.CreatedAt = now
+	o.UpdatedAt = now
+
+	t.mu.Lock()
+	t.orders[o.OrderId] = o
+	snapshot := t.snapshotLocked(o)
+	t.mu.Unlock()
+
+	t.persist(ctx, snapshot, OrderSourcePlace)
+}
+
+// Active 正在跟踪的订单，按下单时间排序
+func (t *OrderTracker) Active() []model.OrderTracking {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]model.OrderTracking, 0, len(t.orders))
+	for _, o := range t.orders {
+		out = append(out, *o)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out
+}
+
+// PollOnce 查询所有未结束订单的最新状态
+func (t *OrderTracker) PollOnce(ctx context.Context) {
+	for _, o := range t.Active() {
+		if t.now().Sub(o.CreatedAt) > orderTrackMaxAge {
+			log.Printf("[OrderTracker] %s %s 超过 %v 仍未结束，停止跟踪", o.Symbol, o.OrderId, orderTrackMaxAge)
+			t.mu.Lock()
+			delete(t.orders, o.OrderId)
+			delete(t.saves, o.OrderId)
+			t.mu.Unlock()
+			continue
+		}
+		st, err := t.ex.GetOrderStatus(o.OrderId, o.Symbol, o.TradeType)
+		if err != nil {
+			log.Printf("[OrderTracker] 查询 %s %s 订单状态失败: %v", o.Symbol, o.OrderId, err)
+			continue
+		}
+		t.Update(ctx, st, OrderSourcePoll)
+	}
+}
+
+// Update 应用交易所返回的订单状态，私有频道推送也通过这里更新。
+// 不是跟踪中的订单或状态没有变化时返回 false
+func (t *OrderTracker) Update(ctx context.Context, st *model.OrderStatus, source string) bool {
+	if t == nil || st == nil {
+		return false
+	}
+	t.mu.Lock()
+	o := t.orders[st.OrderID]
+	if o == nil || !orderChanged(o, st) {
+		t.mu.Unlock()
+		return false
+	}
+
+	now := t.now()
+	o.Status = st.Status
+	o.FilledQty = st.Filled
+	if st.AvgPrice > 0 {
+		o.AvgPrice = st.AvgPrice
+	}
+	o.Fee = st.Fee
+	if st.FeeCcy != "" {
+		o.FeeCcy = st.FeeCcy
+	}
+	if st.CtVal > 0 {
+		o.CtVal = st.CtVal
+	}
+	o.RealizedPnl = realizedPnl(o)
+	o.UpdatedAt = now
+	if o.Done() {
+		o.FinishedAt = &now
+	}
+	snapshot := t.snapshotLocked(o)
+	if o.Done() {
+		delete(t.orders, o.OrderId)
+		// 还没保存完的状态持有 orderSave，删除后不影响它们的先后判断
+		delete(t.saves, o.OrderId)
+	}
+	t.mu.Unlock()
+
+	so := &snapshot.order
+	log.Printf("[OrderTracker] %s %s %s %s: 成交 %.4f 均价 %.4f 手续费 %.4f", so.Symbol, so.Strategy, so.OrderId, so.Status, so.FilledQty, so.AvgPrice, so.Fee)
+	t.persist(ctx, snapshot, source)
+	return true
+}
+
+// snapshotLocked 拷贝一份待保存的订单状态并编号，调用前需要持有 mu
+func (t *OrderTracker) snapshotLocked(o *model.OrderTracking) *trackedSnapshot {
+	save := t.saves[o.OrderId]
+	if save == nil {
+		save = &orderSave{}
+		t.saves[o.OrderId] = save
+	}
+	t.seq++
+	return &trackedSnapshot{order: *o, seq: t.seq, save: save}
+}
+
+func orderChanged(o *model.OrderTracking, st *model.OrderStatus) bool {
+	return o.Status != st.Status || o.FilledQty != st.Filled || o.Fee != st.Fee ||
+		(st.AvgPrice > 0 && o.AvgPrice != st.AvgPrice)
+}
+
+// realizedPnl 已实现盈亏，手续费为负数直接累加；
+// 开仓单只有手续费，平仓单再加上相对开仓均价的价差收益
+func realizedPnl(o *model.OrderTracking) float64 {
+	pnl := o.Fee
+	if o.Role != model.OrderRoleClose || o.EntryPrice <= 0 || o.AvgPrice <= 0 {
+		return pnl
+	}
+	qty := o.FilledQty
+	if o.CtVal > 0 {
+		qty *= o.CtVal
+	}
+	gross := (o.AvgPrice - o.EntryPrice) * qty
+	if o.PosSide == model.OrderPosSideShort {
+		gross = -gross
+	}
+	return pnl + gross
+}
+
+// persist 保存订单状态和状态变化记录，比已保存状态旧的快照只写变化记录
+func (t *OrderTracker) persist(ctx context.Context, snapshot *trackedSnapshot, source string) {
+	if t.d == nil {
+		return
+	}
+	o := &snapshot.order
+	snapshot.save.mu.Lock()
+	if snapshot.seq > snapshot.save.seq {
+		if err := t.d.OrderTrackingSave(ctx, o); err != nil {
+			log.Printf("[OrderTracker] 保存订单 %s 失败: %v", o.OrderId, err)
+		} else {
+			snapshot.save.seq = snapshot.seq
+		}
+	}
+	snapshot.save.mu.Unlock()
+
+	history := &model.OrderStatusHistory{
+		OrderId:   o.OrderId,
+		Status:    o.Status,
+		FilledQty: o.FilledQty,
+		AvgPrice:  o.AvgPrice,
+		Fee:       o.Fee,
+		Source:    source,
+		CreatedAt: o.UpdatedAt,
+	}
+	if err := t.d.OrderStatusHistoryCreate(ctx, history); err != nil {
+		log.Printf("[OrderTracker] 保存订单 %s 状态记录失败: %v", o.OrderId, err)
+	}
+}
@@ -0,0 +1,122 @@
+package position
+
+import (
+	"context"
+	"edgeflow/internal/model"
+	"edgeflow/internal/signal"
+	"edgeflow/pkg/exchange"
+	"math"
+	"testing"
+
+	goexmodel "github.com/nntaoli-project/goex/v2/model"
+)
+
+// 固定价格的行情源
+type stubMarket struct {
+	price float64
+}
+
+func (m *stubMarket) GetLastPrice(symbol string, tradingType model.OrderTradeType) (float64, error) {
+	return m.price, nil
+}
+
+func (m *stubMarket) GetKlineRecords(symbol string, period goexmodel.KlinePeriod, size int, start, end int64, tradeType model.OrderTradeType, includeUnclosed bool) ([]model.Kline, error) {
+	return []model.Kline{{Close: m.price}}, nil
+}
+
+func TestOrderTrackerRealizedPnl(t *testing.T) {
+	ctx := context.Background()
+	market := &stubMarket{price: 100}
+	ex := exchange.NewPaperExchange(market, exchange.PaperConfig{Balance: 1000, TakerFeeRate: 0.001, DefaultLeverage: 10})
+	tracker := NewOrderTracker(ex, nil, 0)
+	ps := NewPositionService(ex, nil).WithTracker(tracker)
+
+	sig := signal.Signal{
+		Strategy:  "tv-level",
+		Symbol:    "BTC/USDT",
+		Side:      "buy",
+		OrderType: "market",
+		TradeType: string(model.OrderTradeSwap),
+		Level:     2,
+		Meta:      map[string]any{"signal_id": float64(7)},
+	}
+	if err := ps.ApplyAction(ctx, signal.ActOpen, sig, nil); err != nil {
+		t.Fatal(err)
+	}
+	active := tracker.Active()
+	if len(active) != 1 || active[0].Role != model.OrderRoleOpen || active[0].SignalID != 7 || active[0].PosSide != model.OrderPosSideLong {
+		t.Fatalf("开仓单应被跟踪: %+v", active)
+	}
+
+	tracker.PollOnce(ctx)
+	if len(tracker.Active()) != 0 {
+		t.Fatalf("市价单成交后应停止跟踪: %+v", tracker.Active())
+	}
+
+	market.price = 101
+	long, _, err := ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	if err != nil || long == nil {
+		t.Fatalf("应有多仓: %v %v", long, err)
+	}
+	if err := ps.ApplyAction(ctx, signal.ActClose, sig, long); err != nil {
+		t.Fatal(err)
+	}
+	closing := tracker.Active()
+	if len(closing) != 1 || closing[0].Role != model.OrderRoleClose || closing[0].EntryPrice != 100 {
+		t.Fatalf("平仓单应按开仓均价跟踪: %+v", closing)
+	}
+
+	// 推送的状态与轮询走同一入口，重复的状态不再记录
+	st, _ := ex.GetOrderStatus(closing[0].OrderId, "BTC/USDT", model.OrderTradeSwap)
+	if !tracker.Update(ctx, st, OrderSourcePush) {
+		t.Fatalf("平仓单状态应被更新")
+	}
+	if tracker.Update(ctx, st, OrderSourcePush) {
+		t.Fatalf("已结束的订单不应再更新")
+	}
+
+	o := &model.OrderTracking{
+		Role:       model.OrderRoleClose,
+		PosSide:    model.OrderPosSideLong,
+		EntryPrice: 100,
+		AvgPrice:   st.AvgPrice,
+		FilledQty:  st.Filled,
+		Fee:        st.Fee,
+	}
+	want := (101-100)*long.Amount - 101*long.Amount*0.001
+	if got := realizedPnl(o); math.Abs(got-want) > 1e-9 || st.Fee >= 0 {
+		t.Fatalf("已实现盈亏应为价差减手续费: got %v want %v fee %v", got, want, st.Fee)
+	}
+
+	// 空单、按张计价
+	short := &model.OrderTracking{Role: model.OrderRoleClose, PosSide: model.OrderPosSideShort, EntryPrice: 100, AvgPrice: 90, FilledQty: 3, CtVal: 0.1, Fee: -0.2}
+	if got := realizedPnl(short); math.Abs(got-2.8) > 1e-9 {
+		t.Fatalf("空单盈亏应按合约面值换算: %v", got)
+	}
+}
+
+func TestOrderTrackerConsume(t *testing.T) {
+	tracker := NewOrderTracker(nil, nil, 0)
+	ctx := context.Background()
+	tracker.Track(ctx, &model.OrderTracking{OrderId: "1001", Symbol: "SOL/USDT", Role: model.OrderRoleOpen})
+
+	updates := make(chan *model.OrderUpdate, 4)
+	// 不是跟踪中的订单忽略
+	updates <- &model.OrderUpdate{OrderStatus: model.OrderStatus{OrderID: "9999", Status: model.OrderStatusFilled}}
+	updates <- &model.OrderUpdate{OrderStatus: model.OrderStatus{OrderID: "1001", Status: model.OrderStatusPartFilled, Filled: 0.5, AvgPrice: 185.1, Fee: -0.02}}
+	close(updates)
+	tracker.Consume(ctx, updates)
+
+	active := tracker.Active()
+	if len(active) != 1 || active[0].Status != model.OrderStatusPartFilled || active[0].FilledQty != 0.5 || active[0].AvgPrice != 185.1 {
+		t.Fatalf("部分成交的推送应更新订单: %+v", active)
+	}
+
+	updates = make(chan *model.OrderUpdate, 1)
+	updates <- &model.OrderUpdate{OrderStatus: model.OrderStatus{OrderID: "1001", Status: model.OrderStatusFilled, Filled: 2, AvgPrice: 185.025, Fee: -0.08}}
+	close(updates)
+	tracker.Consume(ctx, updates)
+	if len(tracker.Active()) != 0 {
+		t.Fatalf("全部成交后应停止跟踪: %+v", tracker.Active())
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -18,18 +19,28 @@ import (
 
 // 本地仓位元信息（逻辑层面的补充）
 type LocalPositionMeta struct {
-	Symbol     string
-	Level      int    // 由哪个信号级别触发 (1/2/3)
-	Side       string // buy/sell
-	EntryPrice float64
-	Size       float64
-	OpenTime   time.Time
+	Symbol      string
+	Level       int    // 由哪个信号级别触发 (1/2/3)
+	Side        string // buy/sell
+	EntryPrice  float64
+	Size        float64
+	OpenTime    time.Time
+	TradeType   model.OrderTradeType // 交易类型，对账时按此查询交易所仓位
+	StopLoss    float64              // 当前止损价，移动止损和收紧止损修改后同步更新，为 0 表示未知
+	InitialStop float64              // 开仓时的止损价，移动止损按此计算 1R
+	Strategy    string
+	Ladder      []*LadderTier // 分批止盈，为空时使用开仓时的单一止盈价
 }
 
 // 仓位管理，统一的下单服务
 type PositionService struct {
 	Exchange exchange.Exchange
 	d        *dao.OrderDao
+	tracker  *OrderTracker  // 为空时不跟踪订单状态
+	sizer    *PositionSizer // 为空时按信号级别的固定比例下单
+	risk     *PortfolioRisk // 为空时不检查组合风控
+	ks       *signal.KillSwitch
+	entry    *EntryManager // 为空时限价单挂出后不再处理
 	mu       sync.Mutex
 	//metas    map[string]*LocalPositionMeta // 本地仓位信息，也是保存的okx端的真实仓位
 	metas map[string]map[int]*LocalPositionMeta // 本地仓位信息
@@ -43,6 +54,41 @@ func NewPositionService(ex exchange.Exchange, d *dao.OrderDao) *PositionService
 	}
 }
 
+// WithTracker 下单、平仓后交给订单跟踪器跟踪成交和手续费
+func (ps *PositionService) WithTracker(tracker *OrderTracker) *PositionService {
+	ps.tracker = tracker
+	return ps
+}
+
+// WithSizer 开仓数量按策略配置的仓位计算模型计算
+func (ps *PositionService) WithSizer(sizer *PositionSizer) *PositionService {
+	ps.sizer = sizer
+	return ps
+}
+
+// WithRisk 开仓前检查组合风控
+func (ps *PositionService) WithRisk(risk *PortfolioRisk) *PositionService {
+	ps.risk = risk
+	return ps
+}
+
+// WithKillSwitch 开仓前检查策略和交易对是否被暂停，平仓不受影响
+func (ps *PositionService) WithKillSwitch(ks *signal.KillSwitch) *PositionService {
+	ps.ks = ks
+	return ps
+}
+
+// WithEntryManager 限价开仓单按策略配置超时撤单、追价或改市价
+func (ps *PositionService) WithEntryManager(m *EntryManager) *PositionService {
+	ps.entry = m
+	return ps
+}
+
+// DailyR 当日盈亏折合多少个 R，没有组合风控时为 0
+func (ps *PositionService) DailyR() float64 {
+	return ps.risk.DailyR()
+}
+
 // 平仓
 func (ps *PositionService) CloseAll(ctx context.Context, symbol string, tradeType model.OrderTradeType) error {
 	if tradeType == "" {
@@ -65,9 +111,7 @@ func (ps *PositionService) CloseAll(ctx context.Context, symbol string, tradeTyp
 
 	for _, item := range positions {
 		// 平仓
-		log.Printf("平仓: %s %s %f", item.Symbol, item.Dir, item.Amount)
-		err = ps.Exchange.ClosePosition(item.Symbol, string(item.Dir), item.Amount, item.MgnMode, tradeType)
-		if err != nil {
+		if err = ps.closePosition(ctx, item, tradeType, signal.Signal{}); err != nil {
 			return err
 		}
 	}
@@ -75,8 +119,36 @@ func (ps *PositionService) CloseAll(ctx context.Context, symbol string, tradeTyp
 	return nil
 }
 
+// FlattenResult 单个交易对的清仓结果
+type FlattenResult struct {
+	Symbol    string               `json:"symbol"`
+	TradeType model.OrderTradeType `json:"trade_type"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// FlattenAll 平掉本地跟踪的交易对以及 symbols 中的全部仓位，单个交易对失败不影响其它交易对
+func (ps *PositionService) FlattenAll(ctx context.Context, symbols []string) []FlattenResult {
+	var results []FlattenResult
+	for _, t := range ps.targets(symbols) {
+		res := FlattenResult{Symbol: t.symbol, TradeType: t.tradeType}
+		if err := ps.CloseAll(ctx, t.symbol, t.tradeType); err != nil {
+			res.Error = err.Error()
+			log.Printf("[PositionService] 清仓 %s 失败: %v", t.symbol, err)
+		} else {
+			ps.ClearMeta(t.symbol)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
 // 平掉某个仓位
 func (ps *PositionService) Close(ctx context.Context, state *model.PositionInfo, tradeType model.OrderTradeType) error {
+	return ps.closeBySignal(ctx, state, tradeType, signal.Signal{})
+}
+
+// closeBySignal 平仓，平仓单关联到触发的信号和策略
+func (ps *PositionService) closeBySignal(ctx context.Context, state *model.PositionInfo, tradeType model.OrderTradeType, sig signal.Signal) error {
 	if tradeType == "" || state == nil {
 		return errors.New("未知平仓类型，不支持")
 	}
@@ -89,9 +161,7 @@ func (ps *PositionService) Close(ctx context.Context, state *model.PositionInfo,
 
 	for _, item := range positions {
 		// 平仓
-		log.Printf("平仓: %s %s %f", item.Symbol, item.Dir, item.Amount)
-		err := ps.Exchange.ClosePosition(item.Symbol, string(item.Dir), item.Amount, item.MgnMode, tradeType)
-		if err != nil {
+		if err := ps.closePosition(ctx, item, tradeType, sig); err != nil {
 			return err
 		}
 	}
@@ -99,8 +169,38 @@ func (ps *PositionService) Close(ctx context.Context, state *model.PositionInfo,
 	return nil
 }
 
+func (ps *PositionService) closePosition(ctx context.Context, item *model.PositionInfo, tradeType model.OrderTradeType, sig signal.Signal) error {
+	log.Printf("平仓: %s %s %f", item.Symbol, item.Dir, item.Amount)
+	resp, err := ps.Exchange.ClosePosition(item.Symbol, string(item.Dir), item.Amount, item.MgnMode, tradeType)
+	if err != nil {
+		return err
+	}
+	if resp != nil {
+		ps.tracker.Track(ctx, &model.OrderTracking{
+			OrderId:    resp.OrderId,
+			Symbol:     item.Symbol,
+			TradeType:  tradeType,
+			Strategy:   sig.Strategy,
+			SignalID:   sig.ID(),
+			Level:      sig.Level,
+			Role:       model.OrderRoleClose,
+			PosSide:    item.Dir,
+			EntryPrice: item.AvgPrice,
+		})
+	}
+	return nil
+}
+
 // 开仓或者加仓
 func (t *PositionService) Open(ctx context.Context, req signal.Signal, tpPercent, slPercent, quantityPct float64) error {
+	return t.open(ctx, signal.ActOpen, req, tpPercent, slPercent, quantityPct)
+}
+
+// open 同一个信号的同一个动作生成相同的 clOrdId，重复推送或者重试时交易所返回已有订单，不再重复记录仓位
+func (t *PositionService) open(ctx context.Context, action signal.Action, req signal.Signal, tpPercent, slPercent, quantityPct float64) error {
+	if err := t.ks.Check(req.Strategy, req.Symbol); err != nil {
+		return err
+	}
 	tradeType := model.OrderTradeType(req.TradeType)
 
 	var side model.OrderSide
@@ -128,30 +228,58 @@ func (t *PositionService) Open(ctx context.Context, req signal.Signal, tpPercent
 	tpPrice := computeTP(req.Side, req.Price, tpPercent)
 	slPrice := computeSL(req.Side, req.Price, slPercent)
 
-	// 根据信号级别和分数计算下单占仓位的比例
-	if quantityPct == 0 {
-		quantityPct = okx.CalculatePositionSize(req.Level)
+	order := model.Order{
+		Symbol:    req.Symbol,
+		Side:      side,
+		Price:     req.Price,
+		Quantity:  0,                              // 开多少数量由后端计算
+		OrderType: model.OrderType(req.OrderType), // "market" / "limit"
+		Strategy:  req.Strategy,
+		TPPrice:   tpPrice,
+		SLPrice:   slPrice,
+		TradeType: tradeType,
+		Comment:   req.Comment,
+		Leverage:  req.Leverage,
+		Level:     req.Level,
+		Timestamp: req.Timestamp,
+
+		ClientOrderId: model.NewClientOrderId(req.IdempotencyKey(), req.Strategy, action.String()),
 	}
 
-	if quantityPct <= 0 {
-		return errors.New("当前仓位占比不足以开仓")
+	// 分批止盈由 TakeProfitLadder 按价格平仓，不再挂单一止盈
+	if len(req.TPLadder) > 0 {
+		if err := validateLadder(req.TPLadder); err != nil {
+			return err
+		}
+		order.TPPrice = 0
 	}
 
-	order := model.Order{
-		Symbol:      req.Symbol,
-		Side:        side,
-		Price:       req.Price,
-		Quantity:    0,                              // 开多少数量由后端计算
-		OrderType:   model.OrderType(req.OrderType), // "market" / "limit"
-		Strategy:    req.Strategy,
-		TPPrice:     tpPrice,
-		SLPrice:     slPrice,
-		TradeType:   tradeType,
-		Comment:     req.Comment,
-		Leverage:    req.Leverage,
-		QuantityPct: quantityPct,
-		Level:       req.Level,
-		Timestamp:   req.Timestamp,
+	// 按策略配置的模型计算数量，level 模型和现货仍按比例由交易所计算
+	var notional float64
+	if t.sizer.Model(req.Strategy) != SizerLevel && tradeType != model.OrderTradeSpot {
+		sz, err := t.size(ctx, &order)
+		if err != nil {
+			return err
+		}
+		order.Quantity = sz.Quantity
+		order.SLPrice = sz.StopPrice
+		notional = sz.Notional
+		log.Printf("[PositionService] %s %s 仓位模型 %s: 数量 %v 名义价值 %.2f 风险 %.2f%% 止损 %v", order.Strategy, order.Symbol, sz.Model, sz.Quantity, sz.Notional, sz.RiskPct*100, sz.StopPrice)
+	} else {
+		// 根据信号级别和分数计算下单占仓位的比例
+		if quantityPct == 0 {
+			quantityPct = okx.CalculatePositionSize(req.Level)
+		}
+
+		if quantityPct <= 0 {
+			return errors.New("当前仓位占比不足以开仓")
+		}
+		order.QuantityPct = quantityPct
+	}
+
+	// 组合风控，按比例下单时数量未知，只检查已有敞口
+	if err := t.risk.CheckOpen(order.Symbol, notional); err != nil {
+		return err
 	}
 
 	// 检查是否有仓位
@@ -188,17 +316,125 @@ func (t *PositionService) Open(ctx context.Context, req signal.Signal, tpPercent
 	if err != nil {
 		return err
 	}
+	if resp.Duplicate {
+		log.Printf("[PositionService] %s %s 信号已经下过单 %s，忽略重复的%s", order.Strategy, order.Symbol, resp.OrderId, action)
+		return nil
+	}
 
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	// 保存本地元数据
-	t.saveMeta(order.Symbol, order.Level, string(order.Side), order.Price, order.Quantity)
+	prev := t.metas[order.Symbol][order.Level]
+	meta := t.saveMeta(order.Symbol, order.Level, string(order.Side), order.Price, order.Quantity, tradeType)
+	meta.StopLoss = order.SLPrice
+	meta.InitialStop = order.SLPrice
+	meta.Strategy = order.Strategy
+	if len(req.TPLadder) > 0 {
+		meta.Ladder = newLadder(side, order.Price, order.SLPrice, req.TPLadder)
+	} else if prev != nil && prev.Side == meta.Side {
+		// 加仓没有指定阶梯时沿用之前的阶梯，未成交的档位按新的仓位数量计算
+		meta.Ladder = prev.Ladder
+	}
+
+	posSide := model.OrderPosSide(model.OrderPosSideLong)
+	if side == model.Sell {
+		posSide = model.OrderPosSideShort
+	}
+	tracking := model.OrderTracking{
+		OrderId:   resp.OrderId,
+		Symbol:    order.Symbol,
+		TradeType: tradeType,
+		Strategy:  order.Strategy,
+		SignalID:  req.ID(),
+		Level:     order.Level,
+		Role:      model.OrderRoleOpen,
+		PosSide:   posSide,
+	}
+	t.tracker.Track(ctx, &tracking)
 
 	// 下单成功，保存订单
 	err = t.OrderCreateNew(ctx, order, resp.OrderId)
+	if t.entry.Enabled(order) {
+		// 信号处理的 ctx 在返回后取消，限价单在后台跟踪
+		go t.manageEntry(context.Background(), order, resp.OrderId, meta, tracking)
+	}
 	return err
 }
 
+// manageEntry 跟踪限价开仓单，结果写入订单记录，并按实际成交修正本地仓位
+func (t *PositionService) manageEntry(ctx context.Context, order model.Order, orderId string, meta *LocalPositionMeta, tracking model.OrderTracking) {
+	res := t.entry.Manage(ctx, order, orderId, func(next model.Order, nextId string) {
+		// 追价和改市价的新订单同样跟踪成交和手续费
+		tr := tracking
+		tr.OrderId = nextId
+		t.tracker.Track(ctx, &tr)
+	})
+	log.Printf("[PositionService] %s %s 限价单 %s 入场结果 %s(%s)，成交 %v 均价 %v，共下单 %d 次", order.Strategy, order.Symbol, orderId, res.Outcome, res.Reason, res.Filled, res.AvgPrice, res.Attempts)
+
+	if t.d != nil {
+		err := t.d.OrderRecordUpdateEntry(ctx, orderId, map[string]interface{}{
+			"entry_policy":    string(res.Policy),
+			"entry_outcome":   res.Outcome,
+			"entry_reason":    res.Reason,
+			"entry_attempts":  res.Attempts,
+			"entry_order_id":  res.OrderId,
+			"entry_filled":    res.Filled,
+			"entry_avg_price": res.AvgPrice,
+		})
+		if err != nil {
+			log.Printf("[PositionService] 保存订单 %s 入场结果失败: %v", orderId, err)
+		}
+	}
+
+	if res.Outcome == EntryFailed {
+		// 状态未知，交给对账修复
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// 期间已经被新的开仓或平仓替换
+	if t.metas[order.Symbol][order.Level] != meta {
+		return
+	}
+	if res.Filled <= 0 {
+		delete(t.metas[order.Symbol], order.Level)
+		return
+	}
+	meta.Size = res.Filled
+	meta.EntryPrice = res.AvgPrice
+}
+
+// size 查询账户权益和下单精度，计算开仓数量
+func (t *PositionService) size(ctx context.Context, order *model.Order) (Sizing, error) {
+	acc, err := t.Exchange.Account(order.TradeType)
+	if err != nil {
+		return Sizing{}, err
+	}
+	balance, err := acc.GetAccount(ctx, "USDT")
+	if err != nil {
+		return Sizing{}, err
+	}
+	var rules model.LotRules
+	if provider, ok := t.Exchange.(exchange.LotRulesProvider); ok {
+		r, err := provider.GetLotRules(order.Symbol, order.TradeType)
+		if err != nil {
+			return Sizing{}, err
+		}
+		rules = *r
+	}
+	return t.sizer.Size(ctx, SizingRequest{
+		Strategy:  order.Strategy,
+		Symbol:    order.Symbol,
+		Side:      order.Side,
+		Price:     order.Price,
+		StopPrice: order.SLPrice,
+		Equity:    balance.Total,
+		Available: balance.Available,
+		Leverage:  order.Leverage,
+		Rules:     rules,
+	})
+}
+
 // 获取仓位状态（交易所真实仓位+本地元信息）
 func (ps *PositionService) State(sig signal.Signal) (state *model.PositionInfo, meta *LocalPositionMeta, err error) {
 	long, short, err := ps.Exchange.GetPosition(sig.Symbol, model.OrderTradeType(sig.TradeType))
@@ -222,32 +458,72 @@ func (ps *PositionService) State(sig signal.Signal) (state *model.PositionInfo,
 	if long != nil {
 		state = long
 		if meta2 == nil {
-			ps.saveMeta(sig.Symbol, 2, string(model.Buy), state.AvgPrice, state.Amount)
+			ps.saveMeta(sig.Symbol, 2, string(model.Buy), state.AvgPrice, state.Amount, model.OrderTradeType(sig.TradeType))
 		}
 	}
 
 	if short != nil {
 		state = short
 		if meta2 == nil {
-			ps.saveMeta(sig.Symbol, 2, string(model.Sell), state.AvgPrice, state.Amount)
+			ps.saveMeta(sig.Symbol, 2, string(model.Sell), state.AvgPrice, state.Amount, model.OrderTradeType(sig.TradeType))
 		}
 	}
 
 	return
 }
 
-// 记录开仓的元信息（在下单成功后调用）
-func (ps *PositionService) saveMeta(symbol string, level int, side string, entry float64, size float64) {
-	m := make(map[int]*LocalPositionMeta)
-	m[level] = &LocalPositionMeta{
+// 记录开仓的元信息（在下单成功后调用），只替换当前级别，其它级别的仓位保留
+func (ps *PositionService) saveMeta(symbol string, level int, side string, entry float64, size float64, tradeType model.OrderTradeType) *LocalPositionMeta {
+	m, ok := ps.metas[symbol]
+	if !ok {
+		m = make(map[int]*LocalPositionMeta)
+		ps.metas[symbol] = m
+	}
+	meta := &LocalPositionMeta{
 		Symbol:     symbol,
 		Level:      level,
 		Side:       side,
 		EntryPrice: entry,
 		Size:       size,
 		OpenTime:   time.Now(),
+		TradeType:  tradeType,
+	}
+	m[level] = meta
+	return meta
+}
+
+// targets 本地有元信息的交易对，加上 symbols 中额外指定的交易对（使用默认交易类型）
+func (ps *PositionService) targets(symbols []string) []reconcileTarget {
+	seen := make(map[reconcileTarget]bool)
+	var targets []reconcileTarget
+	add := func(t reconcileTarget) {
+		if t.tradeType == "" {
+			t.tradeType = defaultReconcileTradeType
+		}
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+
+	ps.mu.Lock()
+	for symbol, levels := range ps.metas {
+		for _, meta := range levels {
+			add(reconcileTarget{symbol: symbol, tradeType: meta.TradeType})
+		}
+	}
+	ps.mu.Unlock()
+	for _, symbol := range symbols {
+		add(reconcileTarget{symbol: symbol})
 	}
-	ps.metas[symbol] = m
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].symbol != targets[j].symbol {
+			return targets[i].symbol < targets[j].symbol
+		}
+		return targets[i].tradeType < targets[j].tradeType
+	})
+	return targets
 }
 
 func (ps *PositionService) GetPositionByLevel(symbol string, level int) *LocalPositionMeta {
@@ -278,7 +554,7 @@ func (ps *PositionService) reducePosition(ctx context.Context, sig signal.Signal
 	}
 	reduceQty := state.Amount * 0.5 * 0.5 // 减半仓位
 	state.Amount = reduceQty
-	err := ps.Close(ctx, state, model.OrderTradeType(sig.TradeType))
+	err := ps.closeBySignal(ctx, state, model.OrderTradeType(sig.TradeType), sig)
 	return err
 }
 
@@ -292,9 +568,9 @@ func (ps *PositionService) ApplyAction(
 	case signal.ActIgnore:
 		return nil
 	case signal.ActOpen:
-		return ps.Open(ctx, sig, 1.3, 1.5, 0.21*0.6) // 开仓把资金控制小一些
+		return ps.open(ctx, action, sig, 1.3, 1.5, 0.21*0.6) // 开仓把资金控制小一些
 	case signal.ActAdd:
-		return ps.Open(ctx, sig, 1.1, 1.3, 0.18)
+		return ps.open(ctx, action, sig, 1.1, 1.3, 0.18)
 	case signal.ActReduce:
 		return ps.reducePosition(ctx, sig, state)
 
@@ -302,7 +578,7 @@ func (ps *PositionService) ApplyAction(
 		return ps.tightenStopLoss(ctx, sig.Symbol, sig, state)
 
 	case signal.ActClose:
-		err := ps.Close(ctx, state, model.OrderTradeType(sig.TradeType))
+		err := ps.closeBySignal(ctx, state, model.OrderTradeType(sig.TradeType), sig)
 		if err != nil {
 			// 清空本地仓位
 			ps.ClearMeta(sig.Symbol)
@@ -314,16 +590,92 @@ func (ps *PositionService) ApplyAction(
 	}
 }
 
-// 收紧止损
+// 收紧止损时锁定的浮盈比例
+const tightenLockProfitRatio = 0.8
+
+// 收紧止损：修改交易所的止损单，而不是直接下单。只会收紧，新的止损价同步写回本地元信息
 func (ps *PositionService) tightenStopLoss(ctx context.Context, symbol string, sig signal.Signal, state *model.PositionInfo) error {
-	// 更新止损价格，而不是直接下单
+	if state == nil || state.AlgoId == "" {
+		// 没有止损单的仓位无法修改
+		return nil
+	}
+	long := state.Dir == model.OrderPosSideLong
+	tradeType := model.OrderTradeType(sig.TradeType)
+	newSL := calcTighterSL(string(state.Dir), state.AvgPrice, sig.Price, tightenLockProfitRatio)
+	if newSL <= 0 {
+		return nil
+	}
+	newSL = ps.roundPrice(symbol, tradeType, newSL)
 
-	//newSL := calcTighterSL(sig.Side, sig.Price, state.AvgPrice,0.8)
-	//return ps.Exchange.AmendAlgoOrder(state.Symbol, sig.TradeType, state)
+	current := state.SlTriggerPx
+	if current <= 0 {
+		current = ps.currentStop(symbol, long)
+	}
+	if !tighter(long, newSL, current, 0) {
+		log.Printf("[PositionService] %s %s 当前止损 %v 已经比 %v 更紧，不修改", symbol, state.Dir, current, newSL)
+		return nil
+	}
+	if _, err := ps.Exchange.AmendAlgoOrder(state.Symbol, tradeType, state.AlgoId, newSL, -1); err != nil {
+		return err
+	}
+	log.Printf("[PositionService] %s %s 收紧止损 %v -> %v", symbol, state.Dir, current, newSL)
+	ps.updateStopLoss(symbol, long, newSL)
 	return nil
 }
 
+// currentStop 本地元信息中同方向最紧的止损价，没有时为 0
+func (ps *PositionService) currentStop(symbol string, long bool) float64 {
+	side := metaSide(long)
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	var stop float64
+	for _, meta := range ps.metas[symbol] {
+		if meta.Side != side || meta.StopLoss <= 0 {
+			continue
+		}
+		if stop == 0 || (long && meta.StopLoss > stop) || (!long && meta.StopLoss < stop) {
+			stop = meta.StopLoss
+		}
+	}
+	return stop
+}
+
+// updateStopLoss 交易所的止损修改后写回同方向的本地元信息
+func (ps *PositionService) updateStopLoss(symbol string, long bool, stop float64) {
+	side := metaSide(long)
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, meta := range ps.metas[symbol] {
+		if meta.Side == side {
+			meta.StopLoss = stop
+		}
+	}
+}
+
+// roundPrice 按交易对的价格精度取整，没有精度信息时不处理
+func (ps *PositionService) roundPrice(symbol string, tradeType model.OrderTradeType, price float64) float64 {
+	provider, ok := ps.Exchange.(exchange.LotRulesProvider)
+	if !ok {
+		return price
+	}
+	rules, err := provider.GetLotRules(symbol, tradeType)
+	if err != nil || rules == nil {
+		return price
+	}
+	return exchange.RoundToStep(price, rules.TickSize)
+}
+
+func metaSide(long bool) string {
+	if long {
+		return string(model.Buy)
+	}
+	return string(model.Sell)
+}
+
 func (r *PositionService) OrderCreateNew(ctx context.Context, order model.Order, orderId string) error {
+	if r.d == nil {
+		return nil
+	}
 
 	record := &model.OrderRecord{
 		OrderId:   orderId,
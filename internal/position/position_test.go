@@ -0,0 +1,86 @@
+package position
+
+import (
+	"context"
+	"edgeflow/internal/model"
+	"edgeflow/internal/signal"
+	"edgeflow/pkg/exchange"
+	"errors"
+	"testing"
+)
+
+func TestKillSwitchAndFlattenAll(t *testing.T) {
+	ctx := context.Background()
+	market := &stubMarket{price: 100}
+	ex := exchange.NewPaperExchange(market, exchange.PaperConfig{Balance: 1000, DefaultLeverage: 10})
+	ks := signal.NewKillSwitch(nil)
+	ps := NewPositionService(ex, nil).WithKillSwitch(ks)
+
+	sig := signal.Signal{
+		Strategy:  "tv-level",
+		Symbol:    "BTC/USDT",
+		Side:      "buy",
+		OrderType: "market",
+		TradeType: string(model.OrderTradeSwap),
+		Level:     2,
+	}
+	ks.Pause(ctx, signal.PauseSymbol, "BTC/USDT", "")
+	if err := ps.ApplyAction(ctx, signal.ActOpen, sig, nil); !errors.Is(err, signal.ErrTradingPaused) {
+		t.Fatalf("暂停的交易对不应开仓: %v", err)
+	}
+	ks.Resume(ctx, signal.PauseSymbol, "BTC/USDT")
+	if err := ps.ApplyAction(ctx, signal.ActOpen, sig, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 暂停后仍然可以清仓
+	ks.Pause(ctx, signal.PauseAll, "", "")
+	results := ps.FlattenAll(ctx, []string{"ETH/USDT"})
+	if len(results) != 2 || results[0].Symbol != "BTC/USDT" || results[0].Error != "" || results[1].Symbol != "ETH/USDT" {
+		t.Fatalf("清仓结果: %+v", results)
+	}
+	long, short, _ := ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	if long != nil || short != nil || ps.GetPositionByLevel("BTC/USDT", 2) != nil {
+		t.Fatalf("应平掉全部仓位: %+v %+v", long, short)
+	}
+}
+
+func TestOpenIdempotent(t *testing.T) {
+	ctx := context.Background()
+	market := &stubMarket{price: 100}
+	ex := exchange.NewPaperExchange(market, exchange.PaperConfig{Balance: 1000, DefaultLeverage: 10})
+	ps := NewPositionService(ex, nil)
+
+	sig := signal.Signal{
+		Strategy:  "tv-level",
+		Symbol:    "BTC/USDT",
+		Side:      "buy",
+		OrderType: "market",
+		TradeType: string(model.OrderTradeSwap),
+		Level:     2,
+		Meta:      map[string]any{"signal_id": float64(9)},
+	}
+	if err := ps.ApplyAction(ctx, signal.ActOpen, sig, nil); err != nil {
+		t.Fatal(err)
+	}
+	long, _, _ := ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	size := long.Amount
+
+	// 重复推送同一个信号，不再下单
+	if err := ps.ApplyAction(ctx, signal.ActOpen, sig, nil); err != nil {
+		t.Fatal(err)
+	}
+	long, _, _ = ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	if !almostEqual(long.Amount, size) || !almostEqual(ps.GetPositionByLevel("BTC/USDT", 2).Size, size) {
+		t.Fatalf("重复信号不应加仓: %v %v", long.Amount, size)
+	}
+
+	// 同一个信号的加仓是不同的动作
+	if err := ps.ApplyAction(ctx, signal.ActAdd, sig, nil); err != nil {
+		t.Fatal(err)
+	}
+	long, _, _ = ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	if long.Amount <= size {
+		t.Fatalf("加仓应下单: %v", long.Amount)
+	}
+}
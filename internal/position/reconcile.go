@@ -0,0 +1,401 @@
+package position
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	pb "edgeflow/pkg/protobuf"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 对账发现的差异类型
+const (
+	DiscrepancyStaleMeta    = "stale_meta"    // 本地有仓位，交易所没有（止盈止损触发、手动平仓）
+	DiscrepancyMissingMeta  = "missing_meta"  // 交易所有仓位，本地没有（手动开仓、重启后丢失）
+	DiscrepancySideMismatch = "side_mismatch" // 方向不一致
+	DiscrepancySizeMismatch = "size_mismatch" // 数量偏差超过容忍范围
+	DiscrepancyBothSides    = "both_sides"    // 交易所同时持有多空仓位，本地无法表示
+)
+
+// 对本地元信息的修复动作
+const (
+	RepairCleared = "cleared" // 清空本地元信息
+	RepairRebuilt = "rebuilt" // 按交易所仓位重建为 L2
+	RepairResized = "resized" // 按交易所数量修正 L2
+	RepairNone    = "none"    // 不做修改，等待人工处理
+)
+
+// 默认交易类型，本地元信息没有记录交易类型时使用
+const defaultReconcileTradeType = model.OrderTradeSwap
+
+// 保留最近的差异记录数量
+const discrepancyKeep = 200
+
+// AlertPublisher 发送提醒，由 service.AlertService 实现
+type AlertPublisher interface {
+	Publish(msg *pb.AlertMessage)
+}
+
+// Discrepancy 一次对账发现的差异
+type Discrepancy = model.PositionDiscrepancy
+
+// Reconciler 定期对比本地仓位元信息和交易所真实仓位，修复本地元信息并记录差异
+type Reconciler struct {
+	ps     *PositionService
+	alerts AlertPublisher
+	cfg    conf.ReconcileConfig
+
+	mu     sync.Mutex
+	recent []Discrepancy
+	now    func() time.Time
+}
+
+func NewReconciler(ps *PositionService, alerts AlertPublisher, cfg conf.ReconcileConfig) *Reconciler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	if cfg.SizeTolerance <= 0 {
+		cfg.SizeTolerance = 0.02
+	}
+	if cfg.AlertSizeDiff <= 0 {
+		cfg.AlertSizeDiff = 0.2
+	}
+	return &Reconciler{
+		ps:     ps,
+		alerts: alerts,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Run 按配置的间隔对账，直到 ctx 结束
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if found := r.ReconcileOnce(ctx); len(found) > 0 {
+			log.Printf("[Reconciler] 本轮发现 %d 处仓位差异", len(found))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Recent 最近发现的差异，最新的在前
+func (r *Reconciler) Recent() []Discrepancy {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Discrepancy, len(r.recent))
+	for i, d := range r.recent {
+		out[len(r.recent)-1-i] = d
+	}
+	return out
+}
+
+type reconcileTarget struct {
+	symbol    string
+	tradeType model.OrderTradeType
+}
+
+// ReconcileOnce 对所有跟踪的交易对做一次对账，返回本轮发现的差异
+func (r *Reconciler) ReconcileOnce(ctx context.Context) []Discrepancy {
+	var found []Discrepancy
+	for _, t := range r.targets() {
+		if d := r.check(ctx, t); d != nil {
+			found = append(found, *d)
+		}
+	}
+	return found
+}
+
+// Consume 私有频道推送仓位数量变化时立即对账该交易对，不用等下一轮定时对账，
+// 直到 ctx 结束或通道关闭。只处理正在对账的交易对，仓位仍然从交易所查询
+func (r *Reconciler) Consume(ctx context.Context, updates <-chan *model.PositionUpdate) {
+	amounts := make(map[string]float64) // instId/posSide -> 上次推送的数量
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u, ok := <-updates:
+			if !ok {
+				return
+			}
+			key := u.InstId + "/" + string(u.Dir)
+			if last, seen := amounts[key]; seen && last == u.Amount {
+				// 只有盈亏、标记价格变化
+				continue
+			}
+			amounts[key] = u.Amount
+			for _, t := range r.targets() {
+				if t.tradeType == u.TradeType && sameSymbol(t.symbol, u.Symbol) {
+					r.check(ctx, t)
+				}
+			}
+		}
+	}
+}
+
+// check 查询交易所仓位并对账单个交易对，一致时返回 nil
+func (r *Reconciler) check(ctx context.Context, t reconcileTarget) *Discrepancy {
+	r.ps.mu.Lock()
+	before := r.versionLocked(t)
+	r.ps.mu.Unlock()
+
+	long, short, err := r.ps.Exchange.GetPosition(t.symbol, t.tradeType)
+	if err != nil {
+		log.Printf("[Reconciler] 查询 %s %s 仓位失败: %v", t.symbol, t.tradeType, err)
+		return nil
+	}
+	d := r.reconcile(t, before, activePosition(long), activePosition(short))
+	if d != nil {
+		r.report(ctx, d)
+	}
+	return d
+}
+
+// sameSymbol BTC/USDT、BTC-USDT 和 BTC-USDT-SWAP 视为同一个交易对
+func sameSymbol(a, b string) bool {
+	norm := func(s string) string {
+		parts := strings.FieldsFunc(s, func(r rune) bool { return r == '/' || r == '-' })
+		if len(parts) > 2 {
+			parts = parts[:2]
+		}
+		return strings.Join(parts, "/")
+	}
+	return norm(a) == norm(b)
+}
+
+// targets 本地有元信息的交易对，加上配置中需要检查的交易对
+func (r *Reconciler) targets() []reconcileTarget {
+	return r.ps.targets(r.cfg.Symbols)
+}
+
+func activePosition(pos *model.PositionInfo) *model.PositionInfo {
+	if pos == nil || pos.Amount <= 0 {
+		return nil
+	}
+	return pos
+}
+
+// metaVersion 单个级别的本地元信息，开仓会替换元信息，入场和对账会修改数量
+type metaVersion struct {
+	meta *LocalPositionMeta
+	size float64
+}
+
+// versionLocked 对账目标的本地元信息快照，调用前需要持有 ps.mu
+func (r *Reconciler) versionLocked(t reconcileTarget) map[int]metaVersion {
+	v := make(map[int]metaVersion)
+	for level, meta := range r.ps.metas[t.symbol] {
+		if meta.TradeType != "" && meta.TradeType != t.tradeType {
+			continue
+		}
+		v[level] = metaVersion{meta: meta, size: meta.Size}
+	}
+	return v
+}
+
+func sameVersion(a, b map[int]metaVersion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for level, v := range a {
+		if b[level] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// reconcile 对比单个交易对，必要时修复本地元信息，一致时返回 nil。
+// before 为查询交易所之前的本地元信息，期间有开仓、平仓时交易所仓位已经过期，跳过本次对账
+func (r *Reconciler) reconcile(t reconcileTarget, before map[int]metaVersion, long, short *model.PositionInfo) *Discrepancy {
+	r.ps.mu.Lock()
+	defer r.ps.mu.Unlock()
+
+	if !sameVersion(before, r.versionLocked(t)) {
+		log.Printf("[Reconciler] %s %s 查询仓位期间本地仓位已变化，跳过本次对账", t.symbol, t.tradeType)
+		return nil
+	}
+
+	var localSide string
+	var localSize float64
+	var metas []*LocalPositionMeta
+	for _, meta := range r.ps.metas[t.symbol] {
+		if meta.TradeType != "" && meta.TradeType != t.tradeType {
+			continue
+		}
+		metas = append(metas, meta)
+		localSize += meta.Size
+		if localSide == "" || meta.Level == 2 {
+			localSide = meta.Side
+		}
+	}
+
+	d := &Discrepancy{
+		Symbol:    t.symbol,
+		TradeType: t.tradeType,
+		LocalSide: localSide,
+		LocalSize: localSize,
+		Action:    RepairNone,
+		CreatedAt: r.now(),
+	}
+
+	switch {
+	case long != nil && short != nil:
+		d.Kind = DiscrepancyBothSides
+		d.ExchangeSide = fmt.Sprintf("%s+%s", long.Dir, short.Dir)
+		d.ExchangeSize = long.Amount + short.Amount
+		return d
+
+	case long == nil && short == nil:
+		if len(metas) == 0 {
+			return nil
+		}
+		d.Kind = DiscrepancyStaleMeta
+		d.Action = RepairCleared
+		r.clearMetasLocked(t)
+		return d
+	}
+
+	pos := long
+	side := string(model.Buy)
+	if pos == nil {
+		pos = short
+		side = string(model.Sell)
+	}
+	d.ExchangeSide = string(pos.Dir)
+	d.ExchangeSize = pos.Amount
+
+	if len(metas) == 0 {
+		d.Kind = DiscrepancyMissingMeta
+		d.Action = RepairRebuilt
+		r.ps.saveMeta(t.symbol, 2, side, pos.AvgPrice, pos.Amount, t.tradeType)
+		return d
+	}
+
+	if localSide != side {
+		d.Kind = DiscrepancySideMismatch
+		d.Action = RepairRebuilt
+		r.clearMetasLocked(t)
+		r.ps.saveMeta(t.symbol, 2, side, pos.AvgPrice, pos.Amount, t.tradeType)
+		return d
+	}
+
+	// 下单时数量由交易所计算，本地记录的数量为 0，直接采用交易所的数量
+	if localSize <= 0 {
+		r.resizeLocked(t, side, pos)
+		return nil
+	}
+	if sizeDiff(localSize, pos.Amount) <= r.cfg.SizeTolerance {
+		return nil
+	}
+	d.Kind = DiscrepancySizeMismatch
+	d.Action = RepairResized
+	r.resizeLocked(t, side, pos)
+	return d
+}
+
+// resizeLocked 修正 L2 数量，使各级别数量之和等于交易所持仓
+func (r *Reconciler) resizeLocked(t reconcileTarget, side string, pos *model.PositionInfo) {
+	others := 0.0
+	for level, meta := range r.ps.metas[t.symbol] {
+		if level != 2 {
+			others += meta.Size
+		}
+	}
+	l2 := r.ps.metas[t.symbol][2]
+	if l2 == nil || others >= pos.Amount {
+		// 其它级别的数量已经超过交易所持仓，整体重建为 L2
+		r.clearMetasLocked(t)
+		r.ps.saveMeta(t.symbol, 2, side, pos.AvgPrice, pos.Amount, t.tradeType)
+		return
+	}
+	l2.Size = pos.Amount - others
+	l2.EntryPrice = pos.AvgPrice
+	if l2.TradeType == "" {
+		l2.TradeType = t.tradeType
+	}
+}
+
+func (r *Reconciler) clearMetasLocked(t reconcileTarget) {
+	levels := r.ps.metas[t.symbol]
+	for level, meta := range levels {
+		if meta.TradeType == "" || meta.TradeType == t.tradeType {
+			delete(levels, level)
+		}
+	}
+	if len(levels) == 0 {
+		delete(r.ps.metas, t.symbol)
+	}
+}
+
+// 数量相对偏差，以交易所数量为基准
+func sizeDiff(local, exchange float64) float64 {
+	if exchange <= 0 {
+		return math.Inf(1)
+	}
+	return math.Abs(local-exchange) / exchange
+}
+
+// shouldAlert 止盈止损触发导致的本地仓位过期属于正常情况，只记录不提醒
+func (r *Reconciler) shouldAlert(d *Discrepancy) bool {
+	switch d.Kind {
+	case DiscrepancyMissingMeta, DiscrepancySideMismatch, DiscrepancyBothSides:
+		return true
+	case DiscrepancySizeMismatch:
+		return sizeDiff(d.LocalSize, d.ExchangeSize) > r.cfg.AlertSizeDiff
+	}
+	return false
+}
+
+func (r *Reconciler) report(ctx context.Context, d *Discrepancy) {
+	if r.shouldAlert(d) && r.alerts != nil && r.cfg.AlertUserID != "" {
+		r.alerts.Publish(&pb.AlertMessage{
+			Id:        uuid.NewString(),
+			UserId:    r.cfg.AlertUserID,
+			Title:     fmt.Sprintf("%s 仓位对账异常", d.Symbol),
+			Content:   fmt.Sprintf("%s %s: 本地 %s %.4f，交易所 %s %.4f，处理: %s", d.Symbol, d.Kind, d.LocalSide, d.LocalSize, d.ExchangeSide, d.ExchangeSize, d.Action),
+			Symbol:    d.Symbol,
+			Level:     pb.AlertLevel_ALERT_LEVEL_WARNING,
+			AlertType: pb.AlertType_ALERT_TYPE_STRATEGY,
+			Timestamp: d.CreatedAt.UnixMilli(),
+			Extra: map[string]string{
+				"kind":          d.Kind,
+				"trade_type":    string(d.TradeType),
+				"local_side":    d.LocalSide,
+				"local_size":    fmt.Sprintf("%.8f", d.LocalSize),
+				"exchange_side": d.ExchangeSide,
+				"exchange_size": fmt.Sprintf("%.8f", d.ExchangeSize),
+				"action":        d.Action,
+			},
+		})
+		d.Alerted = true
+	}
+
+	log.Printf("[Reconciler] %s %s %s: 本地 %s %.4f，交易所 %s %.4f，处理: %s", d.Symbol, d.TradeType, d.Kind, d.LocalSide, d.LocalSize, d.ExchangeSide, d.ExchangeSize, d.Action)
+
+	r.mu.Lock()
+	r.recent = append(r.recent, *d)
+	if len(r.recent) > discrepancyKeep {
+		r.recent = r.recent[len(r.recent)-discrepancyKeep:]
+	}
+	r.mu.Unlock()
+
+	if r.ps.d == nil {
+		return
+	}
+	if err := r.ps.d.PositionDiscrepancyCreate(ctx, d); err != nil {
+		log.Printf("[Reconciler] 保存 %s 仓位差异失败: %v", d.Symbol, err)
+	}
+}
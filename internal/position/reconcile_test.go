@@ -0,0 +1,183 @@
+package position
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	"edgeflow/pkg/exchange"
+	pb "edgeflow/pkg/protobuf"
+	"testing"
+)
+
+// 只实现 GetPosition 的交易所
+type positionExchange struct {
+	exchange.Exchange
+	long, short map[string]*model.PositionInfo
+}
+
+func (e *positionExchange) GetPosition(symbol string, tradeType model.OrderTradeType) (*model.PositionInfo, *model.PositionInfo, error) {
+	return e.long[symbol], e.short[symbol], nil
+}
+
+type memAlerts struct {
+	msgs []*pb.AlertMessage
+}
+
+func (a *memAlerts) Publish(msg *pb.AlertMessage) {
+	a.msgs = append(a.msgs, msg)
+}
+
+func TestReconcileOnce(t *testing.T) {
+	ex := &positionExchange{
+		long: map[string]*model.PositionInfo{
+			"ETH/USDT":  {Symbol: "ETH/USDT", Dir: model.OrderPosSideLong, Amount: 10, AvgPrice: 3000},
+			"SOL/USDT":  {Symbol: "SOL/USDT", Dir: model.OrderPosSideLong, Amount: 5, AvgPrice: 150},
+			"DOGE/USDT": {Symbol: "DOGE/USDT", Dir: model.OrderPosSideLong, Amount: 100, AvgPrice: 0.2},
+		},
+		short: map[string]*model.PositionInfo{
+			"XRP/USDT": {Symbol: "XRP/USDT", Dir: model.OrderPosSideShort, Amount: 7, AvgPrice: 2},
+		},
+	}
+	ps := NewPositionService(ex, nil)
+	// 止盈止损已触发
+	ps.saveMeta("BTC/USDT", 2, "buy", 90000, 1, model.OrderTradeSwap)
+	// 数量偏差过大
+	ps.saveMeta("ETH/USDT", 2, "buy", 3000, 4, model.OrderTradeSwap)
+	ps.saveMeta("ETH/USDT", 3, "buy", 3100, 1, model.OrderTradeSwap)
+	// 下单时没有数量，直接采用交易所数量
+	ps.saveMeta("DOGE/USDT", 2, "buy", 0.2, 0, model.OrderTradeSwap)
+	// 方向相反
+	ps.saveMeta("XRP/USDT", 2, "buy", 2, 7, model.OrderTradeSwap)
+
+	alerts := &memAlerts{}
+	r := NewReconciler(ps, alerts, conf.ReconcileConfig{Symbols: []string{"SOL/USDT"}, AlertUserID: "ops"})
+	found := r.ReconcileOnce(context.Background())
+
+	kinds := make(map[string]Discrepancy)
+	for _, d := range found {
+		kinds[d.Symbol] = d
+	}
+	if len(found) != 4 {
+		t.Fatalf("应发现4处差异: %+v", found)
+	}
+	if d := kinds["BTC/USDT"]; d.Kind != DiscrepancyStaleMeta || d.Action != RepairCleared || d.Alerted {
+		t.Fatalf("BTC 应清空本地仓位且不提醒: %+v", d)
+	}
+	if d := kinds["ETH/USDT"]; d.Kind != DiscrepancySizeMismatch || d.LocalSize != 5 || !d.Alerted {
+		t.Fatalf("ETH 数量偏差应提醒: %+v", d)
+	}
+	if d := kinds["SOL/USDT"]; d.Kind != DiscrepancyMissingMeta || d.Action != RepairRebuilt || !d.Alerted {
+		t.Fatalf("SOL 手动开仓应重建并提醒: %+v", d)
+	}
+	if d := kinds["XRP/USDT"]; d.Kind != DiscrepancySideMismatch || d.ExchangeSide != "short" {
+		t.Fatalf("XRP 方向不一致: %+v", d)
+	}
+	if len(alerts.msgs) != 3 || alerts.msgs[0].AlertType != pb.AlertType_ALERT_TYPE_STRATEGY || alerts.msgs[0].UserId != "ops" {
+		t.Fatalf("应发送3条策略提醒: %+v", alerts.msgs)
+	}
+
+	// 修复后的本地元信息
+	if ps.GetPositionByLevel("BTC/USDT", 2) != nil {
+		t.Fatalf("BTC 本地仓位应被清空")
+	}
+	if m := ps.GetPositionByLevel("ETH/USDT", 2); m.Size != 9 || ps.GetPositionByLevel("ETH/USDT", 3).Size != 1 {
+		t.Fatalf("ETH L2 数量应修正为 9: %+v", m)
+	}
+	if m := ps.GetPositionByLevel("DOGE/USDT", 2); m.Size != 100 {
+		t.Fatalf("DOGE 应采用交易所数量: %+v", m)
+	}
+	if m := ps.GetPositionByLevel("XRP/USDT", 2); m.Side != "sell" || m.Size != 7 {
+		t.Fatalf("XRP 应按交易所仓位重建: %+v", m)
+	}
+
+	// 修复后再次对账应一致
+	if again := r.ReconcileOnce(context.Background()); len(again) != 0 {
+		t.Fatalf("修复后不应再有差异: %+v", again)
+	}
+	if len(r.Recent()) != 4 || r.Recent()[0].Symbol != found[len(found)-1].Symbol {
+		t.Fatalf("最近差异应按时间倒序: %+v", r.Recent())
+	}
+}
+
+func TestReconcilerConsume(t *testing.T) {
+	ex := &countingPositionExchange{positionExchange: positionExchange{
+		long:  map[string]*model.PositionInfo{"SOL/USDT": {Symbol: "SOL/USDT", Dir: model.OrderPosSideLong, Amount: 1.2, AvgPrice: 183.5}},
+		short: map[string]*model.PositionInfo{},
+	}}
+	ps := NewPositionService(ex, nil)
+	ps.saveMeta("SOL/USDT", 2, "buy", 183.5, 1.2, model.OrderTradeSwap)
+	r := NewReconciler(ps, nil, conf.ReconcileConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// 无缓冲，发送下一条时上一条已经处理完
+	updates := make(chan *model.PositionUpdate)
+	done := make(chan struct{})
+	go func() {
+		r.Consume(ctx, updates)
+		close(done)
+	}()
+
+	// 未对账的交易对、只有盈亏变化的推送都不查询交易所
+	updates <- &model.PositionUpdate{PositionInfo: model.PositionInfo{Symbol: "BTC/USDT", Dir: model.OrderPosSideShort, Amount: 0.5}, InstId: "BTC-USDT-SWAP", TradeType: model.OrderTradeSwap}
+	updates <- &model.PositionUpdate{PositionInfo: model.PositionInfo{Symbol: "SOL/USDT", Dir: model.OrderPosSideLong, Amount: 1.2}, InstId: "SOL-USDT-SWAP", TradeType: model.OrderTradeSwap}
+	updates <- &model.PositionUpdate{PositionInfo: model.PositionInfo{Symbol: "SOL/USDT", Dir: model.OrderPosSideLong, Amount: 1.2, UnrealizedPnl: "3.1"}, InstId: "SOL-USDT-SWAP", TradeType: model.OrderTradeSwap}
+	if ex.calls != 1 || len(r.Recent()) != 0 {
+		t.Fatalf("数量一致时不应有差异: calls=%d %+v", ex.calls, r.Recent())
+	}
+	// 止盈止损触发，交易所已经没有仓位
+	delete(ex.long, "SOL/USDT")
+	updates <- &model.PositionUpdate{PositionInfo: model.PositionInfo{Symbol: "SOL/USDT", Dir: model.OrderPosSideLong}, InstId: "SOL-USDT-SWAP", TradeType: model.OrderTradeSwap}
+	close(updates)
+	<-done
+	cancel()
+
+	if ex.calls != 2 {
+		t.Fatalf("应只对 SOL 数量变化对账2次: %d", ex.calls)
+	}
+	if ps.GetPositionByLevel("SOL/USDT", 2) != nil {
+		t.Fatalf("平仓推送后本地仓位应被清空")
+	}
+	if recent := r.Recent(); len(recent) != 1 || recent[0].Kind != DiscrepancyStaleMeta {
+		t.Fatalf("应记录一次过期仓位: %+v", recent)
+	}
+}
+
+// 记录 GetPosition 调用次数
+type countingPositionExchange struct {
+	positionExchange
+	calls int
+}
+
+func (e *countingPositionExchange) GetPosition(symbol string, tradeType model.OrderTradeType) (*model.PositionInfo, *model.PositionInfo, error) {
+	e.calls++
+	return e.positionExchange.GetPosition(symbol, tradeType)
+}
+
+// 查询仓位期间开仓的交易所
+type openingPositionExchange struct {
+	positionExchange
+	ps *PositionService
+}
+
+func (e *openingPositionExchange) GetPosition(symbol string, tradeType model.OrderTradeType) (*model.PositionInfo, *model.PositionInfo, error) {
+	long, short, err := e.positionExchange.GetPosition(symbol, tradeType)
+	e.ps.mu.Lock()
+	e.ps.saveMeta(symbol, 2, "buy", 3000, 1, tradeType)
+	e.ps.mu.Unlock()
+	return long, short, err
+}
+
+// 查询交易所仓位之后本地刚刚开仓，不能按查询结果清空新仓位
+func TestReconcileSkipsChangedMeta(t *testing.T) {
+	ex := &openingPositionExchange{}
+	ps := NewPositionService(ex, nil)
+	ex.ps = ps
+
+	r := NewReconciler(ps, nil, conf.ReconcileConfig{Symbols: []string{"ETH/USDT"}})
+	if found := r.ReconcileOnce(context.Background()); len(found) != 0 {
+		t.Fatalf("本地仓位变化后应跳过对账: %+v", found)
+	}
+	if m := ps.GetPositionByLevel("ETH/USDT", 2); m == nil || m.Size != 1 {
+		t.Fatalf("新开的仓位不应被清空: %+v", m)
+	}
+}
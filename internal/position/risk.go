@@ -0,0 +1,346 @@
+package position
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	pb "edgeflow/pkg/protobuf"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 组合风控触发原因
+const (
+	RiskGrossExposure = "risk:gross-exposure"
+	RiskNetExposure   = "risk:net-exposure"
+	RiskMaxPositions  = "risk:max-positions"
+	RiskSymbolLimit   = "risk:symbol-leverage"
+	RiskDailyDrawdown = "risk:daily-drawdown"
+)
+
+// ErrTradingHalted 组合风控已停止开仓
+var ErrTradingHalted = errors.New("组合风控已停止开仓")
+
+const defaultRiskUnit = 0.01
+
+// RealizedPnlProvider 提供按策略汇总的已实现盈亏，由 dao.OrderDao 实现
+type RealizedPnlProvider interface {
+	StrategyPnlGet(ctx context.Context, since time.Time) ([]model.StrategyPnl, error)
+}
+
+// RiskSnapshot 组合风控最近一次统计的结果
+type RiskSnapshot struct {
+	Day         time.Time           `json:"day"`          // 统计日的开始时间
+	StartEquity float64             `json:"start_equity"` // 日初权益
+	Equity      float64             `json:"equity"`
+	Realized    float64             `json:"realized"`   // 跟踪到的订单当日已实现盈亏，只用于按策略展示
+	Strategies  []model.StrategyPnl `json:"strategies"` // 按策略汇总的当日已实现盈亏
+	Unrealized  float64             `json:"unrealized"` // 当前未实现盈亏
+	DailyPnl    float64             `json:"daily_pnl"`  // 当日权益变化，包含手动交易、资金费和未跟踪订单
+	DailyR      float64             `json:"daily_r"`    // 当日盈亏折合多少个 R
+	Gross       float64             `json:"gross"`      // 多空名义价值之和
+	Net         float64             `json:"net"`        // 多头名义价值 - 空头名义价值
+	Positions   int                 `json:"positions"`  // 有仓位的交易对数量
+	Exposure    map[string]float64  `json:"exposure"`   // 交易对的名义价值，空头为负
+	Halted      bool                `json:"halted"`
+	HaltReason  string              `json:"halt_reason"`
+	HaltedAt    *time.Time          `json:"halted_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// PortfolioRisk 组合风控：统计当日盈亏和敞口，超过限制时停止开仓，可选平掉全部仓位并发送严重提醒。
+// 停止开仓后到第二天或者手动恢复前都不会再开仓
+type PortfolioRisk struct {
+	ps     *PositionService
+	pnl    RealizedPnlProvider
+	alerts AlertPublisher
+	cfg    conf.RiskConfig
+
+	mu   sync.Mutex
+	snap RiskSnapshot
+	now  func() time.Time
+}
+
+func NewPortfolioRisk(ps *PositionService, pnl RealizedPnlProvider, alerts AlertPublisher, cfg conf.RiskConfig) *PortfolioRisk {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.TradeType == "" {
+		cfg.TradeType = string(defaultReconcileTradeType)
+	}
+	if cfg.RiskUnit <= 0 {
+		cfg.RiskUnit = defaultRiskUnit
+	}
+	return &PortfolioRisk{
+		ps:     ps,
+		pnl:    pnl,
+		alerts: alerts,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Run 按配置的间隔刷新，直到 ctx 结束
+func (r *PortfolioRisk) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Refresh(ctx); err != nil {
+			log.Printf("[PortfolioRisk] 刷新失败: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Snapshot 最近一次统计的结果，r 为空时返回零值
+func (r *PortfolioRisk) Snapshot() RiskSnapshot {
+	if r == nil {
+		return RiskSnapshot{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshotLocked()
+}
+
+func (r *PortfolioRisk) snapshotLocked() RiskSnapshot {
+	out := r.snap
+	out.Exposure = make(map[string]float64, len(r.snap.Exposure))
+	for k, v := range r.snap.Exposure {
+		out.Exposure[k] = v
+	}
+	return out
+}
+
+// DailyR 当日盈亏折合多少个 R，r 为空时为 0
+func (r *PortfolioRisk) DailyR() float64 {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snap.DailyR
+}
+
+// Resume 手动恢复开仓
+func (r *PortfolioRisk) Resume() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.snap.Halted {
+		log.Printf("[PortfolioRisk] 手动恢复开仓，之前的原因: %s", r.snap.HaltReason)
+	}
+	r.snap.Halted = false
+	r.snap.HaltReason = ""
+	r.snap.HaltedAt = nil
+}
+
+// CheckOpen 开仓前检查，notional 为本次开仓的名义价值，未知时传 0 只检查已有敞口
+func (r *PortfolioRisk) CheckOpen(symbol string, notional float64) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.snap
+	if s.Halted {
+		return fmt.Errorf("%w: %s", ErrTradingHalted, s.HaltReason)
+	}
+	if s.Equity <= 0 {
+		// 还没有统计到权益，只检查是否停止开仓
+		return nil
+	}
+
+	_, held := s.Exposure[symbol]
+	if r.cfg.MaxPositions > 0 && !held && s.Positions >= r.cfg.MaxPositions {
+		return fmt.Errorf("%s: 已持有 %d 个交易对", RiskMaxPositions, s.Positions)
+	}
+	if r.cfg.MaxGrossExposure > 0 && (s.Gross+notional)/s.Equity > r.cfg.MaxGrossExposure {
+		return fmt.Errorf("%s: 总敞口 %.2f 将超过权益的 %.2f 倍", RiskGrossExposure, s.Gross+notional, r.cfg.MaxGrossExposure)
+	}
+	// 净敞口按开仓方向未知处理，只检查同方向加大敞口的最坏情况
+	if r.cfg.MaxNetExposure > 0 && (math.Abs(s.Net)+notional)/s.Equity > r.cfg.MaxNetExposure {
+		return fmt.Errorf("%s: 净敞口 %.2f 将超过权益的 %.2f 倍", RiskNetExposure, math.Abs(s.Net)+notional, r.cfg.MaxNetExposure)
+	}
+	if r.cfg.MaxSymbolLeverage > 0 && (math.Abs(s.Exposure[symbol])+notional)/s.Equity > r.cfg.MaxSymbolLeverage {
+		return fmt.Errorf("%s: %s 敞口将超过权益的 %.2f 倍", RiskSymbolLimit, symbol, r.cfg.MaxSymbolLeverage)
+	}
+	return nil
+}
+
+// Refresh 查询权益、仓位和当日已实现盈亏，超过限制时停止开仓
+func (r *PortfolioRisk) Refresh(ctx context.Context) (RiskSnapshot, error) {
+	tradeType := model.OrderTradeType(r.cfg.TradeType)
+	acc, err := r.ps.Exchange.Account(tradeType)
+	if err != nil {
+		return RiskSnapshot{}, err
+	}
+	balance, err := acc.GetAccount(ctx, "USDT")
+	if err != nil {
+		return RiskSnapshot{}, err
+	}
+
+	exposure := make(map[string]float64)
+	var gross, net, unrealized float64
+	for _, t := range r.ps.targets(r.cfg.Symbols) {
+		long, short, err := r.ps.Exchange.GetPosition(t.symbol, t.tradeType)
+		if err != nil {
+			return RiskSnapshot{}, fmt.Errorf("查询 %s 仓位失败: %w", t.symbol, err)
+		}
+		for _, pos := range []*model.PositionInfo{activePosition(long), activePosition(short)} {
+			if pos == nil {
+				continue
+			}
+			n := positionNotional(pos)
+			upl, _ := strconv.ParseFloat(pos.UnrealizedPnl, 64)
+			unrealized += upl
+			gross += n
+			if pos.Dir == model.OrderPosSideShort {
+				n = -n
+			}
+			net += n
+			exposure[t.symbol] += n
+		}
+	}
+
+	now := r.now()
+	day := startOfDay(now)
+	realized := 0.0
+	var strategies []model.StrategyPnl
+	if r.pnl != nil {
+		list, err := r.pnl.StrategyPnlGet(ctx, day)
+		if err != nil {
+			return RiskSnapshot{}, err
+		}
+		for _, p := range list {
+			realized += p.RealizedPnl
+		}
+		strategies = list
+	}
+
+	r.mu.Lock()
+	if !r.snap.Day.Equal(day) {
+		// 新的一天，重新记录日初权益，前一天的停止开仓自动恢复
+		r.snap = RiskSnapshot{Day: day, StartEquity: balance.Total}
+	}
+	s := &r.snap
+	s.Equity = balance.Total
+	s.Realized = realized
+	s.Strategies = strategies
+	s.Unrealized = unrealized
+	// 权益已经包含未实现盈亏，按日初权益计算，不依赖订单跟踪是否完整
+	s.DailyPnl = balance.Total - s.StartEquity
+	if s.StartEquity > 0 {
+		s.DailyR = s.DailyPnl / (s.StartEquity * r.cfg.RiskUnit)
+	}
+	s.Gross = gross
+	s.Net = net
+	s.Positions = len(exposure)
+	s.Exposure = exposure
+	s.UpdatedAt = now
+
+	reason := r.breachLocked()
+	halt := reason != "" && !s.Halted
+	if halt {
+		s.Halted = true
+		s.HaltReason = reason
+		s.HaltedAt = &now
+	}
+	snap := r.snapshotLocked()
+	r.mu.Unlock()
+
+	if halt {
+		r.halt(ctx, snap)
+	}
+	return snap, nil
+}
+
+// breachLocked 组合整体超过的限制，没有超过时返回空
+func (r *PortfolioRisk) breachLocked() string {
+	s := r.snap
+	if r.cfg.MaxDailyDrawdown > 0 && s.StartEquity > 0 && -s.DailyPnl/s.StartEquity >= r.cfg.MaxDailyDrawdown {
+		return RiskDailyDrawdown
+	}
+	if s.Equity <= 0 {
+		return ""
+	}
+	if r.cfg.MaxGrossExposure > 0 && s.Gross/s.Equity > r.cfg.MaxGrossExposure {
+		return RiskGrossExposure
+	}
+	if r.cfg.MaxNetExposure > 0 && math.Abs(s.Net)/s.Equity > r.cfg.MaxNetExposure {
+		return RiskNetExposure
+	}
+	if r.cfg.MaxPositions > 0 && s.Positions > r.cfg.MaxPositions {
+		return RiskMaxPositions
+	}
+	if r.cfg.MaxSymbolLeverage > 0 {
+		for _, n := range s.Exposure {
+			if math.Abs(n)/s.Equity > r.cfg.MaxSymbolLeverage {
+				return RiskSymbolLimit
+			}
+		}
+	}
+	return ""
+}
+
+// halt 停止开仓后发送严重提醒，按配置平掉全部仓位
+func (r *PortfolioRisk) halt(ctx context.Context, s RiskSnapshot) {
+	content := fmt.Sprintf("%s: 权益 %.2f，当日盈亏 %.2f (%.2fR)，总敞口 %.2f，净敞口 %.2f，持仓 %d 个",
+		s.HaltReason, s.Equity, s.DailyPnl, s.DailyR, s.Gross, s.Net, s.Positions)
+	log.Printf("[PortfolioRisk] 停止开仓 %s", content)
+
+	flattened := false
+	if r.cfg.FlattenOnBreach {
+		flattened = true
+		for _, res := range r.ps.FlattenAll(ctx, r.cfg.Symbols) {
+			if res.Error != "" {
+				flattened = false
+			}
+		}
+	}
+
+	if r.alerts == nil || r.cfg.AlertUserID == "" {
+		return
+	}
+	r.alerts.Publish(&pb.AlertMessage{
+		Id:        uuid.NewString(),
+		UserId:    r.cfg.AlertUserID,
+		Title:     "组合风控已停止开仓",
+		Content:   content,
+		Level:     pb.AlertLevel_ALERT_LEVEL_CRITICAL,
+		AlertType: pb.AlertType_ALERT_TYPE_STRATEGY,
+		Timestamp: s.UpdatedAt.UnixMilli(),
+		Extra: map[string]string{
+			"reason":    s.HaltReason,
+			"equity":    fmt.Sprintf("%.8f", s.Equity),
+			"daily_pnl": fmt.Sprintf("%.8f", s.DailyPnl),
+			"daily_r":   fmt.Sprintf("%.4f", s.DailyR),
+			"flattened": strconv.FormatBool(flattened),
+		},
+	})
+}
+
+// positionNotional 仓位名义价值，交易所没有返回时按均价估算
+func positionNotional(pos *model.PositionInfo) float64 {
+	if n, err := strconv.ParseFloat(pos.NotionalUsd, 64); err == nil && n != 0 {
+		return math.Abs(n)
+	}
+	return pos.Amount * pos.AvgPrice
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,156 @@
+package position
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	"edgeflow/pkg/account"
+	"edgeflow/pkg/exchange"
+	pb "edgeflow/pkg/protobuf"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 在 positionExchange 的基础上提供权益和市价平仓
+type riskExchange struct {
+	*positionExchange
+	equity float64
+	closed []string
+}
+
+func (e *riskExchange) Account(tradeType model.OrderTradeType) (exchange.Account, error) {
+	return e, nil
+}
+
+func (e *riskExchange) GetAccount(ctx context.Context, coin string) (*account.Account, error) {
+	return &account.Account{Currency: coin, Total: e.equity, Available: e.equity}, nil
+}
+
+func (e *riskExchange) ClosePosition(symbol string, side string, quantity float64, tdMode string, tradeType model.OrderTradeType) (*model.OrderResponse, error) {
+	e.closed = append(e.closed, symbol+":"+side)
+	if side == string(model.OrderPosSideLong) {
+		delete(e.long, symbol)
+	} else {
+		delete(e.short, symbol)
+	}
+	return &model.OrderResponse{OrderId: "close-" + symbol}, nil
+}
+
+type stubPnl float64
+
+func (p *stubPnl) StrategyPnlGet(ctx context.Context, since time.Time) ([]model.StrategyPnl, error) {
+	return []model.StrategyPnl{{Strategy: "tv-level", RealizedPnl: float64(*p)}}, nil
+}
+
+func TestPortfolioRisk(t *testing.T) {
+	ctx := context.Background()
+	ex := &riskExchange{
+		positionExchange: &positionExchange{
+			long: map[string]*model.PositionInfo{
+				"ETH/USDT": {Symbol: "ETH/USDT", Dir: model.OrderPosSideLong, Amount: 10, AvgPrice: 3000, UnrealizedPnl: "0"},
+			},
+			short: map[string]*model.PositionInfo{},
+		},
+		equity: 10000,
+	}
+	ps := NewPositionService(ex, nil)
+	alerts := &memAlerts{}
+	pnl := stubPnl(0)
+	r := NewPortfolioRisk(ps, &pnl, alerts, conf.RiskConfig{
+		Symbols:           []string{"ETH/USDT", "SOL/USDT"},
+		MaxGrossExposure:  5,
+		MaxPositions:      2,
+		MaxSymbolLeverage: 4,
+		MaxDailyDrawdown:  0.05,
+		FlattenOnBreach:   true,
+		AlertUserID:       "ops",
+	})
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	ps.WithRisk(r)
+
+	s, err := r.Refresh(ctx)
+	if err != nil || s.Gross != 30000 || s.Net != 30000 || s.Positions != 1 || s.StartEquity != 10000 {
+		t.Fatalf("统计敞口: %+v %v", s, err)
+	}
+
+	// 单个交易对敞口 (30000+15000)/10000 超过 4 倍
+	if err := r.CheckOpen("ETH/USDT", 15000); err == nil || !strings.HasPrefix(err.Error(), RiskSymbolLimit) {
+		t.Fatalf("应超过单个交易对杠杆: %v", err)
+	}
+	if err := r.CheckOpen("BTC/USDT", 5000); err != nil {
+		t.Fatalf("未超过限制应允许开仓: %v", err)
+	}
+	// 总敞口 (30000+25000)/10000 超过 5 倍
+	if err := r.CheckOpen("BTC/USDT", 25000); err == nil || !strings.HasPrefix(err.Error(), RiskGrossExposure) {
+		t.Fatalf("应超过总敞口: %v", err)
+	}
+
+	// 空头抵消净敞口，持仓数量达到上限
+	ex.short["SOL/USDT"] = &model.PositionInfo{Symbol: "SOL/USDT", Dir: model.OrderPosSideShort, Amount: 100, AvgPrice: 150, NotionalUsd: "15000"}
+	s, _ = r.Refresh(ctx)
+	if s.Net != 15000 || s.Exposure["SOL/USDT"] != -15000 || s.Halted {
+		t.Fatalf("净敞口: %+v", s)
+	}
+	if err := r.CheckOpen("BTC/USDT", 0); err == nil || !strings.HasPrefix(err.Error(), RiskMaxPositions) {
+		t.Fatalf("应超过最大持仓数量: %v", err)
+	}
+	if err := r.CheckOpen("SOL/USDT", 0); err != nil {
+		t.Fatalf("已持有的交易对加仓不受持仓数量限制: %v", err)
+	}
+
+	// 权益减少 600，超过日初权益的 5%；跟踪到的已实现盈亏只用于按策略展示
+	pnl = -400
+	ex.long["ETH/USDT"].UnrealizedPnl = "-200"
+	ex.equity = 9400
+	now = now.Add(time.Hour)
+	s, _ = r.Refresh(ctx)
+	if !s.Halted || s.HaltReason != RiskDailyDrawdown || !almostEqual(s.DailyPnl, -600) || !almostEqual(s.DailyR, -6) {
+		t.Fatalf("应触发当日亏损限制: %+v", s)
+	}
+	if s.Realized != -400 || len(s.Strategies) != 1 || s.Strategies[0].Strategy != "tv-level" {
+		t.Fatalf("按策略的已实现盈亏: %+v", s)
+	}
+	if !almostEqual(ps.DailyR(), -6) {
+		t.Fatalf("DailyR: %v", ps.DailyR())
+	}
+	if len(ex.closed) != 2 || len(ex.long)+len(ex.short) != 0 {
+		t.Fatalf("应平掉全部仓位: %v", ex.closed)
+	}
+	if len(alerts.msgs) != 1 || alerts.msgs[0].Level != pb.AlertLevel_ALERT_LEVEL_CRITICAL || alerts.msgs[0].Extra["flattened"] != "true" {
+		t.Fatalf("应发送一条严重提醒: %+v", alerts.msgs)
+	}
+	if err := r.CheckOpen("BTC/USDT", 0); !errors.Is(err, ErrTradingHalted) {
+		t.Fatalf("停止后不应开仓: %v", err)
+	}
+	// 再次刷新不重复提醒和平仓
+	r.Refresh(ctx)
+	if len(alerts.msgs) != 1 {
+		t.Fatalf("不应重复提醒: %d", len(alerts.msgs))
+	}
+
+	// 手动恢复
+	r.Resume()
+	if err := r.CheckOpen("BTC/USDT", 0); err != nil {
+		t.Fatalf("恢复后应允许开仓: %v", err)
+	}
+
+	// 第二天重新统计，之前的停止开仓自动恢复
+	r.mu.Lock()
+	r.snap.Halted = true
+	r.mu.Unlock()
+	pnl = 0
+	now = now.Add(24 * time.Hour)
+	s, _ = r.Refresh(ctx)
+	if s.Halted || s.StartEquity != 9400 || s.DailyPnl != 0 {
+		t.Fatalf("新的一天应重新统计: %+v", s)
+	}
+
+	// 为空时不限制
+	var nilRisk *PortfolioRisk
+	if nilRisk.CheckOpen("BTC/USDT", 1e9) != nil || nilRisk.DailyR() != 0 {
+		t.Fatalf("风控为空时不应限制")
+	}
+}
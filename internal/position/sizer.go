@@ -0,0 +1,227 @@
+package position
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	"edgeflow/internal/trend"
+	"edgeflow/pkg/exchange"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// 仓位计算模型
+const (
+	SizerLevel = "level" // 按信号级别的固定比例，由交易所按可用余额计算数量
+	SizerFixed = "fixed" // 固定风险比例，止损距离按止损百分比计算
+	SizerATR   = "atr"   // 固定风险比例，止损距离 = ATR * 倍数
+	SizerKelly = "kelly" // 按历史胜率和盈亏比计算风险比例
+)
+
+// 参数没有配置时的默认值
+const (
+	defaultRiskPct       = 0.01
+	defaultAtrMultiple   = 1.5
+	defaultKellyFraction = 0.5
+	defaultKellyCap      = 0.02
+	defaultKellyTrades   = 20
+	defaultLeverage      = 20                  // 与 OkxSwap.PlaceOrder 的默认杠杆一致
+	kellyLookback        = 90 * 24 * time.Hour // 凯利模型统计的历史区间
+	marginTolerance      = 0.98                // 与交易所下单一致，留出价差的余量
+)
+
+// ErrNoEdge 凯利比例不为正，历史上没有优势，不开仓
+var ErrNoEdge = errors.New("策略历史胜率和盈亏比没有优势，不开仓")
+
+// TrendStateProvider 提供趋势状态中的 ATR，由 trend.Manager 实现
+type TrendStateProvider interface {
+	GetState(symbol string) *trend.TrendState
+}
+
+// WinStatsProvider 提供策略的历史胜负统计，由 dao.OrderDao 实现
+type WinStatsProvider interface {
+	StrategyWinStats(ctx context.Context, strategy string, since time.Time) (model.WinStats, error)
+}
+
+// SizingRequest 计算一笔开仓的数量需要的信息
+type SizingRequest struct {
+	Strategy  string
+	Symbol    string
+	Side      model.OrderSide
+	Price     float64 // 入场价
+	StopPrice float64 // 按止损百分比计算的止损价
+	Equity    float64 // 账户权益（USDT）
+	Available float64 // 可用保证金（USDT）
+	Leverage  int
+	Rules     model.LotRules
+}
+
+// Sizing 仓位计算结果，level 模型只返回 Model，数量仍由交易所按比例计算
+type Sizing struct {
+	Model     string
+	Quantity  float64 // 下单数量，单位与 Rules 一致
+	StopPrice float64 // 止损价，atr 模型为 ATR 止损
+	Notional  float64 // 名义价值（USDT）
+	RiskPct   float64 // 本笔止损时亏损占权益的比例（取整前）
+}
+
+// PositionSizer 按策略配置选择仓位计算模型
+type PositionSizer struct {
+	cfg    conf.SizingConfig
+	states TrendStateProvider
+	stats  WinStatsProvider
+	now    func() time.Time
+}
+
+func NewPositionSizer(cfg conf.SizingConfig, states TrendStateProvider, stats WinStatsProvider) *PositionSizer {
+	return &PositionSizer{
+		cfg:    cfg,
+		states: states,
+		stats:  stats,
+		now:    time.Now,
+	}
+}
+
+// Model 策略使用的仓位计算模型
+func (s *PositionSizer) Model(strategy string) string {
+	if s == nil {
+		return SizerLevel
+	}
+	switch m := s.cfg.For(strategy).Model; m {
+	case SizerFixed, SizerATR, SizerKelly:
+		return m
+	}
+	return SizerLevel
+}
+
+// Size 计算开仓数量，数量按交易对的步长向下取整，不足最小下单量时返回错误
+func (s *PositionSizer) Size(ctx context.Context, req SizingRequest) (Sizing, error) {
+	res := Sizing{Model: s.Model(req.Strategy), StopPrice: req.StopPrice}
+	if res.Model == SizerLevel {
+		return res, nil
+	}
+	cfg := s.cfg.For(req.Strategy)
+	if req.Price <= 0 || req.Equity <= 0 {
+		return res, fmt.Errorf("无法计算仓位: price=%v equity=%v", req.Price, req.Equity)
+	}
+
+	riskPct := cfg.RiskPct
+	if riskPct <= 0 {
+		riskPct = defaultRiskPct
+	}
+
+	switch res.Model {
+	case SizerATR:
+		res.StopPrice = s.atrStop(req, cfg)
+	case SizerKelly:
+		pct, err := s.kellyRisk(ctx, req.Strategy, cfg, riskPct)
+		if err != nil {
+			return res, err
+		}
+		riskPct = pct
+	}
+	res.StopPrice = exchange.RoundToStep(res.StopPrice, req.Rules.TickSize)
+
+	distance := math.Abs(req.Price - res.StopPrice)
+	if res.StopPrice <= 0 || distance == 0 {
+		return res, fmt.Errorf("止损价 %v 无效，无法计算仓位", res.StopPrice)
+	}
+
+	// 止损时亏损 = 数量 * 止损距离 = 权益 * 风险比例
+	qty := req.Equity * riskPct / distance
+	notional := qty * req.Price
+	if limit := notionalLimit(req, cfg); notional > limit {
+		log.Printf("[PositionSizer] %s %s 名义价值 %.2f 超过上限 %.2f，按上限下单", req.Strategy, req.Symbol, notional, limit)
+		notional = limit
+		qty = notional / req.Price
+	}
+	res.RiskPct = qty * distance / req.Equity
+
+	if req.Rules.CtVal > 0 {
+		qty /= req.Rules.CtVal
+	}
+	qty = exchange.FloorToStep(qty, req.Rules.LotSize)
+	if qty <= 0 || qty < req.Rules.MinQty {
+		return res, fmt.Errorf("%s 计算数量 %v 小于最小下单量 %v", req.Symbol, qty, req.Rules.MinQty)
+	}
+	res.Quantity = qty
+	res.Notional = notional
+	if req.Rules.CtVal > 0 {
+		res.Notional = qty * req.Rules.CtVal * req.Price
+	}
+	return res, nil
+}
+
+// atrStop ATR 止损价，没有 ATR 时退回按百分比计算的止损价
+func (s *PositionSizer) atrStop(req SizingRequest, cfg conf.SizerConfig) float64 {
+	var atr float64
+	if s.states != nil {
+		if st := s.states.GetState(req.Symbol); st != nil {
+			atr = st.ATR
+		}
+	}
+	if atr <= 0 {
+		log.Printf("[PositionSizer] %s 没有 ATR，使用默认止损价 %v", req.Symbol, req.StopPrice)
+		return req.StopPrice
+	}
+	mult := cfg.AtrMultiple
+	if mult <= 0 {
+		mult = defaultAtrMultiple
+	}
+	if req.Side == model.Sell {
+		return req.Price + atr*mult
+	}
+	return req.Price - atr*mult
+}
+
+// kellyRisk 凯利比例 f = W - (1-W)/R，打折后不超过上限；历史样本不足时使用 fallback
+func (s *PositionSizer) kellyRisk(ctx context.Context, strategy string, cfg conf.SizerConfig, fallback float64) (float64, error) {
+	if s.stats == nil {
+		return fallback, nil
+	}
+	stats, err := s.stats.StrategyWinStats(ctx, strategy, s.now().Add(-kellyLookback))
+	if err != nil {
+		return 0, err
+	}
+	minTrades := cfg.MinTrades
+	if minTrades <= 0 {
+		minTrades = defaultKellyTrades
+	}
+	if stats.Trades < int64(minTrades) || stats.AvgLoss <= 0 {
+		return fallback, nil
+	}
+
+	win := float64(stats.Wins) / float64(stats.Trades)
+	f := win - (1-win)/(stats.AvgWin/stats.AvgLoss)
+	if f <= 0 {
+		return 0, ErrNoEdge
+	}
+	fraction := cfg.KellyFraction
+	if fraction <= 0 {
+		fraction = defaultKellyFraction
+	}
+	limit := cfg.KellyCap
+	if limit <= 0 {
+		limit = defaultKellyCap
+	}
+	return math.Min(f*fraction, limit), nil
+}
+
+// notionalLimit 单笔名义价值上限，取配置上限和可用保证金能开的最大仓位中较小的
+func notionalLimit(req SizingRequest, cfg conf.SizerConfig) float64 {
+	leverage := req.Leverage
+	if leverage <= 0 {
+		leverage = defaultLeverage
+	}
+	limit := req.Available * float64(leverage) * marginTolerance
+	if cfg.MaxNotional > 0 {
+		limit = math.Min(limit, cfg.MaxNotional)
+	}
+	if cfg.MaxNotionalPct > 0 {
+		limit = math.Min(limit, req.Equity*cfg.MaxNotionalPct)
+	}
+	return limit
+}
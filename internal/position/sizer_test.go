@@ -0,0 +1,114 @@
+package position
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	"edgeflow/internal/trend"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+type stubStates map[string]*trend.TrendState
+
+func (s stubStates) GetState(symbol string) *trend.TrendState {
+	return s[symbol]
+}
+
+type stubWinStats model.WinStats
+
+func (s stubWinStats) StrategyWinStats(ctx context.Context, strategy string, since time.Time) (model.WinStats, error) {
+	return model.WinStats(s), nil
+}
+
+func TestPositionSizer(t *testing.T) {
+	ctx := context.Background()
+	cfg := conf.SizingConfig{
+		Default: conf.SizerConfig{Model: SizerLevel},
+		Strategies: map[string]conf.SizerConfig{
+			"fixed": {Model: SizerFixed, RiskPct: 0.01},
+			"atr":   {Model: SizerATR, RiskPct: 0.01, AtrMultiple: 2, MaxNotional: 5000},
+			"kelly": {Model: SizerKelly, RiskPct: 0.01, MinTrades: 10, KellyCap: 0.03},
+		},
+	}
+	states := stubStates{"BTC/USDT": {ATR: 100}}
+	req := SizingRequest{
+		Symbol:    "BTC/USDT",
+		Side:      model.Buy,
+		Price:     10000,
+		StopPrice: 9800,
+		Equity:    10000,
+		Available: 10000,
+		Leverage:  10,
+		// OKX BTC 永续：每张 0.01 BTC，数量精度 0.01 张
+		Rules: model.LotRules{LotSize: 0.01, TickSize: 0.1, MinQty: 0.01, CtVal: 0.01},
+	}
+
+	sizer := NewPositionSizer(cfg, states, stubWinStats{})
+	if m := sizer.Model("unknown"); m != SizerLevel {
+		t.Fatalf("没有配置的策略应使用 level: %s", m)
+	}
+
+	// 风险 100U，止损距离 200，数量 0.5 BTC = 50 张
+	req.Strategy = "fixed"
+	sz, err := sizer.Size(ctx, req)
+	if err != nil || !almostEqual(sz.Quantity, 50) || sz.StopPrice != 9800 {
+		t.Fatalf("固定风险比例: %+v %v", sz, err)
+	}
+
+	// ATR 止损 = 10000 - 2*100，数量 0.5 BTC 名义价值 5000 正好不超过上限
+	req.Strategy = "atr"
+	req.Side = model.Sell
+	sz, err = sizer.Size(ctx, req)
+	if err != nil || !almostEqual(sz.StopPrice, 10200) || !almostEqual(sz.Quantity, 50) {
+		t.Fatalf("ATR 止损距离: %+v %v", sz, err)
+	}
+	req.Side = model.Buy
+	states["BTC/USDT"].ATR = 50
+	sz, _ = sizer.Size(ctx, req)
+	if !almostEqual(sz.Quantity, 50) || !almostEqual(sz.Notional, 5000) {
+		t.Fatalf("超过名义价值上限应按上限下单: %+v", sz)
+	}
+
+	// 样本不足时退回 risk-pct
+	req.Strategy = "kelly"
+	sz, _ = sizer.Size(ctx, req)
+	if !almostEqual(sz.Quantity, 50) {
+		t.Fatalf("样本不足应使用 risk-pct: %+v", sz)
+	}
+	// 胜率 60%，盈亏比 1.5：f = 0.6 - 0.4/1.5 = 0.333，半凯利 0.167 超过上限 0.03
+	sizer.stats = stubWinStats{Trades: 20, Wins: 12, AvgWin: 15, AvgLoss: 10}
+	sz, _ = sizer.Size(ctx, req)
+	if !almostEqual(sz.Quantity, 150) {
+		t.Fatalf("凯利比例应被限制在上限: %+v", sz)
+	}
+	sizer.stats = stubWinStats{Trades: 20, Wins: 6, AvgWin: 10, AvgLoss: 10}
+	if _, err := sizer.Size(ctx, req); !errors.Is(err, ErrNoEdge) {
+		t.Fatalf("没有优势时不应开仓: %v", err)
+	}
+
+	// 按步长向下取整，不足最小下单量时报错
+	req.Strategy = "fixed"
+	req.Equity = 1
+	if _, err := sizer.Size(ctx, req); err == nil {
+		t.Fatalf("数量不足最小下单量应报错")
+	}
+	req.Equity = 10000
+	req.StopPrice = 9700
+	sz, _ = sizer.Size(ctx, req)
+	if !almostEqual(sz.Quantity, 33.33) {
+		t.Fatalf("数量应按步长向下取整: %+v", sz)
+	}
+
+	// 为空时使用 level
+	var nilSizer *PositionSizer
+	if nilSizer.Model("fixed") != SizerLevel {
+		t.Fatalf("sizer 为空时应使用 level")
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
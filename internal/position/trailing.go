@@ -0,0 +1,364 @@
+package position
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 移动止损模式
+const (
+	TrailPercent    = "percent"    // 开仓后最优价回撤固定比例
+	TrailATR        = "atr"        // 最新价 - ATR * 倍数
+	TrailBreakEven  = "break-even" // 浮盈达到 N 个 R 后移到开仓价
+	TrailChandelier = "chandelier" // 吊灯止损：开仓后最优价 - ATR * 倍数
+)
+
+// 参数没有配置时的默认值
+const (
+	defaultTrailInterval      = 10 * time.Second
+	defaultTrailPercent       = 0.02
+	defaultTrailAtrMultiple   = 2.0
+	defaultChandelierMultiple = 3.0
+	defaultBreakEvenR         = 1.0
+	defaultInitialStopPct     = 0.015 // 与 ApplyAction 开仓的止损百分比一致
+	defaultTrailMinInterval   = time.Minute
+	trailHistoryLimit         = 50 // 每个仓位保留的修改记录数量
+)
+
+// PriceSource 提供实时价格，key 为 OKX instId，如 BTC-USDT，由 service.MarketDataService 实现
+type PriceSource interface {
+	GetPrices() map[string]float64
+}
+
+// TrailAmend 一次修改止损的记录
+type TrailAmend struct {
+	Symbol  string             `json:"symbol"`
+	PosSide model.OrderPosSide `json:"pos_side"`
+	AlgoId  string             `json:"algo_id"`
+	Mode    string             `json:"mode"` // 给出新止损价的模式
+	Price   float64            `json:"price"`
+	From    float64            `json:"from"`
+	To      float64            `json:"to"`
+	Error   string             `json:"error,omitempty"`
+	At      time.Time          `json:"at"`
+}
+
+// trailState 单个仓位的移动止损状态，algoId 变化时视为新仓位重新开始
+type trailState struct {
+	algoId    string
+	entry     float64
+	risk      float64 // 1R 对应的价格距离
+	stop      float64 // 当前止损价
+	extreme   float64 // 开仓后的最优价，多仓为最高价，空仓为最低价
+	lastAmend time.Time
+	history   []TrailAmend
+}
+
+// TrailingStopManager 按实时价格为每个持仓上移（空仓下移）止损，通过 AmendAlgoOrder 修改交易所的止损单，
+// 止损只会收紧不会放宽
+type TrailingStopManager struct {
+	ps     *PositionService
+	prices PriceSource
+	states TrendStateProvider
+	cfg    conf.TrailingConfig
+
+	mu     sync.Mutex
+	trails map[string]*trailState // key: symbol:posSide
+	now    func() time.Time
+}
+
+func NewTrailingStopManager(ps *PositionService, prices PriceSource, states TrendStateProvider, cfg conf.TrailingConfig) *TrailingStopManager {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultTrailInterval
+	}
+	if cfg.Percent <= 0 {
+		cfg.Percent = defaultTrailPercent
+	}
+	if cfg.AtrMultiple <= 0 {
+		cfg.AtrMultiple = defaultTrailAtrMultiple
+	}
+	if cfg.ChandelierMultiple <= 0 {
+		cfg.ChandelierMultiple = defaultChandelierMultiple
+	}
+	if cfg.BreakEvenR <= 0 {
+		cfg.BreakEvenR = defaultBreakEvenR
+	}
+	if cfg.InitialStopPct <= 0 {
+		cfg.InitialStopPct = defaultInitialStopPct
+	}
+	if cfg.MinInterval <= 0 {
+		cfg.MinInterval = defaultTrailMinInterval
+	}
+	return &TrailingStopManager{
+		ps:     ps,
+		prices: prices,
+		states: states,
+		cfg:    cfg,
+		trails: make(map[string]*trailState),
+		now:    time.Now,
+	}
+}
+
+// Run 按配置的间隔检查所有持仓，直到 ctx 结束
+func (m *TrailingStopManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		m.CheckOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// History 仓位的止损修改记录，最新的在前
+func (m *TrailingStopManager) History(symbol string, posSide model.OrderPosSide) []TrailAmend {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.trails[trailKey(symbol, posSide)]
+	if st == nil {
+		return nil
+	}
+	out := make([]TrailAmend, len(st.history))
+	for i, a := range st.history {
+		out[len(st.history)-1-i] = a
+	}
+	return out
+}
+
+// CheckOnce 检查一次所有持仓，返回本轮修改的止损
+func (m *TrailingStopManager) CheckOnce(ctx context.Context) []TrailAmend {
+	var prices map[string]float64
+	if m.prices != nil {
+		prices = m.prices.GetPrices()
+	}
+
+	var amended []TrailAmend
+	active := make(map[string]bool)
+	for _, t := range m.ps.targets(m.cfg.Symbols) {
+		long, short, err := m.ps.Exchange.GetPosition(t.symbol, t.tradeType)
+		if err != nil {
+			log.Printf("[TrailingStop] 查询 %s 仓位失败: %v", t.symbol, err)
+			continue
+		}
+		for _, pos := range []*model.PositionInfo{activePosition(long), activePosition(short)} {
+			if pos == nil || pos.AlgoId == "" {
+				// 没有止损单的仓位无法修改
+				continue
+			}
+			key := trailKey(t.symbol, pos.Dir)
+			active[key] = true
+			price := lookupPrice(prices, t.symbol)
+			if price <= 0 {
+				price = pos.Last
+			}
+			if price <= 0 {
+				continue
+			}
+			if a := m.trail(t, pos, price); a != nil {
+				amended = append(amended, *a)
+			}
+		}
+	}
+
+	// 已经平掉的仓位不再保留状态
+	m.mu.Lock()
+	for key := range m.trails {
+		if !active[key] {
+			delete(m.trails, key)
+		}
+	}
+	m.mu.Unlock()
+	return amended
+}
+
+// trail 计算单个仓位的新止损价，满足收紧幅度和间隔要求时修改交易所的止损单
+func (m *TrailingStopManager) trail(t reconcileTarget, pos *model.PositionInfo, price float64) *TrailAmend {
+	long := pos.Dir == model.OrderPosSideLong
+	key := trailKey(t.symbol, pos.Dir)
+
+	// 优先使用交易所当前的止损触发价，没有时使用本地记录（收紧止损会同步修改）
+	current := pos.SlTriggerPx
+	if current <= 0 {
+		current = m.ps.currentStop(t.symbol, long)
+	}
+
+	m.mu.Lock()
+	st := m.trails[key]
+	if st == nil || st.algoId != pos.AlgoId {
+		st = m.newState(t.symbol, pos, current)
+		m.trails[key] = st
+	}
+	if pos.SlTriggerPx > 0 {
+		st.stop = pos.SlTriggerPx
+	} else if current > 0 && tighter(long, current, st.stop, 0) {
+		st.stop = current
+	}
+	if (long && price > st.extreme) || (!long && price < st.extreme) {
+		st.extreme = price
+	}
+
+	mode, stop := m.candidate(t.symbol, long, st, price)
+	now := m.now()
+	ok := stop > 0 && tighter(long, stop, st.stop, m.cfg.MinStep) &&
+		// 新止损必须在当前价之外，否则会立即触发
+		((long && stop < price) || (!long && stop > price)) &&
+		now.Sub(st.lastAmend) >= m.cfg.MinInterval
+	if !ok {
+		m.mu.Unlock()
+		return nil
+	}
+	from := st.stop
+	st.lastAmend = now
+	m.mu.Unlock()
+
+	stop = m.ps.roundPrice(t.symbol, t.tradeType, stop)
+	if !tighter(long, stop, from, 0) {
+		// 取整后不再比当前止损紧
+		return nil
+	}
+	a := TrailAmend{Symbol: t.symbol, PosSide: pos.Dir, AlgoId: pos.AlgoId, Mode: mode, Price: price, From: from, To: stop, At: now}
+	if _, err := m.ps.Exchange.AmendAlgoOrder(pos.Symbol, t.tradeType, pos.AlgoId, stop, -1); err != nil {
+		a.Error = err.Error()
+		log.Printf("[TrailingStop] %s %s 修改止损 %v -> %v 失败: %v", t.symbol, pos.Dir, from, stop, err)
+	} else {
+		log.Printf("[TrailingStop] %s %s 价格 %v，%s 止损 %v -> %v", t.symbol, pos.Dir, price, mode, from, stop)
+	}
+
+	m.mu.Lock()
+	if a.Error == "" {
+		st.stop = stop
+	}
+	st.history = append(st.history, a)
+	if len(st.history) > trailHistoryLimit {
+		st.history = st.history[len(st.history)-trailHistoryLimit:]
+	}
+	m.mu.Unlock()
+	if a.Error == "" {
+		m.ps.updateStopLoss(t.symbol, long, stop)
+	}
+	return &a
+}
+
+// newState 开始跟踪一个仓位，current 为当前的止损价。
+// 1R 按开仓时记录的止损计算，都没有时按 InitialStopPct 推算
+func (m *TrailingStopManager) newState(symbol string, pos *model.PositionInfo, current float64) *trailState {
+	long := pos.Dir == model.OrderPosSideLong
+	initial := m.initialStop(symbol, long)
+	if initial <= 0 {
+		initial = current
+	}
+	if initial <= 0 {
+		if long {
+			initial = pos.AvgPrice * (1 - m.cfg.InitialStopPct)
+		} else {
+			initial = pos.AvgPrice * (1 + m.cfg.InitialStopPct)
+		}
+	}
+	stop := current
+	if stop <= 0 {
+		stop = initial
+	}
+	return &trailState{
+		algoId:  pos.AlgoId,
+		entry:   pos.AvgPrice,
+		risk:    math.Abs(pos.AvgPrice - initial),
+		stop:    stop,
+		extreme: pos.AvgPrice,
+	}
+}
+
+// initialStop 本地元信息中同方向最早开仓时的止损价
+func (m *TrailingStopManager) initialStop(symbol string, long bool) float64 {
+	side := metaSide(long)
+	m.ps.mu.Lock()
+	defer m.ps.mu.Unlock()
+	var first *LocalPositionMeta
+	for _, meta := range m.ps.metas[symbol] {
+		if meta.Side != side || meta.InitialStop <= 0 {
+			continue
+		}
+		if first == nil || meta.OpenTime.Before(first.OpenTime) {
+			first = meta
+		}
+	}
+	if first == nil {
+		return 0
+	}
+	return first.InitialStop
+}
+
+// candidate 按配置的模式计算止损价，取最紧的一个
+func (m *TrailingStopManager) candidate(symbol string, long bool, st *trailState, price float64) (string, float64) {
+	dir := 1.0
+	if !long {
+		dir = -1
+	}
+	var atr float64
+	if m.states != nil {
+		if ts := m.states.GetState(symbol); ts != nil {
+			atr = ts.ATR
+		}
+	}
+
+	var best string
+	var stop float64
+	consider := func(mode string, v float64) {
+		if v <= 0 {
+			return
+		}
+		if stop == 0 || (long && v > stop) || (!long && v < stop) {
+			best, stop = mode, v
+		}
+	}
+	for _, mode := range m.cfg.Modes {
+		switch mode {
+		case TrailPercent:
+			consider(mode, st.extreme*(1-dir*m.cfg.Percent))
+		case TrailATR:
+			if atr > 0 {
+				consider(mode, price-dir*atr*m.cfg.AtrMultiple)
+			}
+		case TrailChandelier:
+			if atr > 0 {
+				consider(mode, st.extreme-dir*atr*m.cfg.ChandelierMultiple)
+			}
+		case TrailBreakEven:
+			if st.risk > 0 && dir*(price-st.entry) >= st.risk*m.cfg.BreakEvenR {
+				consider(mode, st.entry*(1+dir*m.cfg.BreakEvenOffset))
+			}
+		}
+	}
+	return best, stop
+}
+
+// tighter 新止损是否比当前止损收紧了至少 minStep 的比例
+func tighter(long bool, stop, current, minStep float64) bool {
+	if current <= 0 {
+		return true
+	}
+	if long {
+		return stop > current*(1+minStep)
+	}
+	return stop < current*(1-minStep)
+}
+
+func trailKey(symbol string, posSide model.OrderPosSide) string {
+	return symbol + ":" + string(posSide)
+}
+
+// lookupPrice 行情中的 instId 为 BTC-USDT 格式，交易对为 BTC/USDT
+func lookupPrice(prices map[string]float64, symbol string) float64 {
+	if p, ok := prices[symbol]; ok {
+		return p
+	}
+	return prices[strings.ReplaceAll(symbol, "/", "-")]
+}
@@ -0,0 +1,187 @@
+package position
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	"edgeflow/internal/signal"
+	"testing"
+	"time"
+)
+
+// 在 positionExchange 的基础上记录止损修改
+type trailingExchange struct {
+	*positionExchange
+	amends []float64
+}
+
+func (e *trailingExchange) AmendAlgoOrder(instId string, tradeType model.OrderTradeType, algoId string, newSlTriggerPx, newTpTriggerPx float64) ([]byte, error) {
+	e.amends = append(e.amends, newSlTriggerPx)
+	// 同步交易所仓位上的止损触发价
+	for _, positions := range []map[string]*model.PositionInfo{e.long, e.short} {
+		for _, p := range positions {
+			if p.AlgoId == algoId {
+				p.SlTriggerPx = newSlTriggerPx
+			}
+		}
+	}
+	return []byte(`{"code":"0"}`), nil
+}
+
+type stubPrices map[string]float64
+
+func (p stubPrices) GetPrices() map[string]float64 {
+	return p
+}
+
+func TestTrailingStopManager(t *testing.T) {
+	ctx := context.Background()
+	ex := &trailingExchange{positionExchange: &positionExchange{
+		long: map[string]*model.PositionInfo{
+			"BTC/USDT": {Symbol: "BTC-USDT-SWAP", Dir: model.OrderPosSideLong, Amount: 1, AvgPrice: 100, AlgoId: "a1"},
+		},
+		short: map[string]*model.PositionInfo{},
+	}}
+	ps := NewPositionService(ex, nil)
+	// 开仓止损 98，1R = 2
+	meta := ps.saveMeta("BTC/USDT", 2, "buy", 100, 1, model.OrderTradeSwap)
+	meta.StopLoss = 98
+	meta.InitialStop = 98
+
+	prices := stubPrices{"BTC-USDT": 101}
+	m := NewTrailingStopManager(ps, prices, stubStates{"BTC/USDT": {ATR: 1}}, conf.TrailingConfig{
+		Modes:       []string{TrailBreakEven, TrailChandelier},
+		MinStep:     0.001,
+		MinInterval: time.Minute,
+	})
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	// 浮盈不足 1R，吊灯止损 101-3 比开仓止损宽，不修改
+	if a := m.CheckOnce(ctx); len(a) != 0 {
+		t.Fatalf("不应修改止损: %+v", a)
+	}
+
+	// 浮盈 1R，移到开仓价
+	prices["BTC-USDT"] = 102
+	a := m.CheckOnce(ctx)
+	if len(a) != 1 || a[0].Mode != TrailBreakEven || a[0].To != 100 || a[0].From != 98 {
+		t.Fatalf("应移动到保本: %+v", a)
+	}
+
+	// 间隔不足一分钟，不修改
+	prices["BTC-USDT"] = 106
+	if a := m.CheckOnce(ctx); len(a) != 0 {
+		t.Fatalf("修改过于频繁: %+v", a)
+	}
+	// 吊灯止损 106-3
+	now = now.Add(time.Minute)
+	a = m.CheckOnce(ctx)
+	if len(a) != 1 || a[0].Mode != TrailChandelier || a[0].To != 103 {
+		t.Fatalf("应按吊灯止损上移: %+v", a)
+	}
+
+	// 价格回落，止损不放宽
+	prices["BTC-USDT"] = 104
+	now = now.Add(time.Minute)
+	if a := m.CheckOnce(ctx); len(a) != 0 {
+		t.Fatalf("止损不应放宽: %+v", a)
+	}
+	if len(ex.amends) != 2 || ex.amends[1] != 103 {
+		t.Fatalf("交易所收到的修改: %v", ex.amends)
+	}
+	if h := m.History("BTC/USDT", model.OrderPosSideLong); len(h) != 2 || h[0].To != 103 {
+		t.Fatalf("修改记录: %+v", h)
+	}
+	// 修改后的止损写回本地元信息，1R 仍按开仓止损计算
+	if meta.StopLoss != 103 || meta.InitialStop != 98 {
+		t.Fatalf("本地止损应同步为 103: %+v", meta)
+	}
+
+	// BTC 仓位平掉，空仓按百分比跟踪最低价，没有本地止损时按 initial-stop-pct 推算
+	delete(ex.long, "BTC/USDT")
+	ex.short["ETH/USDT"] = &model.PositionInfo{Symbol: "ETH-USDT-SWAP", Dir: model.OrderPosSideShort, Amount: 1, AvgPrice: 100, AlgoId: "a2"}
+	m.cfg.Symbols = []string{"ETH/USDT"}
+	m.cfg.Modes = []string{TrailPercent}
+	prices["ETH-USDT"] = 90
+	now = now.Add(time.Minute)
+	a = m.CheckOnce(ctx)
+	if len(a) != 1 || a[0].PosSide != model.OrderPosSideShort || !almostEqual(a[0].To, 91.8) || !almostEqual(a[0].From, 101.5) {
+		t.Fatalf("空仓应按最低价回撤比例下移: %+v", a)
+	}
+	// 平掉的仓位清除状态
+	if h := m.History("BTC/USDT", model.OrderPosSideLong); h != nil {
+		t.Fatalf("平仓后应清除状态: %+v", h)
+	}
+}
+
+// 交易所的止损已经比候选止损更紧（重启或被收紧止损修改过），不能提交更宽的止损
+func TestTrailingStopUsesLiveStop(t *testing.T) {
+	ctx := context.Background()
+	ex := &trailingExchange{positionExchange: &positionExchange{
+		long: map[string]*model.PositionInfo{
+			"BTC/USDT": {Symbol: "BTC-USDT-SWAP", Dir: model.OrderPosSideLong, Amount: 1, AvgPrice: 100, AlgoId: "a1", SlTriggerPx: 101},
+		},
+		short: map[string]*model.PositionInfo{},
+	}}
+	ps := NewPositionService(ex, nil)
+	meta := ps.saveMeta("BTC/USDT", 2, "buy", 100, 1, model.OrderTradeSwap)
+	meta.StopLoss = 98
+	meta.InitialStop = 98
+
+	prices := stubPrices{"BTC-USDT": 102}
+	m := NewTrailingStopManager(ps, prices, stubStates{}, conf.TrailingConfig{
+		Modes:       []string{TrailBreakEven},
+		MinStep:     0.001,
+		MinInterval: time.Minute,
+	})
+	now := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	// 浮盈 1R，保本价 100 比交易所当前止损 101 宽，不修改
+	if a := m.CheckOnce(ctx); len(a) != 0 || len(ex.amends) != 0 {
+		t.Fatalf("不应放宽止损: %+v %v", a, ex.amends)
+	}
+
+	// 跟踪过程中止损被其他路径收紧，以交易所为准
+	ex.long["BTC/USDT"].SlTriggerPx = 99
+	now = now.Add(time.Minute)
+	a := m.CheckOnce(ctx)
+	if len(a) != 1 || a[0].From != 99 || a[0].To != 100 {
+		t.Fatalf("应从交易所当前止损移动到保本: %+v", a)
+	}
+	if meta.StopLoss != 100 {
+		t.Fatalf("本地止损应同步为 100: %+v", meta)
+	}
+}
+
+func TestTightenStopLoss(t *testing.T) {
+	ctx := context.Background()
+	pos := &model.PositionInfo{Symbol: "BTC-USDT-SWAP", Dir: model.OrderPosSideLong, Amount: 1, AvgPrice: 100, AlgoId: "a1", SlTriggerPx: 98}
+	ex := &trailingExchange{positionExchange: &positionExchange{
+		long:  map[string]*model.PositionInfo{"BTC/USDT": pos},
+		short: map[string]*model.PositionInfo{},
+	}}
+	ps := NewPositionService(ex, nil)
+	meta := ps.saveMeta("BTC/USDT", 2, "buy", 100, 1, model.OrderTradeSwap)
+	meta.StopLoss = 98
+	meta.InitialStop = 98
+
+	sig := signal.Signal{Symbol: "BTC/USDT", Price: 110, TradeType: string(model.OrderTradeSwap)}
+	// 锁定 80% 浮盈：100 + 10*0.8
+	if err := ps.ApplyAction(ctx, signal.ActTightenSL, sig, pos); err != nil {
+		t.Fatal(err)
+	}
+	if len(ex.amends) != 1 || !almostEqual(ex.amends[0], 108) || !almostEqual(meta.StopLoss, 108) {
+		t.Fatalf("应收紧到 108 并写回本地: %v %+v", ex.amends, meta)
+	}
+
+	// 价格回落后计算出的止损更宽，不修改
+	sig.Price = 105
+	if err := ps.ApplyAction(ctx, signal.ActTightenSL, sig, pos); err != nil {
+		t.Fatal(err)
+	}
+	if len(ex.amends) != 1 || !almostEqual(meta.StopLoss, 108) {
+		t.Fatalf("不应放宽止损: %v %+v", ex.amends, meta)
+	}
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"edgeflow/internal/handler/admin"
 	"edgeflow/internal/handler/alert"
 	"edgeflow/internal/handler/hyperliquid"
 	"edgeflow/internal/handler/insight"
@@ -24,10 +25,11 @@ type ApiRouter struct {
 	tickerGw       *ticker.TickerGateway
 	subscriptionGw *market.SubscriptionGateway
 	alertGw        *alert.AlertGateway
+	tradingHandler *admin.TradingHandler
 }
 
-func NewApiRouter(ch *instrument.Handler, marketHandler *market.MarketHandler, hyperHandler *hyperliquid.Handler, insightHandler *insight.Handler, userHandler *user.UserHandler, signalHandler *signal.SignalHandler, tickerGw *ticker.TickerGateway, subscriptionGw *market.SubscriptionGateway, alertGw *alert.AlertGateway) *ApiRouter {
-	return &ApiRouter{coinHandler: ch, marketHandler: marketHandler, hyperHandler: hyperHandler, insightHandler: insightHandler, userHandler: userHandler, signalHandler: signalHandler, tickerGw: tickerGw, subscriptionGw: subscriptionGw, alertGw: alertGw}
+func NewApiRouter(ch *instrument.Handler, marketHandler *market.MarketHandler, hyperHandler *hyperliquid.Handler, insightHandler *insight.Handler, userHandler *user.UserHandler, signalHandler *signal.SignalHandler, tickerGw *ticker.TickerGateway, subscriptionGw *market.SubscriptionGateway, alertGw *alert.AlertGateway, tradingHandler *admin.TradingHandler) *ApiRouter {
+	return &ApiRouter{coinHandler: ch, marketHandler: marketHandler, hyperHandler: hyperHandler, insightHandler: insightHandler, userHandler: userHandler, signalHandler: signalHandler, tickerGw: tickerGw, subscriptionGw: subscriptionGw, alertGw: alertGw, tradingHandler: tradingHandler}
 }
 
 func (api *ApiRouter) Load(g *gin.Engine) {
@@ -84,7 +86,10 @@ func (api *ApiRouter) Load(g *gin.Engine) {
 	{
 		sg.GET("/list", api.signalHandler.SignalGetList())
 		sg.GET("/detail", api.signalHandler.GetSignalDetailByID())
-		sg.POST("/execute", api.signalHandler.ExecuteSignal())
+		// 使用用户自己绑定的 API Key 下单，需要登录
+		sg.POST("/execute", middleware.AuthToken(), api.signalHandler.ExecuteSignal())
+		// 决策记录包含仓位快照和配置版本，只对管理员开放
+		sg.GET("/decisions", middleware.AuthAdmin(), api.signalHandler.SignalDecisionGetList())
 	}
 
 	u := base.Group("/user", middleware.AuthToken())
@@ -102,6 +107,11 @@ func (api *ApiRouter) Load(g *gin.Engine) {
 		u.GET("/plan", api.userHandler.UserGetPlan())
 		// 获取用户当前用于交易的资金概况和风控配置，以供前端进行即时计算和风险提示。
 		u.GET("/assets", api.userHandler.UserGetBalance())
+		// 交易所 API Key，用于信号下单
+		u.POST("/exchange/credential", api.signalHandler.CredentialSave())
+		u.GET("/exchange/credential", api.signalHandler.CredentialGet())
+		u.DELETE("/exchange/credential", api.signalHandler.CredentialDelete())
+		u.GET("/orders", api.signalHandler.UserOrderGetList())
 	}
 
 	auth := base.Group("/auth")
@@ -132,5 +142,22 @@ func (api *ApiRouter) Load(g *gin.Engine) {
 		alerts.GET("/histories", api.alertGw.GetHistories())
 	}
 
+	// 交易运维，只对管理员开放
+	ad := base.Group("/admin", middleware.AuthAdmin())
+	{
+		// 交易频次统计
+		ad.GET("/trade-limit", api.tradingHandler.TradeLimitStatsGet())
+		ad.POST("/trade-limit/reset", api.tradingHandler.TradeLimitReset())
+		ad.GET("/orders/pnl", api.tradingHandler.StrategyPnlGet())
+		ad.GET("/orders/history", api.tradingHandler.OrderHistoryGet())
+		ad.GET("/risk", api.tradingHandler.RiskGet())
+		ad.POST("/risk/resume", api.tradingHandler.RiskResume())
+		ad.GET("/exchange/stats", api.tradingHandler.ExchangeStatsGet())
+		ad.GET("/killswitch", api.tradingHandler.KillSwitchGet())
+		ad.POST("/killswitch/pause", api.tradingHandler.KillSwitchPause())
+		ad.POST("/killswitch/resume", api.tradingHandler.KillSwitchResume())
+		ad.POST("/killswitch/flatten", api.tradingHandler.Flatten())
+	}
+
 	//base.POST("/webhook", middleware.RequestValidationMiddleware(), api.wh.HandlerWebhook())
 }
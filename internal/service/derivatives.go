@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"edgeflow/internal/dao"
+	"edgeflow/internal/model/entity"
+	"edgeflow/pkg/exchange/okx"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 默认采集持仓价值最大的前 N 个 USDT 永续
+	derivativesDefaultTop = 50
+	// taker-volume 接口限频 5次/2s，逐个交易对请求时保持间隔
+	derivativesDefaultPace = 450 * time.Millisecond
+	// 主动买卖比使用的统计周期
+	takerVolumePeriod = "5m"
+)
+
+// DerivativesCollector 定时采集永续合约的资金费率、预测资金费率、持仓量和主动买卖比，
+// 每一轮的结果写入 derivative_metrics，最新一轮保存在内存中供行情排序和趋势计算使用。
+type DerivativesCollector struct {
+	client *okx.PublicClient
+	repo   dao.DerivativeMetricDao
+	top    int
+	pace   time.Duration
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	latest map[string]entity.DerivativeMetric // instId(BTC-USDT-SWAP) -> 最新一次采集
+}
+
+// NewDerivativesCollector client 为 nil 时使用默认的 okx 公共接口，repo 为 nil 时只保存在内存中
+func NewDerivativesCollector(client *okx.PublicClient, repo dao.DerivativeMetricDao) *DerivativesCollector {
+	if client == nil {
+		client = okx.NewPublicClient()
+	}
+	return &DerivativesCollector{
+		client: client,
+		repo:   repo,
+		top:    derivativesDefaultTop,
+		pace:   derivativesDefaultPace,
+		now:    time.Now,
+		latest: make(map[string]entity.DerivativeMetric),
+	}
+}
+
+// WithTop 每轮采集的交易对数量，按持仓价值从大到小
+func (c *DerivativesCollector) WithTop(n int) *DerivativesCollector {
+	if n > 0 {
+		c.top = n
+	}
+	return c
+}
+
+// WithPace 两个交易对请求之间的间隔
+func (c *DerivativesCollector) WithPace(d time.Duration) *DerivativesCollector {
+	c.pace = d
+	return c
+}
+
+// Run 定时采集，直到 ctx 结束
+func (c *DerivativesCollector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := c.Collect(ctx); err != nil {
+			log.Printf("ERROR: [Derivatives] 采集失败: %v", err)
+		} else {
+			log.Printf("[Derivatives] 本轮采集 %d 个交易对", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect 采集一轮，返回成功的交易对数量。
+// 单个交易对的资金费率或主动买卖量获取失败时跳过该交易对，不影响其他交易对
+func (c *DerivativesCollector) Collect(ctx context.Context) (int, error) {
+	ois, err := c.client.GetOpenInterest(ctx, "SWAP")
+	if err != nil {
+		return 0, err
+	}
+	active := activeSwaps(ois, c.top)
+
+	ts := c.now()
+	metrics := make([]entity.DerivativeMetric, 0, len(active))
+	var errs []error
+	for i, oi := range active {
+		if i > 0 && c.pace > 0 {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(c.pace):
+			}
+		}
+		m, err := c.collectOne(ctx, oi)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m.Ts = ts
+		metrics = append(metrics, m)
+	}
+	if len(errs) > 0 {
+		log.Printf("WARN: [Derivatives] %d 个交易对采集失败: %v", len(errs), errors.Join(errs...))
+	}
+
+	c.mu.Lock()
+	for _, m := range metrics {
+		c.latest[m.InstID] = m
+	}
+	c.mu.Unlock()
+
+	if c.repo != nil {
+		if err := c.repo.SaveMetrics(ctx, metrics); err != nil {
+			return len(metrics), err
+		}
+	}
+	return len(metrics), nil
+}
+
+func (c *DerivativesCollector) collectOne(ctx context.Context, oi okx.OpenInterestRaw) (entity.DerivativeMetric, error) {
+	m := entity.DerivativeMetric{
+		InstID:          oi.InstId,
+		OpenInterest:    parseFloat(oi.Oi),
+		OpenInterestCcy: parseFloat(oi.OiCcy),
+		OpenInterestUsd: parseFloat(oi.OiUsd),
+	}
+
+	fr, err := c.client.GetFundingRate(ctx, oi.InstId)
+	if err != nil {
+		return m, err
+	}
+	m.FundingRate = parseFloat(fr.FundingRate)
+	m.NextFundingRate = parseFloat(fr.NextFundingRate)
+	if ms, err := strconv.ParseInt(fr.FundingTime, 10, 64); err == nil && ms > 0 {
+		t := time.UnixMilli(ms)
+		m.FundingTime = &t
+	}
+
+	vols, err := c.client.GetTakerVolume(ctx, oi.InstId, takerVolumePeriod, 1)
+	if err != nil {
+		return m, err
+	}
+	if len(vols) > 0 {
+		if sell := parseFloat(vols[0].SellVol); sell > 0 {
+			m.LongShortRatio = parseFloat(vols[0].BuyVol) / sell
+		}
+	}
+	return m, nil
+}
+
+// Latest 最新一次采集的数据，id 可以是 BTC/USDT、BTC-USDT 或 BTC-USDT-SWAP
+func (c *DerivativesCollector) Latest(id string) (entity.DerivativeMetric, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	m, ok := c.latest[swapInstID(id)]
+	return m, ok
+}
+
+// History 查询 [start, end] 内保存的时间序列，按时间从旧到新
+func (c *DerivativesCollector) History(ctx context.Context, id string, start, end time.Time, limit int) ([]entity.DerivativeMetric, error) {
+	if c.repo == nil {
+		return nil, nil
+	}
+	return c.repo.GetMetrics(ctx, swapInstID(id), start, end, limit)
+}
+
+// activeSwaps USDT 永续按持仓价值从大到小取前 top 个
+func activeSwaps(ois []okx.OpenInterestRaw, top int) []okx.OpenInterestRaw {
+	list := make([]okx.OpenInterestRaw, 0, len(ois))
+	for _, oi := range ois {
+		if strings.HasSuffix(oi.InstId, "-USDT-SWAP") {
+			list = append(list, oi)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return parseFloat(list[i].OiUsd) > parseFloat(list[j].OiUsd)
+	})
+	if top > 0 && len(list) > top {
+		list = list[:top]
+	}
+	return list
+}
+
+// swapInstID BTC/USDT、BTC-USDT -> BTC-USDT-SWAP
+func swapInstID(id string) string {
+	id = strings.ReplaceAll(id, "/", "-")
+	if strings.HasSuffix(id, "-SWAP") {
+		return id
+	}
+	return id + "-SWAP"
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"edgeflow/internal/model/entity"
+	"edgeflow/pkg/exchange/okx"
+	"edgeflow/pkg/exchange/okxtest"
+	"math"
+	"testing"
+	"time"
+)
+
+// memDerivativeDao 内存中的 derivative_metrics
+type memDerivativeDao struct {
+	rows []entity.DerivativeMetric
+}
+
+func (d *memDerivativeDao) SaveMetrics(ctx context.Context, metrics []entity.DerivativeMetric) error {
+	d.rows = append(d.rows, metrics...)
+	return nil
+}
+
+func (d *memDerivativeDao) GetMetrics(ctx context.Context, instID string, start, end time.Time, limit int) ([]entity.DerivativeMetric, error) {
+	var out []entity.DerivativeMetric
+	for _, m := range d.rows {
+		if m.InstID == instID && !m.Ts.Before(start) && !m.Ts.After(end) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func TestDerivativesCollector_Collect(t *testing.T) {
+	srv := okxtest.NewServer()
+	defer srv.Close()
+	repo := &memDerivativeDao{}
+	c := NewDerivativesCollector(okx.NewPublicClient().WithBaseURL(srv.URL), repo).WithTop(2).WithPace(0)
+	ctx := context.Background()
+
+	// 按持仓价值取前两个 USDT 永续：BTC、ETH，币本位的 BTC-USD-SWAP 不采集
+	n, err := c.Collect(ctx)
+	if err != nil || n != 2 || len(repo.rows) != 2 {
+		t.Fatalf("collect n=%d err=%v rows=%d", n, err, len(repo.rows))
+	}
+	btc, ok := c.Latest("BTC/USDT")
+	if !ok || btc.InstID != "BTC-USDT-SWAP" || btc.FundingRate != 0.0000873 || btc.NextFundingRate != 0.0001012 ||
+		btc.OpenInterestUsd != 3026249816.7 || btc.FundingTime == nil || math.Abs(btc.LongShortRatio-48675.59/41250.5) > 1e-9 {
+		t.Errorf("BTC = %+v", btc)
+	}
+	if eth, _ := c.Latest("ETH-USDT"); eth.FundingRate != 0.0001 || eth.NextFundingRate != 0 {
+		t.Errorf("ETH = %+v", eth)
+	}
+	if _, ok := c.Latest("SOL-USDT-SWAP"); ok {
+		t.Errorf("SOL 不在前两个交易对中")
+	}
+
+	// 单个交易对失败时跳过，其他交易对照常保存
+	c.WithTop(3)
+	srv.Enqueue("GET", "/api/v5/public/funding-rate", okxtest.Error("50026", "System error"))
+	if n, err := c.Collect(ctx); err != nil || n != 2 {
+		t.Fatalf("collect n=%d err=%v", n, err)
+	}
+	if sol, ok := c.Latest("SOL/USDT"); !ok || sol.LongShortRatio != 1 {
+		t.Errorf("SOL = %+v", sol)
+	}
+	history, _ := c.History(ctx, "BTC/USDT", time.Now().Add(-time.Minute), time.Now(), 100)
+	if len(history) != 1 {
+		t.Errorf("BTC 失败的一轮不应写入: %d", len(history))
+	}
+}
+
+func TestMarketDataService_SortByDerivatives(t *testing.T) {
+	srv := okxtest.NewServer()
+	defer srv.Close()
+	c := NewDerivativesCollector(okx.NewPublicClient().WithBaseURL(srv.URL), nil).WithTop(2).WithPace(0)
+	if _, err := c.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &MarketDataService{
+		tradingItems:     make(map[string]TradingItem),
+		currentSortField: SortByVolume,
+		producer:         &captureProducer{},
+	}
+	for _, id := range []string{"BTC-USDT", "ETH-USDT", "SOL-USDT"} {
+		m.tradingItems[id] = TradingItem{Coin: entity.CryptoInstrument{InstrumentID: id}}
+	}
+	if err := m.ChangeSortField(SortByFundingRate); err == nil {
+		t.Fatal("没有永续合约数据时不支持按资金费率排序")
+	}
+
+	m.WithDerivatives(c)
+	cases := map[string][]string{
+		SortByFundingRate:    {"ETH-USDT", "BTC-USDT", "SOL-USDT"},
+		SortByOpenInterest:   {"BTC-USDT", "ETH-USDT", "SOL-USDT"},
+		SortByLongShortRatio: {"BTC-USDT", "ETH-USDT", "SOL-USDT"},
+	}
+	for field, want := range cases {
+		m.currentSortField = field
+		m.performSortAndCache()
+		if got, _ := m.GetSortedIDsl(); !slicesEqual(got, want) {
+			t.Errorf("%s 排序 = %v, want %v", field, got, want)
+		}
+	}
+}
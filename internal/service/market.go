@@ -27,6 +27,11 @@ const (
 	SortByVolume      = "volume_24h"   // 成交量（默认）
 	SortByPriceChange = "price_change" // 24小时价格涨跌幅
 	SortByPrice       = "price"        // 最新价格
+
+	// 以下排序需要 WithDerivatives，没有永续合约数据的交易对排在最后
+	SortByFundingRate    = "funding_rate"     // 当期资金费率
+	SortByOpenInterest   = "open_interest"    // 持仓价值(美元)
+	SortByLongShortRatio = "long_short_ratio" // 主动买卖比
 )
 
 // 交易数据结构体
@@ -42,6 +47,12 @@ type SortableItem struct {
 	PriceFloat   float64
 	ChangeFloat  float64 // 如果 Change24h 已经是 float，则跳过转换
 	OriginalItem TradingItem
+
+	// 永续合约数据，HasDerivatives 为 false 时以下字段无效
+	HasDerivatives  bool
+	FundingRate     float64
+	OpenInterestUsd float64
+	LongShortRatio  float64
 }
 
 // 币种更新结构体
@@ -98,6 +109,9 @@ type MarketDataService struct {
 	// 历史价格队列 (InstID -> []PricePoint)
 	// 这是一个临界资源，必须在 mu 锁保护下访问
 	priceHistory map[string][]PricePoint
+
+	// 资金费率、持仓量和主动买卖比，为空时不支持对应的排序和详情字段
+	derivatives *DerivativesCollector
 }
 
 func NewMarketDataService(ticker *OKXTickerService, instrumentFetcher InstrumentFetcher, ex exchange.Exchange, SignalRepo dao.SignalDao, producer kafka.ProducerService, alertService AlertPublisher, boundaryRepo *dao.AlertBoundaryRepository) *MarketDataService {
@@ -319,6 +333,14 @@ func (m *MarketDataService) updateRealTimeData(tickerMap map[string]TickerData)
 	}(protoMsg)
 }
 
+// WithDerivatives 使用采集的永续合约数据排序和展示详情
+func (m *MarketDataService) WithDerivatives(c *DerivativesCollector) *MarketDataService {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.derivatives = c
+	return m
+}
+
 // startSortingScheduler 定时执行排序和缓存
 func (m *MarketDataService) startSortingScheduler() {
 	// 定时器，例如每 1.5 秒执行一次排序
@@ -338,6 +360,7 @@ func (m *MarketDataService) startSortingScheduler() {
 // performSortAndCache 执行排序，并更新缓存（需要在后台线程调用）
 func (m *MarketDataService) performSortAndCache() {
 	m.mu.RLock()
+	derivatives := m.derivatives
 	// 1. 转换为可排序切片并预处理浮点数
 	sortableItems := make([]SortableItem, 0, len(m.tradingItems))
 	for _, item := range m.tradingItems {
@@ -345,13 +368,22 @@ func (m *MarketDataService) performSortAndCache() {
 		vol, _ := strconv.ParseFloat(item.Ticker.VolCcy24h, 64)
 		price, _ := strconv.ParseFloat(item.Ticker.LastPrice, 64)
 
-		sortableItems = append(sortableItems, SortableItem{
+		sortable := SortableItem{
 			ID:          item.Coin.InstrumentID,
 			VolumeFloat: vol,
 			PriceFloat:  price,
 			// 假设 Change24h 已经是 float 或直接从 item.Ticker 中获取
 			OriginalItem: item,
-		})
+		}
+		if derivatives != nil {
+			if d, ok := derivatives.Latest(item.Coin.InstrumentID); ok {
+				sortable.HasDerivatives = true
+				sortable.FundingRate = d.FundingRate
+				sortable.OpenInterestUsd = d.OpenInterestUsd
+				sortable.LongShortRatio = d.LongShortRatio
+			}
+		}
+		sortableItems = append(sortableItems, sortable)
 	}
 	m.mu.RUnlock()
 
@@ -372,6 +404,20 @@ func (m *MarketDataService) performSortAndCache() {
 			// 按价格降序 (Highest Price first)
 			return a.PriceFloat > b.PriceFloat
 
+		case SortByFundingRate, SortByOpenInterest, SortByLongShortRatio:
+			// 没有永续合约数据的排在最后
+			if a.HasDerivatives != b.HasDerivatives {
+				return a.HasDerivatives
+			}
+			switch m.currentSortField {
+			case SortByFundingRate:
+				return a.FundingRate > b.FundingRate
+			case SortByOpenInterest:
+				return a.OpenInterestUsd > b.OpenInterestUsd
+			default:
+				return a.LongShortRatio > b.LongShortRatio
+			}
+
 		default:
 			// 默认回退到 Volume 排序
 			return a.VolumeFloat > b.VolumeFloat
@@ -599,6 +645,13 @@ func (m *MarketDataService) ChangeSortField(newField string) error {
 	switch newField {
 	case SortByVolume, SortByPriceChange, SortByPrice:
 		// 支持的字段
+	case SortByFundingRate, SortByOpenInterest, SortByLongShortRatio:
+		m.mu.RLock()
+		enabled := m.derivatives != nil
+		m.mu.RUnlock()
+		if !enabled {
+			return errors.New("sort field requires derivatives data: " + newField)
+		}
 	default:
 		return errors.New("unsupported sort field: " + newField)
 	}
@@ -637,6 +690,7 @@ func (m *MarketDataService) GetPrices() map[string]float64 {
 func (m *MarketDataService) GetDetailByID(ctx context.Context, req model.MarketDetailReq) (*model.MarketDetail, error) {
 	m.mu.Lock()
 	coin, ok := m.baseCoins[req.InstrumentID]
+	derivatives := m.derivatives
 	m.mu.Unlock()
 	if !ok {
 		return nil, fmt.Errorf("不存在的交易对:%v", req.InstrumentID)
@@ -655,6 +709,20 @@ func (m *MarketDataService) GetDetailByID(ctx context.Context, req model.MarketD
 	}
 	detail.HistoryKlines = kLines
 
+	// 永续合约的资金费率、持仓量和主动买卖比，时间范围与K线一致
+	if derivatives != nil {
+		if d, ok := derivatives.Latest(req.InstrumentID); ok {
+			detail.Derivatives = &d
+		}
+		if len(kLines) >= 2 {
+			history, err := derivatives.History(ctx, req.InstrumentID, kLines[0].Timestamp, kLines[len(kLines)-1].Timestamp, req.Size)
+			if err != nil {
+				log.Printf("WARN: MarketDataService 查询%s永续合约数据失败: %v", req.InstrumentID, err)
+			}
+			detail.DerivativeHistory = history
+		}
+	}
+
 	if len(kLines) >= 2 {
 		startTime := kLines[0].Timestamp
 		endTime := kLines[len(kLines)-1].Timestamp
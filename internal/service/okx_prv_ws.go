@@ -0,0 +1,466 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"edgeflow/internal/model"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 私有频道，登录成功后一次性订阅
+var okxPrivateChannels = []map[string]string{
+	{"channel": "orders", "instType": "ANY"},
+	{"channel": "positions", "instType": "ANY"},
+	{"channel": "balance_and_position"},
+	{"channel": "orders-algo", "instType": "ANY"},
+}
+
+// 登录需要在这个时间内完成，否则断开重连
+const okxLoginTimeout = 10 * time.Second
+
+// OKXPrivateService OKX 私有频道：登录后订阅订单、仓位、余额和策略委托推送，
+// 断线后重新登录并恢复订阅。推送按类型分发到各自的通道，通道满时丢弃并记录日志，
+// 订单状态还有 OrderTracker 轮询兜底，仓位还有 Reconciler 定期对账兜底
+type OKXPrivateService struct {
+	apiKey     string
+	secretKey  string
+	passphrase string
+	url        string
+
+	orderCh    chan *model.OrderUpdate
+	positionCh chan *model.PositionUpdate
+	balanceCh  chan *model.BalanceUpdate
+	algoCh     chan *model.AlgoOrderUpdate
+
+	mu     sync.Mutex
+	logins int // 登录成功的次数，包括重连
+	now    func() time.Time
+}
+
+// NewOKXPrivateService 使用与 REST 相同的 API Key 登录
+func NewOKXPrivateService(apiKey, secretKey, passphrase string) *OKXPrivateService {
+	return &OKXPrivateService{
+		apiKey:     apiKey,
+		secretKey:  secretKey,
+		passphrase: passphrase,
+		url:        "wss://ws.okx.com:8443/ws/v5/private",
+		orderCh:    make(chan *model.OrderUpdate, 256),
+		positionCh: make(chan *model.PositionUpdate, 256),
+		balanceCh:  make(chan *model.BalanceUpdate, 64),
+		algoCh:     make(chan *model.AlgoOrderUpdate, 64),
+		now:        time.Now,
+	}
+}
+
+// WithURL 替换私有频道地址，需要在 Run 之前调用，测试时指向本地的 okxtest 服务
+func (s *OKXPrivateService) WithURL(url string) *OKXPrivateService {
+	s.url = url
+	return s
+}
+
+// Orders 订单状态变化，交给 OrderTracker.Consume
+func (s *OKXPrivateService) Orders() <-chan *model.OrderUpdate {
+	return s.orderCh
+}
+
+// Positions 仓位变化，交给 Reconciler.Consume
+func (s *OKXPrivateService) Positions() <-chan *model.PositionUpdate {
+	return s.positionCh
+}
+
+// Balances 币种余额变化
+func (s *OKXPrivateService) Balances() <-chan *model.BalanceUpdate {
+	return s.balanceCh
+}
+
+// AlgoOrders 止盈止损等策略委托的变化
+func (s *OKXPrivateService) AlgoOrders() <-chan *model.AlgoOrderUpdate {
+	return s.algoCh
+}
+
+// Logins 登录成功的次数，重连后会增加
+func (s *OKXPrivateService) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// Run 连接、登录并订阅，断开后 2s 重连，直到 ctx 结束
+func (s *OKXPrivateService) Run(ctx context.Context) {
+	for {
+		err := s.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[OKXPrivate] 连接断开，2s 后重连: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// session 一次连接的生命周期：登录、订阅，然后读取推送直到断开
+func (s *OKXPrivateService) session(ctx context.Context) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.url, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// ctx 结束时关闭连接，让 ReadMessage 返回
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	// gorilla/websocket 不支持并发写，ping 和订阅共用一把锁
+	var wmu sync.Mutex
+	write := func(messageType int, data []byte) error {
+		wmu.Lock()
+		defer wmu.Unlock()
+		return conn.WriteMessage(messageType, data)
+	}
+
+	login, _ := json.Marshal(s.loginMessage())
+	if err := write(websocket.TextMessage, login); err != nil {
+		return err
+	}
+	if err := s.awaitLogin(conn); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.logins++
+	s.mu.Unlock()
+	log.Println("[OKXPrivate] 登录成功，订阅私有频道")
+
+	sub, _ := json.Marshal(map[string]any{"op": "subscribe", "args": okxPrivateChannels})
+	if err := write(websocket.TextMessage, sub); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := write(websocket.TextMessage, []byte("ping")); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		s.handleMessage(msg)
+	}
+}
+
+// loginMessage 签名为 base64(hmac_sha256(secret, timestamp+"GET"+"/users/self/verify"))，timestamp 为秒
+func (s *OKXPrivateService) loginMessage() map[string]any {
+	ts := strconv.FormatInt(s.now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(s.secretKey))
+	mac.Write([]byte(ts + "GET" + "/users/self/verify"))
+	return map[string]any{
+		"op": "login",
+		"args": []map[string]string{{
+			"apiKey":     s.apiKey,
+			"passphrase": s.passphrase,
+			"timestamp":  ts,
+			"sign":       base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+		}},
+	}
+}
+
+// awaitLogin 等待登录结果，登录失败（例如 60009 签名错误）返回错误
+func (s *OKXPrivateService) awaitLogin(conn *websocket.Conn) error {
+	_ = conn.SetReadDeadline(s.now().Add(okxLoginTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("等待登录结果失败: %w", err)
+		}
+		var ev okxPrivatePush
+		if json.Unmarshal(msg, &ev) != nil {
+			continue
+		}
+		switch ev.Event {
+		case "login":
+			if ev.Code == "0" || ev.Code == "" {
+				return nil
+			}
+			return fmt.Errorf("登录失败 %s: %s", ev.Code, ev.Msg)
+		case "error":
+			return fmt.Errorf("登录失败 %s: %s", ev.Code, ev.Msg)
+		}
+	}
+}
+
+type okxPrivatePush struct {
+	Event string `json:"event"`
+	Code  string `json:"code"`
+	Msg   string `json:"msg"`
+	Arg   struct {
+		Channel string `json:"channel"`
+	} `json:"arg"`
+	Data json.RawMessage `json:"data"`
+}
+
+type okxOrderPush struct {
+	InstType  string `json:"instType"`
+	InstId    string `json:"instId"`
+	OrdId     string `json:"ordId"`
+	ClOrdId   string `json:"clOrdId"`
+	Side      string `json:"side"`
+	PosSide   string `json:"posSide"`
+	State     string `json:"state"`
+	Sz        string `json:"sz"`
+	AccFillSz string `json:"accFillSz"`
+	AvgPx     string `json:"avgPx"`
+	Fee       string `json:"fee"`
+	FeeCcy    string `json:"feeCcy"`
+	UTime     string `json:"uTime"`
+}
+
+type okxPositionPush struct {
+	InstType    string `json:"instType"`
+	InstId      string `json:"instId"`
+	PosSide     string `json:"posSide"`
+	Pos         string `json:"pos"`
+	AvgPx       string `json:"avgPx"`
+	MgnMode     string `json:"mgnMode"`
+	LiqPx       string `json:"liqPx"`
+	PosId       string `json:"posId"`
+	Upl         string `json:"upl"`
+	UplRatio    string `json:"uplRatio"`
+	MarkPx      string `json:"markPx"`
+	Margin      string `json:"margin"`
+	Lever       string `json:"lever"`
+	NotionalUsd string `json:"notionalUsd"`
+	Last        string `json:"last"`
+	CTime       string `json:"cTime"`
+	UTime       string `json:"uTime"`
+	// 仓位上挂的止盈止损
+	CloseOrderAlgo []struct {
+		AlgoId string `json:"algoId"`
+	} `json:"closeOrderAlgo"`
+}
+
+type okxBalancePush struct {
+	PTime   string `json:"pTime"`
+	BalData []struct {
+		Ccy     string `json:"ccy"`
+		CashBal string `json:"cashBal"`
+		UTime   string `json:"uTime"`
+	} `json:"balData"`
+}
+
+type okxAlgoPush struct {
+	AlgoId      string `json:"algoId"`
+	InstType    string `json:"instType"`
+	InstId      string `json:"instId"`
+	OrdType     string `json:"ordType"`
+	State       string `json:"state"`
+	OrdId       string `json:"ordId"`
+	TpTriggerPx string `json:"tpTriggerPx"`
+	SlTriggerPx string `json:"slTriggerPx"`
+	UTime       string `json:"uTime"`
+}
+
+func (s *OKXPrivateService) handleMessage(msg []byte) {
+	if string(msg) == "pong" {
+		return
+	}
+	var push okxPrivatePush
+	if err := json.Unmarshal(msg, &push); err != nil {
+		log.Println("[OKXPrivate] json反序列化 error:", err)
+		return
+	}
+	switch push.Event {
+	case "":
+	case "error":
+		log.Printf("[OKXPrivate] [ERROR] Code: %s, Message: %s", push.Code, push.Msg)
+		return
+	default:
+		return
+	}
+	if len(push.Data) == 0 {
+		return
+	}
+
+	var err error
+	switch push.Arg.Channel {
+	case "orders":
+		var items []okxOrderPush
+		if err = json.Unmarshal(push.Data, &items); err == nil {
+			for _, it := range items {
+				send(s.orderCh, it.update(), "orders")
+			}
+		}
+	case "positions":
+		var items []okxPositionPush
+		if err = json.Unmarshal(push.Data, &items); err == nil {
+			for _, it := range items {
+				send(s.positionCh, it.update(), "positions")
+			}
+		}
+	case "balance_and_position":
+		var items []okxBalancePush
+		if err = json.Unmarshal(push.Data, &items); err == nil {
+			for _, it := range items {
+				for _, b := range it.BalData {
+					send(s.balanceCh, &model.BalanceUpdate{
+						Currency:  b.Ccy,
+						CashBal:   parseFloat(b.CashBal),
+						UpdatedAt: time.UnixMilli(parseInt64(b.UTime)),
+					}, "balance_and_position")
+				}
+			}
+		}
+	case "orders-algo":
+		var items []okxAlgoPush
+		if err = json.Unmarshal(push.Data, &items); err == nil {
+			for _, it := range items {
+				send(s.algoCh, it.update(), "orders-algo")
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("[OKXPrivate] 解析 %s 推送失败: %v", push.Arg.Channel, err)
+	}
+}
+
+// send 不阻塞读循环，消费方跟不上时丢弃
+func send[T any](ch chan T, v T, channel string) {
+	select {
+	case ch <- v:
+	default:
+		log.Printf("[OKXPrivate] %s 通道已满，丢弃推送", channel)
+	}
+}
+
+func (o okxOrderPush) update() *model.OrderUpdate {
+	filled := parseFloat(o.AccFillSz)
+	return &model.OrderUpdate{
+		OrderStatus: model.OrderStatus{
+			OrderID:   o.OrdId,
+			Status:    okxOrderState(o.State),
+			Filled:    filled,
+			Remaining: parseFloat(o.Sz) - filled,
+			AvgPrice:  parseFloat(o.AvgPx),
+			Fee:       parseFloat(o.Fee),
+			FeeCcy:    o.FeeCcy,
+		},
+		InstId:        o.InstId,
+		TradeType:     okxTradeType(o.InstType),
+		ClientOrderID: o.ClOrdId,
+		Side:          model.OrderSide(o.Side),
+		PosSide:       model.OrderPosSide(o.PosSide),
+		UpdatedAt:     time.UnixMilli(parseInt64(o.UTime)),
+	}
+}
+
+func (p okxPositionPush) update() *model.PositionUpdate {
+	pos := parseFloat(p.Pos)
+	dir := model.OrderPosSide(p.PosSide)
+	if p.PosSide == "net" {
+		// 单向持仓模式按数量的正负区分方向
+		dir = model.OrderPosSideLong
+		if pos < 0 {
+			dir = model.OrderPosSideShort
+		}
+	}
+	u := &model.PositionUpdate{
+		PositionInfo: model.PositionInfo{
+			Symbol:        okxInstSymbol(p.InstId),
+			Dir:           dir,
+			Amount:        math.Abs(pos),
+			AvgPrice:      parseFloat(p.AvgPx),
+			MgnMode:       p.MgnMode,
+			LiqPx:         p.LiqPx,
+			PositionId:    p.PosId,
+			UnrealizedPnl: p.Upl,
+			UplRatio:      p.UplRatio,
+			MarkPx:        p.MarkPx,
+			Margin:        p.Margin,
+			Lever:         p.Lever,
+			NotionalUsd:   p.NotionalUsd,
+			Last:          parseFloat(p.Last),
+			CTime:         p.CTime,
+		},
+		InstId:    p.InstId,
+		TradeType: okxTradeType(p.InstType),
+		UpdatedAt: time.UnixMilli(parseInt64(p.UTime)),
+	}
+	if len(p.CloseOrderAlgo) > 0 {
+		u.AlgoId = p.CloseOrderAlgo[0].AlgoId
+	}
+	return u
+}
+
+func (a okxAlgoPush) update() *model.AlgoOrderUpdate {
+	return &model.AlgoOrderUpdate{
+		AlgoId:      a.AlgoId,
+		InstId:      a.InstId,
+		TradeType:   okxTradeType(a.InstType),
+		OrdType:     a.OrdType,
+		State:       a.State,
+		OrderID:     a.OrdId,
+		TpTriggerPx: parseFloat(a.TpTriggerPx),
+		SlTriggerPx: parseFloat(a.SlTriggerPx),
+		UpdatedAt:   time.UnixMilli(parseInt64(a.UTime)),
+	}
+}
+
+// okxOrderState OKX 订单状态转换为与 goex 一致的状态
+func okxOrderState(state string) string {
+	switch state {
+	case "partially_filled":
+		return model.OrderStatusPartFilled
+	case "filled":
+		return model.OrderStatusFilled
+	case "canceled", "mmp_canceled":
+		return model.OrderStatusCanceled
+	}
+	return model.OrderStatusPending
+}
+
+func okxTradeType(instType string) model.OrderTradeType {
+	switch instType {
+	case "SWAP":
+		return model.OrderTradeSwap
+	case "FUTURES":
+		return model.OrderTradeFutures
+	case "SPOT", "MARGIN":
+		return model.OrderTradeSpot
+	}
+	return ""
+}
+
+// okxInstSymbol BTC-USDT-SWAP 转换为本地使用的 BTC/USDT
+func okxInstSymbol(instId string) string {
+	parts := strings.Split(instId, "-")
+	if len(parts) < 2 {
+		return instId
+	}
+	return parts[0] + "/" + parts[1]
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"edgeflow/internal/model"
+	"edgeflow/pkg/exchange"
+	"edgeflow/pkg/exchange/okxtest"
+	"testing"
+	"time"
+)
+
+// recv 等待通道中满足条件的推送
+func recv[T any](t *testing.T, ch <-chan T, match func(T) bool) T {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case v := <-ch:
+			if match(v) {
+				return v
+			}
+		case <-timeout:
+			t.Fatal("no matching push")
+		}
+	}
+}
+
+func TestOKXPrivateService(t *testing.T) {
+	srv := okxtest.NewServer()
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 第一次登录失败，2s 后重连并重新登录
+	srv.RejectLogins(1)
+	s := NewOKXPrivateService("test-key", "test-secret", "test-passphrase").WithURL(srv.PrivateWSURL())
+	go s.Run(ctx)
+
+	// 订阅后先推送余额和仓位快照，数量为 0 的仓位不推送
+	bal := recv(t, s.Balances(), func(b *model.BalanceUpdate) bool { return b.Currency == "USDT" })
+	if s.Logins() != 1 || srv.Dials() != 2 {
+		t.Fatalf("logins=%d dials=%d", s.Logins(), srv.Dials())
+	}
+	if bal.CashBal <= 0 {
+		t.Errorf("balance = %+v", bal)
+	}
+	pos := recv(t, s.Positions(), func(p *model.PositionUpdate) bool { return p.InstId == "SOL-USDT-SWAP" })
+	if pos.Symbol != "SOL/USDT" || pos.TradeType != model.OrderTradeSwap || pos.Dir != model.OrderPosSideLong ||
+		pos.Amount != 1.2 || pos.AvgPrice != 183.5 || pos.AlgoId != "2045018394058174464" {
+		t.Errorf("position = %+v", pos)
+	}
+
+	// 下单、部分成交、全部成交都会推送
+	okxEx := exchange.NewOkxExchange("test-key", "test-secret", "test-passphrase").WithBaseURL(srv.URL)
+	resp, err := okxEx.PlaceOrder(ctx, &model.Order{
+		Symbol: "SOL/USDT", Side: model.Buy, Price: 185.1, Quantity: 2, OrderType: model.Limit, TradeType: model.OrderTradeSwap,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byId := func(u *model.OrderUpdate) bool { return u.OrderID == resp.OrderId }
+	if u := recv(t, s.Orders(), byId); u.Status != model.OrderStatusPending || u.InstId != "SOL-USDT-SWAP" || u.PosSide != model.OrderPosSideLong {
+		t.Errorf("live order = %+v", u)
+	}
+	srv.FillOrder(resp.OrderId, "185.1", "0.5")
+	if u := recv(t, s.Orders(), byId); u.Status != model.OrderStatusPartFilled || u.Filled != 0.5 || u.Remaining != 1.5 {
+		t.Errorf("partial fill = %+v", u)
+	}
+	srv.FillOrder(resp.OrderId, "185", "")
+	u := recv(t, s.Orders(), byId)
+	if u.Status != model.OrderStatusFilled || u.Filled != 2 || u.AvgPrice != 185.025 || u.Fee >= 0 || u.TradeType != model.OrderTradeSwap {
+		t.Errorf("filled order = %+v", u)
+	}
+
+	// 止盈止损触发后仓位归零
+	srv.SetPosition("SOL-USDT-SWAP", "long", map[string]string{"pos": "0"})
+	if p := recv(t, s.Positions(), func(p *model.PositionUpdate) bool { return p.InstId == "SOL-USDT-SWAP" }); p.Amount != 0 {
+		t.Errorf("closed position = %+v", p)
+	}
+
+	// 断线后重新登录并恢复订阅
+	srv.DropConnections()
+	if !waitUntil(5*time.Second, func() bool { return s.Logins() == 2 && srv.Subscribed("orders", "") }) {
+		t.Fatalf("not resubscribed, logins=%d", s.Logins())
+	}
+	for _, channel := range []string{"positions", "balance_and_position", "orders-algo"} {
+		if !srv.Subscribed(channel, "") {
+			t.Errorf("%s not resubscribed", channel)
+		}
+	}
+	srv.Push("orders-algo", "", []map[string]string{{
+		"algoId": "2045018394058174465", "instType": "SWAP", "instId": "BTC-USDT-SWAP", "ordType": "conditional",
+		"state": "effective", "ordId": "2045100000000000099", "slTriggerPx": "110000", "uTime": "1760688000000",
+	}})
+	algo := recv(t, s.AlgoOrders(), func(a *model.AlgoOrderUpdate) bool { return true })
+	if algo.State != "effective" || algo.SlTriggerPx != 110000 || algo.OrderID != "2045100000000000099" {
+		t.Errorf("algo order = %+v", algo)
+	}
+}
@@ -89,8 +89,15 @@ func NewOKXCandleService(producer kafka.ProducerService) *OKXCandleService {
 	return s
 }
 
+// WithURL 替换业务频道地址，需要在首次订阅之前调用，测试时指向本地的 okxtest 服务
+func (s *OKXCandleService) WithURL(url string) *OKXCandleService {
+	s.url = url
+	return s
+}
+
 // --- 连接和重连逻辑 (与 TickerService 类似，确保独立性) ---
-func (s *OKXCandleService) startPingLoop(conn *websocket.Conn) {
+// closeCh 在启动时传入，重连时 run 会替换 s.closeCh
+func (s *OKXCandleService) startPingLoop(conn *websocket.Conn, closeCh <-chan struct{}) {
 	ticker := time.NewTicker(time.Second * 15)
 	defer ticker.Stop()
 
@@ -106,7 +113,7 @@ func (s *OKXCandleService) startPingLoop(conn *websocket.Conn) {
 				return
 			}
 
-		case <-s.closeCh:
+		case <-closeCh:
 			return
 		}
 	}
@@ -139,7 +146,9 @@ func (s *OKXCandleService) resubscribeAll() (int, error) {
 		"args": args,
 	}
 
-	// 发送批量订阅
+	// 发送批量订阅，与 SubscribeCandle 等一样持有写锁，同一时间只有一个协程写连接
+	s.Lock()
+	defer s.Unlock()
 	return len(keys), s.writeMessageInternal(subMsg)
 }
 
@@ -196,6 +205,7 @@ func (s *OKXCandleService) run() {
 			close(s.closeCh)
 		}
 		s.closeCh = make(chan struct{})
+		closeCh := s.closeCh
 
 		// 注意：这里没有清空 s.subscribed，因为 resubscribeAll 会依赖它来恢复订阅
 		s.Unlock()
@@ -213,7 +223,7 @@ func (s *OKXCandleService) run() {
 		}
 
 		// 启动 Ping 循环
-		go s.startPingLoop(conn)
+		go s.startPingLoop(conn, closeCh)
 		s.runListen(conn) // 阻塞直到连接断开
 
 		// 连接断开后，重置状态
@@ -237,7 +247,7 @@ func (s *OKXCandleService) runListen(conn *websocket.Conn) {
 	}
 }
 
-// 内部方法，负责限速，假设外部已加锁 s.Lock() 或 s.RLock()
+// 内部方法，负责限速，调用方必须持有 s.Lock()：lastRequest 和连接的写入都不是并发安全的
 func (s *OKXCandleService) writeMessageInternal(message interface{}) error {
 	timeSinceLastRequest := time.Since(s.lastRequest)
 	if timeSinceLastRequest < 50*time.Millisecond {
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"edgeflow/pkg/exchange/okxtest"
+	"edgeflow/pkg/kafka"
+	pb "edgeflow/pkg/protobuf"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureProducer 记录写入 Kafka 的消息
+type captureProducer struct {
+	mu   sync.Mutex
+	msgs []kafka.Message
+}
+
+func (p *captureProducer) Produce(ctx context.Context, topic string, messages ...kafka.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.msgs = append(p.msgs, messages...)
+	return nil
+}
+
+func (p *captureProducer) Close() {}
+
+// count 已收到的 K 线推送数量，按是否收盘区分
+func (p *captureProducer) count(key string, confirm bool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, m := range p.msgs {
+		if m.Key == key && m.Data.(*pb.WebSocketMessage).GetKlineUpdate().GetConfirm() == confirm {
+			n++
+		}
+	}
+	return n
+}
+
+func TestOKXCandleService_Subscribe(t *testing.T) {
+	srv := okxtest.NewServer()
+	defer srv.Close()
+
+	producer := &captureProducer{}
+	s := NewOKXCandleService(producer).WithURL(srv.BusinessWSURL())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 首次订阅时才建立连接，订阅成功后先推送当前未收盘的 K 线
+	if err := s.SubscribeCandle(ctx, "BTC-USDT-SWAP", "15m"); err != nil {
+		t.Fatal(err)
+	}
+	const key = "CANDLE:BTC-USDT-SWAP:15m"
+	if !waitUntil(2*time.Second, func() bool { return producer.count(key, false) == 1 }) {
+		t.Fatal("snapshot not produced")
+	}
+	srv.PushCandle("BTC-USDT-SWAP", "15m", []string{"1760688000000", "106000", "106600", "105900", "106480.5", "120", "1.2", "127776.6", "1"})
+	if !waitUntil(2*time.Second, func() bool { return producer.count(key, true) == 1 }) {
+		t.Fatal("closed candle not produced")
+	}
+
+	// 不存在的交易对通过错误通道通知上游，并清理本地订阅
+	if err := s.SubscribeCandle(ctx, "XXX-USDT-SWAP", "15m"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-s.GetErrorChannel():
+		if e.Data["symbol"] != "XXX-USDT-SWAP" || e.Data["period"] != "15m" {
+			t.Errorf("client error = %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no subscribe error")
+	}
+
+	// 断线后重连并恢复已有订阅
+	srv.DropConnections()
+	if !waitUntil(5*time.Second, func() bool { return srv.Dials() == 2 && srv.Subscribed("candle15m", "BTC-USDT-SWAP") }) {
+		t.Fatalf("not resubscribed, dials=%d", srv.Dials())
+	}
+	if srv.Subscribed("candle15m", "XXX-USDT-SWAP") {
+		t.Error("failed subscription should not be restored")
+	}
+}
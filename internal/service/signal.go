@@ -12,18 +12,21 @@ import (
 
 // SignalProcessorService 是集成了所有功能的单体服务
 type SignalProcessorService struct {
-	signalRepo dao.SignalDao // DB 接口
+	signalRepo   dao.SignalDao // DB 接口
+	decisionRepo dao.SignalDecisionDao
 
 	ex exchange.Exchange
 }
 
 func NewSignalProcessorService(
 	signalRepo dao.SignalDao,
+	decisionRepo dao.SignalDecisionDao,
 	ex exchange.Exchange,
 ) *SignalProcessorService {
 	return &SignalProcessorService{
-		signalRepo: signalRepo,
-		ex:         ex,
+		signalRepo:   signalRepo,
+		decisionRepo: decisionRepo,
+		ex:           ex,
 	}
 }
 
@@ -51,6 +54,15 @@ func (s *SignalProcessorService) SignalGetDetail(ctx context.Context, signalID i
 		return nil, err
 	}
 
+	// 该信号触发的交易决策，查询失败不影响详情
+	decisions, err := s.decisionRepo.GetDecisionsBySignal(ctx, uint64(detail.ID), detail.Symbol, detail.Timestamp)
+	if err == nil {
+		detail.Decisions = make([]model22.SignalDecisionBrief, 0, len(decisions))
+		for _, d := range decisions {
+			detail.Decisions = append(detail.Decisions, model22.NewSignalDecisionBrief(d))
+		}
+	}
+
 	// 192 是48个小时的15分钟k线数量
 	start, end := calcKlineTimeRange(detail.Timestamp, 15, 192, time.Now())
 	klines, err := s.ex.GetKlineRecords(detail.Symbol, model2.Kline_15min, 192, start, end, model22.OrderTradeSwap, true)
@@ -97,11 +109,12 @@ func calcKlineTimeRange(signalTime time.Time, periodMinutes int, count int, late
 	return
 }
 
-func (s *SignalProcessorService) ExecuteOrder(ctx context.Context, signalID int64, ex exchange.Exchange) error {
+// BuildOrder 按信号的入场价和推荐止盈止损生成限价单，杠杆和仓位比例由调用方按用户设置填写
+func (s *SignalProcessorService) BuildOrder(ctx context.Context, signalID int64) (*model22.Order, error) {
 	// 查询信号
 	signal, err := s.signalRepo.GetSignalByID(ctx, uint(signalID))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var side model22.OrderSide
@@ -110,38 +123,23 @@ func (s *SignalProcessorService) ExecuteOrder(ctx context.Context, signalID int6
 		side = model22.Buy
 	case "SELL", "REVERSAL_SELL":
 		side = model22.Sell
+	default:
+		return nil, fmt.Errorf("信号 %d 不支持下单: %s", signalID, signal.Command)
 	}
 
-	//if req.OrderType == "market" {
-	//	// 可考虑调用市场价格作为 fallback
-	//	price, err := t.Exchange.GetLastPrice(req.Symbol, tradeType)
-	//	if err != nil {
-	//		return err
-	//	}
-	//	req.Price = price
-	//}
-
-	order := model22.Order{
-		Symbol:      signal.Symbol,
-		Side:        side,
-		Price:       signal.EntryPrice,
-		Quantity:    0,
-		OrderType:   model22.Limit,
-		TPPrice:     signal.RecommendedTP,
-		SLPrice:     signal.RecommendedSL,
-		Strategy:    fmt.Sprintf("%v - %v", signal.Symbol, signal.Period),
-		Comment:     "",
-		TradeType:   model22.OrderTradeSwap,
-		MgnMode:     model22.OrderMgnModeIsolated,
-		Leverage:    5,
-		QuantityPct: 0.2,
-		Level:       3,
-		Timestamp:   time.Now(),
-	}
-	_, err = ex.PlaceOrder(ctx, &order)
-
-	if err != nil {
-		return err
-	}
-	return nil
+	return &model22.Order{
+		Symbol:    signal.Symbol,
+		Side:      side,
+		Price:     signal.EntryPrice,
+		Quantity:  0,
+		OrderType: model22.Limit,
+		TPPrice:   signal.RecommendedTP,
+		SLPrice:   signal.RecommendedSL,
+		Strategy:  fmt.Sprintf("%v - %v", signal.Symbol, signal.Period),
+		Comment:   "",
+		TradeType: model22.OrderTradeSwap,
+		MgnMode:   model22.OrderMgnModeIsolated,
+		Level:     3,
+		Timestamp: time.Now(),
+	}, nil
 }
@@ -0,0 +1,395 @@
+package kline
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	"edgeflow/internal/service"
+	"edgeflow/pkg/exchange"
+	"edgeflow/pkg/kafka"
+	pb "edgeflow/pkg/protobuf"
+	"edgeflow/pkg/utils"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nntaoli-project/goex/v2/model"
+	"github.com/nntaoli-project/goex/v2/okx/common"
+	kafka2 "github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// 每个周期保留的K线数量，趋势评分至少需要200根，OKX单次最多返回300根
+	defaultWindowSize = 300
+	// 独立的消费组，保证与 SubscriptionGateway 各自收到全量的 K 线推送
+	klineConsumerGroup = "edgeflow_kline_manager_group"
+	// 收盘回调的防抖时间，多个币种几乎同时收盘时只触发一次
+	onUpdateDebounce = 3 * time.Second
+)
+
+// 趋势计算需要的周期
+var defaultPeriods = []model.KlinePeriod{model.Kline_4h, model.Kline_1h, model.Kline_30min, model.Kline_15min}
+
+// KlineManager 多币种、多周期的滚动K线缓存
+// 启动时通过 REST 回补历史K线，之后依赖 OKXCandleService 推送到 Kafka 的已收盘K线保持最新
+// 缓存中的K线顺序为 从旧到新
+type KlineManager struct {
+	mu sync.RWMutex
+
+	ex       exchange.Exchange
+	candle   service.CandleService
+	consumer kafka.ConsumerService
+
+	symbols    []string
+	periods    []model.KlinePeriod
+	windowSize int
+
+	// symbol -> period -> klines
+	caches map[string]map[model.KlinePeriod][]model2.Kline
+
+	onUpdate      func()
+	debounceTimer *time.Timer
+}
+
+// NewKlineManager 创建K线管理器
+// candle 和 consumer 为空时退化为按周期对齐的 REST 轮询
+func NewKlineManager(ex exchange.Exchange, candle service.CandleService, consumer kafka.ConsumerService, symbols []string) *KlineManager {
+	newSymbols := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		newSymbols[i] = utils.FormatSymbol(symbol)
+	}
+	caches := make(map[string]map[model.KlinePeriod][]model2.Kline, len(newSymbols))
+	for _, symbol := range newSymbols {
+		caches[symbol] = make(map[model.KlinePeriod][]model2.Kline, len(defaultPeriods))
+	}
+	return &KlineManager{
+		ex:         ex,
+		candle:     candle,
+		consumer:   consumer,
+		symbols:    newSymbols,
+		periods:    defaultPeriods,
+		windowSize: defaultWindowSize,
+		caches:     caches,
+	}
+}
+
+// Get 获取某币种某周期的K线副本，顺序为 从旧到新
+func (m *KlineManager) Get(symbol string, period model.KlinePeriod) ([]model2.Kline, bool) {
+	symbol = utils.FormatSymbol(symbol)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	byPeriod, ok := m.caches[symbol]
+	if !ok {
+		return nil, false
+	}
+	lines, ok := byPeriod[period]
+	if !ok || len(lines) == 0 {
+		return nil, false
+	}
+	out := make([]model2.Kline, len(lines))
+	copy(out, lines)
+	return out, true
+}
+
+// RunScheduled 启动K线缓存，回补完成后以及之后每次有K线收盘时调用 onUpdate，直到 ctx 结束
+func (m *KlineManager) RunScheduled(ctx context.Context, onUpdate func()) {
+	m.mu.Lock()
+	m.onUpdate = onUpdate
+	m.mu.Unlock()
+
+	if err := m.Start(ctx); err != nil {
+		log.Printf("[KlineManager] 启动失败: %v", err)
+		return
+	}
+	if onUpdate != nil {
+		onUpdate()
+	}
+}
+
+// Start 回补历史K线，并开始接收实时K线
+func (m *KlineManager) Start(ctx context.Context) error {
+	m.backfillAll()
+
+	if m.candle == nil || m.consumer == nil {
+		go m.runPolling(ctx)
+		return nil
+	}
+
+	// 先启动消费，避免订阅成功后的第一批推送丢失
+	msgCh, err := m.consumer.Consume(ctx, kafka.TopicSubscribe, klineConsumerGroup)
+	if err != nil {
+		return err
+	}
+	go m.listen(ctx, msgCh)
+
+	for _, symbol := range m.symbols {
+		for _, period := range m.periods {
+			if err := m.candle.SubscribeCandle(ctx, toInstId(symbol), common.AdaptKlinePeriodToSymbol(period)); err != nil {
+				log.Printf("[KlineManager] 订阅 %s %s K线失败: %v", symbol, period, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *KlineManager) backfillAll() {
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, 3) // 最多3个并发，避免触发限频
+
+	for _, symbol := range m.symbols {
+		for _, period := range m.periods {
+			wg.Add(1)
+			go func(sym string, p model.KlinePeriod) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+
+				if err := m.backfill(sym, p); err != nil {
+					log.Printf("[KlineManager] 回补 %s %s K线失败: %v", sym, p, err)
+				}
+			}(symbol, period)
+		}
+	}
+	wg.Wait()
+}
+
+// backfill 通过 REST 拉取最近的已收盘K线，整体替换缓存
+func (m *KlineManager) backfill(symbol string, period model.KlinePeriod) error {
+	lines, err := m.ex.GetKlineRecords(symbol, period, m.windowSize, 0, 0, model2.OrderTradeSwap, false)
+	if err != nil {
+		return err
+	}
+	// 不依赖交易所返回的顺序，统一按时间升序
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].Timestamp.Before(lines[j].Timestamp)
+	})
+	if len(lines) > m.windowSize {
+		lines = lines[len(lines)-m.windowSize:]
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.caches[symbol]; !ok {
+		m.caches[symbol] = make(map[model.KlinePeriod][]model2.Kline)
+	}
+	m.caches[symbol][period] = lines
+	return nil
+}
+
+func (m *KlineManager) listen(ctx context.Context, msgCh <-chan kafka2.Message) {
+	for {
+		var msg kafka2.Message
+		select {
+		case <-ctx.Done():
+			log.Println("[KlineManager] 停止消费K线")
+			return
+		case m2, ok := <-msgCh:
+			if !ok {
+				log.Println("[KlineManager] K线消费通道已关闭")
+				return
+			}
+			msg = m2
+		}
+		// 只关心K线消息，直接通过 key 过滤，避免反序列化无关数据
+		if !strings.HasPrefix(string(msg.Key), "CANDLE:") {
+			continue
+		}
+
+		var wsMsg pb.WebSocketMessage
+		if err := proto.Unmarshal(msg.Value, &wsMsg); err != nil {
+			log.Printf("[KlineManager] 解析K线消息失败: %v", err)
+			continue
+		}
+		update := wsMsg.GetKlineUpdate()
+		if update == nil || !update.GetConfirm() || update.GetData() == nil {
+			// 未收盘的K线不进入缓存
+			continue
+		}
+
+		symbol, ok := fromInstId(update.GetInstId())
+		if !ok {
+			continue
+		}
+		period, ok := fromBar(update.GetTimePeriod())
+		if !ok {
+			continue
+		}
+		line, err := toKline(update.GetData())
+		if err != nil {
+			log.Printf("[KlineManager] 转换K线失败 %s %s: %v", symbol, period, err)
+			continue
+		}
+
+		applied, gap := m.apply(symbol, period, line)
+		if gap {
+			// 中间缺了K线（比如断线），重新回补整段窗口
+			go func() {
+				if err := m.backfill(symbol, period); err != nil {
+					log.Printf("[KlineManager] 补齐 %s %s 缺口失败: %v", symbol, period, err)
+				}
+			}()
+		}
+		if applied {
+			m.notifyUpdate()
+		}
+	}
+}
+
+// apply 把一根已收盘的K线合并到缓存
+// applied 表示缓存是否发生变化，gap 表示与上一根之间存在缺口
+func (m *KlineManager) apply(symbol string, period model.KlinePeriod, line model2.Kline) (applied bool, gap bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byPeriod, ok := m.caches[symbol]
+	if !ok {
+		// 只维护配置中的币种
+		return false, false
+	}
+	lines := byPeriod[period]
+	if len(lines) == 0 {
+		byPeriod[period] = []model2.Kline{line}
+		return true, false
+	}
+
+	last := lines[len(lines)-1]
+	switch {
+	case line.Timestamp.Equal(last.Timestamp):
+		lines[len(lines)-1] = line
+		return true, false
+	case line.Timestamp.Before(last.Timestamp):
+		// 迟到的消息，只更新已存在的那根
+		for i := len(lines) - 1; i >= 0; i-- {
+			if lines[i].Timestamp.Equal(line.Timestamp) {
+				lines[i] = line
+				return true, false
+			}
+		}
+		return false, false
+	}
+
+	gap = line.Timestamp.Sub(last.Timestamp) > periodDuration(period)
+	lines = append(lines, line)
+	if len(lines) > m.windowSize {
+		lines = lines[len(lines)-m.windowSize:]
+	}
+	byPeriod[period] = lines
+	return true, gap
+}
+
+// notifyUpdate 防抖后触发收盘回调
+func (m *KlineManager) notifyUpdate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.onUpdate == nil || m.debounceTimer != nil {
+		return
+	}
+	m.debounceTimer = time.AfterFunc(onUpdateDebounce, func() {
+		m.mu.Lock()
+		fn := m.onUpdate
+		m.debounceTimer = nil
+		m.mu.Unlock()
+		fn()
+	})
+}
+
+// runPolling 没有实时推送时，在每根15m K线收盘后通过 REST 刷新到期的周期
+func (m *KlineManager) runPolling(ctx context.Context) {
+	for {
+		now := time.Now()
+		next := now.Truncate(15 * time.Minute).Add(15 * time.Minute)
+		// 多等几秒确保交易所数据已经更新
+		timer := time.NewTimer(time.Until(next.Add(5 * time.Second)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		for _, symbol := range m.symbols {
+			for _, period := range m.periods {
+				if next.Truncate(periodDuration(period)) != next {
+					continue
+				}
+				if err := m.backfill(symbol, period); err != nil {
+					log.Printf("[KlineManager] 刷新 %s %s K线失败: %v", symbol, period, err)
+				}
+			}
+		}
+
+		m.mu.RLock()
+		fn := m.onUpdate
+		m.mu.RUnlock()
+		if fn != nil {
+			fn()
+		}
+	}
+}
+
+func periodDuration(period model.KlinePeriod) time.Duration {
+	switch period {
+	case model.Kline_15min:
+		return 15 * time.Minute
+	case model.Kline_30min:
+		return 30 * time.Minute
+	case model.Kline_1h, model.Kline_60min:
+		return time.Hour
+	case model.Kline_4h:
+		return 4 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// fromBar 把 OKX 的周期（4H/1H/30m/15m）转换回 goex 的周期
+func fromBar(bar string) (model.KlinePeriod, bool) {
+	for _, period := range defaultPeriods {
+		if common.AdaptKlinePeriodToSymbol(period) == bar {
+			return period, true
+		}
+	}
+	return "", false
+}
+
+// toInstId BTC/USDT -> BTC-USDT-SWAP，缓存使用的是永续合约K线
+func toInstId(symbol string) string {
+	return strings.ReplaceAll(symbol, "/", "-") + "-SWAP"
+}
+
+// fromInstId 只接受永续合约 BTC-USDT-SWAP -> BTC/USDT
+// 客户端也可能订阅现货K线，不能混进缓存
+func fromInstId(instId string) (string, bool) {
+	parts := strings.Split(instId, "-")
+	if len(parts) != 3 || parts[2] != "SWAP" {
+		return "", false
+	}
+	return parts[0] + "/" + parts[1], true
+}
+
+func toKline(data *pb.WsKlineUpdate_KlineData) (model2.Kline, error) {
+	values := []string{data.GetOpen(), data.GetClose(), data.GetHigh(), data.GetLow(), data.GetVol(), data.GetVolCcy()}
+	parsed := make([]float64, len(values))
+	for i, v := range values {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return model2.Kline{}, err
+		}
+		parsed[i] = f
+	}
+	return model2.Kline{
+		// OKXCandleService 推送的时间戳是秒
+		Timestamp: time.Unix(data.GetTimestamp(), 0),
+		Open:      parsed[0],
+		Close:     parsed[1],
+		High:      parsed[2],
+		Low:       parsed[3],
+		Vol:       parsed[4],
+		VolCcy:    parsed[5],
+	}, nil
+}
@@ -0,0 +1,96 @@
+package kline
+
+import (
+	model2 "edgeflow/internal/model"
+	"testing"
+	"time"
+
+	"github.com/nntaoli-project/goex/v2/model"
+)
+
+func newTestManager(windowSize int) *KlineManager {
+	m := NewKlineManager(nil, nil, nil, []string{"BTCUSDT"})
+	m.windowSize = windowSize
+	return m
+}
+
+func TestApplyKline(t *testing.T) {
+	m := newTestManager(3)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	line := func(i int, c float64) model2.Kline {
+		return model2.Kline{Timestamp: base.Add(time.Duration(i) * 15 * time.Minute), Close: c}
+	}
+
+	for i := 0; i < 4; i++ {
+		if applied, gap := m.apply("BTC/USDT", model.Kline_15min, line(i, float64(i))); !applied || gap {
+			t.Fatalf("apply %d: applied=%v gap=%v", i, applied, gap)
+		}
+	}
+
+	lines, ok := m.Get("BTC/USDT", model.Kline_15min)
+	if !ok || len(lines) != 3 {
+		t.Fatalf("窗口应裁剪为3根, got %d", len(lines))
+	}
+	if lines[0].Close != 1 || lines[2].Close != 3 {
+		t.Fatalf("顺序应为从旧到新: %+v", lines)
+	}
+
+	// 同一根K线重复推送，覆盖而不是追加
+	m.apply("BTC/USDT", model.Kline_15min, line(3, 30))
+	lines, _ = m.Get("BTC/USDT", model.Kline_15min)
+	if len(lines) != 3 || lines[2].Close != 30 {
+		t.Fatalf("重复K线应覆盖最后一根: %+v", lines)
+	}
+
+	// 已滑出窗口的旧K线直接丢弃
+	if applied, _ := m.apply("BTC/USDT", model.Kline_15min, line(0, 100)); applied {
+		t.Fatalf("窗口外的旧K线不应写入")
+	}
+
+	// 跳过一根，需要回补
+	if _, gap := m.apply("BTC/USDT", model.Kline_15min, line(5, 5)); !gap {
+		t.Fatalf("应检测到缺口")
+	}
+
+	// 返回的是副本
+	lines[0].Close = -1
+	again, _ := m.Get("BTC/USDT", model.Kline_15min)
+	if again[0].Close == -1 {
+		t.Fatalf("Get 应返回副本")
+	}
+
+	// 未配置的币种不缓存
+	if applied, _ := m.apply("ETH/USDT", model.Kline_15min, line(0, 1)); applied {
+		t.Fatalf("未配置的币种不应写入")
+	}
+	if _, ok := m.Get("ETH/USDT", model.Kline_15min); ok {
+		t.Fatalf("未配置的币种不应有数据")
+	}
+}
+
+func TestInstIdAndBar(t *testing.T) {
+	if got := toInstId("BTC/USDT"); got != "BTC-USDT-SWAP" {
+		t.Fatalf("toInstId got %s", got)
+	}
+	if got, ok := fromInstId("BTC-USDT-SWAP"); !ok || got != "BTC/USDT" {
+		t.Fatalf("fromInstId got %s %v", got, ok)
+	}
+	if _, ok := fromInstId("BTC-USDT"); ok {
+		t.Fatalf("现货K线不应进入缓存")
+	}
+
+	cases := map[string]model.KlinePeriod{
+		"4H":  model.Kline_4h,
+		"1H":  model.Kline_1h,
+		"30m": model.Kline_30min,
+		"15m": model.Kline_15min,
+	}
+	for bar, want := range cases {
+		if got, ok := fromBar(bar); !ok || got != want {
+			t.Fatalf("fromBar(%s) got %s %v", bar, got, ok)
+		}
+	}
+	if _, ok := fromBar("1m"); ok {
+		t.Fatalf("不维护 1m 周期")
+	}
+}
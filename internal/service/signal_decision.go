@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"edgeflow/internal/dao"
+	model22 "edgeflow/internal/model"
+	"edgeflow/internal/model/entity"
+	"edgeflow/internal/signal"
+	"edgeflow/pkg/logger"
+	"encoding/json"
+	"time"
+)
+
+const (
+	// 待写入决策的缓冲大小，写满后丢弃新的记录，不阻塞交易
+	decisionBufferSize = 1024
+	// 每批最多写入的决策数量
+	decisionBatchSize = 100
+	// 不满一批时的刷新间隔
+	decisionFlushInterval = 2 * time.Second
+)
+
+// SignalDecisionRecorder 异步批量保存交易决策
+type SignalDecisionRecorder struct {
+	repo dao.SignalDecisionDao
+	ch   chan entity.SignalDecision
+}
+
+var _ signal.DecisionRecorder = (*SignalDecisionRecorder)(nil)
+
+func NewSignalDecisionRecorder(repo dao.SignalDecisionDao) *SignalDecisionRecorder {
+	return &SignalDecisionRecorder{
+		repo: repo,
+		ch:   make(chan entity.SignalDecision, decisionBufferSize),
+	}
+}
+
+func (r *SignalDecisionRecorder) Record(rec signal.DecisionRecord) {
+	select {
+	case r.ch <- decisionEntity(rec):
+	default:
+		logger.Errorf("[SignalDecision] 缓冲已满，丢弃决策记录: %s %s %s", rec.Symbol, rec.Action, rec.Reason)
+	}
+}
+
+// Run 定时批量写入，ctx 结束时写完剩余的记录
+func (r *SignalDecisionRecorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(decisionFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entity.SignalDecision, 0, decisionBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		wctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := r.repo.SaveDecisions(wctx, batch); err != nil {
+			logger.Errorf("[SignalDecision] 保存 %d 条决策记录失败: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case d := <-r.ch:
+					batch = append(batch, d)
+					if len(batch) >= decisionBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		case d := <-r.ch:
+			batch = append(batch, d)
+			if len(batch) >= decisionBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func decisionEntity(rec signal.DecisionRecord) entity.SignalDecision {
+	d := entity.SignalDecision{
+		Source:        rec.Source,
+		Strategy:      rec.Strategy,
+		Symbol:        rec.Symbol,
+		Level:         rec.Level,
+		Side:          rec.Side,
+		Price:         rec.Price,
+		SignalTime:    rec.SignalTime,
+		Action:        rec.Action.String(),
+		Reason:        rec.Reason,
+		ReducePercent: rec.ReducePercent,
+		ConfigVersion: rec.ConfigVersion,
+		CreatedAt:     rec.DecidedAt,
+	}
+	if rec.SignalID > 0 {
+		id := rec.SignalID
+		d.SignalID = &id
+	}
+	if d.SignalTime.IsZero() {
+		d.SignalTime = rec.DecidedAt
+	}
+	if raw, err := json.Marshal(rec.Signal); err == nil {
+		sig := string(raw)
+		d.SignalJSON = &sig
+	}
+	if rec.Position != nil {
+		if raw, err := json.Marshal(rec.Position); err == nil {
+			pos := string(raw)
+			d.PositionJSON = &pos
+		}
+	}
+	if st := rec.Trend; st != nil {
+		d.TrendDirection = signal.TrendName(st.Direction)
+		d.FinalScore = st.Scores.FinalScore
+		d.TrendScore = st.Scores.TrendScore
+		d.SignalScore = st.Scores.SignalScore
+		d.Score30m = st.Scores.Score30m
+		d.Score1h = st.Scores.Score1h
+		d.Score4h = st.Scores.Score4h
+		d.Slope = st.Slope
+	}
+	return d
+}
+
+// DecisionGetList 分页查询决策记录
+func (s *SignalProcessorService) DecisionGetList(ctx context.Context, req model22.SignalDecisionListReq) (*model22.SignalDecisionListRes, error) {
+	total, list, err := s.decisionRepo.GetDecisionList(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &model22.SignalDecisionListRes{Total: total, Decisions: list}, nil
+}
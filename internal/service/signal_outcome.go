@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"edgeflow/internal/dao"
+	model22 "edgeflow/internal/model"
+	"edgeflow/internal/model/entity"
+	"edgeflow/pkg/exchange"
+	"edgeflow/pkg/logger"
+	"sort"
+	"strings"
+	"time"
+
+	model2 "github.com/nntaoli-project/goex/v2/model"
+)
+
+const (
+	// 每轮最多评估的信号数量
+	outcomeBatchSize = 200
+	// 每次拉取K线的数量
+	outcomeKlinePageSize = 100
+)
+
+// SignalOutcomeEvaluator 从信号产生的K线开始向后逐根检查，判断信号先触及止盈、止损还是过期，
+// 把结果写入 signal_outcomes，供胜率和收益统计使用。
+// 已有结果的信号不会再被评估，同一时间段可以重复回填。
+type SignalOutcomeEvaluator struct {
+	signalRepo dao.SignalDao
+	ex         exchange.Exchange
+	now        func() time.Time
+}
+
+func NewSignalOutcomeEvaluator(signalRepo dao.SignalDao, ex exchange.Exchange) *SignalOutcomeEvaluator {
+	return &SignalOutcomeEvaluator{
+		signalRepo: signalRepo,
+		ex:         ex,
+		now:        time.Now,
+	}
+}
+
+// Run 定时评估最近 lookback 时间内产生的信号，直到 ctx 结束
+func (e *SignalOutcomeEvaluator) Run(ctx context.Context, interval, lookback time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := e.now()
+		if n, err := e.EvaluateRange(ctx, now.Add(-lookback), now); err != nil {
+			logger.Errorf("[SignalOutcome] 评估信号结果失败: %v", err)
+		} else if n > 0 {
+			logger.Infof("[SignalOutcome] 本轮写入 %d 条信号结果", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EvaluateRange 评估信号时间在 [start, end] 内且还没有结果的信号，返回写入的结果数量。
+// 还没有触及止盈止损并且没有过期的信号会留到下一轮。
+func (e *SignalOutcomeEvaluator) EvaluateRange(ctx context.Context, start, end time.Time) (int, error) {
+	saved := 0
+	for {
+		signals, err := e.signalRepo.GetSignalsWithoutOutcome(ctx, start, end, outcomeBatchSize)
+		if err != nil {
+			return saved, err
+		}
+
+		for _, sig := range signals {
+			outcome, err := e.evaluate(sig)
+			if err != nil {
+				logger.Errorf("[SignalOutcome] 信号 %d(%s) 评估失败: %v", sig.ID, sig.Symbol, err)
+				continue
+			}
+			if outcome == nil {
+				continue
+			}
+			ok, err := e.signalRepo.SaveOutcomeAndExpireSignal(ctx, outcome)
+			if err != nil {
+				return saved, err
+			}
+			if ok {
+				saved++
+			}
+		}
+
+		// 一批没处理完的信号都还在进行中，继续翻页也只会拿到同样的数据
+		if len(signals) < outcomeBatchSize {
+			return saved, nil
+		}
+		start = signals[len(signals)-1].Timestamp.Add(time.Second)
+	}
+}
+
+// evaluate 拉取信号之后的K线并判定结果，结果未定时返回 nil
+func (e *SignalOutcomeEvaluator) evaluate(sig entity.Signal) (*entity.SignalOutcome, error) {
+	period, step := outcomeKlinePeriod(sig.Period)
+	end := sig.ExpiryTimestamp
+	if now := e.now(); now.Before(end) {
+		end = now
+	}
+	if !end.After(sig.Timestamp) {
+		return evaluateSignalOutcome(sig, nil, step, e.now()), nil
+	}
+
+	klines, err := e.fetchKlines(sig.Symbol, period, step, sig.Timestamp, end)
+	if err != nil {
+		return nil, err
+	}
+	return evaluateSignalOutcome(sig, klines, step, e.now()), nil
+}
+
+// fetchKlines 从 end 向前分页拉取开盘时间在 [start, end) 内的已收盘K线，返回顺序为 从旧到新
+func (e *SignalOutcomeEvaluator) fetchKlines(symbol string, period model2.KlinePeriod, step time.Duration, start, end time.Time) ([]model22.Kline, error) {
+	var all []model22.Kline
+	before := end.Add(step).UnixMilli()
+	for {
+		lines, err := e.ex.GetKlineRecords(symbol, period, outcomeKlinePageSize, 0, before, model22.OrderTradeSwap, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) == 0 {
+			break
+		}
+		sort.Slice(lines, func(i, j int) bool {
+			return lines[i].Timestamp.Before(lines[j].Timestamp)
+		})
+		all = append(lines, all...)
+		oldest := lines[0].Timestamp
+		if !oldest.After(start) || len(lines) < outcomeKlinePageSize {
+			break
+		}
+		before = oldest.UnixMilli()
+	}
+
+	out := make([]model22.Kline, 0, len(all))
+	for _, k := range all {
+		if !k.Timestamp.Before(start) && k.Timestamp.Before(end) {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+
+// evaluateSignalOutcome 按时间顺序逐根检查K线：
+// 先触及止损记为 HIT_SL，先触及止盈记为 HIT_TP，同一根K线同时触及时保守地按止损处理；
+// 到过期时间都没有触及则按最后一根K线收盘价记为 EXPIRED。
+// klines 为信号时间之后、过期时间之前的K线，从旧到新
+func evaluateSignalOutcome(sig entity.Signal, klines []model22.Kline, step time.Duration, now time.Time) *entity.SignalOutcome {
+	dir := signalDirection(sig.Command)
+	entry := sig.EntryPrice
+	if entry <= 0 {
+		entry = sig.MarkPrice
+	}
+
+	outcome := &entity.SignalOutcome{
+		SignalID: sig.ID,
+		Symbol:   sig.Symbol,
+	}
+
+	// 无法模拟的信号（非买卖指令或没有价格）到期后直接按 0 收益过期，避免每轮重复评估
+	if dir == 0 || entry <= 0 {
+		if now.Before(sig.ExpiryTimestamp) {
+			return nil
+		}
+		outcome.Outcome = string(entity.Expired)
+		outcome.ClosedAt = sig.ExpiryTimestamp
+		return outcome
+	}
+
+	var used uint
+	var last *model22.Kline
+	for i := range klines {
+		k := klines[i]
+		if k.Timestamp.Before(sig.Timestamp) {
+			continue
+		}
+		if !k.Timestamp.Before(sig.ExpiryTimestamp) {
+			break
+		}
+		used++
+		last = &klines[i]
+
+		hitSL := sig.RecommendedSL > 0 && ((dir > 0 && k.Low <= sig.RecommendedSL) || (dir < 0 && k.High >= sig.RecommendedSL))
+		hitTP := sig.RecommendedTP > 0 && ((dir > 0 && k.High >= sig.RecommendedTP) || (dir < 0 && k.Low <= sig.RecommendedTP))
+		switch {
+		case hitSL:
+			outcome.Outcome = string(entity.HitSL)
+			outcome.FinalPnlPct = pnlPct(dir, entry, sig.RecommendedSL)
+		case hitTP:
+			outcome.Outcome = string(entity.HitTP)
+			outcome.FinalPnlPct = pnlPct(dir, entry, sig.RecommendedTP)
+		default:
+			continue
+		}
+		outcome.CandlesUsed = used
+		outcome.ClosedAt = k.Timestamp.Add(step)
+		return outcome
+	}
+
+	// 还没过期，也可能K线还没有全部收盘，等下一轮
+	if now.Before(sig.ExpiryTimestamp) {
+		return nil
+	}
+
+	outcome.Outcome = string(entity.Expired)
+	outcome.CandlesUsed = used
+	outcome.ClosedAt = sig.ExpiryTimestamp
+	if last != nil {
+		outcome.FinalPnlPct = pnlPct(dir, entry, last.Close)
+	}
+	return outcome
+}
+
+// signalDirection 做多返回 1，做空返回 -1，其他指令返回 0
+func signalDirection(command string) int {
+	switch strings.ToUpper(command) {
+	case "BUY", "REVERSAL_BUY":
+		return 1
+	case "SELL", "REVERSAL_SELL":
+		return -1
+	default:
+		return 0
+	}
+}
+
+func pnlPct(dir int, entry, exit float64) float64 {
+	return float64(dir) * (exit - entry) / entry
+}
+
+// outcomeKlinePeriod 把信号周期（5m、15min、1h 等）转为K线周期，无法识别时使用 15m
+func outcomeKlinePeriod(period string) (model2.KlinePeriod, time.Duration) {
+	switch strings.ToLower(strings.TrimSpace(period)) {
+	case "1m", "1min":
+		return model2.Kline_1min, time.Minute
+	case "5m", "5min":
+		return model2.Kline_5min, 5 * time.Minute
+	case "30m", "30min":
+		return model2.Kline_30min, 30 * time.Minute
+	case "1h", "60m", "60min":
+		return model2.Kline_1h, time.Hour
+	case "4h":
+		return model2.Kline_4h, 4 * time.Hour
+	case "1d", "1day":
+		return model2.Kline_1day, 24 * time.Hour
+	default:
+		return model2.Kline_15min, 15 * time.Minute
+	}
+}
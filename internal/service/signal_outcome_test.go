@@ -0,0 +1,91 @@
+package service
+
+import (
+	"edgeflow/internal/model"
+	"edgeflow/internal/model/entity"
+	"math"
+	"testing"
+	"time"
+)
+
+func outcomeKlines(start time.Time, bars [][4]float64) []model.Kline {
+	lines := make([]model.Kline, len(bars))
+	for i, b := range bars {
+		lines[i] = model.Kline{
+			Timestamp: start.Add(time.Duration(i) * 15 * time.Minute),
+			Open:      b[0],
+			High:      b[1],
+			Low:       b[2],
+			Close:     b[3],
+		}
+	}
+	return lines
+}
+
+func TestEvaluateSignalOutcome(t *testing.T) {
+	ts := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	sig := entity.Signal{
+		ID:              1,
+		Symbol:          "BTC-USDT",
+		Command:         "BUY",
+		Timestamp:       ts,
+		ExpiryTimestamp: ts.Add(time.Hour),
+		EntryPrice:      100,
+		RecommendedTP:   110,
+		RecommendedSL:   95,
+	}
+	step := 15 * time.Minute
+
+	// 第2根K线触及止盈
+	klines := outcomeKlines(ts, [][4]float64{{100, 105, 98, 104}, {104, 111, 103, 109}, {109, 112, 94, 96}})
+	out := evaluateSignalOutcome(sig, klines, step, ts.Add(2*time.Hour))
+	if out == nil || out.Outcome != string(entity.HitTP) || out.CandlesUsed != 2 || math.Abs(out.FinalPnlPct-0.1) > 1e-9 {
+		t.Fatalf("应止盈: %+v", out)
+	}
+	if !out.ClosedAt.Equal(ts.Add(30 * time.Minute)) {
+		t.Fatalf("平仓时间应为触及K线的收盘时间: %v", out.ClosedAt)
+	}
+
+	// 同一根K线同时触及止盈止损按止损处理
+	klines = outcomeKlines(ts, [][4]float64{{100, 111, 94, 100}})
+	out = evaluateSignalOutcome(sig, klines, step, ts.Add(2*time.Hour))
+	if out == nil || out.Outcome != string(entity.HitSL) || math.Abs(out.FinalPnlPct+0.05) > 1e-9 {
+		t.Fatalf("应止损: %+v", out)
+	}
+
+	// 未触及且未过期时不出结果
+	klines = outcomeKlines(ts, [][4]float64{{100, 105, 98, 104}, {104, 106, 103, 102}})
+	if out = evaluateSignalOutcome(sig, klines, step, ts.Add(30*time.Minute)); out != nil {
+		t.Fatalf("未过期不应有结果: %+v", out)
+	}
+
+	// 过期按最后一根收盘价计算，过期时间之后的K线不计入
+	klines = outcomeKlines(ts, [][4]float64{{100, 105, 98, 104}, {104, 106, 103, 102}, {102, 104, 99, 103}, {103, 104, 101, 102}, {102, 120, 90, 100}})
+	out = evaluateSignalOutcome(sig, klines, step, ts.Add(2*time.Hour))
+	if out == nil || out.Outcome != string(entity.Expired) || out.CandlesUsed != 4 || math.Abs(out.FinalPnlPct-0.02) > 1e-9 {
+		t.Fatalf("应过期: %+v", out)
+	}
+
+	// 做空方向
+	short := sig
+	short.Command = "REVERSAL_SELL"
+	short.RecommendedTP = 90
+	short.RecommendedSL = 105
+	klines = outcomeKlines(ts, [][4]float64{{100, 101, 95, 96}, {96, 97, 89, 90}})
+	out = evaluateSignalOutcome(short, klines, step, ts.Add(2*time.Hour))
+	if out == nil || out.Outcome != string(entity.HitTP) || math.Abs(out.FinalPnlPct-0.1) > 1e-9 {
+		t.Fatalf("做空应止盈: %+v", out)
+	}
+}
+
+func TestOutcomeKlinePeriod(t *testing.T) {
+	if _, step := outcomeKlinePeriod("5m"); step != 5*time.Minute {
+		t.Fatalf("5m 解析错误: %v", step)
+	}
+	if _, step := outcomeKlinePeriod("1H"); step != time.Hour {
+		t.Fatalf("1H 解析错误: %v", step)
+	}
+	if _, step := outcomeKlinePeriod(""); step != 15*time.Minute {
+		t.Fatalf("默认应为15m: %v", step)
+	}
+}
@@ -104,8 +104,14 @@ func NewOKXTickerService(defaultSymbols []string) *OKXTickerService {
 	return s
 }
 
+// WithURL 替换公共频道地址，需要在 Run 之前调用，测试时指向本地的 okxtest 服务
+func (s *OKXTickerService) WithURL(url string) *OKXTickerService {
+	s.url = url
+	return s
+}
+
 // startPingLoop 在每次新连接建立后调用
-func (s *OKXTickerService) startPingLoop(conn *websocket.Conn) {
+func (s *OKXTickerService) startPingLoop(conn *websocket.Conn, closeCh <-chan struct{}) {
 	// 间隔时间应该小于 OKX 的超时时间 (例如 30s，我们设置为 15s)
 	ticker := time.NewTicker(time.Second * 15)
 	defer ticker.Stop()
@@ -128,8 +134,8 @@ func (s *OKXTickerService) startPingLoop(conn *websocket.Conn) {
 				return
 			}
 
-		// 关键：监听 s.closeCh 或其他退出信号
-		case <-s.closeCh:
+		// 关键：监听启动时的 closeCh，重连时 Run 会替换 s.closeCh
+		case <-closeCh:
 			// 收到服务关闭信号，优雅退出
 			return
 		}
@@ -138,15 +144,15 @@ func (s *OKXTickerService) startPingLoop(conn *websocket.Conn) {
 
 // 订阅初始币种 订阅默认列表中的所有币种
 func (s *OKXTickerService) subscribeDefaults() error {
-	// 订阅默认列表，无需过滤，因为这是首次订阅
+	// 订阅默认列表，无需过滤，因为这是首次订阅。写连接需要持有 s.Lock()
+	s.Lock()
+	defer s.Unlock()
 	err := s.sendSubscribe(s.defaultSymbols)
 	if err == nil {
 		// 订阅成功后，更新本地状态
-		s.Lock()
 		for _, sym := range s.defaultSymbols {
 			s.subscribed[sym] = struct{}{}
 		}
-		s.Unlock()
 	}
 	return err
 }
@@ -186,11 +192,13 @@ func (s *OKXTickerService) Run() {
 		s.Lock()
 		s.conn = conn
 
-		//  readyCh 的广播逻辑（只执行一次）
+		//  readyCh 的广播逻辑（只执行一次），isReady 和 readyCh 由 s.mu 保护
+		s.mu.Lock()
 		if !s.isReady {
 			close(s.readyCh) // 关闭 readyCh，通知所有等待者（WaitForConnectionReady）
 			s.isReady = true
 		}
+		s.mu.Unlock()
 
 		// connectionNotifier 的事件流逻辑（每次连接成功都尝试发送）
 		// 外部监听的是这个通道
@@ -211,6 +219,7 @@ func (s *OKXTickerService) Run() {
 		}
 		// 创建新的协程
 		s.closeCh = make(chan struct{})
+		closeCh := s.closeCh
 
 		s.Unlock()
 
@@ -220,12 +229,12 @@ func (s *OKXTickerService) Run() {
 		err = s.subscribeDefaults()
 		if err != nil {
 			log.Printf("Failed to subscribe default symbols after connect: %v. Retrying connection.", err)
-			_ = s.conn.Close() // 订阅失败，关闭连接以触发下一次重试
+			_ = conn.Close() // 订阅失败，关闭连接以触发下一次重试
 			continue
 		}
 
 		// 启动心跳协程
-		go s.startPingLoop(conn)
+		go s.startPingLoop(conn, closeCh)
 
 		// 启动数据读取循环
 		// 这个 runListen 协程会阻塞直到连接断开
@@ -326,21 +335,18 @@ func (s *OKXTickerService) ResubscribeAll(activeClientSymbols []string) error {
 		symbolsToResubscribe = append(symbolsToResubscribe, sym)
 	}
 
-	// 3. 清空并更新本地状态
+	// 3. 清空并更新本地状态，发送订阅也在锁内，同一时间只有一个协程写连接
 	s.Lock()
+	defer s.Unlock()
 	// 清空旧的订阅状态，因为连接已经失效
 	s.subscribed = make(map[string]struct{})
-	// 成功发送后，再更新 s.subscribed
-	s.Unlock()
 
-	// 4. 发送订阅请求
+	// 4. 发送订阅请求，成功发送后，再更新 s.subscribed
 	err := s.sendSubscribe(symbolsToResubscribe)
 	if err == nil {
-		s.Lock()
 		for _, sym := range symbolsToResubscribe {
 			s.subscribed[sym] = struct{}{}
 		}
-		s.Unlock()
 	}
 	return err
 }
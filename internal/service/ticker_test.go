@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"edgeflow/pkg/exchange/okxtest"
+	"testing"
+	"time"
+)
+
+// waitUntil 轮询直到 cond 成立，WS 推送是异步的
+func waitUntil(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestOKXTickerService_Reconnect(t *testing.T) {
+	srv := okxtest.NewServer()
+	defer srv.Close()
+
+	s := NewOKXTickerService([]string{"BTC-USDT", "ETH"}).WithURL(srv.PublicWSURL())
+	go s.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.WaitForConnectionReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	priceIs := func(symbol, last string) func() bool {
+		return func() bool {
+			p, err := s.GetPrice(ctx, symbol)
+			return err == nil && p.LastPrice == last
+		}
+	}
+	// 只有币种时补全为 -USDT
+	if !srv.WaitSubscribed("tickers", "ETH-USDT", 2*time.Second) {
+		t.Fatal("ETH-USDT not subscribed")
+	}
+	if !waitUntil(2*time.Second, priceIs("BTC-USDT", "106500.1")) {
+		t.Fatal("no snapshot for BTC-USDT")
+	}
+
+	// 服务端断开后自动重连并恢复默认订阅
+	if n := srv.DropConnections(); n != 1 {
+		t.Fatalf("dropped %d connections", n)
+	}
+	if !waitUntil(5*time.Second, func() bool { return srv.Dials() == 2 && srv.Subscribed("tickers", "BTC-USDT") }) {
+		t.Fatalf("not resubscribed, dials=%d", srv.Dials())
+	}
+	srv.SetTicker("BTC-USDT", map[string]string{"last": "107000"})
+	if !waitUntil(2*time.Second, priceIs("BTC-USDT", "107000")) {
+		t.Fatal("ticker update not received after reconnect")
+	}
+}
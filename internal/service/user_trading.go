@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"edgeflow/conf"
+	"edgeflow/internal/dao"
+	"edgeflow/internal/model"
+	"edgeflow/internal/model/entity"
+	"edgeflow/pkg/exchange"
+	"edgeflow/pkg/logger"
+	"edgeflow/utils/security"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// 目前只支持 OKX
+const userExchangeOkx = "okx"
+
+// 没有指定时的默认下单参数，超过计划上限时按上限
+const (
+	defaultUserLeverage    = 5
+	defaultUserQuantityPct = 0.2
+)
+
+var ErrCredentialNotFound = errors.New("未绑定交易所 API Key")
+
+// UserPlanProvider 查询用户当前的订阅角色，过期的订阅按标准用户处理
+type UserPlanProvider interface {
+	UserGetSubscription(ctx context.Context, userId int64) (res model.UserSubscriptionsRes, err error)
+}
+
+// UserTradingService 用户使用自己的 API Key 执行信号：API Key 加密保存，
+// 按用户缓存交易所实例，下单参数不能超过订阅计划的上限，每次下单都记录到用户订单历史
+type UserTradingService struct {
+	dao     dao.UserTradingDao
+	signals *SignalProcessorService
+	plans   UserPlanProvider
+	cfg     conf.UserTradingConfig
+
+	// 根据解密后的 API Key 创建交易所实例，测试时替换
+	newExchange func(apiKey, secretKey, passphrase string) exchange.Exchange
+	// 为空时只依赖交易所拒绝重复的 clOrdId
+	dedupe exchange.OrderDedupeStore
+	// 为空时不按交易对规则取整和校验
+	rules *exchange.InstrumentRegistry
+
+	mu        sync.Mutex
+	exchanges map[int64]exchange.Exchange
+}
+
+func NewUserTradingService(d dao.UserTradingDao, signals *SignalProcessorService, plans UserPlanProvider, cfg conf.UserTradingConfig) *UserTradingService {
+	return &UserTradingService{
+		dao:     d,
+		signals: signals,
+		plans:   plans,
+		cfg:     cfg,
+		newExchange: func(apiKey, secretKey, passphrase string) exchange.Exchange {
+			// 限频按 API Key 计算，每个用户单独排队
+			return exchange.NewRateLimitedExchange(exchange.NewOkxExchange(apiKey, secretKey, passphrase), exchange.OkxRateLimits())
+		},
+		exchanges: make(map[int64]exchange.Exchange),
+	}
+}
+
+// WithDedupeStore 重复点击下单时返回已有订单，多个实例共享去重记录
+func (s *UserTradingService) WithDedupeStore(store exchange.OrderDedupeStore) *UserTradingService {
+	s.dedupe = store
+	return s
+}
+
+// WithInstrumentRules 下单前按交易对规则取整价格和数量，不符合规则的订单不提交
+func (s *UserTradingService) WithInstrumentRules(rules *exchange.InstrumentRegistry) *UserTradingService {
+	s.rules = rules
+	return s
+}
+
+// CredentialSave 加密保存 API Key，覆盖之前绑定的 Key
+func (s *UserTradingService) CredentialSave(ctx context.Context, userId int64, req model.UserCredentialSaveReq) (res model.UserCredentialRes, err error) {
+	cred := &entity.UserExchangeCredential{
+		UserID:     userId,
+		Exchange:   userExchangeOkx,
+		ApiKeyMask: maskApiKey(req.ApiKey),
+	}
+	if cred.ApiKey, err = s.encrypt(req.ApiKey); err != nil {
+		return
+	}
+	if cred.SecretKey, err = s.encrypt(req.SecretKey); err != nil {
+		return
+	}
+	if cred.Passphrase, err = s.encrypt(req.Passphrase); err != nil {
+		return
+	}
+	if err = s.dao.CredentialSave(ctx, cred); err != nil {
+		return
+	}
+	s.evict(userId)
+	return model.UserCredentialRes{Exchange: cred.Exchange, ApiKeyMask: cred.ApiKeyMask, UpdatedAt: cred.UpdatedAt}, nil
+}
+
+// CredentialGet 只返回脱敏后的 API Key
+func (s *UserTradingService) CredentialGet(ctx context.Context, userId int64) (res model.UserCredentialRes, err error) {
+	cred, err := s.credential(ctx, userId)
+	if err != nil {
+		return
+	}
+	return model.UserCredentialRes{Exchange: cred.Exchange, ApiKeyMask: cred.ApiKeyMask, UpdatedAt: cred.UpdatedAt}, nil
+}
+
+func (s *UserTradingService) CredentialDelete(ctx context.Context, userId int64) error {
+	if err := s.dao.CredentialDelete(ctx, userId, userExchangeOkx); err != nil {
+		return err
+	}
+	s.evict(userId)
+	return nil
+}
+
+// ExecuteSignal 使用用户自己的交易所账户执行信号，成功和失败都会记录到订单历史
+func (s *UserTradingService) ExecuteSignal(ctx context.Context, userId, signalID int64, leverage int, quantityPct float64) (res model.SignalExecutionRes, err error) {
+	sub, err := s.plans.UserGetSubscription(ctx, userId)
+	if err != nil {
+		return
+	}
+	limit, ok := s.cfg.Plans[sub.Role]
+	if !ok {
+		return res, fmt.Errorf("当前订阅计划不支持信号下单")
+	}
+	leverage, quantityPct, err = checkPlanLimit(limit, leverage, quantityPct)
+	if err != nil {
+		return
+	}
+
+	ex, err := s.userExchange(ctx, userId)
+	if err != nil {
+		return
+	}
+	order, err := s.signals.BuildOrder(ctx, signalID)
+	if err != nil {
+		return
+	}
+	order.Leverage = leverage
+	order.QuantityPct = quantityPct
+	// 同一个用户对同一个信号只下一次单
+	order.ClientOrderId = model.NewClientOrderId(fmt.Sprintf("id:%d", signalID), fmt.Sprintf("user:%d", userId), "execute")
+
+	record := &entity.UserOrder{
+		UserID:      userId,
+		Exchange:    userExchangeOkx,
+		SignalID:    signalID,
+		Symbol:      order.Symbol,
+		Side:        string(order.Side),
+		Leverage:    leverage,
+		QuantityPct: quantityPct,
+	}
+	resp, err := ex.PlaceOrder(ctx, order)
+	// 记录取整后实际提交的价格
+	record.Price, record.TPPrice, record.SLPrice = order.Price, order.TPPrice, order.SLPrice
+	if err == nil && resp != nil && resp.Duplicate {
+		// 已经下过单，不重复记录
+		return model.SignalExecutionRes{OrderID: resp.OrderId, Leverage: leverage, QuantityPct: quantityPct}, nil
+	}
+	if err != nil {
+		record.Error = truncate(err.Error(), 255)
+	} else if resp != nil {
+		record.OrderID = resp.OrderId
+	}
+	if saveErr := s.dao.UserOrderSave(ctx, record); saveErr != nil && err == nil {
+		// 订单已经提交，只是历史记录写入失败，不影响返回
+		logger.Errorf("[UserTrading] 保存用户 %d 订单 %s 失败: %v", userId, record.OrderID, saveErr)
+	}
+	if err != nil {
+		return
+	}
+	return model.SignalExecutionRes{OrderID: record.OrderID, Leverage: leverage, QuantityPct: quantityPct}, nil
+}
+
+func (s *UserTradingService) UserOrderGetList(ctx context.Context, userId int64, req model.UserOrderListReq) (res model.UserOrderListRes, err error) {
+	res.Total, res.Orders, err = s.dao.UserOrderGetList(ctx, userId, req.Limit, req.Offset)
+	return
+}
+
+// userExchange 按用户缓存交易所实例，API Key 修改或删除时清除
+func (s *UserTradingService) userExchange(ctx context.Context, userId int64) (exchange.Exchange, error) {
+	s.mu.Lock()
+	ex, ok := s.exchanges[userId]
+	s.mu.Unlock()
+	if ok {
+		return ex, nil
+	}
+
+	cred, err := s.credential(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	apiKey, err := s.decrypt(cred.ApiKey)
+	if err != nil {
+		return nil, err
+	}
+	secretKey, err := s.decrypt(cred.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := s.decrypt(cred.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	ex = s.newExchange(apiKey, secretKey, passphrase)
+	if s.dedupe != nil {
+		ex = exchange.NewIdempotentExchange(ex, s.dedupe)
+	}
+	if s.rules != nil {
+		// 先校验，不符合规则的订单不占用 clOrdId
+		ex = exchange.NewRulesExchange(ex, s.rules)
+	}
+
+	s.mu.Lock()
+	s.exchanges[userId] = ex
+	s.mu.Unlock()
+	return ex, nil
+}
+
+func (s *UserTradingService) credential(ctx context.Context, userId int64) (*entity.UserExchangeCredential, error) {
+	cred, err := s.dao.CredentialGet(ctx, userId, userExchangeOkx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCredentialNotFound
+	}
+	return cred, err
+}
+
+func (s *UserTradingService) evict(userId int64) {
+	s.mu.Lock()
+	delete(s.exchanges, userId)
+	s.mu.Unlock()
+}
+
+// encrypt 和 decrypt 使用 utils/security 的 AES-CBC，密文以 base64 保存。
+// 不使用 PasswordEncrypt，它会吞掉密钥长度错误
+func (s *UserTradingService) encrypt(plain string) (string, error) {
+	cipherText, err := security.AesEncrypt([]byte(plain), []byte(s.cfg.SecretKey))
+	if err != nil {
+		return "", fmt.Errorf("加密 API Key 失败: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(cipherText), nil
+}
+
+func (s *UserTradingService) decrypt(cipherText string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return "", fmt.Errorf("API Key 密文格式错误")
+	}
+	plain, err := security.AesDecrypt(data, []byte(s.cfg.SecretKey))
+	if err != nil {
+		return "", fmt.Errorf("解密 API Key 失败: %w", err)
+	}
+	return string(plain), nil
+}
+
+// checkPlanLimit 未指定的参数使用默认值（不超过计划上限），指定的参数超过上限时报错
+func checkPlanLimit(limit conf.PlanLimit, leverage int, quantityPct float64) (int, float64, error) {
+	if leverage == 0 {
+		leverage = min(defaultUserLeverage, limit.MaxLeverage)
+	}
+	if quantityPct == 0 {
+		quantityPct = min(defaultUserQuantityPct, limit.MaxQuantityPct)
+	}
+	if leverage <= 0 || leverage > limit.MaxLeverage {
+		return 0, 0, fmt.Errorf("杠杆倍数 %d 超出订阅计划范围 1-%d", leverage, limit.MaxLeverage)
+	}
+	if quantityPct <= 0 || quantityPct > limit.MaxQuantityPct {
+		return 0, 0, fmt.Errorf("仓位比例 %v 超出订阅计划上限 %v", quantityPct, limit.MaxQuantityPct)
+	}
+	return leverage, quantityPct, nil
+}
+
+// maskApiKey 只保留前后 4 位
+func maskApiKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "****" + key[len(key)-4:]
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	"edgeflow/internal/model/entity"
+	"edgeflow/pkg/exchange"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type memUserTradingDao struct {
+	creds  map[int64]entity.UserExchangeCredential
+	orders []entity.UserOrder
+}
+
+func (m *memUserTradingDao) CredentialGet(ctx context.Context, userId int64, exchange string) (*entity.UserExchangeCredential, error) {
+	cred, ok := m.creds[userId]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &cred, nil
+}
+
+func (m *memUserTradingDao) CredentialSave(ctx context.Context, cred *entity.UserExchangeCredential) error {
+	m.creds[cred.UserID] = *cred
+	return nil
+}
+
+func (m *memUserTradingDao) CredentialDelete(ctx context.Context, userId int64, exchange string) error {
+	delete(m.creds, userId)
+	return nil
+}
+
+func (m *memUserTradingDao) UserOrderSave(ctx context.Context, order *entity.UserOrder) error {
+	m.orders = append(m.orders, *order)
+	return nil
+}
+
+func (m *memUserTradingDao) UserOrderGetList(ctx context.Context, userId int64, limit, offset int) (int64, []entity.UserOrder, error) {
+	return int64(len(m.orders)), m.orders, nil
+}
+
+func TestUserTradingCredential(t *testing.T) {
+	ctx := context.Background()
+	d := &memUserTradingDao{creds: make(map[int64]entity.UserExchangeCredential)}
+	s := NewUserTradingService(d, nil, nil, conf.UserTradingConfig{SecretKey: "0123456789abcdef0123456789abcdef"})
+	var keys []string
+	s.newExchange = func(apiKey, secretKey, passphrase string) exchange.Exchange {
+		keys = append(keys, apiKey+"/"+secretKey+"/"+passphrase)
+		return nil
+	}
+
+	if _, err := s.userExchange(ctx, 1); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("未绑定时应返回 ErrCredentialNotFound: %v", err)
+	}
+
+	res, err := s.CredentialSave(ctx, 1, model.UserCredentialSaveReq{ApiKey: "api-key-123456", SecretKey: "secret", Passphrase: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ApiKeyMask != "api-****3456" {
+		t.Fatalf("脱敏: %s", res.ApiKeyMask)
+	}
+	if cred := d.creds[1]; cred.ApiKey == "api-key-123456" || cred.SecretKey == "secret" || cred.Passphrase == "pass" {
+		t.Fatalf("API Key 应加密保存: %+v", cred)
+	}
+
+	// 解密后创建交易所实例，并按用户缓存
+	s.userExchange(ctx, 1)
+	s.userExchange(ctx, 1)
+	if len(keys) != 1 || keys[0] != "api-key-123456/secret/pass" {
+		t.Fatalf("交易所实例: %v", keys)
+	}
+
+	// 修改 API Key 后重新创建
+	s.CredentialSave(ctx, 1, model.UserCredentialSaveReq{ApiKey: "new-key-000000", SecretKey: "secret2", Passphrase: "pass2"})
+	s.userExchange(ctx, 1)
+	if len(keys) != 2 || keys[1] != "new-key-000000/secret2/pass2" {
+		t.Fatalf("修改后应重新创建: %v", keys)
+	}
+
+	// 密钥长度错误时不能保存
+	bad := NewUserTradingService(d, nil, nil, conf.UserTradingConfig{SecretKey: "short"})
+	if _, err := bad.CredentialSave(ctx, 2, model.UserCredentialSaveReq{ApiKey: "k", SecretKey: "s", Passphrase: "p"}); err == nil {
+		t.Fatalf("密钥长度错误应报错")
+	}
+}
+
+func TestCheckPlanLimit(t *testing.T) {
+	standard := conf.PlanLimit{MaxLeverage: 3, MaxQuantityPct: 0.1}
+	leverage, pct, err := checkPlanLimit(standard, 0, 0)
+	if err != nil || leverage != 3 || pct != 0.1 {
+		t.Fatalf("默认值不应超过上限: %d %v %v", leverage, pct, err)
+	}
+	if _, _, err := checkPlanLimit(standard, 5, 0); err == nil {
+		t.Fatalf("杠杆超过上限应报错")
+	}
+	if _, _, err := checkPlanLimit(standard, 2, 0.5); err == nil {
+		t.Fatalf("仓位比例超过上限应报错")
+	}
+	if _, _, err := checkPlanLimit(standard, -1, 0); err == nil {
+		t.Fatalf("杠杆必须为正")
+	}
+
+	plus := conf.PlanLimit{MaxLeverage: 20, MaxQuantityPct: 0.5}
+	leverage, pct, err = checkPlanLimit(plus, 10, 0)
+	if err != nil || leverage != 10 || pct != 0.2 {
+		t.Fatalf("指定杠杆: %d %v %v", leverage, pct, err)
+	}
+}
+
+// 密钥错误或密文被篡改时，解密后的填充不合法，应返回错误而不是 panic
+func TestUserTradingDecryptBadPadding(t *testing.T) {
+	key := "0123456789abcdef0123456789abcdef"
+	s := NewUserTradingService(&memUserTradingDao{}, nil, nil, conf.UserTradingConfig{SecretKey: key})
+	// 按 AesEncrypt 的方式加密，但不做填充
+	raw := func(plain []byte) string {
+		block, _ := aes.NewCipher([]byte(key))
+		out := make([]byte, len(plain))
+		cipher.NewCBCEncrypter(block, []byte(key)[:aes.BlockSize]).CryptBlocks(out, plain)
+		return base64.StdEncoding.EncodeToString(out)
+	}
+	tail := func(b ...byte) []byte {
+		return append(bytes.Repeat([]byte{'a'}, aes.BlockSize-len(b)), b...)
+	}
+	for name, plain := range map[string][]byte{
+		"填充超过块大小": tail(0xff),
+		"填充为 0":   tail(0x00),
+		"填充字节不一致": tail(0x01, 0x03, 0x03),
+	} {
+		if _, err := s.decrypt(raw(plain)); err == nil {
+			t.Fatalf("%s 应报错", name)
+		}
+	}
+	if plain, err := s.decrypt(raw(tail(0x02, 0x02))); err != nil || plain != "aaaaaaaaaaaaaa" {
+		t.Fatalf("合法填充: %q %v", plain, err)
+	}
+}
@@ -0,0 +1,185 @@
+package signal
+
+import (
+	"crypto/sha256"
+	"edgeflow/conf"
+	"edgeflow/internal/trend"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 决策来源
+const (
+	DecisionSourceManager = "manager" // TradingView 分级信号 Manager.Decide
+	DecisionSourceEngine  = "engine"  // K线信号 DecisionEngine.Run
+)
+
+// DecisionRecord 一次决策的完整快照，用于复盘"为什么在这个时间点做了这个动作"
+type DecisionRecord struct {
+	Source   string
+	Strategy string
+	Symbol   string
+	// 关联 signals 表的信号 ID，没有时为 0
+	SignalID   uint64
+	Level      int
+	Side       string
+	Price      float64
+	SignalTime time.Time
+	// 原始输入信号
+	Signal any
+
+	// 趋势快照，没有趋势时为空
+	Trend *trend.TrendState
+	// 决策时的仓位快照
+	Position any
+
+	Action        Action
+	Reason        string
+	ReducePercent float64
+	// 策略配置 + 规则文件的版本，配置修改后可以区分前后的决策
+	ConfigVersion string
+	DecidedAt     time.Time
+}
+
+// DecisionRecorder 保存决策记录，实现方不能阻塞交易流程
+type DecisionRecorder interface {
+	Record(rec DecisionRecord)
+}
+
+// TradingView 策略决策时的仓位快照
+type managerPosition struct {
+	HasL2Position bool    `json:"has_l2_position"`
+	L2Entry       float64 `json:"l2_entry"`
+	UnrealizedR   float64 `json:"unrealized_r"`
+}
+
+// 配置在运行期间不会变化，只计算一次
+func strategyConfigVersion(cfg conf.StrategyConfig) string {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// 规则热加载后版本随之变化
+func joinConfigVersion(cfgVersion string, rules *RuleEngine) string {
+	v := rules.Version()
+	if v == "" {
+		return cfgVersion
+	}
+	if cfgVersion == "" {
+		return v
+	}
+	return cfgVersion + "/" + v
+}
+
+func (m *defaultSignalManager) record(sig Signal, ctx DecisionContext, decision Decision) {
+	if m.recorder == nil {
+		return
+	}
+	rec := DecisionRecord{
+		Source:     DecisionSourceManager,
+		Strategy:   sig.Strategy,
+		SignalID:   metaSignalID(sig.Meta),
+		Symbol:     sig.Symbol,
+		Level:      sig.Level,
+		Side:       sig.Side,
+		Price:      sig.Price,
+		SignalTime: sig.Timestamp,
+		Signal:     sig,
+		Trend:      ctx.Trend,
+		Position: managerPosition{
+			HasL2Position: ctx.HasL2Position,
+			L2Entry:       ctx.L2Entry,
+			UnrealizedR:   ctx.UnrealizedR,
+		},
+		Action:        decision.Action,
+		Reason:        decision.Reason,
+		ReducePercent: decision.ReducePercent,
+		ConfigVersion: joinConfigVersion(m.cfgVersion, m.rules),
+		DecidedAt:     time.Now(),
+	}
+	if rec.Trend == nil {
+		// 至少保留策略给出的趋势方向
+		rec.Trend = &trend.TrendState{Symbol: sig.Symbol, Direction: ctx.TrendDir}
+	}
+	m.recorder.Record(rec)
+}
+
+func (de *DecisionEngine) record(decision Decision) {
+	if de.Recorder == nil {
+		return
+	}
+	st := de.Ctx.Trend
+	rec := DecisionRecord{
+		Source:        DecisionSourceEngine,
+		Strategy:      de.Strategy,
+		SignalID:      de.SignalID,
+		Symbol:        de.Ctx.Sig.Symbol,
+		Side:          de.Ctx.Sig.Side,
+		Price:         de.Ctx.Sig.Price,
+		SignalTime:    de.Ctx.Sig.Timestamp,
+		Signal:        de.Ctx.Sig,
+		Trend:         &st,
+		Action:        decision.Action,
+		Reason:        decision.Reason,
+		ReducePercent: decision.ReducePercent,
+		ConfigVersion: joinConfigVersion(de.cfgVersion, de.Rules),
+		DecidedAt:     time.Now(),
+	}
+	if rec.Symbol == "" {
+		rec.Symbol = st.Symbol
+	}
+	if de.Ctx.Pos != nil {
+		rec.Position = de.Ctx.Pos
+	}
+	if rec.ConfigVersion == "" {
+		rec.ConfigVersion = "builtin"
+	}
+	de.Recorder.Record(rec)
+}
+
+// ID 信号关联的 signals 表 id，没有时为 0
+func (sig Signal) ID() uint64 {
+	return metaSignalID(sig.Meta)
+}
+
+// TradingView 信号可以在 meta 中带上 signal_id 关联 signals 表
+func metaSignalID(meta map[string]any) uint64 {
+	switch v := meta["signal_id"].(type) {
+	case float64:
+		if v > 0 {
+			return uint64(v)
+		}
+	case int64:
+		if v > 0 {
+			return uint64(v)
+		}
+	case int:
+		if v > 0 {
+			return uint64(v)
+		}
+	case uint64:
+		return v
+	case string:
+		var id uint64
+		if _, err := fmt.Sscan(v, &id); err == nil {
+			return id
+		}
+	}
+	return 0
+}
+
+// TrendName 趋势方向的英文名，与规则文件中的 trend 取值一致
+func TrendName(d trend.TrendDirection) string {
+	for name, dir := range trendNames {
+		if dir == d {
+			return name
+		}
+	}
+	return fmt.Sprintf("unknown(%d)", int(d))
+}
@@ -0,0 +1,122 @@
+package signal
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	"edgeflow/internal/trend"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memRecorder struct {
+	records []DecisionRecord
+}
+
+func (r *memRecorder) Record(rec DecisionRecord) {
+	r.records = append(r.records, rec)
+}
+
+func TestManagerRecordsDecision(t *testing.T) {
+	rec := &memRecorder{}
+	cfg := conf.StrategyConfig{MinSpacingL2: 5 * time.Minute}
+	m, err := NewDefaultSignalManager(context.Background(), cfg, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := &trend.TrendState{Symbol: "ETH/USDT", Direction: trend.TrendUp, Scores: trend.TrendScores{FinalScore: 1.5, Score4h: 2}}
+	sig := Signal{Strategy: "tv-level", Symbol: "ETH/USDT", Side: "buy", Level: 2, Price: 3000, Timestamp: time.Now(), Meta: map[string]any{"signal_id": float64(42)}}
+	d := m.Decide(sig, DecisionContext{TrendDir: trend.TrendUp, Trend: st})
+
+	if len(rec.records) != 1 {
+		t.Fatalf("应记录一次决策: %d", len(rec.records))
+	}
+	got := rec.records[0]
+	if got.Source != DecisionSourceManager || got.Strategy != "tv-level" || got.SignalID != 42 || got.Level != 2 || got.Symbol != "ETH/USDT" {
+		t.Fatalf("信号信息不正确: %+v", got)
+	}
+	if got.Action != d.Action || got.Reason != d.Reason || got.Trend != st {
+		t.Fatalf("决策结果或趋势快照不正确: %+v", got)
+	}
+	if got.ConfigVersion == "" || got.ConfigVersion != strategyConfigVersion(cfg) {
+		t.Fatalf("配置版本不正确: %q", got.ConfigVersion)
+	}
+	if pos, ok := got.Position.(managerPosition); !ok || pos.HasL2Position {
+		t.Fatalf("仓位快照不正确: %+v", got.Position)
+	}
+
+	// 被频次限制的决策按限制后的结果记录
+	now := time.Now()
+	limiter := NewTradeLimiter(TradeLimiterConfig{})
+	limiter.now = func() time.Time { return now }
+	limiter.Record("ETH/USDT", ActOpen, 3000, "buy")
+	sig.Timestamp = now.Add(10 * time.Minute)
+	d = m.Decide(sig, DecisionContext{TrendDir: trend.TrendUp, Trend: st, Limiter: limiter})
+	if last := rec.records[len(rec.records)-1]; d.Action != ActIgnore || last.Action != ActIgnore || last.Reason != LimitOpenCooldown {
+		t.Fatalf("应记录限制后的决策: %+v %+v", d, last)
+	}
+
+	// 没有完整趋势时保留策略给出的方向
+	m.Decide(Signal{Symbol: "BTC/USDT", Side: "sell", Level: 1, Timestamp: time.Now()}, DecisionContext{TrendDir: trend.TrendDown})
+	if tr := rec.records[len(rec.records)-1].Trend; tr == nil || tr.Direction != trend.TrendDown {
+		t.Fatalf("应记录趋势方向: %+v", tr)
+	}
+}
+
+func TestEngineRecordsDecision(t *testing.T) {
+	ctx := Context{
+		Trend: trend.TrendState{Symbol: "BTC/USDT", Direction: trend.TrendNeutral},
+		Sig:   trend.Signal{Side: "buy", Price: 100, Strength: 0.9, IsReversal: true},
+	}
+
+	rec := &memRecorder{}
+	action := NewDecisionEngine(ctx).WithRecorder(rec, "kline", 7).Run()
+	if len(rec.records) != 1 {
+		t.Fatalf("应记录一次决策: %d", len(rec.records))
+	}
+	got := rec.records[0]
+	if got.Action != action || got.Reason != "builtin:neutral" || got.ConfigVersion != "builtin" {
+		t.Fatalf("内置逻辑记录不正确: %+v", got)
+	}
+	if got.Source != DecisionSourceEngine || got.Symbol != "BTC/USDT" || got.SignalID != 7 || got.Position != nil {
+		t.Fatalf("信号信息不正确: %+v", got)
+	}
+
+	// 规则命中时记录规则名和规则版本，文件修改后版本变化
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte("engine: {rules: [{name: hold, action: ignore}]}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := NewRuleEngine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Pos = &model.PositionInfo{Dir: model.OrderPosSideLong, AvgPrice: 100}
+	NewDecisionEngine(ctx).WithRules(rules).WithRecorder(rec, "kline", 0).Run()
+	got = rec.records[1]
+	if got.Reason != "rule:hold" || !strings.HasPrefix(got.ConfigVersion, "rules-") || got.Position != ctx.Pos {
+		t.Fatalf("规则决策记录不正确: %+v", got)
+	}
+
+	// 带上策略配置时记录配置和规则两部分版本
+	cfg := conf.StrategyConfig{MinSpacingL2: time.Minute}
+	NewDecisionEngine(ctx).WithRules(rules).WithConfig(cfg).WithRecorder(rec, "kline", 0).Run()
+	if got = rec.records[2]; got.ConfigVersion != strategyConfigVersion(cfg)+"/"+rules.Version() {
+		t.Fatalf("应记录配置和规则版本: %s", got.ConfigVersion)
+	}
+
+	before := rules.Version()
+	if err := os.WriteFile(path, []byte("engine: {rules: [{name: hold, action: close}]}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := rules.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if rules.Version() == before {
+		t.Fatalf("规则修改后版本应变化")
+	}
+}
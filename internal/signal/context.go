@@ -20,7 +20,7 @@ type Context struct {
 	// 仓位信息
 	Pos *model.PositionInfo
 
-	// 风控
+	// 风控：当日盈亏折合多少个 R
 	DailyR float64
 
 	Line model.Kline
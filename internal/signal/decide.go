@@ -1,6 +1,7 @@
 package signal
 
 import (
+	"edgeflow/conf"
 	"edgeflow/internal/model"
 	"edgeflow/internal/trend"
 	"log"
@@ -11,29 +12,71 @@ import (
 // 决策
 type DecisionEngine struct {
 	Ctx Context
+	// 不为空时先按规则决策，没有命中再使用内置逻辑
+	Rules *RuleEngine
+
+	// 不为空时记录每一次决策
+	Recorder DecisionRecorder
+	Strategy string
+	SignalID uint64
+	// 策略配置的摘要，与规则版本一起写入决策记录
+	cfgVersion string
 }
 
 func NewDecisionEngine(ctx Context) *DecisionEngine {
 	return &DecisionEngine{Ctx: ctx}
 }
 
+// WithRules 使用规则决策
+func (de *DecisionEngine) WithRules(rules *RuleEngine) *DecisionEngine {
+	de.Rules = rules
+	return de
+}
+
+// WithRecorder 记录决策，signalID 为 signals 表中对应的信号，没有时传 0
+func (de *DecisionEngine) WithRecorder(recorder DecisionRecorder, strategy string, signalID uint64) *DecisionEngine {
+	de.Recorder = recorder
+	de.Strategy = strategy
+	de.SignalID = signalID
+	return de
+}
+
+// WithConfig 决策记录中带上策略配置的版本
+func (de *DecisionEngine) WithConfig(cfg conf.StrategyConfig) *DecisionEngine {
+	de.cfgVersion = strategyConfigVersion(cfg)
+	return de
+}
+
 func (de *DecisionEngine) Run() Action {
+	decision := de.decide()
+	de.record(decision)
+	return decision.Action
+}
+
+func (de *DecisionEngine) decide() Decision {
+	if decision, ok := de.Rules.engineDecide(ruleInputFromContext(de.Ctx)); ok {
+		return decision
+	}
+
 	// 更新并获取当前的趋势方向
 	currentTrendDirection := de.Ctx.Trend.Direction
 
+	// 内置逻辑没有细分原因，记录走的是哪个分支
+	reason := "builtin:" + TrendName(currentTrendDirection)
+
 	// 根据趋势方向来决定交易模式
 	switch currentTrendDirection {
 	case trend.TrendUp:
-		return de.handleTrend(getBullishOperator())
+		return Decision{Action: de.handleTrend(getBullishOperator()), Reason: reason}
 	case trend.TrendDown:
-		return de.handleTrend(getBearishOperator())
+		return Decision{Action: de.handleTrend(getBearishOperator()), Reason: reason}
 	case trend.TrendReversal:
-		return de.handleReversal()
+		return Decision{Action: de.handleReversal(), Reason: reason}
 	case trend.TrendNeutral:
-		return de.handleNeutral()
+		return Decision{Action: de.handleNeutral(), Reason: reason}
 	}
 
-	return ActIgnore
+	return Decision{Action: ActIgnore, Reason: reason}
 }
 
 // 震荡模式
@@ -0,0 +1,193 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PauseScope 暂停交易的范围
+type PauseScope string
+
+const (
+	PauseAll      PauseScope = "all"      // 暂停全部策略
+	PauseStrategy PauseScope = "strategy" // 暂停单个策略
+	PauseSymbol   PauseScope = "symbol"   // 暂停单个交易对
+)
+
+// ErrTradingPaused 交易已被紧急开关暂停
+var ErrTradingPaused = errors.New("交易已暂停")
+
+const defaultKillSwitchSync = 2 * time.Second
+
+// Pause 一条暂停记录，scope 为 all 时 target 为空
+type Pause struct {
+	Scope     PauseScope `json:"scope"`
+	Target    string     `json:"target"`
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (p Pause) key() string {
+	if p.Scope == PauseAll {
+		return string(PauseAll)
+	}
+	return string(p.Scope) + ":" + p.Target
+}
+
+// KillSwitchStore 保存暂停记录，多个实例共享同一份开关
+type KillSwitchStore interface {
+	Load(ctx context.Context) ([]Pause, error)
+	Set(ctx context.Context, p Pause) error
+	Delete(ctx context.Context, p Pause) error
+}
+
+// KillSwitch 紧急开关：可以暂停全部策略、单个策略或者单个交易对。
+// 检查只读本地缓存，Run 定期从 store 同步其它实例的修改
+type KillSwitch struct {
+	store KillSwitchStore
+
+	mu     sync.RWMutex
+	pauses map[string]Pause
+}
+
+// NewKillSwitch store 为空时只在本进程内生效
+func NewKillSwitch(store KillSwitchStore) *KillSwitch {
+	ks := &KillSwitch{store: store, pauses: make(map[string]Pause)}
+	if err := ks.Sync(context.Background()); err != nil {
+		log.Printf("[KillSwitch] 读取暂停记录失败: %v", err)
+	}
+	return ks
+}
+
+// Run 定期同步暂停记录，直到 ctx 结束
+func (ks *KillSwitch) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultKillSwitchSync
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.Sync(ctx); err != nil {
+				log.Printf("[KillSwitch] 同步暂停记录失败: %v", err)
+			}
+		}
+	}
+}
+
+// Sync 从 store 重新加载暂停记录
+func (ks *KillSwitch) Sync(ctx context.Context) error {
+	if ks.store == nil {
+		return nil
+	}
+	list, err := ks.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	pauses := make(map[string]Pause, len(list))
+	for _, p := range list {
+		pauses[p.key()] = p
+	}
+	ks.mu.Lock()
+	ks.pauses = pauses
+	ks.mu.Unlock()
+	return nil
+}
+
+// Check 策略或交易对被暂停时返回 ErrTradingPaused，ks 为 nil 时不限制
+func (ks *KillSwitch) Check(strategy, symbol string) error {
+	if ks == nil {
+		return nil
+	}
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	candidates := []Pause{
+		{Scope: PauseAll},
+		{Scope: PauseStrategy, Target: strategy},
+		{Scope: PauseSymbol, Target: symbol},
+	}
+	for _, c := range candidates {
+		if c.Scope != PauseAll && c.Target == "" {
+			continue
+		}
+		if p, ok := ks.pauses[c.key()]; ok {
+			return fmt.Errorf("%w: %s %s %s", ErrTradingPaused, p.Scope, p.Target, p.Reason)
+		}
+	}
+	return nil
+}
+
+// Pause 暂停交易，重复暂停时更新原因
+func (ks *KillSwitch) Pause(ctx context.Context, scope PauseScope, target, reason string) (Pause, error) {
+	p := Pause{Scope: scope, Target: target, Reason: reason, CreatedAt: time.Now()}
+	if err := validatePause(p); err != nil {
+		return p, err
+	}
+	if p.Scope == PauseAll {
+		p.Target = ""
+	}
+	if ks.store != nil {
+		if err := ks.store.Set(ctx, p); err != nil {
+			return p, err
+		}
+	}
+	ks.mu.Lock()
+	ks.pauses[p.key()] = p
+	ks.mu.Unlock()
+	log.Printf("[KillSwitch] 暂停 %s %s: %s", p.Scope, p.Target, p.Reason)
+	return p, nil
+}
+
+// Resume 恢复交易，只解除对应范围的暂停
+func (ks *KillSwitch) Resume(ctx context.Context, scope PauseScope, target string) error {
+	p := Pause{Scope: scope, Target: target}
+	if err := validatePause(p); err != nil {
+		return err
+	}
+	if ks.store != nil {
+		if err := ks.store.Delete(ctx, p); err != nil {
+			return err
+		}
+	}
+	ks.mu.Lock()
+	delete(ks.pauses, p.key())
+	ks.mu.Unlock()
+	log.Printf("[KillSwitch] 恢复 %s %s", p.Scope, p.Target)
+	return nil
+}
+
+// Pauses 当前生效的暂停记录，按创建时间排序
+func (ks *KillSwitch) Pauses() []Pause {
+	if ks == nil {
+		return nil
+	}
+	ks.mu.RLock()
+	out := make([]Pause, 0, len(ks.pauses))
+	for _, p := range ks.pauses {
+		out = append(out, p)
+	}
+	ks.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+func validatePause(p Pause) error {
+	switch p.Scope {
+	case PauseAll:
+		return nil
+	case PauseStrategy, PauseSymbol:
+		if p.Target == "" {
+			return fmt.Errorf("暂停 %s 需要指定 target", p.Scope)
+		}
+		return nil
+	}
+	return fmt.Errorf("未知的暂停范围: %s", p.Scope)
+}
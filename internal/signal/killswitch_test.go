@@ -0,0 +1,92 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type memKillSwitchStore struct {
+	pauses map[string]Pause
+}
+
+func (m *memKillSwitchStore) Load(ctx context.Context) ([]Pause, error) {
+	var out []Pause
+	for _, p := range m.pauses {
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (m *memKillSwitchStore) Set(ctx context.Context, p Pause) error {
+	m.pauses[p.key()] = p
+	return nil
+}
+
+func (m *memKillSwitchStore) Delete(ctx context.Context, p Pause) error {
+	delete(m.pauses, p.key())
+	return nil
+}
+
+func TestKillSwitch(t *testing.T) {
+	ctx := context.Background()
+	store := &memKillSwitchStore{pauses: make(map[string]Pause)}
+	ks := NewKillSwitch(store)
+
+	if err := ks.Check("tv-level", "BTC/USDT"); err != nil {
+		t.Fatalf("没有暂停时应允许: %v", err)
+	}
+	if _, err := ks.Pause(ctx, PauseStrategy, "", "维护"); err == nil {
+		t.Fatalf("暂停单个策略需要指定 target")
+	}
+
+	// 暂停单个交易对
+	if _, err := ks.Pause(ctx, PauseSymbol, "ETH/USDT", "行情异常"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Check("tv-level", "ETH/USDT"); !errors.Is(err, ErrTradingPaused) {
+		t.Fatalf("ETH 应被暂停: %v", err)
+	}
+	if err := ks.Check("tv-level", "BTC/USDT"); err != nil {
+		t.Fatalf("BTC 不应被暂停: %v", err)
+	}
+
+	// 暂停单个策略
+	ks.Pause(ctx, PauseStrategy, "tv-level", "")
+	if err := ks.Check("tv-level", "BTC/USDT"); !errors.Is(err, ErrTradingPaused) {
+		t.Fatalf("tv-level 应被暂停: %v", err)
+	}
+	if err := ks.Check("hype", "BTC/USDT"); err != nil {
+		t.Fatalf("其它策略不应被暂停: %v", err)
+	}
+
+	// 其它实例暂停全部，同步后生效
+	store.Set(ctx, Pause{Scope: PauseAll, Reason: "紧急停止"})
+	if err := ks.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Check("hype", "SOL/USDT"); !errors.Is(err, ErrTradingPaused) {
+		t.Fatalf("应暂停全部策略: %v", err)
+	}
+	if len(ks.Pauses()) != 3 {
+		t.Fatalf("暂停记录: %+v", ks.Pauses())
+	}
+
+	// 只解除对应范围的暂停
+	ks.Resume(ctx, PauseAll, "")
+	if err := ks.Check("hype", "SOL/USDT"); err != nil {
+		t.Fatalf("恢复后应允许: %v", err)
+	}
+	if err := ks.Check("hype", "ETH/USDT"); !errors.Is(err, ErrTradingPaused) {
+		t.Fatalf("ETH 仍应被暂停: %v", err)
+	}
+	if len(store.pauses) != 2 {
+		t.Fatalf("恢复应写入 store: %+v", store.pauses)
+	}
+
+	// 为空时不限制
+	var nilKs *KillSwitch
+	if nilKs.Check("tv-level", "ETH/USDT") != nil {
+		t.Fatalf("开关为空时不应限制")
+	}
+}
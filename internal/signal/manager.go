@@ -1,6 +1,7 @@
 package signal
 
 import (
+	"context"
 	"edgeflow/conf"
 	"edgeflow/internal/model"
 	"edgeflow/internal/trend"
@@ -16,6 +17,9 @@ type DecisionContext struct {
 	UnrealizedR   float64
 	TrendDir      trend.TrendDirection // 由策略提供的趋势/回撤过滤结果
 	StrongM15     bool                 // 是不是强15分钟趋势
+	Trend         *trend.TrendState    // 可选，完整的趋势状态，只用于决策记录
+	DailyR        float64              // 当日盈亏折合多少个 R，由组合风控计算
+	Limiter       *TradeLimiter        // 可选，超过频次限制的开仓/加仓改为忽略，决策记录中保存限制后的结果
 }
 
 // SignalManager 接口
@@ -31,17 +35,28 @@ type Manager interface {
 type defaultSignalManager struct {
 	mu sync.RWMutex
 	// 保存tradingView的信号状态
-	state        map[string]*State
-	level3Buffer []Signal
-	cfg          conf.StrategyConfig
+	state map[string]*State
+	cfg   conf.StrategyConfig
+	// 配置了规则文件时优先按规则决策
+	rules *RuleEngine
+	// 不为空时记录每一次决策
+	recorder   DecisionRecorder
+	cfgVersion string
 }
 
-func NewDefaultSignalManager(cfg conf.StrategyConfig) Manager {
-	return &defaultSignalManager{
-		state:        make(map[string]*State),
-		level3Buffer: []Signal{},
-		cfg:          cfg,
+// NewDefaultSignalManager 配置了规则文件时加载规则，ctx 结束时停止规则热加载
+func NewDefaultSignalManager(ctx context.Context, cfg conf.StrategyConfig, recorder DecisionRecorder) (Manager, error) {
+	rules, err := loadRules(ctx, cfg.RulesFile)
+	if err != nil {
+		return nil, err
 	}
+	return &defaultSignalManager{
+		state:      make(map[string]*State),
+		cfg:        cfg,
+		rules:      rules,
+		recorder:   recorder,
+		cfgVersion: strategyConfigVersion(cfg),
+	}, nil
 }
 
 // 保存最新信号
@@ -49,6 +64,10 @@ func (m *defaultSignalManager) Save(sig Signal) {
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.saveLocked(sig)
+}
+
+func (m *defaultSignalManager) saveLocked(sig Signal) {
 	s := m.ensureStateLocked(sig.Symbol)
 	s.LastByLevel[sig.Level] = sig
 	if sig.Level == 2 {
@@ -187,6 +206,13 @@ func (m *defaultSignalManager) GetLastSignal(symbol string, level int) *Signal {
 //}
 
 func (m *defaultSignalManager) Decide(sig Signal, ctx DecisionContext) Decision {
+	decision := m.decide(sig, ctx)
+	decision = ctx.Limiter.Check(sig.Symbol, decision)
+	m.record(sig, ctx, decision)
+	return decision
+}
+
+func (m *defaultSignalManager) decide(sig Signal, ctx DecisionContext) Decision {
 	st := m.getState(sig.Symbol)
 
 	m.mu.RLock()
@@ -201,6 +227,43 @@ func (m *defaultSignalManager) Decide(sig Signal, ctx DecisionContext) Decision
 		return has && last.Side == sig.Side && sig.Timestamp.Sub(last.Timestamp) < minSpacing
 	}
 
+	// -------- 规则 --------
+	if m.rules != nil {
+		in := RuleInput{
+			Level:       sig.Level,
+			Side:        sig.Side,
+			Strength:    sig.Strength(),
+			Price:       sig.Price,
+			TrendDir:    currentTrend,
+			StrongM15:   ctx.StrongM15,
+			HasPosition: ctx.HasL2Position,
+			UnrealizedR: ctx.UnrealizedR,
+			AvgPrice:    ctx.L2Entry,
+			DailyR:      ctx.DailyR,
+		}
+		switch sig.Level {
+		case 2:
+			in.Debounced = isDebounced(lastL2, hasL2, m.cfg.MinSpacingL2)
+		case 3:
+			in.Debounced = isDebounced(lastL3, hasL3, m.cfg.MinSpacingL3)
+		}
+		// 持仓方向取最近的 L2 信号方向
+		if ctx.HasL2Position && hasL2 {
+			in.PosSide = model.OrderPosSideLong
+			if lastL2.Side == string(model.Sell) {
+				in.PosSide = model.OrderPosSideShort
+			}
+		}
+		if decision, ok := m.rules.managerDecide(in); ok {
+			// 规则没有填写减仓比例时使用策略配置
+			if decision.Action == ActReduce && decision.ReducePercent == 0 {
+				decision.ReducePercent = m.cfg.L3ReducePercent
+			}
+			decision.Log(sig, &m.cfg)
+			return decision
+		}
+	}
+
 	// -------- 信号处理 --------
 	switch sig.Level {
 	case 2: // L2 主仓
@@ -290,13 +353,21 @@ func (m *defaultSignalManager) Decide(sig Signal, ctx DecisionContext) Decision
 }
 
 // 核心逻辑：判断是否执行信号以及是否需要先平仓
+// 整个读改写在 m.mu 下完成，避免与持久化时的状态拷贝并发
 func (m *defaultSignalManager) ShouldExecute(sig Signal) (bool, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.shouldExecuteLocked(sig)
+}
+
+func (m *defaultSignalManager) shouldExecuteLocked(sig Signal) (bool, bool) {
 
 	// STEP 2: 获取最新缓存
-	lastSignals := m.state[sig.Symbol].LastByLevel
+	st := m.ensureStateLocked(sig.Symbol)
+	lastSignals := st.LastByLevel
 
 	// SETP 4: 缓存信号
-	defer m.Save(sig)
+	defer m.saveLocked(sig)
 
 	// 获取最新缓存
 	lvl1, hasL1 := lastSignals[1]
@@ -328,7 +399,8 @@ func (m *defaultSignalManager) ShouldExecute(sig Signal) (bool, bool) {
 	case 3:
 		// 触发升级的最小数量
 		level3UpgradeThreshold := 2
-		level3Buffer := m.level3Buffer
+		// L3 缓存按币种保存，不同币种的 L3 信号互不影响升级计数
+		level3Buffer := st.Level3Buffer
 		if hasL2 && lvl2.Side == sig.Side && hasL1 && lvl1.Side == sig.Side {
 			// 1级和2级一致直接下单
 			return true, false
@@ -343,7 +415,7 @@ func (m *defaultSignalManager) ShouldExecute(sig Signal) (bool, bool) {
 			}
 
 			level3Buffer = append(level3Buffer, sig)
-			m.level3Buffer = level3Buffer
+			st.Level3Buffer = level3Buffer
 
 			// 检查是否满足升级条件
 			if len(level3Buffer) >= level3UpgradeThreshold {
@@ -354,10 +426,10 @@ func (m *defaultSignalManager) ShouldExecute(sig Signal) (bool, bool) {
 
 				// 清空缓存避免重复触发
 				level3Buffer = []Signal{}
-				m.level3Buffer = level3Buffer
+				st.Level3Buffer = level3Buffer
 
 				// 递交给上级逻辑处理
-				return m.ShouldExecute(sig)
+				return m.shouldExecuteLocked(sig)
 			} else {
 				log.Println("L3 信号仅记录，不执行")
 			}
@@ -0,0 +1,108 @@
+package signal
+
+import (
+	"context"
+	"edgeflow/conf"
+	"log"
+	"sync"
+	"time"
+)
+
+// 读写 Redis 的超时时间，避免存储异常卡住信号处理
+const stateStoreTimeout = 3 * time.Second
+
+// persistentSignalManager 在 defaultSignalManager 外层持久化信号状态：
+// 每个币种第一次被访问时从 StateStore 恢复，状态变化后写回，重新部署不会改变交易决策
+type persistentSignalManager struct {
+	*defaultSignalManager
+	store StateStore
+
+	loadMu sync.Mutex
+	loaded map[string]bool
+}
+
+// NewPersistentSignalManager 配置了规则文件时加载规则，ctx 结束时停止规则热加载
+func NewPersistentSignalManager(ctx context.Context, cfg conf.StrategyConfig, store StateStore, recorder DecisionRecorder) (Manager, error) {
+	rules, err := loadRules(ctx, cfg.RulesFile)
+	if err != nil {
+		return nil, err
+	}
+	return &persistentSignalManager{
+		defaultSignalManager: &defaultSignalManager{
+			state:      make(map[string]*State),
+			cfg:        cfg,
+			rules:      rules,
+			recorder:   recorder,
+			cfgVersion: strategyConfigVersion(cfg),
+		},
+		store:  store,
+		loaded: make(map[string]bool),
+	}, nil
+}
+
+func (m *persistentSignalManager) Save(sig Signal) {
+	m.restore(sig.Symbol)
+	m.defaultSignalManager.Save(sig)
+	m.persist(sig.Symbol)
+}
+
+func (m *persistentSignalManager) GetLastSignal(symbol string, level int) *Signal {
+	m.restore(symbol)
+	return m.defaultSignalManager.GetLastSignal(symbol, level)
+}
+
+func (m *persistentSignalManager) ShouldExecute(sig Signal) (bool, bool) {
+	m.restore(sig.Symbol)
+	execute, closeFirst := m.defaultSignalManager.ShouldExecute(sig)
+	m.persist(sig.Symbol)
+	return execute, closeFirst
+}
+
+func (m *persistentSignalManager) Decide(sig Signal, ctx DecisionContext) Decision {
+	m.restore(sig.Symbol)
+	return m.defaultSignalManager.Decide(sig, ctx)
+}
+
+// restore 每个币种只恢复一次，读取失败时下次访问再重试
+func (m *persistentSignalManager) restore(symbol string) {
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+	if m.loaded[symbol] {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stateStoreTimeout)
+	defer cancel()
+	st, err := m.store.Load(ctx, symbol)
+	if err != nil {
+		log.Printf("[SignalManager] 恢复 %s 信号状态失败: %v", symbol, err)
+		return
+	}
+	m.loaded[symbol] = true
+	if st == nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.state[symbol] = st
+	m.mu.Unlock()
+	log.Printf("[SignalManager] 已恢复 %s 信号状态，L2方向: %s", symbol, st.L2Side)
+}
+
+func (m *persistentSignalManager) persist(symbol string) {
+	m.mu.RLock()
+	st, ok := m.state[symbol]
+	if ok {
+		st = st.clone()
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stateStoreTimeout)
+	defer cancel()
+	if err := m.store.Save(ctx, symbol, st); err != nil {
+		log.Printf("[SignalManager] 保存 %s 信号状态失败: %v", symbol, err)
+	}
+}
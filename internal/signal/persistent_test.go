@@ -0,0 +1,120 @@
+package signal
+
+import (
+	"context"
+	"edgeflow/conf"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 内存版 StateStore，序列化一遍以模拟 Redis 读写
+type memStateStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (s *memStateStore) Load(ctx context.Context, symbol string) (*State, error) {
+	s.mu.Lock()
+	raw, ok := s.data[symbol]
+	s.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	var st State
+	if err := json.Unmarshal(raw, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (s *memStateStore) Save(ctx context.Context, symbol string, st *State) error {
+	raw, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.data[symbol] = raw
+	s.mu.Unlock()
+	return nil
+}
+
+func TestPersistentSignalManager_Restore(t *testing.T) {
+	store := &memStateStore{data: make(map[string][]byte)}
+	cfg := conf.StrategyConfig{MinSpacingL2: 5 * time.Minute}
+	now := time.Now().Truncate(time.Second)
+
+	m, err := NewPersistentSignalManager(context.Background(), cfg, store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Save(Signal{Symbol: "BTC/USDT", Side: "buy", Level: 2, Timestamp: now})
+	m.ShouldExecute(Signal{Symbol: "BTC/USDT", Side: "sell", Level: 3, Timestamp: now})
+
+	// 模拟重启
+	restarted, _ := NewPersistentSignalManager(context.Background(), cfg, store, nil)
+	last := restarted.GetLastSignal("BTC/USDT", 2)
+	if last == nil || last.Side != "buy" || !last.Timestamp.Equal(now) {
+		t.Fatalf("重启后应恢复 L2 信号: %+v", last)
+	}
+
+	st := restarted.(*persistentSignalManager).state["BTC/USDT"]
+	if st.L2Side != "buy" || !st.L2LastFlipAt.Equal(now) || len(st.Level3Buffer) != 1 {
+		t.Fatalf("重启后应恢复 L2 方向和 L3 缓存: %+v", st)
+	}
+
+	// 恢复后的防抖与重启前一致
+	d := restarted.Decide(Signal{Symbol: "BTC/USDT", Side: "buy", Level: 2, Timestamp: now.Add(time.Minute)}, DecisionContext{})
+	if d.Action != ActIgnore || d.Reason != "L2-debounce" {
+		t.Fatalf("恢复后应触发L2防抖: %+v", d)
+	}
+}
+
+// 并发处理信号时，L3 缓存的修改与持久化拷贝不能交错（配合 -race 运行）
+func TestPersistentSignalManager_ConcurrentShouldExecute(t *testing.T) {
+	store := &memStateStore{data: make(map[string][]byte)}
+	m, err := NewPersistentSignalManager(context.Background(), conf.StrategyConfig{}, store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				side := "buy"
+				if (i+j)%3 == 0 {
+					side = "sell"
+				}
+				m.ShouldExecute(Signal{Symbol: "BTC/USDT", Side: side, Level: 3, Timestamp: now})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	st := m.(*persistentSignalManager).state["BTC/USDT"]
+	if len(st.Level3Buffer) > 1 {
+		t.Fatalf("达到升级阈值后应清空 L3 缓存: %d", len(st.Level3Buffer))
+	}
+}
+
+func TestStateTTL(t *testing.T) {
+	now := time.Now()
+	st := &State{LastByLevel: map[int]Signal{
+		1: {Level: 1, Timestamp: now.Add(-7 * time.Hour)},
+		3: {Level: 3, Timestamp: now.Add(-10 * time.Minute)},
+	}}
+	// L1 剩 1 小时，L3 剩 40 分钟，取最晚的
+	if ttl := st.ttl(now); ttl != time.Hour {
+		t.Fatalf("TTL 应为1小时: %v", ttl)
+	}
+	st.LastByLevel[1] = Signal{Level: 1, Timestamp: now.Add(-9 * time.Hour)}
+	st.LastByLevel[3] = Signal{Level: 3, Timestamp: now.Add(-time.Hour)}
+	if ttl := st.ttl(now); ttl != 0 {
+		t.Fatalf("信号全部过期时 TTL 应为0: %v", ttl)
+	}
+}
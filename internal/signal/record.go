@@ -1,6 +1,9 @@
 package signal
 
 import (
+	"context"
+	"edgeflow/conf"
+	"log"
 	"sync"
 	"time"
 )
@@ -25,29 +28,52 @@ type SymbolStats struct {
 	LastResetTime    time.Time     `json:"last_reset_time"`   // 上次重置时间
 	TotalOpensToday  int           `json:"total_opens_today"` // 今日总开仓次数
 	TotalAddsToday   int           `json:"total_adds_today"`  // 今日总加仓次数
-	mu               sync.RWMutex  `json:"-"`                 // 读写锁
 }
 
-// TradeLimiterConfig 配置参数
-type TradeLimiterConfig struct {
-	MaxConsecutiveOpens int           `json:"max_consecutive_opens"` // 最大连续开仓次数
-	MaxConsecutiveAdds  int           `json:"max_consecutive_adds"`  // 最大连续加仓次数
-	OpenCooldownPeriod  time.Duration `json:"open_cooldown_period"`  // 开仓冷却时间
-	AddCooldownPeriod   time.Duration `json:"add_cooldown_period"`   // 加仓冷却时间
-	MaxOpensPerDay      int           `json:"max_opens_per_day"`     // 每日最大开仓次数
-	MaxAddsPerDay       int           `json:"max_adds_per_day"`      // 每日最大加仓次数
-	HistoryKeepCount    int           `json:"history_keep_count"`    // 保留历史记录数量
-	ResetHour           int           `json:"reset_hour"`            // 每日重置时间（小时）
+func (s *SymbolStats) clone() *SymbolStats {
+	cp := *s
+	cp.TradeHistory = make([]TradeRecord, len(s.TradeHistory))
+	copy(cp.TradeHistory, s.TradeHistory)
+	return &cp
 }
 
+// TradeLimiterConfig 配置参数
+type TradeLimiterConfig = conf.TradeLimiterConfig
+
+// 被限制时的原因，写入 Decision.Reason
+const (
+	LimitOpenConsecutive = "limit:open-consecutive"
+	LimitOpenCooldown    = "limit:open-cooldown"
+	LimitOpenDaily       = "limit:open-daily"
+	LimitAddConsecutive  = "limit:add-consecutive"
+	LimitAddCooldown     = "limit:add-cooldown"
+	LimitAddDaily        = "limit:add-daily"
+)
+
 // TradeLimiter 交易频次控制器
+// 所有统计由 mu 保护；配置了 store 时每个交易对第一次访问从 store 恢复，
+// 变化后在 mu 下拷贝一份，释放 mu 之后再写回，写回由 saveMu 串行，旧的拷贝不会覆盖新的
 type TradeLimiter struct {
-	config      TradeLimiterConfig      `json:"config"`
-	symbolStats map[string]*SymbolStats `json:"symbol_stats"`
-	mu          sync.RWMutex            `json:"-"`
+	config      TradeLimiterConfig
+	symbolStats map[string]*SymbolStats
+	mu          sync.Mutex
+
+	store  TradeLimiterStore
+	loaded map[string]bool
+	now    func() time.Time
+
+	seq    map[string]uint64 // 每个交易对统计变化的次数，mu 保护
+	saveMu sync.Mutex
+	saved  map[string]uint64 // 已经写回的版本，saveMu 保护
+}
+
+// statsSnapshot 待写回 store 的统计拷贝
+type statsSnapshot struct {
+	stats *SymbolStats
+	seq   uint64
 }
 
-// NewTradeLimiter 创建交易频次控制器
+// NewTradeLimiter 创建交易频次控制器，统计只保存在内存
 func NewTradeLimiter(config TradeLimiterConfig) *TradeLimiter {
 	// 设置默认配置
 	if config.MaxConsecutiveOpens == 0 {
@@ -78,94 +104,152 @@ func NewTradeLimiter(config TradeLimiterConfig) *TradeLimiter {
 	return &TradeLimiter{
 		config:      config,
 		symbolStats: make(map[string]*SymbolStats),
+		loaded:      make(map[string]bool),
+		now:         time.Now,
+		seq:         make(map[string]uint64),
+		saved:       make(map[string]uint64),
+	}
+}
+
+// NewPersistentTradeLimiter 统计保存在 store 中，重启后限制继续生效
+func NewPersistentTradeLimiter(config TradeLimiterConfig, store TradeLimiterStore) *TradeLimiter {
+	tl := NewTradeLimiter(config)
+	tl.store = store
+	return tl
+}
+
+// Config 当前生效的配置（已填充默认值）
+func (tl *TradeLimiter) Config() TradeLimiterConfig {
+	return tl.config
+}
+
+// Check 在 Decide 之后、执行之前检查频次，超过限制的开仓/加仓改为 ActIgnore
+// limiter 为 nil 时不做限制
+func (tl *TradeLimiter) Check(symbol string, decision Decision) Decision {
+	if tl == nil {
+		return decision
+	}
+	var reason string
+	switch decision.Action {
+	case ActOpen:
+		reason = tl.checkOpen(symbol)
+	case ActAdd:
+		reason = tl.checkAdd(symbol)
+	default:
+		return decision
+	}
+	if reason == "" {
+		return decision
+	}
+	log.Printf("[TradeLimiter] %s %s 被限制: %s (原因: %s)", symbol, decision.Action, reason, decision.Reason)
+	return Decision{Action: ActIgnore, Reason: reason}
+}
+
+// Record 执行成功后记录交易，开仓/加仓累加计数，平仓重置连续计数
+func (tl *TradeLimiter) Record(symbol string, action Action, price float64, side string) {
+	if tl == nil {
+		return
+	}
+	switch action {
+	case ActOpen, ActAdd:
+		tl.RecordTrade(symbol, action, price, side)
+	case ActClose:
+		tl.RecordClose(symbol, price, side)
 	}
 }
 
 // CanOpen 检查是否可以开仓
 func (tl *TradeLimiter) CanOpen(symbol string) bool {
+	return tl.checkOpen(symbol) == ""
+}
+
+// CanAdd 检查是否可以加仓
+func (tl *TradeLimiter) CanAdd(symbol string) bool {
+	return tl.checkAdd(symbol) == ""
+}
+
+// checkOpen 返回不能开仓的原因，可以开仓时返回空
+func (tl *TradeLimiter) checkOpen(symbol string) string {
+	// 先注册写回，在释放 mu 之后执行
+	var snap *statsSnapshot
+	defer func() { tl.persist(snap) }()
 	tl.mu.Lock()
 	defer tl.mu.Unlock()
 
 	stats := tl.getOrCreateSymbolStats(symbol)
-	stats.mu.RLock()
-	defer stats.mu.RUnlock()
-
-	tl.checkAndResetDaily(stats)
-
-	now := time.Now()
+	now := tl.now()
+	if tl.checkAndResetDaily(stats, now) {
+		snap = tl.snapshotLocked(stats)
+	}
 
 	// 检查连续开仓次数
 	if stats.ConsecutiveOpens >= tl.config.MaxConsecutiveOpens {
-		return false
+		return LimitOpenConsecutive
 	}
 
 	// 检查开仓冷却时间
 	if !stats.LastOpenTime.IsZero() && now.Sub(stats.LastOpenTime) < tl.config.OpenCooldownPeriod {
-		return false
+		return LimitOpenCooldown
 	}
 
 	// 检查每日开仓次数限制
 	if stats.TotalOpensToday >= tl.config.MaxOpensPerDay {
-		return false
+		return LimitOpenDaily
 	}
 
-	return true
+	return ""
 }
 
-// CanAdd 检查是否可以加仓
-func (tl *TradeLimiter) CanAdd(symbol string) bool {
+// checkAdd 返回不能加仓的原因，可以加仓时返回空
+func (tl *TradeLimiter) checkAdd(symbol string) string {
+	// 先注册写回，在释放 mu 之后执行
+	var snap *statsSnapshot
+	defer func() { tl.persist(snap) }()
 	tl.mu.Lock()
 	defer tl.mu.Unlock()
 
 	stats := tl.getOrCreateSymbolStats(symbol)
-	stats.mu.RLock()
-	defer stats.mu.RUnlock()
-
-	tl.checkAndResetDaily(stats)
-
-	now := time.Now()
+	now := tl.now()
+	if tl.checkAndResetDaily(stats, now) {
+		snap = tl.snapshotLocked(stats)
+	}
 
 	// 检查连续加仓次数
 	if stats.ConsecutiveAdds >= tl.config.MaxConsecutiveAdds {
-		return false
+		return LimitAddConsecutive
 	}
 
 	// 检查加仓冷却时间
 	if !stats.LastAddTime.IsZero() && now.Sub(stats.LastAddTime) < tl.config.AddCooldownPeriod {
-		return false
+		return LimitAddCooldown
 	}
 
 	// 检查每日加仓次数限制
 	if stats.TotalAddsToday >= tl.config.MaxAddsPerDay {
-		return false
+		return LimitAddDaily
 	}
 
-	return true
+	return ""
 }
 
 // RecordTrade 记录交易
 func (tl *TradeLimiter) RecordTrade(symbol string, action Action, price float64, side string) {
+	// 先注册写回，在释放 mu 之后执行
+	var snap *statsSnapshot
+	defer func() { tl.persist(snap) }()
 	tl.mu.Lock()
 	defer tl.mu.Unlock()
 
 	stats := tl.getOrCreateSymbolStats(symbol)
-	stats.mu.Lock()
-	defer stats.mu.Unlock()
-
-	now := time.Now()
-	record := TradeRecord{
+	now := tl.now()
+	tl.checkAndResetDaily(stats, now)
+	tl.appendHistory(stats, TradeRecord{
 		Action:    action,
 		Symbol:    symbol,
 		Timestamp: now,
 		Price:     price,
 		Side:      side,
-	}
-
-	// 添加到历史记录
-	stats.TradeHistory = append(stats.TradeHistory, record)
-	if len(stats.TradeHistory) > tl.config.HistoryKeepCount {
-		stats.TradeHistory = stats.TradeHistory[1:] // 移除最旧的记录
-	}
+	})
 
 	// 更新统计信息
 	switch action {
@@ -180,91 +264,93 @@ func (tl *TradeLimiter) RecordTrade(symbol string, action Action, price float64,
 		stats.LastAddTime = now
 		stats.TotalAddsToday++
 	}
+	snap = tl.snapshotLocked(stats)
 }
 
 // RecordClose 记录平仓（重置连续计数）
 func (tl *TradeLimiter) RecordClose(symbol string, price float64, side string) {
+	// 先注册写回，在释放 mu 之后执行
+	var snap *statsSnapshot
+	defer func() { tl.persist(snap) }()
 	tl.mu.Lock()
 	defer tl.mu.Unlock()
 
 	stats := tl.getOrCreateSymbolStats(symbol)
-	stats.mu.Lock()
-	defer stats.mu.Unlock()
-
-	now := time.Now()
-	record := TradeRecord{
+	tl.appendHistory(stats, TradeRecord{
 		Action:    ActClose,
 		Symbol:    symbol,
-		Timestamp: now,
+		Timestamp: tl.now(),
 		Price:     price,
 		Side:      side,
-	}
-
-	// 添加到历史记录
-	stats.TradeHistory = append(stats.TradeHistory, record)
-	if len(stats.TradeHistory) > tl.config.HistoryKeepCount {
-		stats.TradeHistory = stats.TradeHistory[1:]
-	}
+	})
 
 	// 平仓后重置所有连续计数
 	stats.ConsecutiveOpens = 0
 	stats.ConsecutiveAdds = 0
+	snap = tl.snapshotLocked(stats)
 }
 
 // GetSymbolStats 获取交易对统计信息
 func (tl *TradeLimiter) GetSymbolStats(symbol string) *SymbolStats {
-	tl.mu.RLock()
-	defer tl.mu.RUnlock()
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
 
+	tl.restoreLocked(symbol)
 	if stats, exists := tl.symbolStats[symbol]; exists {
-		stats.mu.RLock()
-		defer stats.mu.RUnlock()
-
 		// 返回副本，避免外部修改
-		statsCopy := *stats
-		statsCopy.TradeHistory = make([]TradeRecord, len(stats.TradeHistory))
-		copy(statsCopy.TradeHistory, stats.TradeHistory)
-		return &statsCopy
+		return stats.clone()
 	}
 	return nil
 }
 
-// GetAllStats 获取所有交易对统计信息
+// GetAllStats 获取所有交易对统计信息，包括只保存在 store 中还没有访问过的交易对
 func (tl *TradeLimiter) GetAllStats() map[string]*SymbolStats {
-	tl.mu.RLock()
-	defer tl.mu.RUnlock()
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	if tl.store != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), stateStoreTimeout)
+		symbols, err := tl.store.Symbols(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("[TradeLimiter] 读取交易对列表失败: %v", err)
+		}
+		for _, symbol := range symbols {
+			tl.restoreLocked(symbol)
+		}
+	}
 
 	result := make(map[string]*SymbolStats)
 	for symbol, stats := range tl.symbolStats {
-		stats.mu.RLock()
-		statsCopy := *stats
-		statsCopy.TradeHistory = make([]TradeRecord, len(stats.TradeHistory))
-		copy(statsCopy.TradeHistory, stats.TradeHistory)
-		result[symbol] = &statsCopy
-		stats.mu.RUnlock()
+		result[symbol] = stats.clone()
 	}
 	return result
 }
 
 // ResetSymbol 重置指定交易对的统计信息
 func (tl *TradeLimiter) ResetSymbol(symbol string) {
+	// 先注册写回，在释放 mu 之后执行
+	var snap *statsSnapshot
+	defer func() { tl.persist(snap) }()
 	tl.mu.Lock()
 	defer tl.mu.Unlock()
 
+	tl.restoreLocked(symbol)
 	if stats, exists := tl.symbolStats[symbol]; exists {
-		stats.mu.Lock()
-		defer stats.mu.Unlock()
-
 		stats.ConsecutiveOpens = 0
 		stats.ConsecutiveAdds = 0
 		stats.TotalOpensToday = 0
 		stats.TotalAddsToday = 0
-		stats.LastResetTime = time.Now()
+		stats.LastOpenTime = time.Time{}
+		stats.LastAddTime = time.Time{}
+		stats.LastResetTime = tl.now()
+		snap = tl.snapshotLocked(stats)
 	}
 }
 
 // 获取或创建交易对统计信息（内部方法，调用前需要加锁）
 func (tl *TradeLimiter) getOrCreateSymbolStats(symbol string) *SymbolStats {
+	tl.restoreLocked(symbol)
 	if stats, exists := tl.symbolStats[symbol]; exists {
 		return stats
 	}
@@ -274,15 +360,21 @@ func (tl *TradeLimiter) getOrCreateSymbolStats(symbol string) *SymbolStats {
 		ConsecutiveOpens: 0,
 		ConsecutiveAdds:  0,
 		TradeHistory:     make([]TradeRecord, 0),
-		LastResetTime:    time.Now(),
+		LastResetTime:    tl.now(),
 	}
 	tl.symbolStats[symbol] = stats
 	return stats
 }
 
-// 检查并重置每日统计（内部方法）
-func (tl *TradeLimiter) checkAndResetDaily(stats *SymbolStats) {
-	now := time.Now()
+func (tl *TradeLimiter) appendHistory(stats *SymbolStats, record TradeRecord) {
+	stats.TradeHistory = append(stats.TradeHistory, record)
+	if len(stats.TradeHistory) > tl.config.HistoryKeepCount {
+		stats.TradeHistory = stats.TradeHistory[1:] // 移除最旧的记录
+	}
+}
+
+// 检查并重置每日统计（内部方法），发生重置时返回 true
+func (tl *TradeLimiter) checkAndResetDaily(stats *SymbolStats, now time.Time) bool {
 	lastReset := stats.LastResetTime
 
 	// 计算今天的重置时间点
@@ -293,46 +385,58 @@ func (tl *TradeLimiter) checkAndResetDaily(stats *SymbolStats) {
 		stats.TotalOpensToday = 0
 		stats.TotalAddsToday = 0
 		stats.LastResetTime = now
+		return true
 	}
+	return false
 }
 
-// 使用示例
-func main() {
-	// 创建配置
-	config := TradeLimiterConfig{
-		MaxConsecutiveOpens: 2,                // 最多连续开仓2次
-		MaxConsecutiveAdds:  3,                // 最多连续加仓3次
-		OpenCooldownPeriod:  30 * time.Minute, // 开仓冷却30分钟
-		AddCooldownPeriod:   15 * time.Minute, // 加仓冷却15分钟
-		MaxOpensPerDay:      8,                // 每日最多开仓8次
-		MaxAddsPerDay:       12,               // 每日最多加仓12次
+// restoreLocked 每个交易对只恢复一次，读取失败时下次访问再重试
+func (tl *TradeLimiter) restoreLocked(symbol string) {
+	if tl.store == nil || tl.loaded[symbol] {
+		return
 	}
-
-	// 创建限制器
-	limiter := NewTradeLimiter(config)
-
-	symbol := "BTCUSDT"
-
-	// 检查是否可以开仓
-	if limiter.CanOpen(symbol) {
-		// 执行开仓
-		limiter.RecordTrade(symbol, ActOpen, 50000.0, "LONG")
-		println("开仓成功")
+	ctx, cancel := context.WithTimeout(context.Background(), stateStoreTimeout)
+	defer cancel()
+	stats, err := tl.store.Load(ctx, symbol)
+	if err != nil {
+		log.Printf("[TradeLimiter] 恢复 %s 交易统计失败: %v", symbol, err)
+		return
 	}
-
-	// 检查是否可以加仓
-	if limiter.CanAdd(symbol) {
-		// 执行加仓
-		limiter.RecordTrade(symbol, ActAdd, 49800.0, "LONG")
-		println("加仓成功")
+	tl.loaded[symbol] = true
+	if stats == nil {
+		return
+	}
+	if stats.TradeHistory == nil {
+		stats.TradeHistory = make([]TradeRecord, 0)
 	}
+	tl.symbolStats[symbol] = stats
+}
 
-	// 记录平仓
-	limiter.RecordClose(symbol, 51000.0, "LONG")
+// snapshotLocked 拷贝一份待写回的统计，没有配置 store 时返回 nil
+func (tl *TradeLimiter) snapshotLocked(stats *SymbolStats) *statsSnapshot {
+	if tl.store == nil {
+		return nil
+	}
+	tl.seq[stats.Symbol]++
+	return &statsSnapshot{stats: stats.clone(), seq: tl.seq[stats.Symbol]}
+}
 
-	// 查看统计信息
-	stats := limiter.GetSymbolStats(symbol)
-	if stats != nil {
-		println("交易统计:", stats.Symbol, "连续开仓:", stats.ConsecutiveOpens, "连续加仓:", stats.ConsecutiveAdds)
+// persist 在 mu 之外写回 store，比已写回版本旧的拷贝直接丢弃
+func (tl *TradeLimiter) persist(snap *statsSnapshot) {
+	if snap == nil {
+		return
+	}
+	tl.saveMu.Lock()
+	defer tl.saveMu.Unlock()
+	symbol := snap.stats.Symbol
+	if snap.seq <= tl.saved[symbol] {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), stateStoreTimeout)
+	defer cancel()
+	if err := tl.store.Save(ctx, snap.stats); err != nil {
+		log.Printf("[TradeLimiter] 保存 %s 交易统计失败: %v", symbol, err)
+		return
 	}
+	tl.saved[symbol] = snap.seq
 }
@@ -0,0 +1,136 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// 内存版 TradeLimiterStore，序列化一遍以模拟 Redis 读写
+type memLimiterStore struct {
+	data map[string][]byte
+}
+
+func (s *memLimiterStore) Load(ctx context.Context, symbol string) (*SymbolStats, error) {
+	raw, ok := s.data[symbol]
+	if !ok {
+		return nil, nil
+	}
+	var stats SymbolStats
+	if err := json.Unmarshal(raw, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (s *memLimiterStore) Save(ctx context.Context, stats *SymbolStats) error {
+	raw, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	s.data[stats.Symbol] = raw
+	return nil
+}
+
+func (s *memLimiterStore) Symbols(ctx context.Context) ([]string, error) {
+	var symbols []string
+	for symbol := range s.data {
+		symbols = append(symbols, symbol)
+	}
+	return symbols, nil
+}
+
+func newTestLimiter(store TradeLimiterStore, now *time.Time) *TradeLimiter {
+	tl := NewPersistentTradeLimiter(TradeLimiterConfig{
+		MaxConsecutiveOpens: 2,
+		MaxConsecutiveAdds:  1,
+		OpenCooldownPeriod:  10 * time.Minute,
+		AddCooldownPeriod:   5 * time.Minute,
+		MaxOpensPerDay:      3,
+	}, store)
+	tl.now = func() time.Time { return *now }
+	return tl
+}
+
+func TestTradeLimiterCheck(t *testing.T) {
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.Local)
+	tl := newTestLimiter(&memLimiterStore{data: make(map[string][]byte)}, &now)
+	open := Decision{Action: ActOpen, Reason: "L2-open"}
+
+	if d := tl.Check("ETH/USDT", open); d != open {
+		t.Fatalf("第一次开仓不应被限制: %+v", d)
+	}
+	tl.Record("ETH/USDT", ActOpen, 3000, "buy")
+
+	// 冷却期内
+	now = now.Add(5 * time.Minute)
+	if d := tl.Check("ETH/USDT", open); d.Action != ActIgnore || d.Reason != LimitOpenCooldown {
+		t.Fatalf("冷却期内应被限制: %+v", d)
+	}
+
+	// 连续开仓上限
+	now = now.Add(10 * time.Minute)
+	tl.Record("ETH/USDT", ActOpen, 3000, "buy")
+	now = now.Add(time.Hour)
+	if d := tl.Check("ETH/USDT", open); d.Reason != LimitOpenConsecutive {
+		t.Fatalf("连续开仓应被限制: %+v", d)
+	}
+
+	// 加仓不受开仓计数影响，平仓、减仓不做限制
+	if d := tl.Check("ETH/USDT", Decision{Action: ActAdd}); d.Action != ActAdd {
+		t.Fatalf("加仓不应被限制: %+v", d)
+	}
+	if d := tl.Check("ETH/USDT", Decision{Action: ActClose}); d.Action != ActClose {
+		t.Fatalf("平仓不应被限制: %+v", d)
+	}
+
+	// 平仓后连续计数清零，但每日上限仍然生效
+	tl.Record("ETH/USDT", ActClose, 3100, "buy")
+	tl.Record("ETH/USDT", ActOpen, 3000, "buy")
+	now = now.Add(time.Hour)
+	tl.Record("ETH/USDT", ActClose, 3100, "buy")
+	if d := tl.Check("ETH/USDT", open); d.Reason != LimitOpenDaily {
+		t.Fatalf("超过每日开仓次数应被限制: %+v", d)
+	}
+
+	// 过了重置时间点后每日计数清零
+	now = time.Date(2025, 1, 3, 0, 30, 0, 0, time.Local)
+	if d := tl.Check("ETH/USDT", open); d != open {
+		t.Fatalf("第二天不应被限制: %+v", d)
+	}
+
+	// 为空时不做限制
+	var nilLimiter *TradeLimiter
+	if d := nilLimiter.Check("ETH/USDT", open); d != open {
+		t.Fatalf("limiter 为空时不应限制: %+v", d)
+	}
+	nilLimiter.Record("ETH/USDT", ActOpen, 3000, "buy")
+}
+
+func TestTradeLimiterRestore(t *testing.T) {
+	store := &memLimiterStore{data: make(map[string][]byte)}
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.Local)
+	tl := newTestLimiter(store, &now)
+	tl.Record("BTC/USDT", ActAdd, 90000, "buy")
+
+	// 模拟重启
+	now = now.Add(time.Minute)
+	restarted := newTestLimiter(store, &now)
+	if d := restarted.Check("BTC/USDT", Decision{Action: ActAdd}); d.Reason != LimitAddConsecutive {
+		t.Fatalf("重启后加仓计数应恢复: %+v", d)
+	}
+	if all := newTestLimiter(store, &now).GetAllStats(); all["BTC/USDT"] == nil || all["BTC/USDT"].TotalAddsToday != 1 {
+		t.Fatalf("应返回 store 中的统计: %+v", all)
+	}
+
+	// 重置后写回 store
+	restarted.ResetSymbol("BTC/USDT")
+	again := newTestLimiter(store, &now)
+	if d := again.Check("BTC/USDT", Decision{Action: ActAdd}); d.Action != ActAdd {
+		t.Fatalf("重置后不应被限制: %+v", d)
+	}
+	if stats := again.GetSymbolStats("BTC/USDT"); stats.ConsecutiveAdds != 0 || len(stats.TradeHistory) != 1 {
+		t.Fatalf("重置应清零计数并保留历史: %+v", stats)
+	}
+}
@@ -0,0 +1,521 @@
+package signal
+
+import (
+	"context"
+	"crypto/sha256"
+	"edgeflow/internal/model"
+	"edgeflow/internal/trend"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+决策规则，从 YAML 加载，按顺序匹配，第一条命中的规则决定动作：
+
+	manager:            # Manager.Decide 使用，TradingView 分级信号
+	  fallback: false   # 没有规则命中时是否使用内置逻辑，默认 true
+	  rules:
+	    - name: l2-debounce
+	      when: {level: [2], debounced: true}
+	      action: ignore
+	engine:             # DecisionEngine 使用，K线信号
+	  rules:
+	    - name: neutral-reversal-open
+	      when: {trend: [neutral], position: none, reversal: true, strength: {gte: 0.8}}
+	      action: open
+	      reason: 震荡市强反转信号开仓
+
+when 中没有填写的条件不参与判断，全部为空的规则匹配所有输入
+*/
+
+// RangeCond 数值区间，未填写的边界不限制
+type RangeCond struct {
+	Gt  *float64 `yaml:"gt"`
+	Gte *float64 `yaml:"gte"`
+	Lt  *float64 `yaml:"lt"`
+	Lte *float64 `yaml:"lte"`
+}
+
+func (r *RangeCond) match(v float64) bool {
+	if r == nil {
+		return true
+	}
+	return (r.Gt == nil || v > *r.Gt) &&
+		(r.Gte == nil || v >= *r.Gte) &&
+		(r.Lt == nil || v < *r.Lt) &&
+		(r.Lte == nil || v <= *r.Lte)
+}
+
+func (r *RangeCond) validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.Gt == nil && r.Gte == nil && r.Lt == nil && r.Lte == nil {
+		return errors.New("区间至少需要 gt/gte/lt/lte 之一")
+	}
+	if r.Gt != nil && r.Gte != nil || r.Lt != nil && r.Lte != nil {
+		return errors.New("gt 与 gte、lt 与 lte 不能同时设置")
+	}
+	lo, hi := r.Gt, r.Lt
+	if r.Gte != nil {
+		lo = r.Gte
+	}
+	if r.Lte != nil {
+		hi = r.Lte
+	}
+	if lo != nil && hi != nil && *lo > *hi {
+		return fmt.Errorf("下限 %v 大于上限 %v", *lo, *hi)
+	}
+	return nil
+}
+
+// RuleCondition 规则条件，所有填写的条件都满足才算命中
+type RuleCondition struct {
+	// 信号
+	Level            []int      `yaml:"level"` // 信号等级 1/2/3
+	Side             string     `yaml:"side"`  // buy / sell
+	Strength         *RangeCond `yaml:"strength"`
+	Reversal         *bool      `yaml:"reversal"`
+	ReversalStrength *RangeCond `yaml:"reversal_strength"`
+	Debounced        *bool      `yaml:"debounced"` // 与上一个同级同向信号的间隔小于配置的最小间隔
+
+	// 趋势
+	Trend           []string   `yaml:"trend"`            // up / down / neutral / reversal
+	TrendAligned    *bool      `yaml:"trend_aligned"`    // 信号方向与趋势方向一致
+	MomentumAligned *bool      `yaml:"momentum_aligned"` // 趋势斜率与信号方向一致
+	StrongM15       *bool      `yaml:"strong_m15"`
+	FinalScore      *RangeCond `yaml:"final_score"`
+	TrendScore      *RangeCond `yaml:"trend_score"`
+	Score30m        *RangeCond `yaml:"score_30m"`
+	Score1h         *RangeCond `yaml:"score_1h"`
+	Score4h         *RangeCond `yaml:"score_4h"`
+	Slope           *RangeCond `yaml:"slope"`
+
+	// 仓位
+	Position    string     `yaml:"position"`     // none 无持仓 / any 有持仓 / same 与信号同向 / opposite 与信号反向
+	PosSide     string     `yaml:"pos_side"`     // long / short
+	UplRatio    *RangeCond `yaml:"upl_ratio"`    // 持仓收益率，只用于 engine
+	UnrealizedR *RangeCond `yaml:"unrealized_r"` // 浮盈折合多少个 R，只用于 manager
+	PriceToAvg  *RangeCond `yaml:"price_to_avg"` // 信号价格 / 持仓均价 - 1
+
+	// 风控
+	DailyR *RangeCond `yaml:"daily_r"` // 当日盈亏折合多少个 R
+}
+
+type Rule struct {
+	Name          string        `yaml:"name"`
+	When          RuleCondition `yaml:"when"`
+	Action        string        `yaml:"action"` // ignore / open / add / reduce / tighten_sl / close
+	Reason        string        `yaml:"reason"`
+	ReducePercent float64       `yaml:"reduce_percent"` // action 为 reduce 时使用，manager 中不填写时使用 strategy.L3ReducePercent
+
+	action Action
+}
+
+// RuleScope 一组规则
+type RuleScope struct {
+	Fallback *bool  `yaml:"fallback"`
+	Rules    []Rule `yaml:"rules"`
+}
+
+// 没有配置这一组规则或者配置了 fallback 时，未命中的输入交给内置逻辑
+func (s *RuleScope) fallback() bool {
+	return s == nil || s.Fallback == nil || *s.Fallback
+}
+
+type RuleSet struct {
+	Manager *RuleScope `yaml:"manager"`
+	Engine  *RuleScope `yaml:"engine"`
+
+	// 规则文件内容的摘要，写入决策记录
+	version string
+}
+
+// RuleInput 规则判断的输入，由 Manager.Decide 和 DecisionEngine 各自填充
+type RuleInput struct {
+	Level            int
+	Side             string
+	Strength         float64
+	IsReversal       bool
+	ReversalStrength float64
+	Price            float64
+	Debounced        bool
+
+	TrendDir  trend.TrendDirection
+	Scores    trend.TrendScores
+	Slope     float64
+	StrongM15 bool
+
+	HasPosition bool
+	PosSide     model.OrderPosSide
+	UplRatio    float64
+	UnrealizedR float64
+	AvgPrice    float64
+
+	DailyR float64
+}
+
+var trendNames = map[string]trend.TrendDirection{
+	"neutral":  trend.TrendNeutral,
+	"up":       trend.TrendUp,
+	"down":     trend.TrendDown,
+	"reversal": trend.TrendReversal,
+}
+
+func parseAction(name string) (Action, error) {
+	for a := ActIgnore; a <= ActClose; a++ {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return ActIgnore, fmt.Errorf("未知的 action: %q", name)
+}
+
+func optBool(b *bool, v bool) bool {
+	return b == nil || *b == v
+}
+
+func (c *RuleCondition) match(in RuleInput) bool {
+	if len(c.Level) > 0 {
+		found := false
+		for _, l := range c.Level {
+			found = found || l == in.Level
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.Side != "" && c.Side != in.Side {
+		return false
+	}
+	if len(c.Trend) > 0 {
+		found := false
+		for _, name := range c.Trend {
+			found = found || trendNames[name] == in.TrendDir
+		}
+		if !found {
+			return false
+		}
+	}
+
+	sideSign := 0.0
+	switch in.Side {
+	case string(model.Buy):
+		sideSign = 1
+	case string(model.Sell):
+		sideSign = -1
+	}
+	posSide := ""
+	switch in.PosSide {
+	case model.OrderPosSideLong:
+		posSide = string(model.Buy)
+	case model.OrderPosSideShort:
+		posSide = string(model.Sell)
+	}
+	switch c.Position {
+	case "none":
+		if in.HasPosition {
+			return false
+		}
+	case "any":
+		if !in.HasPosition {
+			return false
+		}
+	case "same":
+		if !in.HasPosition || posSide == "" || posSide != in.Side {
+			return false
+		}
+	case "opposite":
+		if !in.HasPosition || posSide == "" || posSide == in.Side {
+			return false
+		}
+	}
+	if c.PosSide != "" && (!in.HasPosition || string(in.PosSide) != c.PosSide) {
+		return false
+	}
+	// 仓位相关的数值条件只在有持仓时成立
+	if (c.UplRatio != nil || c.PriceToAvg != nil) && !in.HasPosition {
+		return false
+	}
+	priceToAvg := 0.0
+	if in.AvgPrice > 0 {
+		priceToAvg = in.Price/in.AvgPrice - 1
+	}
+
+	return optBool(c.Reversal, in.IsReversal) &&
+		optBool(c.Debounced, in.Debounced) &&
+		optBool(c.TrendAligned, in.TrendDir.MatchesSide(model.OrderSide(in.Side))) &&
+		optBool(c.MomentumAligned, in.Slope*sideSign > 0) &&
+		optBool(c.StrongM15, in.StrongM15) &&
+		c.Strength.match(in.Strength) &&
+		c.ReversalStrength.match(in.ReversalStrength) &&
+		c.FinalScore.match(in.Scores.FinalScore) &&
+		c.TrendScore.match(in.Scores.TrendScore) &&
+		c.Score30m.match(in.Scores.Score30m) &&
+		c.Score1h.match(in.Scores.Score1h) &&
+		c.Score4h.match(in.Scores.Score4h) &&
+		c.Slope.match(in.Slope) &&
+		c.UplRatio.match(in.UplRatio) &&
+		c.UnrealizedR.match(in.UnrealizedR) &&
+		c.PriceToAvg.match(priceToAvg) &&
+		c.DailyR.match(in.DailyR)
+}
+
+func (c *RuleCondition) validate(scope string) error {
+	for _, l := range c.Level {
+		if l < 1 || l > 3 {
+			return fmt.Errorf("level 只能是 1/2/3: %d", l)
+		}
+	}
+	if c.Side != "" && c.Side != string(model.Buy) && c.Side != string(model.Sell) {
+		return fmt.Errorf("side 只能是 buy/sell: %q", c.Side)
+	}
+	for _, name := range c.Trend {
+		if _, ok := trendNames[name]; !ok {
+			return fmt.Errorf("未知的 trend: %q", name)
+		}
+	}
+	switch c.Position {
+	case "", "none", "any", "same", "opposite":
+	default:
+		return fmt.Errorf("position 只能是 none/any/same/opposite: %q", c.Position)
+	}
+	if c.PosSide != "" && c.PosSide != model.OrderPosSideLong && c.PosSide != model.OrderPosSideShort {
+		return fmt.Errorf("pos_side 只能是 long/short: %q", c.PosSide)
+	}
+	if c.Position == "none" && (c.PosSide != "" || c.UplRatio != nil || c.PriceToAvg != nil) {
+		return errors.New("position 为 none 时不能设置仓位条件")
+	}
+	// 两组规则的盈亏单位不同：manager 使用 R 倍数，engine 使用持仓收益率
+	if scope == "manager" && c.UplRatio != nil {
+		return errors.New("manager 规则使用 unrealized_r，不支持 upl_ratio")
+	}
+	if scope == "engine" && c.UnrealizedR != nil {
+		return errors.New("engine 规则使用 upl_ratio，不支持 unrealized_r")
+	}
+	ranges := map[string]*RangeCond{
+		"strength":          c.Strength,
+		"reversal_strength": c.ReversalStrength,
+		"final_score":       c.FinalScore,
+		"trend_score":       c.TrendScore,
+		"score_30m":         c.Score30m,
+		"score_1h":          c.Score1h,
+		"score_4h":          c.Score4h,
+		"slope":             c.Slope,
+		"upl_ratio":         c.UplRatio,
+		"unrealized_r":      c.UnrealizedR,
+		"price_to_avg":      c.PriceToAvg,
+		"daily_r":           c.DailyR,
+	}
+	for name, r := range ranges {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (s *RuleScope) validate(scope string) error {
+	if s == nil {
+		return nil
+	}
+	names := make(map[string]bool)
+	for i := range s.Rules {
+		r := &s.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("第 %d 条规则缺少 name", i+1)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("规则名重复: %s", r.Name)
+		}
+		names[r.Name] = true
+
+		action, err := parseAction(r.Action)
+		if err != nil {
+			return fmt.Errorf("规则 %s: %w", r.Name, err)
+		}
+		r.action = action
+		if r.ReducePercent < 0 || r.ReducePercent > 1 {
+			return fmt.Errorf("规则 %s: reduce_percent 必须在 0~1 之间", r.Name)
+		}
+		if r.ReducePercent > 0 && action != ActReduce {
+			return fmt.Errorf("规则 %s: 只有 reduce 可以设置 reduce_percent", r.Name)
+		}
+		if r.Reason == "" {
+			r.Reason = r.Name
+		}
+		if err := r.When.validate(scope); err != nil {
+			return fmt.Errorf("规则 %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *RuleScope) decide(in RuleInput) (Decision, bool) {
+	if s == nil {
+		return Decision{}, false
+	}
+	for _, r := range s.Rules {
+		if r.When.match(in) {
+			return Decision{Action: r.action, Reason: "rule:" + r.Reason, ReducePercent: r.ReducePercent}, true
+		}
+	}
+	if s.fallback() {
+		return Decision{}, false
+	}
+	return Decision{Action: ActIgnore, Reason: "rule:no-match"}, true
+}
+
+// ParseRules 解析并校验规则
+func ParseRules(data []byte) (*RuleSet, error) {
+	var rs RuleSet
+	if err := yaml.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("解析决策规则失败: %w", err)
+	}
+	if err := rs.Manager.validate("manager"); err != nil {
+		return nil, fmt.Errorf("manager %w", err)
+	}
+	if err := rs.Engine.validate("engine"); err != nil {
+		return nil, fmt.Errorf("engine %w", err)
+	}
+	return &rs, nil
+}
+
+// RuleEngine 持有当前生效的规则，文件修改后可以热加载，校验失败时继续使用旧规则
+type RuleEngine struct {
+	path    string
+	rules   atomic.Pointer[RuleSet]
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// NewRuleEngine 从文件加载规则，文件不合法时返回错误
+func NewRuleEngine(path string) (*RuleEngine, error) {
+	e := &RuleEngine{path: path}
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload 重新读取规则文件
+func (e *RuleEngine) Reload() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return err
+	}
+	rs, err := ParseRules(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.path, err)
+	}
+	sum := sha256.Sum256(data)
+	rs.version = "rules-" + hex.EncodeToString(sum[:])[:12]
+	e.rules.Store(rs)
+	e.modTime = info.ModTime()
+	return nil
+}
+
+// Watch 定时检查文件修改时间，有变化时重新加载
+func (e *RuleEngine) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(e.path)
+		if err != nil {
+			log.Printf("[RuleEngine] 读取规则文件失败: %v", err)
+			continue
+		}
+		e.mu.Lock()
+		changed := !info.ModTime().Equal(e.modTime)
+		e.mu.Unlock()
+		if !changed {
+			continue
+		}
+		if err := e.Reload(); err != nil {
+			log.Printf("[RuleEngine] 规则文件不合法，继续使用旧规则: %v", err)
+			continue
+		}
+		log.Printf("[RuleEngine] 已重新加载决策规则: %s", e.path)
+	}
+}
+
+// Version 当前生效规则的版本，没有规则时为空
+func (e *RuleEngine) Version() string {
+	if e == nil {
+		return ""
+	}
+	return e.rules.Load().version
+}
+
+func (e *RuleEngine) managerDecide(in RuleInput) (Decision, bool) {
+	if e == nil {
+		return Decision{}, false
+	}
+	return e.rules.Load().Manager.decide(in)
+}
+
+func (e *RuleEngine) engineDecide(in RuleInput) (Decision, bool) {
+	if e == nil {
+		return Decision{}, false
+	}
+	return e.rules.Load().Engine.decide(in)
+}
+
+// 规则文件检查间隔
+const rulesReloadInterval = 30 * time.Second
+
+// loadRules 根据配置加载规则并开启热加载，ctx 结束时停止检查规则文件。
+// 没有配置规则文件时返回 nil 使用内置逻辑
+func loadRules(ctx context.Context, path string) (*RuleEngine, error) {
+	if path == "" {
+		return nil, nil
+	}
+	rules, err := NewRuleEngine(path)
+	if err != nil {
+		return nil, fmt.Errorf("加载决策规则失败: %w", err)
+	}
+	go rules.Watch(ctx, rulesReloadInterval)
+	return rules, nil
+}
+
+// ruleInputFromContext DecisionEngine 的输入
+func ruleInputFromContext(ctx Context) RuleInput {
+	in := RuleInput{
+		Side:             ctx.Sig.Side,
+		Strength:         ctx.Sig.Strength,
+		IsReversal:       ctx.Sig.IsReversal,
+		ReversalStrength: ctx.Sig.ReversalStrength,
+		Price:            ctx.Sig.Price,
+		TrendDir:         ctx.Trend.Direction,
+		Scores:           ctx.Trend.Scores,
+		Slope:            ctx.Trend.Slope,
+		DailyR:           ctx.DailyR,
+	}
+	if ctx.Pos != nil {
+		in.HasPosition = true
+		in.PosSide = ctx.Pos.Dir
+		in.UplRatio, _ = strconv.ParseFloat(ctx.Pos.UplRatio, 64)
+		in.AvgPrice = ctx.Pos.AvgPrice
+	}
+	return in
+}
@@ -0,0 +1,224 @@
+package signal
+
+import (
+	"context"
+	"edgeflow/conf"
+	"edgeflow/internal/model"
+	"edgeflow/internal/trend"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rulesTemplate = "../../conf/rules.yaml.template"
+
+func pick[T any](r *rand.Rand, values ...T) T {
+	return values[r.Intn(len(values))]
+}
+
+// 模板中的 manager 规则应与内置的 Manager.Decide 完全一致
+func TestRulesTemplateMatchesManager(t *testing.T) {
+	rules, err := NewRuleEngine(rulesTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := conf.StrategyConfig{
+		MinSpacingL2:        5 * time.Minute,
+		MinSpacingL3:        3 * time.Minute,
+		L3ReduceAtRMultiple: 2,
+		L3ReducePercent:     0.3,
+	}
+	r := rand.New(rand.NewSource(1))
+	now := time.Now()
+
+	for i := 0; i < 5000; i++ {
+		builtin := &defaultSignalManager{state: make(map[string]*State), cfg: cfg}
+		withRules := &defaultSignalManager{state: make(map[string]*State), cfg: cfg, rules: rules}
+		for level := 2; level <= 3; level++ {
+			if r.Intn(2) == 0 {
+				continue
+			}
+			last := Signal{Symbol: "BTC/USDT", Level: level, Side: pick(r, "buy", "sell"), Timestamp: now.Add(-pick(r, time.Minute, 10*time.Minute))}
+			builtin.Save(last)
+			withRules.Save(last)
+		}
+
+		sig := Signal{Symbol: "BTC/USDT", Level: pick(r, 1, 2, 3), Side: pick(r, "buy", "sell"), Timestamp: now}
+		ctx := DecisionContext{
+			HasL2Position: r.Intn(2) == 0,
+			TrendDir:      pick(r, trend.TrendNeutral, trend.TrendUp, trend.TrendDown, trend.TrendReversal),
+			StrongM15:     r.Intn(2) == 0,
+			UnrealizedR:   pick(r, -3.0, 0.0, 1.9, 2.0, 3.0),
+		}
+
+		want := builtin.Decide(sig, ctx)
+		got := withRules.Decide(sig, ctx)
+		if got.Action != want.Action || got.ReducePercent != want.ReducePercent || got.Reason != "rule:"+want.Reason {
+			t.Fatalf("规则与内置逻辑不一致: sig=%+v ctx=%+v want=%+v got=%+v", sig, ctx, want, got)
+		}
+	}
+}
+
+// 模板中的 engine 规则在震荡和反转行情下应与内置的 DecisionEngine 一致
+func TestRulesTemplateMatchesEngine(t *testing.T) {
+	rules, err := NewRuleEngine(rulesTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(2))
+
+	for i := 0; i < 20000; i++ {
+		ctx := Context{
+			Trend: trend.TrendState{
+				Direction: pick(r, trend.TrendNeutral, trend.TrendReversal),
+				Slope:     pick(r, -1.0, 0.0, 1.0),
+				Scores: trend.TrendScores{
+					FinalScore: pick(r, -1.0, 0.0, 1.0),
+					Score30m:   pick(r, -2.5, -2.0, 0.0, 2.0, 2.5),
+					Score1h:    pick(r, -1.5, -1.0, -0.5, 0.0, 0.5, 1.0, 1.5),
+					Score4h:    pick(r, -1.0, 0.0, 1.0),
+				},
+			},
+			Sig: trend.Signal{
+				Side:             pick(r, "buy", "sell"),
+				Price:            pick(r, 98.0, 99.5, 100.0, 101.0),
+				Strength:         pick(r, 0.2, 0.3, 0.5, 0.7, 0.8, 0.9),
+				IsReversal:       r.Intn(2) == 0,
+				ReversalStrength: pick(r, 0.3, 0.6),
+			},
+		}
+		if r.Intn(3) > 0 {
+			ctx.Pos = &model.PositionInfo{
+				Dir:      pick[model.OrderPosSide](r, model.OrderPosSideLong, model.OrderPosSideShort),
+				AvgPrice: 100,
+				UplRatio: strconv.FormatFloat(pick(r, -0.06, -0.05, -0.03, -0.025, -0.02, 0.0, 0.03, 0.04, 0.05, 0.06), 'f', -1, 64),
+			}
+		}
+
+		want := NewDecisionEngine(ctx).Run()
+		got := NewDecisionEngine(ctx).WithRules(rules).Run()
+		if got != want {
+			t.Fatalf("规则与内置逻辑不一致: trend=%+v sig=%+v pos=%+v want=%s got=%s", ctx.Trend, ctx.Sig, ctx.Pos, want, got)
+		}
+	}
+}
+
+func TestParseRulesValidate(t *testing.T) {
+	cases := map[string]string{
+		"未知动作":       "engine: {rules: [{name: a, action: buy}]}",
+		"缺少名称":       "engine: {rules: [{action: open}]}",
+		"规则名重复":      "engine: {rules: [{name: a, action: open}, {name: a, action: close}]}",
+		"未知趋势":       "engine: {rules: [{name: a, action: open, when: {trend: [sideways]}}]}",
+		"区间矛盾":       "engine: {rules: [{name: a, action: open, when: {strength: {gte: 0.8, lt: 0.5}}}]}",
+		"空区间":        "engine: {rules: [{name: a, action: open, when: {strength: {}}}]}",
+		"非减仓设置减仓比例":  "manager: {rules: [{name: a, action: open, reduce_percent: 0.5}]}",
+		"无持仓的仓位条件":   "manager: {rules: [{name: a, action: open, when: {position: none, price_to_avg: {gt: 0}}}]}",
+		"manager收益率": "manager: {rules: [{name: a, action: open, when: {upl_ratio: {gt: 0}}}]}",
+		"engine的R倍数": "engine: {rules: [{name: a, action: open, when: {unrealized_r: {gt: 0}}}]}",
+	}
+	for name, data := range cases {
+		if _, err := ParseRules([]byte(data)); err == nil {
+			t.Errorf("%s: 应校验失败", name)
+		}
+	}
+
+	rs, err := ParseRules([]byte("engine: {fallback: false, rules: [{name: strong, action: open, when: {strength: {gte: 0.8}}}]}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := rs.Engine.decide(RuleInput{Strength: 0.9}); !ok || d.Action != ActOpen || d.Reason != "rule:strong" {
+		t.Fatalf("应命中规则: %+v", d)
+	}
+	// 关闭 fallback 后未命中的输入直接忽略
+	if d, ok := rs.Engine.decide(RuleInput{Strength: 0.5}); !ok || d.Action != ActIgnore {
+		t.Fatalf("未命中应忽略: %+v", d)
+	}
+	// 没有配置的规则组交给内置逻辑
+	if _, ok := rs.Manager.decide(RuleInput{}); ok {
+		t.Fatalf("未配置 manager 规则时应使用内置逻辑")
+	}
+}
+
+// manager 规则的 strength 取告警 meta.strength，unrealized_r 取 DecisionContext.UnrealizedR
+func TestManagerRuleInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	data := `manager: {fallback: false, rules: [
+  {name: strong, action: open, when: {strength: {gte: 0.8}}},
+  {name: profit, action: reduce, when: {unrealized_r: {gte: 2}}}]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewDefaultSignalManager(context.Background(), conf.StrategyConfig{RulesFile: path, L3ReducePercent: 0.4}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := Signal{Symbol: "BTC/USDT", Side: "buy", Level: 2, Meta: map[string]any{"strength": 0.9}}
+	if d := m.Decide(sig, DecisionContext{}); d.Action != ActOpen {
+		t.Fatalf("strength 应命中: %+v", d)
+	}
+	sig.Meta = map[string]any{"strength": "0.5"}
+	if d := m.Decide(sig, DecisionContext{HasL2Position: true, UnrealizedR: 2}); d.Action != ActReduce || d.ReducePercent != 0.4 {
+		t.Fatalf("unrealized_r 应命中并使用配置的减仓比例: %+v", d)
+	}
+}
+
+// 规则文件不合法时返回错误，不退出进程
+func TestNewSignalManagerRulesError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte("engine: {rules: [{name: a, action: unknown}]}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := NewDefaultSignalManager(ctx, conf.StrategyConfig{RulesFile: path}, nil); err == nil {
+		t.Fatal("应返回规则加载错误")
+	}
+	if _, err := NewPersistentSignalManager(ctx, conf.StrategyConfig{RulesFile: filepath.Join(t.TempDir(), "missing.yaml")}, nil, nil); err == nil {
+		t.Fatal("规则文件不存在时应返回错误")
+	}
+	if _, err := NewDefaultSignalManager(ctx, conf.StrategyConfig{RulesFile: rulesTemplate}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRuleEngineReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	write := func(data string, mod time.Time) {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	write("engine: {fallback: false, rules: [{name: a, action: open}]}", now)
+	e, err := NewRuleEngine(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := e.engineDecide(RuleInput{}); d.Action != ActOpen {
+		t.Fatalf("初始规则未生效: %+v", d)
+	}
+
+	// 不合法的文件不会替换当前规则
+	write("engine: {rules: [{name: a, action: unknown}]}", now.Add(time.Second))
+	if err := e.Reload(); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("应返回校验错误: %v", err)
+	}
+	if d, _ := e.engineDecide(RuleInput{}); d.Action != ActOpen {
+		t.Fatalf("校验失败后应保留旧规则: %+v", d)
+	}
+
+	write("engine: {fallback: false, rules: [{name: a, action: close}]}", now.Add(2*time.Second))
+	if err := e.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := e.engineDecide(RuleInput{}); d.Action != ActClose {
+		t.Fatalf("重新加载后规则未生效: %+v", d)
+	}
+}
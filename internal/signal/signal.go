@@ -2,7 +2,9 @@ package signal
 
 import (
 	"edgeflow/conf"
+	"fmt"
 	"log"
+	"strconv"
 	"time"
 )
 
@@ -18,7 +20,8 @@ import (
 	  "order_type": "market",
 	  "quantity": 0.01,
 	  "strategy": "tv-breakout-v2",
-	  "comment": "突破+回踩买入"
+	  "comment": "突破+回踩买入",
+	  "tp_ladder": [{"r": 1, "pct": 0.4}, {"r": 2, "pct": 0.3}]
 	}
 */
 type Signal struct {
@@ -35,6 +38,13 @@ type Signal struct {
 	Timestamp time.Time      `json:"timestamp"` // 触发时间
 	TpPct     float64        `json:"tp"`        // 止盈比例，默认为0时使用系统的
 	SlPct     float64        `json:"sl"`        // 止损比例，默认为0时使用系统的
+	TPLadder  []TPTier       `json:"tp_ladder"` // 分批止盈，为空时使用单一止盈价
+}
+
+// TPTier 分批止盈的一档：浮盈达到 R 倍止损距离时平掉开仓数量的 Pct，剩余部分由移动止损管理
+type TPTier struct {
+	R   float64 `json:"r"`
+	Pct float64 `json:"pct"` // 0~1
 }
 
 // 当前信号是否过期
@@ -43,6 +53,30 @@ func (sig Signal) IsExpired() bool {
 	return isExpired
 }
 
+// IdempotencyKey 同一个信号重复推送时 key 相同：优先使用 signal_id，否则使用交易对、方向、级别和触发时间，
+// 都没有时返回空，不做幂等
+func (sig Signal) IdempotencyKey() string {
+	if id := sig.ID(); id > 0 {
+		return fmt.Sprintf("id:%d", id)
+	}
+	if sig.Timestamp.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s:%d:%d", sig.Symbol, sig.Side, sig.Level, sig.Timestamp.UnixMilli())
+}
+
+// Strength 信号强度，TradingView 告警在 meta.strength 中传入，没有时为 0
+func (sig Signal) Strength() float64 {
+	switch v := sig.Meta["strength"].(type) {
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
+
 // 信号有效期
 var signalExpiry = map[int]time.Duration{
 	1: 8 * time.Hour,    // 1级信号有效期6小时
@@ -61,6 +95,25 @@ const (
 	ActClose            // 仅L2反向时允许：平掉所有主仓
 )
 
+func (a Action) String() string {
+	switch a {
+	case ActIgnore:
+		return "ignore"
+	case ActOpen:
+		return "open"
+	case ActAdd:
+		return "add"
+	case ActReduce:
+		return "reduce"
+	case ActTightenSL:
+		return "tighten_sl"
+	case ActClose:
+		return "close"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(a))
+	}
+}
+
 type Decision struct {
 	Action        Action
 	Reason        string
@@ -83,9 +136,39 @@ func (d Decision) Log(sig Signal, cfg *conf.StrategyConfig) {
 
 // 信号状态
 type State struct {
-	LastByLevel map[int]Signal
+	LastByLevel map[int]Signal `json:"last_by_level"`
 	// L2 当前方向与最近一次 L2 信号时间（用于冷静期）
-	L2Side       string
-	L2LastFlipAt time.Time
+	L2Side       string    `json:"l2_side"`
+	L2LastFlipAt time.Time `json:"l2_last_flip_at"`
+	// 等待升级为 L2 的同向 L3 信号
+	Level3Buffer []Signal `json:"level3_buffer"`
 	// L2 是否持仓由 PositionService 查询，这里只做信号侧状态
 }
+
+// ttl 状态中最晚失效的信号还剩多久有效，全部过期时返回 0
+func (s *State) ttl(now time.Time) time.Duration {
+	var ttl time.Duration
+	extend := func(sig Signal, level int) {
+		if d := sig.Timestamp.Add(signalExpiry[level]).Sub(now); d > ttl {
+			ttl = d
+		}
+	}
+	for level, sig := range s.LastByLevel {
+		extend(sig, level)
+	}
+	for _, sig := range s.Level3Buffer {
+		extend(sig, 3)
+	}
+	return ttl
+}
+
+// clone 深拷贝，避免持久化时与正在处理的信号并发读写
+func (s *State) clone() *State {
+	cp := *s
+	cp.LastByLevel = make(map[int]Signal, len(s.LastByLevel))
+	for level, sig := range s.LastByLevel {
+		cp.LastByLevel[level] = sig
+	}
+	cp.Level3Buffer = append([]Signal(nil), s.Level3Buffer...)
+	return &cp
+}
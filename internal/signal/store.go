@@ -0,0 +1,161 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StateStore 按币种保存信号状态，服务重启后恢复
+type StateStore interface {
+	// Load 读取币种的状态，不存在或已过期时返回 nil
+	Load(ctx context.Context, symbol string) (*State, error)
+	Save(ctx context.Context, symbol string, st *State) error
+}
+
+type redisStateStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisStateStore(rdb *redis.Client) StateStore {
+	return &redisStateStore{rdb: rdb}
+}
+
+// getKey 生成 Redis Key: signal:state:BTC/USDT
+func (r *redisStateStore) getKey(symbol string) string {
+	return fmt.Sprintf("signal:state:%s", symbol)
+}
+
+func (r *redisStateStore) Load(ctx context.Context, symbol string) (*State, error) {
+	data, err := r.rdb.Get(ctx, r.getKey(symbol)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("unmarshal signal state %s: %w", symbol, err)
+	}
+	if st.LastByLevel == nil {
+		st.LastByLevel = make(map[int]Signal)
+	}
+	return &st, nil
+}
+
+// Save 写入状态，TTL 与状态中最晚失效的信号对齐，信号全部过期后 key 自动删除
+func (r *redisStateStore) Save(ctx context.Context, symbol string, st *State) error {
+	ttl := st.ttl(time.Now())
+	if ttl <= 0 {
+		return r.rdb.Del(ctx, r.getKey(symbol)).Err()
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Set(ctx, r.getKey(symbol), data, ttl).Err()
+}
+
+// TradeLimiterStore 按交易对保存 TradeLimiter 的统计，服务重启后频次限制继续生效
+type TradeLimiterStore interface {
+	// Load 读取交易对的统计，不存在时返回 nil
+	Load(ctx context.Context, symbol string) (*SymbolStats, error)
+	Save(ctx context.Context, stats *SymbolStats) error
+	// Symbols 有统计记录的交易对
+	Symbols(ctx context.Context) ([]string, error)
+}
+
+// 统计长时间没有变化后自动删除，连续计数和冷却时间都不会超过这个时长
+const limiterStatsTTL = 7 * 24 * time.Hour
+
+const limiterKeyPrefix = "signal:limiter:"
+
+type redisTradeLimiterStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisTradeLimiterStore(rdb *redis.Client) TradeLimiterStore {
+	return &redisTradeLimiterStore{rdb: rdb}
+}
+
+// getKey 生成 Redis Key: signal:limiter:BTC/USDT
+func (r *redisTradeLimiterStore) getKey(symbol string) string {
+	return limiterKeyPrefix + symbol
+}
+
+func (r *redisTradeLimiterStore) Load(ctx context.Context, symbol string) (*SymbolStats, error) {
+	data, err := r.rdb.Get(ctx, r.getKey(symbol)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stats SymbolStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("unmarshal limiter stats %s: %w", symbol, err)
+	}
+	return &stats, nil
+}
+
+func (r *redisTradeLimiterStore) Save(ctx context.Context, stats *SymbolStats) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Set(ctx, r.getKey(stats.Symbol), data, limiterStatsTTL).Err()
+}
+
+func (r *redisTradeLimiterStore) Symbols(ctx context.Context) ([]string, error) {
+	var symbols []string
+	iter := r.rdb.Scan(ctx, 0, limiterKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		symbols = append(symbols, strings.TrimPrefix(iter.Val(), limiterKeyPrefix))
+	}
+	return symbols, iter.Err()
+}
+
+const killSwitchKey = "signal:killswitch"
+
+// redisKillSwitchStore 暂停记录保存在一个 hash 中，field 为 scope:target
+type redisKillSwitchStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisKillSwitchStore(rdb *redis.Client) KillSwitchStore {
+	return &redisKillSwitchStore{rdb: rdb}
+}
+
+func (r *redisKillSwitchStore) Load(ctx context.Context) ([]Pause, error) {
+	fields, err := r.rdb.HGetAll(ctx, killSwitchKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	pauses := make([]Pause, 0, len(fields))
+	for field, data := range fields {
+		var p Pause
+		if err := json.Unmarshal([]byte(data), &p); err != nil {
+			return nil, fmt.Errorf("unmarshal pause %s: %w", field, err)
+		}
+		pauses = append(pauses, p)
+	}
+	return pauses, nil
+}
+
+func (r *redisKillSwitchStore) Set(ctx context.Context, p Pause) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return r.rdb.HSet(ctx, killSwitchKey, p.key(), data).Err()
+}
+
+func (r *redisKillSwitchStore) Delete(ctx context.Context, p Pause) error {
+	return r.rdb.HDel(ctx, killSwitchKey, p.key()).Err()
+}
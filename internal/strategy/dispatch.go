@@ -14,6 +14,7 @@ import (
 type StrategyDispatcher struct {
 	// // 策略注册表， 支持多策略注册 // key: strategy name
 	strategies map[string]StrategyExecutor
+	killSwitch *signal.KillSwitch // 为空时不检查暂停
 	mu         sync.RWMutex
 }
 
@@ -21,6 +22,12 @@ func NewStrategyDispatcher() *StrategyDispatcher {
 	return &StrategyDispatcher{strategies: make(map[string]StrategyExecutor)}
 }
 
+// WithKillSwitch 分发前检查策略和交易对是否被暂停
+func (d *StrategyDispatcher) WithKillSwitch(ks *signal.KillSwitch) *StrategyDispatcher {
+	d.killSwitch = ks
+	return d
+}
+
 func (d *StrategyDispatcher) Register(name string, s StrategyExecutor) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -38,6 +45,13 @@ func (d *StrategyDispatcher) Dispatch(sig signal.Signal, callback func(err error
 		}
 		return
 	}
+	if err := d.killSwitch.Check(sig.Strategy, sig.Symbol); err != nil {
+		log.Printf("StrategyDispatcher %s %s 已暂停: %v", sig.Strategy, sig.Symbol, err)
+		if callback != nil {
+			callback(err)
+		}
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 
@@ -4,6 +4,7 @@ import (
 	"edgeflow/conf"
 	"edgeflow/internal/dao"
 	"edgeflow/internal/position"
+	"edgeflow/internal/service/signal/kline"
 	"edgeflow/internal/trend"
 	"edgeflow/pkg/db"
 	"edgeflow/pkg/exchange"
@@ -70,7 +71,7 @@ func TestTradeOrders(t *testing.T) {
 	okxEx := exchange.NewOkxExchange(appCfg.ApiKey, appCfg.SecretKey, appCfg.Password)
 
 	symbols := []string{"BTC/USDT", "ETH/USDT", "SOL/USDT", "DOGE/USDT", "HYPE/USDT", "LTC/USDT"}
-	klineManger := trend.NewKlineManager(okxEx, symbols)
+	klineManger := kline.NewKlineManager(okxEx, nil, nil, symbols)
 	tm := trend.NewManager(okxEx, symbols, klineManger)
 
 	// 仓位管理服务
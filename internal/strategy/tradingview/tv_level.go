@@ -15,14 +15,16 @@ import (
 // 趋势/波段策略：基于 1H 周期，减少频繁进出，拉大止盈止损，追求稳定的中等收益率
 type TVLevelStrategy struct {
 	signalManager signal.Manager
+	limiter       *signal.TradeLimiter // 为空时不限制交易频次
 	positionSvc   *position.PositionService
 	trend         *trend.Manager
 }
 
-func NewTVLevelStrategy(sm signal.Manager,
+func NewTVLevelStrategy(sm signal.Manager, limiter *signal.TradeLimiter,
 	ps *position.PositionService, trend *trend.Manager) *TVLevelStrategy {
 	return &TVLevelStrategy{
 		signalManager: sm,
+		limiter:       limiter,
 		positionSvc:   ps,
 		trend:         trend}
 
@@ -42,7 +44,7 @@ func (t *TVLevelStrategy) Execute(ctx context.Context, sig signal.Signal) error
 	if metaL2 != nil {
 		lastSig := t.signalManager.GetLastSignal(sig.Symbol, 2)
 		if lastSig == nil {
-			// 当服务端有l2仓位，本地没有信号缓存时，应该是服务重启了，数据丢失了，此时我们补全即可
+			// 当服务端有l2仓位，本地没有信号缓存时，应该是信号状态已过期或者没有启用持久化，此时我们补全即可
 			t.signalManager.Save(signal.Signal{
 				Strategy:  sig.Strategy,
 				Symbol:    sig.Symbol,
@@ -78,6 +80,9 @@ func (t *TVLevelStrategy) Execute(ctx context.Context, sig signal.Signal) error
 		UnrealizedR:   upnl, // 从交易所仓位算
 		TrendDir:      st.Direction,
 		StrongM15:     false,
+		Trend:         st,
+		DailyR:        t.positionSvc.DailyR(),
+		Limiter:       t.limiter, // 频次限制：超过限制的开仓/加仓不执行
 	}
 
 	desc := t.signalManager.Decide(sig, dCtx)
@@ -85,6 +90,8 @@ func (t *TVLevelStrategy) Execute(ctx context.Context, sig signal.Signal) error
 	err = t.positionSvc.ApplyAction(ctx, desc.Action, sig, state)
 	if err != nil {
 		log.Printf("Execute error: %v", err)
+	} else {
+		t.limiter.Record(sig.Symbol, desc.Action, sig.Price, sig.Side)
 	}
 	if desc.Action == signal.ActIgnore {
 		return errors.New(desc.Reason)
@@ -2,7 +2,7 @@ package trend
 
 import (
 	model2 "edgeflow/internal/model"
-	"edgeflow/internal/service/signal/kline"
+	"edgeflow/internal/model/entity"
 	"edgeflow/pkg/exchange"
 	"edgeflow/pkg/utils"
 	"errors"
@@ -17,6 +17,18 @@ import (
 	"github.com/nntaoli-project/goex/v2/model"
 )
 
+// KlineSource 趋势计算所需的K线来源，返回的K线顺序为 从旧到新
+// 线上使用 kline.KlineManager，回测时使用按模拟时钟回放的数据
+type KlineSource interface {
+	Get(symbol string, period model.KlinePeriod) ([]model2.Kline, bool)
+}
+
+// DerivativesSource 永续合约的资金费率、持仓量和主动买卖比，作为趋势计算的可选输入
+// 线上使用 service.DerivativesCollector
+type DerivativesSource interface {
+	Latest(symbol string) (entity.DerivativeMetric, bool)
+}
+
 // TrendManager 负责管理多个币种的趋势状态
 type Manager struct {
 	mu       sync.RWMutex
@@ -25,10 +37,11 @@ type Manager struct {
 	ex           exchange.Exchange // OKX 客户端
 	symbols      []string
 	cfg          TrendCfg
-	klineManager *kline.KlineManager
+	klineManager KlineSource
+	derivatives  DerivativesSource // 可选，为空时 IndicatorSnapshot 不包含永续合约数据
 }
 
-func NewManager(ex exchange.Exchange, symbols []string, klineManager *kline.KlineManager) *Manager {
+func NewManager(ex exchange.Exchange, symbols []string, klineManager KlineSource) *Manager {
 	newSymbols := make([]string, len(symbols))
 	for i, symbol := range symbols {
 		newSymbols[i] = utils.FormatSymbol(symbol)
@@ -42,6 +55,12 @@ func NewManager(ex exchange.Exchange, symbols []string, klineManager *kline.Klin
 	}
 }
 
+// WithDerivatives 趋势状态的 IndicatorSnapshot 附带资金费率、持仓量和主动买卖比
+func (tm *Manager) WithDerivatives(src DerivativesSource) *Manager {
+	tm.derivatives = src
+	return tm
+}
+
 // 启动调度：独立于 15min 信号
 func (tm *Manager) RunScheduled() {
 
@@ -170,6 +189,11 @@ func (tm *Manager) GenerateTrend(symbol string) (state *TrendState, finalSlope *
 		Timestamp: last.Timestamp,
 		Scores:    scores,
 	}
+	if tm.derivatives != nil {
+		if d, ok := tm.derivatives.Latest(symbol); ok {
+			state.IndicatorSnapshot = d.Indicators()
+		}
+	}
 
 	// 计算加权平均分数，可以给越新的趋势权重越高
 	tm.save(state)
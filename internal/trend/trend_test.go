@@ -1,56 +1,74 @@
 package trend
 
 import (
-	"edgeflow/conf"
+	model2 "edgeflow/internal/model"
+	"edgeflow/internal/model/entity"
 	"edgeflow/pkg/exchange"
+	"edgeflow/pkg/exchange/okxtest"
 	"log"
 	"testing"
-	"time"
+
+	"github.com/nntaoli-project/goex/v2/model"
 )
 
-func loadOkxConf() (*conf.Okx, error) {
-	// 加载配置文件
-	err := conf.LoadConfig("../../conf/config.yaml")
-	if err != nil {
-		return nil, err
-	}
+// restKlines 每次直接通过 REST 拉取K线，kline 包间接依赖 trend，测试中不能引用
+type restKlines struct {
+	ex exchange.Exchange
+}
 
-	return &conf.AppConfig.Okx, nil
+func (r restKlines) Get(symbol string, period model.KlinePeriod) ([]model2.Kline, bool) {
+	lines, err := r.ex.GetKlineRecords(symbol, period, 300, 0, 0, model2.OrderTradeSwap, false)
+	return lines, err == nil && len(lines) > 0
 }
 
 func TestTrend(t *testing.T) {
-
-	// 加载配置文件
-	okxConf, err := loadOkxConf()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
-
-	okx := exchange.NewOkxExchange(okxConf.ApiKey, okxConf.SecretKey, okxConf.Password)
+	// 本地 OKX 替身，K 线按最新价生成，不需要真实的 API Key
+	srv := okxtest.NewServer()
+	defer srv.Close()
+	okx := exchange.NewOkxExchange("test-key", "test-secret", "test-passphrase").WithBaseURL(srv.URL)
 
 	symbols := []string{"BTC/USDT", "ETH/USDT", "SOL/USDT"}
-	tm := NewManager(okx, symbols)
-	tm.StartUpdater()
+	tm := NewManager(okx, symbols, restKlines{ex: okx})
+	tm.RunScheduled()
 
 	// 查询某币种趋势
-	for {
-		state, ok := tm.Get("BTC/USDT")
-		if ok {
-			log.Println(state.Description)
-		}
-
-		state1, ok := tm.Get("ETH/USDT")
-		if ok {
-			log.Println(state1.Description)
+	for _, symbol := range symbols {
+		state := tm.GetState(symbol)
+		if state == nil {
+			t.Fatalf("%s 没有趋势状态", symbol)
 		}
-		state2, ok := tm.Get("SOL/USDT")
-		if ok {
-			log.Println(state2.Description)
+		if state.LastPrice <= 0 || state.Timestamp.IsZero() {
+			t.Errorf("%s 趋势状态不完整: %+v", symbol, state)
 		}
-
-		time.Sleep(time.Minute * 1)
+		log.Println(state.Description())
 	}
+}
+
+// fixedDerivatives 固定的永续合约数据
+type fixedDerivatives map[string]entity.DerivativeMetric
+
+func (f fixedDerivatives) Latest(symbol string) (entity.DerivativeMetric, bool) {
+	m, ok := f[symbol]
+	return m, ok
+}
 
+func TestTrend_Derivatives(t *testing.T) {
+	srv := okxtest.NewServer()
+	defer srv.Close()
+	okx := exchange.NewOkxExchange("test-key", "test-secret", "test-passphrase").WithBaseURL(srv.URL)
+
+	tm := NewManager(okx, []string{"BTC/USDT", "ETH/USDT"}, restKlines{ex: okx}).
+		WithDerivatives(fixedDerivatives{"BTC/USDT": {FundingRate: 0.0001, LongShortRatio: 1.18}})
+	tm.RunScheduled()
+
+	snapshot := tm.GetState("BTC/USDT").IndicatorSnapshot
+	if snapshot["funding_rate"] != 0.0001 || snapshot["long_short_ratio"] != 1.18 {
+		t.Errorf("BTC IndicatorSnapshot = %v", snapshot)
+	}
+	// 没有数据的交易对不附带
+	if snapshot := tm.GetState("ETH/USDT").IndicatorSnapshot; snapshot != nil {
+		t.Errorf("ETH IndicatorSnapshot = %v", snapshot)
+	}
 }
 
 func TestIndicator(t *testing.T) {
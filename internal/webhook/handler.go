@@ -23,6 +23,7 @@ type WebhookHandler struct {
 	rc         *service.RiskService
 	sm         signal.Manager
 	ps         *position.PositionService
+	killSwitch *signal.KillSwitch // 为空时不检查暂停
 }
 
 func NewWebhookHandler(
@@ -38,6 +39,12 @@ func NewWebhookHandler(
 	}
 }
 
+// WithKillSwitch 收到信号后先检查是否被暂停
+func (wh *WebhookHandler) WithKillSwitch(ks *signal.KillSwitch) *WebhookHandler {
+	wh.killSwitch = ks
+	return wh
+}
+
 // TradingView Webhook 的接收器
 
 // HandleWebhook 接收POST 请求并解析为策略信号
@@ -94,6 +101,12 @@ func (wh *WebhookHandler) Handle(r *http.Request, callback func(err error, statu
 		callback(fmt.Errorf("invalid JSON error level"), http.StatusBadRequest)
 	}
 
+	// 紧急开关，暂停期间不处理信号
+	if err := wh.killSwitch.Check(sig.Strategy, sig.Symbol); err != nil {
+		callback(err, http.StatusServiceUnavailable)
+		return
+	}
+
 	// 风控检查，是否允许下单
 	err = wh.rc.Allow(context.Background(), sig.Strategy, sig.Symbol, sig.Side, sig.TradeType)
 	if err != nil {
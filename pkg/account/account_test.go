@@ -2,43 +2,40 @@ package account
 
 import (
 	"context"
-	"edgeflow/conf"
-	"github.com/nntaoli-project/goex/v2"
-	"github.com/nntaoli-project/goex/v2/options"
-	"log"
+	"edgeflow/pkg/exchange/okxtest"
 	"testing"
+
+	"github.com/nntaoli-project/goex/v2/okx/spot"
+	"github.com/nntaoli-project/goex/v2/options"
 )
 
 func TestAccountService_GetBalance(t *testing.T) {
-	goex.DefaultHttpCli.SetHeaders("x-simulated-trading", "1") // 设置为模拟环境
-	// 加载配置文件
-	okxConf, err := loadOkxConf()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
+	srv := okxtest.NewServer()
+	defer srv.Close()
 
 	opts := []options.ApiOption{
-		options.WithApiKey(okxConf.ApiKey),
-		options.WithApiSecretKey(okxConf.SecretKey),
-		options.WithPassphrase(okxConf.Password),
+		options.WithApiKey("test-key"),
+		options.WithApiSecretKey("test-secret"),
+		options.WithPassphrase("test-passphrase"),
 	}
 
-	prv := goex.OKx.Spot.NewPrvApi(opts...)
-	as := NewAccountService(prv)
+	pub := spot.New()
+	pub.WithUriOption(options.WithEndpoint(srv.URL))
+	as := NewAccountService(pub.NewPrvApi(opts...))
 	avail, err := as.GetAccount(context.Background(), "USDT")
 	if err != nil {
-		log.Fatalf("查询余额失败: %v", err)
+		t.Fatalf("查询余额失败: %v", err)
+	}
+	if avail.Available != 9870.25 || avail.Total != 10004.83 {
+		t.Errorf("USDT 余额: %+v", avail)
 	}
 
-	log.Printf("USDT 可用余额: %.6f", avail.Available)
-}
-
-func loadOkxConf() (*conf.Okx, error) {
-	// 加载配置文件
-	err := conf.LoadConfig("../../conf/config.yaml")
-	if err != nil {
-		return nil, err
+	if _, err := as.GetAccount(context.Background(), "ETH"); err == nil {
+		t.Errorf("没有持有的币种应返回错误")
 	}
 
-	return &conf.AppConfig.Okx, nil
+	srv.Enqueue("GET", "/api/v5/account/balance", okxtest.Error("50113", "Invalid Sign"))
+	if _, err := as.GetAccount(context.Background(), "USDT"); err == nil || err.Error() != "Invalid Sign" {
+		t.Errorf("签名错误应原样返回: %v", err)
+	}
 }
@@ -0,0 +1,148 @@
+package exchange
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	binance2 "edgeflow/pkg/exchange/binance"
+	"errors"
+	"fmt"
+
+	"github.com/nntaoli-project/goex/v2/model"
+)
+
+var (
+	_ Exchange         = (*BinanceExchange)(nil)
+	_ LotRulesProvider = (*BinanceExchange)(nil)
+	_ QuoteProvider    = (*BinanceExchange)(nil)
+)
+
+// BinanceExchange 币安现货和 U 本位永续合约，接口语义与 OkxExchange 一致：
+// symbol 使用 BTC/USDT，K线从新到旧，止盈止损通过 AmendAlgoOrder 修改
+type BinanceExchange struct {
+	spot *binance2.BinanceSpot
+	swap *binance2.BinanceSwap
+}
+
+// opts 可以替换接口地址，测试时指向本地服务
+func NewBinanceExchange(apiKey, apiSecret string, opts ...binance2.Option) *BinanceExchange {
+	return &BinanceExchange{
+		spot: binance2.NewBinanceSpot(apiKey, apiSecret, opts...),
+		swap: binance2.NewBinanceSwap(apiKey, apiSecret, opts...),
+	}
+}
+
+func (e *BinanceExchange) getApi(marketType model2.OrderTradeType) (binance2.BinanceService, error) {
+	switch marketType {
+	case model2.OrderTradeSpot:
+		return e.spot, nil
+	case model2.OrderTradeSwap:
+		return e.swap, nil
+	default:
+		return nil, fmt.Errorf("unsupported market type: %s", marketType)
+	}
+}
+
+func (e *BinanceExchange) Account(tradeType model2.OrderTradeType) (Account, error) {
+	return e.getApi(tradeType)
+}
+
+func (e *BinanceExchange) GetLastPrice(symbol string, tradingType model2.OrderTradeType) (float64, error) {
+	api, err := e.getApi(tradingType)
+	if err != nil {
+		return 0, err
+	}
+	return api.GetLastPrice(symbol)
+}
+
+// GetBestQuote 盘口买一卖一价
+func (e *BinanceExchange) GetBestQuote(symbol string, tradeType model2.OrderTradeType) (bid, ask float64, err error) {
+	api, err := e.getApi(tradeType)
+	if err != nil {
+		return 0, 0, err
+	}
+	return api.GetBestQuote(symbol)
+}
+
+// 下单购买，数量单位为币
+func (e *BinanceExchange) PlaceOrder(ctx context.Context, order *model2.Order) (*model2.OrderResponse, error) {
+	api, err := e.getApi(order.TradeType)
+	if err != nil {
+		return nil, err
+	}
+	return api.PlaceOrder(ctx, order)
+}
+
+func (e *BinanceExchange) CancelOrder(orderID, symbol string, tradingType model2.OrderTradeType) error {
+	api, err := e.getApi(tradingType)
+	if err != nil {
+		return err
+	}
+	return api.CancelOrder(orderID, symbol)
+}
+
+func (e *BinanceExchange) GetOrderStatus(orderID string, symbol string, tradingType model2.OrderTradeType) (*model2.OrderStatus, error) {
+	api, err := e.getApi(tradingType)
+	if err != nil {
+		return nil, err
+	}
+	return api.GetOrderStatus(orderID, symbol)
+}
+
+// GetLotRules 交易对的下单精度
+func (e *BinanceExchange) GetLotRules(symbol string, tradeType model2.OrderTradeType) (*model2.LotRules, error) {
+	api, err := e.getApi(tradeType)
+	if err != nil {
+		return nil, err
+	}
+	return api.GetLotRules(symbol)
+}
+
+// SetLeverage 设置合约杠杆
+func (e *BinanceExchange) SetLeverage(symbol string, leverage int, marginMode, posSide string, tradeType model2.OrderTradeType) error {
+	if tradeType != model2.OrderTradeSwap {
+		return errors.New("当前交易类型不支持设置杠杆倍数SetLeverage")
+	}
+	return e.swap.SetLeverage(symbol, leverage, marginMode, posSide)
+}
+
+// 平仓函数
+func (e *BinanceExchange) ClosePosition(symbol string, side string, quantity float64, tdMode string, tradeType model2.OrderTradeType) (*model2.OrderResponse, error) {
+	if tradeType != model2.OrderTradeSwap {
+		return nil, errors.New("当前交易类型不支持关闭仓位ClosePosition")
+	}
+	return e.swap.ClosePosition(symbol, side, quantity, tdMode)
+}
+
+// 查询是否有持仓
+func (e *BinanceExchange) GetPosition(symbol string, tradeType model2.OrderTradeType) (long *model2.PositionInfo, short *model2.PositionInfo, err error) {
+	if tradeType != model2.OrderTradeSwap {
+		return nil, nil, errors.New("当前交易类型不支持获取仓位GetPosition")
+	}
+	return e.swap.GetPosition(symbol)
+}
+
+// AmendAlgoOrder 撤掉原来的止损（止盈）条件单后按新的触发价重新挂单，algoId 来自 GetPosition
+func (e *BinanceExchange) AmendAlgoOrder(instId string, tradeType model2.OrderTradeType, algoId string, newSlTriggerPx, newTpTriggerPx float64) ([]byte, error) {
+	if tradeType != model2.OrderTradeSwap {
+		return nil, errors.New("当前交易类型不支持修改止盈止损AmendAlgoOrder")
+	}
+	return e.swap.AmendAlgoOrder(instId, algoId, newSlTriggerPx, newTpTriggerPx)
+}
+
+// GetKlineRecords 顺序与 OkxExchange 一致，从新到旧
+func (e *BinanceExchange) GetKlineRecords(symbol string, period model.KlinePeriod, size int, start, end int64, tradeType model2.OrderTradeType, includeUnclosed bool) ([]model2.Kline, error) {
+	api, err := e.getApi(tradeType)
+	if err != nil {
+		return nil, err
+	}
+	klines, err := api.GetKlineRecords(symbol, period, size, start, end, includeUnclosed)
+	if err != nil {
+		return nil, err
+	}
+
+	reLines := make([]model2.Kline, len(klines))
+	for i := 0; i < len(klines); i++ {
+		reLines[i] = klines[len(klines)-1-i] // 最新 -> 最前
+	}
+	return reLines, nil
+}
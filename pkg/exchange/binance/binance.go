@@ -0,0 +1,480 @@
+package binance
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	"edgeflow/pkg/account"
+	"edgeflow/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nntaoli-project/goex/v2/model"
+)
+
+// 与 goex 一致，交易对不存在时不再重试
+var ErrSymbolNotFound = errors.New("not found currency pair")
+
+type BinanceService interface {
+	GetLastPrice(symbol string) (float64, error)
+	GetBestQuote(symbol string) (bid, ask float64, err error)
+	PlaceOrder(ctx context.Context, order *model2.Order) (*model2.OrderResponse, error)
+	CancelOrder(orderID, symbol string) error
+	GetOrderStatus(orderID string, symbol string) (*model2.OrderStatus, error)
+	GetKlineRecords(symbol string, period model.KlinePeriod, size int, start, end int64, includeUnclosed bool) ([]model2.Kline, error)
+	GetLotRules(symbol string) (*model2.LotRules, error)
+	GetAccount(ctx context.Context, coin string) (*account.Account, error)
+}
+
+// 现货和 U 本位合约的接口路径
+type endpoints struct {
+	exchangeInfo string
+	price        string
+	bookTicker   string
+	klines       string
+	order        string
+	trades       string
+}
+
+// Binance 现货和合约的公共部分：交易对精度、行情、K线和订单查询
+type Binance struct {
+	c     *client
+	paths endpoints
+
+	mu    sync.Mutex
+	rules map[string]symbolRules // BTCUSDT -> 精度
+}
+
+type symbolRules struct {
+	tickSize  float64
+	stepSize  float64
+	minQty    float64
+	pricePrec int
+	qtyPrec   int
+}
+
+// ToSymbol "BTC/USDT"、"BTC-USDT-SWAP"、"BTCUSDT" 统一转换为币安的 BTCUSDT
+func ToSymbol(symbol string) string {
+	s := strings.ToUpper(strings.TrimSpace(symbol))
+	s = strings.TrimSuffix(s, "-SWAP")
+	s = strings.ReplaceAll(s, "-", "/")
+	return strings.ReplaceAll(utils.FormatSymbol(s), "/", "")
+}
+
+// FromSymbol 币安的 BTCUSDT 转换为服务端使用的 BTC/USDT
+func FromSymbol(symbol string) string {
+	return utils.FormatSymbol(symbol)
+}
+
+// symbolRules 首次使用时加载全部交易对的精度
+func (b *Binance) symbolRules(symbol string) (symbolRules, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.rules == nil {
+		rules, err := b.loadExchangeInfo()
+		if err != nil {
+			return symbolRules{}, err
+		}
+		b.rules = rules
+	}
+	r, ok := b.rules[symbol]
+	if !ok {
+		return symbolRules{}, ErrSymbolNotFound
+	}
+	return r, nil
+}
+
+func (b *Binance) loadExchangeInfo() (map[string]symbolRules, error) {
+	var info struct {
+		Symbols []struct {
+			Symbol  string `json:"symbol"`
+			Status  string `json:"status"`
+			Filters []struct {
+				FilterType string `json:"filterType"`
+				TickSize   string `json:"tickSize"`
+				StepSize   string `json:"stepSize"`
+				MinQty     string `json:"minQty"`
+			} `json:"filters"`
+		} `json:"symbols"`
+	}
+	if err := b.c.public(context.Background(), b.paths.exchangeInfo, nil, &info); err != nil {
+		return nil, err
+	}
+	rules := make(map[string]symbolRules, len(info.Symbols))
+	for _, s := range info.Symbols {
+		if s.Status != "TRADING" {
+			continue
+		}
+		var r symbolRules
+		for _, f := range s.Filters {
+			switch f.FilterType {
+			case "PRICE_FILTER":
+				r.tickSize = parseFloat(f.TickSize)
+				r.pricePrec = decimals(f.TickSize)
+			case "LOT_SIZE":
+				r.stepSize = parseFloat(f.StepSize)
+				r.qtyPrec = decimals(f.StepSize)
+				r.minQty = parseFloat(f.MinQty)
+			}
+		}
+		rules[s.Symbol] = r
+	}
+	return rules, nil
+}
+
+// 交易对的下单精度，币安合约的数量单位也是币
+func (b *Binance) GetLotRules(symbol string) (*model2.LotRules, error) {
+	r, err := b.symbolRules(ToSymbol(symbol))
+	if err != nil {
+		return nil, err
+	}
+	return &model2.LotRules{
+		LotSize:  r.stepSize,
+		TickSize: r.tickSize,
+		MinQty:   r.minQty,
+	}, nil
+}
+
+// 获取最新价格
+func (b *Binance) GetLastPrice(symbol string) (float64, error) {
+	var ticker struct {
+		Price string `json:"price"`
+	}
+	params := url.Values{"symbol": {ToSymbol(symbol)}}
+	if err := b.c.public(context.Background(), b.paths.price, params, &ticker); err != nil {
+		return 0, err
+	}
+	price := parseFloat(ticker.Price)
+	if price <= 0 {
+		return 0, errors.New("failed to get ticker")
+	}
+	return price, nil
+}
+
+// 获取买一卖一价
+func (b *Binance) GetBestQuote(symbol string) (bid, ask float64, err error) {
+	var ticker struct {
+		BidPrice string `json:"bidPrice"`
+		AskPrice string `json:"askPrice"`
+	}
+	params := url.Values{"symbol": {ToSymbol(symbol)}}
+	if err = b.c.public(context.Background(), b.paths.bookTicker, params, &ticker); err != nil {
+		return 0, 0, err
+	}
+	bid, ask = parseFloat(ticker.BidPrice), parseFloat(ticker.AskPrice)
+	if bid <= 0 || ask <= 0 {
+		return 0, 0, fmt.Errorf("invalid quote for %s: %v/%v", symbol, bid, ask)
+	}
+	return bid, ask, nil
+}
+
+// GetKlineRecords 币安返回的顺序是从旧到新，与 okx 包一致，由 BinanceExchange 转换为从新到旧
+func (b *Binance) GetKlineRecords(symbol string, period model.KlinePeriod, size int, start, end int64, includeUnclosed bool) ([]model2.Kline, error) {
+	sym := ToSymbol(symbol)
+	if _, err := b.symbolRules(sym); err != nil {
+		return nil, err
+	}
+	params := url.Values{
+		"symbol":   {sym},
+		"interval": {klineInterval(period)},
+	}
+	if size > 0 {
+		params.Set("limit", strconv.Itoa(size))
+	}
+	if start > 0 {
+		params.Set("startTime", strconv.FormatInt(start, 10))
+	}
+	if end > 0 {
+		params.Set("endTime", strconv.FormatInt(end, 10))
+	}
+
+	// [开盘时间, 开, 高, 低, 收, 成交量, 收盘时间, 成交额, ...]
+	var rows [][]json.RawMessage
+	if err := b.c.public(context.Background(), b.paths.klines, params, &rows); err != nil {
+		return nil, err
+	}
+	now := b.c.now().UnixMilli()
+	items := make([]model2.Kline, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 8 {
+			return nil, fmt.Errorf("invalid kline row: %d fields", len(row))
+		}
+		closeTime := rawInt64(row[6])
+		// 过滤未收盘的k线
+		if !includeUnclosed && now <= closeTime {
+			continue
+		}
+		items = append(items, model2.Kline{
+			Timestamp: time.UnixMilli(rawInt64(row[0])),
+			Open:      rawFloat(row[1]),
+			High:      rawFloat(row[2]),
+			Low:       rawFloat(row[3]),
+			Close:     rawFloat(row[4]),
+			Vol:       rawFloat(row[5]),
+			VolCcy:    rawFloat(row[7]),
+		})
+	}
+	return items, nil
+}
+
+// orderInfo 现货和合约订单查询的公共字段
+type orderInfo struct {
+	OrderId       int64  `json:"orderId"`
+	ClientOrderId string `json:"clientOrderId"`
+	Status        string `json:"status"`
+	OrigQty       string `json:"origQty"`
+	ExecutedQty   string `json:"executedQty"`
+	AvgPrice      string `json:"avgPrice"`            // 合约
+	CumQuote      string `json:"cumQuote"`            // 合约
+	SpotCumQuote  string `json:"cummulativeQuoteQty"` // 现货
+}
+
+func (o *orderInfo) avgPrice() float64 {
+	if avg := parseFloat(o.AvgPrice); avg > 0 {
+		return avg
+	}
+	executed := parseFloat(o.ExecutedQty)
+	if executed <= 0 {
+		return 0
+	}
+	quote := parseFloat(o.CumQuote)
+	if quote <= 0 {
+		quote = parseFloat(o.SpotCumQuote)
+	}
+	return quote / executed
+}
+
+func (o *orderInfo) response() *model2.OrderResponse {
+	return &model2.OrderResponse{
+		OrderId: strconv.FormatInt(o.OrderId, 10),
+		Status:  int(orderStatus(o.Status)),
+	}
+}
+
+// 取消订单
+func (b *Binance) CancelOrder(orderID, symbol string) error {
+	params := url.Values{"symbol": {ToSymbol(symbol)}, "orderId": {orderID}}
+	return b.c.signed(context.Background(), http.MethodDelete, b.paths.order, params, nil)
+}
+
+// 获取订单状态，手续费按订单的成交明细汇总
+func (b *Binance) GetOrderStatus(orderID string, symbol string) (*model2.OrderStatus, error) {
+	sym := ToSymbol(symbol)
+	info, err := b.queryOrder(sym, url.Values{"orderId": {orderID}})
+	if err != nil {
+		return nil, err
+	}
+	st := &model2.OrderStatus{
+		OrderID:   strconv.FormatInt(info.OrderId, 10),
+		Status:    orderStatus(info.Status).String(),
+		Filled:    parseFloat(info.ExecutedQty),
+		Remaining: parseFloat(info.OrigQty) - parseFloat(info.ExecutedQty),
+		AvgPrice:  info.avgPrice(),
+	}
+	if st.Filled > 0 {
+		st.Fee, st.FeeCcy, err = b.orderFee(sym, st.OrderID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+func (b *Binance) queryOrder(symbol string, params url.Values) (*orderInfo, error) {
+	params.Set("symbol", symbol)
+	var info orderInfo
+	if err := b.c.signed(context.Background(), http.MethodGet, b.paths.order, params, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// orderFee 成交明细的手续费之和，扣费为负数
+func (b *Binance) orderFee(symbol, orderID string) (float64, string, error) {
+	var trades []struct {
+		Commission      string `json:"commission"`
+		CommissionAsset string `json:"commissionAsset"`
+	}
+	params := url.Values{"symbol": {symbol}, "orderId": {orderID}}
+	if err := b.c.signed(context.Background(), http.MethodGet, b.paths.trades, params, &trades); err != nil {
+		return 0, "", err
+	}
+	var fee float64
+	var ccy string
+	for _, t := range trades {
+		fee += parseFloat(t.Commission)
+		if ccy == "" {
+			ccy = t.CommissionAsset
+		}
+	}
+	return -fee, ccy, nil
+}
+
+// createOrder 下单，带 clientOrderId 时交易所返回重复错误视为已经下过单，查询并返回已有订单
+func (b *Binance) createOrder(ctx context.Context, symbol, clientOrderId string, params url.Values) (*model2.OrderResponse, error) {
+	params.Set("symbol", symbol)
+	if clientOrderId != "" {
+		params.Set("newClientOrderId", clientOrderId)
+	}
+	var info orderInfo
+	err := b.c.signed(ctx, http.MethodPost, b.paths.order, params, &info)
+	if err == nil {
+		return info.response(), nil
+	}
+	if clientOrderId == "" || !isDuplicateClientId(err) {
+		return nil, err
+	}
+	existing, qErr := b.queryOrder(symbol, url.Values{"origClientOrderId": {clientOrderId}})
+	if qErr != nil {
+		return nil, fmt.Errorf("clientOrderId %s 重复，查询已有订单失败: %w", clientOrderId, qErr)
+	}
+	resp := existing.response()
+	resp.Duplicate = true
+	return resp, nil
+}
+
+func isDuplicateClientId(err error) bool {
+	if isAPIError(err, codeDuplicateClientId) {
+		return true
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == codeSpotDuplicateClient && strings.Contains(strings.ToLower(apiErr.Msg), "duplicate")
+}
+
+// orderParams 限价单和市价单的公共参数
+func (b *Binance) orderParams(symbol string, order *model2.Order, side string) (url.Values, error) {
+	r, err := b.symbolRules(symbol)
+	if err != nil {
+		return nil, err
+	}
+	qty := floorToStep(order.Quantity, r.stepSize)
+	if qty <= 0 || qty < r.minQty {
+		return nil, fmt.Errorf("您的账户余额不足，Quantity:%v 不足以开仓", order.Quantity)
+	}
+	order.Quantity = qty
+	params := url.Values{
+		"side":     {side},
+		"quantity": {strconv.FormatFloat(qty, 'f', r.qtyPrec, 64)},
+	}
+	switch order.OrderType {
+	case model2.Limit:
+		params.Set("type", "LIMIT")
+		params.Set("timeInForce", "GTC")
+		params.Set("price", strconv.FormatFloat(roundToStep(order.Price, r.tickSize), 'f', r.pricePrec, 64))
+	case model2.Market:
+		params.Set("type", "MARKET")
+	default:
+		return nil, fmt.Errorf("unsupported order type: %s", order.OrderType)
+	}
+	return params, nil
+}
+
+// formatPrice 按价格精度格式化触发价
+func (b *Binance) formatPrice(symbol string, price float64) (string, error) {
+	r, err := b.symbolRules(symbol)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(roundToStep(price, r.tickSize), 'f', r.pricePrec, 64), nil
+}
+
+// orderSide 开仓方向对应的买卖方向
+func orderSide(side model2.OrderSide) (string, error) {
+	switch strings.ToLower(string(side)) {
+	case "buy":
+		return "BUY", nil
+	case "sell":
+		return "SELL", nil
+	}
+	return "", errors.New("invalid order side")
+}
+
+func orderStatus(st string) model.OrderStatus {
+	switch st {
+	case "NEW":
+		return model.OrderStatus_Pending
+	case "PARTIALLY_FILLED":
+		return model.OrderStatus_PartFinished
+	case "FILLED":
+		return model.OrderStatus_Finished
+	case "CANCELED", "EXPIRED", "EXPIRED_IN_MATCH", "REJECTED":
+		return model.OrderStatus_Canceled
+	}
+	return model.OrderStatus(-1)
+}
+
+func klineInterval(period model.KlinePeriod) string {
+	switch period {
+	case model.Kline_1min:
+		return "1m"
+	case model.Kline_5min:
+		return "5m"
+	case model.Kline_15min:
+		return "15m"
+	case model.Kline_30min:
+		return "30m"
+	case model.Kline_60min, model.Kline_1h:
+		return "1h"
+	case model.Kline_4h:
+		return "4h"
+	case model.Kline_6h:
+		return "6h"
+	case model.Kline_1day:
+		return "1d"
+	case model.Kline_1week:
+		return "1w"
+	}
+	return string(period)
+}
+
+// ---- 工具函数 ----
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+func rawFloat(raw json.RawMessage) float64 {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return parseFloat(s)
+	}
+	var v float64
+	_ = json.Unmarshal(raw, &v)
+	return v
+}
+
+func rawInt64(raw json.RawMessage) int64 {
+	var v int64
+	_ = json.Unmarshal(raw, &v)
+	return v
+}
+
+// decimals 步长的小数位数，如 "0.00100000" -> 3
+func decimals(step string) int {
+	i := strings.IndexByte(step, '.')
+	if i < 0 {
+		return 0
+	}
+	return len(strings.TrimRight(step[i+1:], "0"))
+}
+
+func floorToStep(v, step float64) float64 {
+	if step <= 0 {
+		return v
+	}
+	return math.Floor(v/step+1e-9) * step
+}
+
+func roundToStep(v, step float64) float64 {
+	if step <= 0 {
+		return v
+	}
+	return math.Round(v/step) * step
+}
@@ -0,0 +1,177 @@
+package binance
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultSpotBaseURL    = "https://api.binance.com"
+	defaultFuturesBaseURL = "https://fapi.binance.com"
+	recvWindow            = "5000"
+)
+
+// 币安的错误码
+const (
+	codeNoNeedChangeMargin  = -4046 // 保证金模式没有变化
+	codeDuplicateClientId   = -4116 // 合约 newClientOrderId 重复
+	codeSpotDuplicateClient = -2010 // 现货下单被拒绝，msg 为 Duplicate order sent. 时表示 clientOrderId 重复
+	codeDisconnected        = -1001 // 服务内部错误，无法处理请求
+	codeTooManyRequests     = -1003 // 请求权重超限
+	codeTimeout             = -1007 // 等待后端响应超时，结果未知
+	codeTooManyOrders       = -1015 // 下单频率超限
+)
+
+// APIError 币安返回的业务错误
+type APIError struct {
+	Status int    `json:"-"`
+	Code   int    `json:"code"`
+	Msg    string `json:"msg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("binance error: status=%d code=%d msg=%s", e.Status, e.Code, e.Msg)
+}
+
+// RateLimited 请求权重或下单频率超限（-1003、-1015），418 为超限后被封禁 IP
+func (e *APIError) RateLimited() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status == http.StatusTeapot ||
+		e.Code == codeTooManyRequests || e.Code == codeTooManyOrders
+}
+
+// Temporary 服务端错误或超时（-1001、-1007），可以重试
+func (e *APIError) Temporary() bool {
+	return e.Status >= http.StatusInternalServerError || e.Code == codeDisconnected || e.Code == codeTimeout
+}
+
+// Option 修改默认的接口地址和 http 客户端，测试时指向本地服务
+type Option func(*options)
+
+type options struct {
+	spotBaseURL    string
+	futuresBaseURL string
+	httpClient     *http.Client
+}
+
+func WithSpotBaseURL(u string) Option {
+	return func(o *options) { o.spotBaseURL = u }
+}
+
+func WithFuturesBaseURL(u string) Option {
+	return func(o *options) { o.futuresBaseURL = u }
+}
+
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) { o.httpClient = c }
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		spotBaseURL:    defaultSpotBaseURL,
+		futuresBaseURL: defaultFuturesBaseURL,
+		httpClient:     &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// client 币安 REST 请求，私有接口使用 HMAC-SHA256 签名
+type client struct {
+	baseURL   string
+	apiKey    string
+	secretKey string
+	http      *http.Client
+	now       func() time.Time
+}
+
+func newClient(baseURL, apiKey, secretKey string, hc *http.Client) *client {
+	return &client{baseURL: baseURL, apiKey: apiKey, secretKey: secretKey, http: hc, now: time.Now}
+}
+
+// public 公共行情接口
+func (c *client) public(ctx context.Context, path string, params url.Values, out any) error {
+	return c.do(ctx, http.MethodGet, path, params, false, out)
+}
+
+// signed 交易和账户接口
+func (c *client) signed(ctx context.Context, method, path string, params url.Values, out any) error {
+	return c.do(ctx, method, path, params, true, out)
+}
+
+func (c *client) do(ctx context.Context, method, path string, params url.Values, sign bool, out any) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	if sign {
+		params.Set("timestamp", strconv.FormatInt(c.now().UnixMilli(), 10))
+		params.Set("recvWindow", recvWindow)
+	}
+	query := params.Encode()
+	if sign {
+		mac := hmac.New(sha256.New, []byte(c.secretKey))
+		mac.Write([]byte(query))
+		query += "&signature=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	reqUrl := c.baseURL + path
+	if query != "" {
+		reqUrl += "?" + query
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, nil)
+	if err != nil {
+		return err
+	}
+	if sign {
+		req.Header.Set("X-MBX-APIKEY", c.apiKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{Status: resp.StatusCode}
+		if json.Unmarshal(body, apiErr) != nil || apiErr.Msg == "" {
+			apiErr.Msg = string(body)
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+// isAPIError 是否为指定错误码的业务错误
+func isAPIError(err error, codes ...int) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
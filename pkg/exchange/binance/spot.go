@@ -0,0 +1,94 @@
+package binance
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	"edgeflow/pkg/account"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// BinanceSpot 现货，只支持限价和市价单，不挂止盈止损
+type BinanceSpot struct {
+	Binance
+}
+
+func NewBinanceSpot(apiKey, secretKey string, opts ...Option) *BinanceSpot {
+	o := newOptions(opts)
+	return &BinanceSpot{Binance{
+		c: newClient(o.spotBaseURL, apiKey, secretKey, o.httpClient),
+		paths: endpoints{
+			exchangeInfo: "/api/v3/exchangeInfo",
+			price:        "/api/v3/ticker/price",
+			bookTicker:   "/api/v3/ticker/bookTicker",
+			klines:       "/api/v3/klines",
+			order:        "/api/v3/order",
+			trades:       "/api/v3/myTrades",
+		},
+	}}
+}
+
+// GetAccount 现货账户的余额
+func (e *BinanceSpot) GetAccount(ctx context.Context, coin string) (*account.Account, error) {
+	var acc struct {
+		Balances []struct {
+			Asset  string `json:"asset"`
+			Free   string `json:"free"`
+			Locked string `json:"locked"`
+		} `json:"balances"`
+	}
+	if err := e.c.signed(ctx, http.MethodGet, "/api/v3/account", nil, &acc); err != nil {
+		return nil, err
+	}
+	for _, b := range acc.Balances {
+		if b.Asset != coin {
+			continue
+		}
+		free, locked := parseFloat(b.Free), parseFloat(b.Locked)
+		return &account.Account{Currency: b.Asset, Total: free + locked, Available: free, Frozen: locked}, nil
+	}
+	return nil, errors.New("account info not found for coin " + coin)
+}
+
+// 下单购买，限价和市价的数量单位都是币。按比例下单时买入使用计价币余额，卖出使用持有的币
+func (e *BinanceSpot) PlaceOrder(ctx context.Context, order *model2.Order) (*model2.OrderResponse, error) {
+	symbol := ToSymbol(order.Symbol)
+	side, err := orderSide(order.Side)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.QuantityPct > 0 {
+		base, quote, _ := strings.Cut(FromSymbol(symbol), "/")
+		if side == "BUY" {
+			acc, err := e.GetAccount(ctx, quote)
+			if err != nil {
+				return nil, err
+			}
+			price := order.Price
+			if order.OrderType != model2.Limit || price <= 0 {
+				if price, err = e.GetLastPrice(order.Symbol); err != nil {
+					return nil, err
+				}
+			}
+			order.Quantity = acc.Available * order.QuantityPct * 0.98 / price
+		} else {
+			acc, err := e.GetAccount(ctx, base)
+			if err != nil {
+				return nil, err
+			}
+			order.Quantity = acc.Available * order.QuantityPct
+		}
+	}
+	if order.TPPrice > 0 || order.SLPrice > 0 {
+		log.Printf("[BinanceSpot] %s 现货不支持止盈止损，忽略 tp=%v sl=%v", symbol, order.TPPrice, order.SLPrice)
+	}
+
+	params, err := e.orderParams(symbol, order, side)
+	if err != nil {
+		return nil, err
+	}
+	return e.createOrder(ctx, symbol, order.ClientOrderId, params)
+}
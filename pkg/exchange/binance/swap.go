@@ -0,0 +1,399 @@
+package binance
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	"edgeflow/pkg/account"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 条件单（止盈止损）使用 Algo 接口
+const (
+	algoOrderPath      = "/fapi/v1/algoOrder"
+	openAlgoOrdersPath = "/fapi/v1/openAlgoOrders"
+)
+
+// 默认杠杆，与 OkxSwap 一致
+const defaultLeverage = 20
+
+// BinanceSwap U 本位永续合约，账户需要开启双向持仓，开平仓按 positionSide 区分多空
+type BinanceSwap struct {
+	Binance
+}
+
+func NewBinanceSwap(apiKey, secretKey string, opts ...Option) *BinanceSwap {
+	o := newOptions(opts)
+	return &BinanceSwap{Binance{
+		c: newClient(o.futuresBaseURL, apiKey, secretKey, o.httpClient),
+		paths: endpoints{
+			exchangeInfo: "/fapi/v1/exchangeInfo",
+			price:        "/fapi/v1/ticker/price",
+			bookTicker:   "/fapi/v1/ticker/bookTicker",
+			klines:       "/fapi/v1/klines",
+			order:        "/fapi/v1/order",
+			trades:       "/fapi/v1/userTrades",
+		},
+	}}
+}
+
+// GetAccount 合约账户的余额
+func (e *BinanceSwap) GetAccount(ctx context.Context, coin string) (*account.Account, error) {
+	var balances []struct {
+		Asset            string `json:"asset"`
+		Balance          string `json:"balance"`
+		AvailableBalance string `json:"availableBalance"`
+	}
+	if err := e.c.signed(ctx, http.MethodGet, "/fapi/v2/balance", nil, &balances); err != nil {
+		return nil, err
+	}
+	for _, b := range balances {
+		if b.Asset != coin {
+			continue
+		}
+		total, available := parseFloat(b.Balance), parseFloat(b.AvailableBalance)
+		return &account.Account{Currency: b.Asset, Total: total, Available: available, Frozen: total - available}, nil
+	}
+	return nil, errors.New("account info not found for coin " + coin)
+}
+
+// 下单购买，数量单位为币。带止盈止损时开仓后按仓位方向挂条件单，条件单失败不影响开仓结果
+func (e *BinanceSwap) PlaceOrder(ctx context.Context, order *model2.Order) (*model2.OrderResponse, error) {
+	symbol := ToSymbol(order.Symbol)
+	side, err := orderSide(order.Side)
+	if err != nil {
+		return nil, err
+	}
+	posSide := "LONG"
+	if side == "SELL" {
+		posSide = "SHORT"
+	}
+
+	mgnMode := order.MgnMode
+	if mgnMode == "" {
+		mgnMode = model2.OrderMgnModeIsolated
+	}
+	if order.Leverage <= 0 {
+		order.Leverage = defaultLeverage
+	}
+	if err := e.setMarginType(ctx, symbol, string(mgnMode)); err != nil {
+		return nil, err
+	}
+	if err := e.SetLeverage(order.Symbol, order.Leverage, string(mgnMode), strings.ToLower(posSide)); err != nil {
+		return nil, err
+	}
+	order.MgnMode = mgnMode
+
+	// 根据比例计算下单数量
+	if order.QuantityPct > 0 {
+		acc, err := e.GetAccount(ctx, "USDT")
+		if err != nil {
+			return nil, err
+		}
+		price := order.Price
+		if order.OrderType != model2.Limit || price <= 0 {
+			if price, err = e.GetLastPrice(order.Symbol); err != nil {
+				return nil, err
+			}
+		}
+		// 0.98 是最大仓位的容差，防止价差导致余额不足
+		order.Quantity = acc.Available * order.QuantityPct * 0.98 * float64(order.Leverage) / price
+	}
+
+	params, err := e.orderParams(symbol, order, side)
+	if err != nil {
+		return nil, err
+	}
+	params.Set("positionSide", posSide)
+	resp, err := e.createOrder(ctx, symbol, order.ClientOrderId, params)
+	if err != nil || resp.Duplicate {
+		return resp, err
+	}
+
+	if order.SLPrice > 0 || order.TPPrice > 0 {
+		if _, err := e.protect(ctx, symbol, posSide, order.SLPrice, order.TPPrice); err != nil {
+			log.Printf("[BinanceSwap] %s %s 止盈止损下单失败: %v", symbol, posSide, err)
+			resp.Message = fmt.Sprintf("止盈止损下单失败: %v", err)
+		}
+	}
+	return resp, nil
+}
+
+// SetLeverage 设置合约杠杆，币安的杠杆按交易对设置，不区分多空
+func (e *BinanceSwap) SetLeverage(symbol string, leverage int, marginMode, posSide string) error {
+	params := url.Values{"symbol": {ToSymbol(symbol)}, "leverage": {strconv.Itoa(leverage)}}
+	if err := e.c.signed(context.Background(), http.MethodPost, "/fapi/v1/leverage", params, nil); err != nil {
+		return fmt.Errorf("设置杠杆失败: %w", err)
+	}
+	return nil
+}
+
+// setMarginType 设置保证金模式，没有变化时币安返回 -4046，忽略
+func (e *BinanceSwap) setMarginType(ctx context.Context, symbol, mgnMode string) error {
+	var marginType string
+	switch mgnMode {
+	case model2.OrderMgnModeIsolated:
+		marginType = "ISOLATED"
+	case model2.OrderMgnModeCross:
+		marginType = "CROSSED"
+	default:
+		return fmt.Errorf("不支持的保证金模式: %s", mgnMode)
+	}
+	params := url.Values{"symbol": {symbol}, "marginType": {marginType}}
+	err := e.c.signed(ctx, http.MethodPost, "/fapi/v1/marginType", params, nil)
+	if err != nil && !isAPIError(err, codeNoNeedChangeMargin) {
+		return fmt.Errorf("设置保证金模式失败: %w", err)
+	}
+	return nil
+}
+
+// 平仓函数，市价平掉指定方向的仓位
+func (e *BinanceSwap) ClosePosition(symbol string, dir string, quantity float64, tdMode string) (*model2.OrderResponse, error) {
+	var side, posSide string
+	switch dir {
+	case "long":
+		side, posSide = "SELL", "LONG"
+	case "short":
+		side, posSide = "BUY", "SHORT"
+	default:
+		return nil, fmt.Errorf("unknown side: %s", dir)
+	}
+	sym := ToSymbol(symbol)
+	r, err := e.symbolRules(sym)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{
+		"side":         {side},
+		"positionSide": {posSide},
+		"type":         {"MARKET"},
+		"quantity":     {strconv.FormatFloat(floorToStep(quantity, r.stepSize), 'f', r.qtyPrec, 64)},
+	}
+	return e.createOrder(context.Background(), sym, "", params)
+}
+
+// positionRisk 合约持仓
+type positionRisk struct {
+	Symbol           string `json:"symbol"`
+	PositionAmt      string `json:"positionAmt"`
+	EntryPrice       string `json:"entryPrice"`
+	MarkPrice        string `json:"markPrice"`
+	UnRealizedProfit string `json:"unRealizedProfit"`
+	LiquidationPrice string `json:"liquidationPrice"`
+	Leverage         string `json:"leverage"`
+	MarginType       string `json:"marginType"`
+	IsolatedMargin   string `json:"isolatedMargin"`
+	PositionSide     string `json:"positionSide"`
+	Notional         string `json:"notional"`
+	UpdateTime       int64  `json:"updateTime"`
+}
+
+// 查询是否有持仓，AlgoId 为该方向止盈止损条件单的标识，用于 AmendAlgoOrder
+func (e *BinanceSwap) GetPosition(symbol string) (long *model2.PositionInfo, short *model2.PositionInfo, err error) {
+	sym := ToSymbol(symbol)
+	var positions []positionRisk
+	params := url.Values{"symbol": {sym}}
+	if err = e.c.signed(context.Background(), http.MethodGet, "/fapi/v2/positionRisk", params, &positions); err != nil {
+		return nil, nil, fmt.Errorf("GetPosition error: %w", err)
+	}
+	algos, algoErr := e.openAlgoOrders(context.Background(), sym)
+	if algoErr != nil {
+		log.Printf("[BinanceSwap] 查询 %s 条件单失败: %v", sym, algoErr)
+	}
+
+	for _, p := range positions {
+		amt := parseFloat(p.PositionAmt)
+		if amt == 0 {
+			continue
+		}
+		dir := model2.OrderPosSide(model2.OrderPosSideLong)
+		if p.PositionSide == "SHORT" || (p.PositionSide == "BOTH" && amt < 0) {
+			dir = model2.OrderPosSideShort
+		}
+		item := &model2.PositionInfo{
+			Symbol:        FromSymbol(p.Symbol),
+			Dir:           dir,
+			Amount:        math.Abs(amt),
+			AvgPrice:      parseFloat(p.EntryPrice),
+			MgnMode:       marginMode(p.MarginType),
+			LiqPx:         p.LiquidationPrice,
+			UnrealizedPnl: p.UnRealizedProfit,
+			MarkPx:        p.MarkPrice,
+			Margin:        p.IsolatedMargin,
+			Lever:         p.Leverage,
+			NotionalUsd:   strings.TrimPrefix(p.Notional, "-"),
+			Last:          parseFloat(p.MarkPrice),
+			CTime:         strconv.FormatInt(p.UpdateTime, 10),
+		}
+		id := algoId(p.Symbol, strings.ToUpper(string(dir)))
+		var slAlgo int64
+		for _, a := range algos {
+			switch {
+			case isAlgoLeg(a.ClientAlgoId, id, slSuffix):
+				item.AlgoId = id
+				// 撤单失败时可能残留旧的止损单，以最新挂出的为准
+				if a.AlgoId > slAlgo {
+					slAlgo = a.AlgoId
+					item.SlTriggerPx = parseFloat(a.TriggerPrice)
+				}
+			case isAlgoLeg(a.ClientAlgoId, id, tpSuffix):
+				item.AlgoId = id
+			}
+		}
+		if dir == model2.OrderPosSideLong {
+			long = item
+		} else {
+			short = item
+		}
+	}
+	return long, short, nil
+}
+
+func marginMode(marginType string) string {
+	if strings.EqualFold(marginType, "cross") || strings.EqualFold(marginType, "crossed") {
+		return model2.OrderMgnModeCross
+	}
+	return model2.OrderMgnModeIsolated
+}
+
+// 每个交易对和方向只保留一组止盈止损条件单，AlgoId 保持不变。
+// clientAlgoId 为 AlgoId + 后缀 + 下单时间，修改时先挂新单再撤旧单，旧版本没有下单时间
+const (
+	slSuffix = "_sl"
+	tpSuffix = "_tp"
+)
+
+func algoId(symbol, posSide string) string {
+	return "ef_" + symbol + "_" + posSide
+}
+
+// newClientAlgoId 每次挂单使用新的 clientAlgoId，避免和未撤掉的旧单冲突。
+// 毫秒时间转 36 进制，长度不超过币安 36 个字符的限制
+func newClientAlgoId(id, suffix string) string {
+	return id + suffix + "_" + strconv.FormatInt(time.Now().UnixMilli(), 36)
+}
+
+// isAlgoLeg clientAlgoId 是否为 id 的止损（止盈）单
+func isAlgoLeg(clientAlgoId, id, suffix string) bool {
+	return clientAlgoId == id+suffix || strings.HasPrefix(clientAlgoId, id+suffix+"_")
+}
+
+// parseAlgoId AlgoId 中的交易对和持仓方向
+func parseAlgoId(id string) (symbol, posSide string, err error) {
+	rest, ok := strings.CutPrefix(id, "ef_")
+	i := strings.LastIndexByte(rest, '_')
+	if !ok || i <= 0 {
+		return "", "", fmt.Errorf("invalid algoId: %s", id)
+	}
+	symbol, posSide = rest[:i], rest[i+1:]
+	if posSide != "LONG" && posSide != "SHORT" {
+		return "", "", fmt.Errorf("invalid algoId: %s", id)
+	}
+	return symbol, posSide, nil
+}
+
+type algoOrder struct {
+	AlgoId       int64  `json:"algoId"`
+	ClientAlgoId string `json:"clientAlgoId"`
+	Symbol       string `json:"symbol"`
+	Side         string `json:"side"`
+	PositionSide string `json:"positionSide"`
+	OrderType    string `json:"orderType"`
+	TriggerPrice string `json:"triggerPrice"`
+	AlgoStatus   string `json:"algoStatus"`
+}
+
+func (e *BinanceSwap) openAlgoOrders(ctx context.Context, symbol string) ([]algoOrder, error) {
+	var orders []algoOrder
+	params := url.Values{"symbol": {symbol}}
+	if err := e.c.signed(ctx, http.MethodGet, openAlgoOrdersPath, params, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// AmendAlgoOrder 币安的条件单不能修改，按新的触发价挂单后撤掉原来的止损（止盈）单，
+// 价格 <= 0 的一侧保持不变。返回新挂出的条件单
+func (e *BinanceSwap) AmendAlgoOrder(instId, algoId string, newSlTriggerPx, newTpTriggerPx float64) ([]byte, error) {
+	symbol, posSide, err := parseAlgoId(algoId)
+	if err != nil {
+		return nil, err
+	}
+	if sym := ToSymbol(instId); sym != symbol {
+		return nil, fmt.Errorf("algoId %s 与交易对 %s 不匹配", algoId, instId)
+	}
+	placed, err := e.protect(context.Background(), symbol, posSide, newSlTriggerPx, newTpTriggerPx)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(placed)
+}
+
+// protect 按仓位方向挂止损和止盈条件单（触发后市价全部平仓）。
+// 新单挂出后才撤掉已有的同类条件单，下单失败时原来的条件单保持不变，仓位不会失去保护
+func (e *BinanceSwap) protect(ctx context.Context, symbol, posSide string, slPrice, tpPrice float64) ([]algoOrder, error) {
+	open, err := e.openAlgoOrders(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	side := "SELL"
+	if posSide == "SHORT" {
+		side = "BUY"
+	}
+	id := algoId(symbol, posSide)
+
+	var placed []algoOrder
+	for _, leg := range []struct {
+		suffix    string
+		orderType string
+		price     float64
+	}{
+		{slSuffix, "STOP_MARKET", slPrice},
+		{tpSuffix, "TAKE_PROFIT_MARKET", tpPrice},
+	} {
+		if leg.price <= 0 {
+			continue
+		}
+		clientAlgoId := newClientAlgoId(id, leg.suffix)
+		trigger, err := e.formatPrice(symbol, leg.price)
+		if err != nil {
+			return placed, err
+		}
+		params := url.Values{
+			"algoType":      {"CONDITIONAL"},
+			"symbol":        {symbol},
+			"side":          {side},
+			"positionSide":  {posSide},
+			"type":          {leg.orderType},
+			"triggerPrice":  {trigger},
+			"closePosition": {"true"},
+			"workingType":   {"MARK_PRICE"},
+			"clientAlgoId":  {clientAlgoId},
+		}
+		var o algoOrder
+		if err := e.c.signed(ctx, http.MethodPost, algoOrderPath, params, &o); err != nil {
+			return placed, fmt.Errorf("条件单 %s 下单失败: %w", clientAlgoId, err)
+		}
+		placed = append(placed, o)
+
+		for _, old := range open {
+			if !isAlgoLeg(old.ClientAlgoId, id, leg.suffix) || old.AlgoId == o.AlgoId {
+				continue
+			}
+			// 新单已经生效，撤单失败只记录日志，残留的旧单在下次修改时再撤
+			params := url.Values{"algoId": {strconv.FormatInt(old.AlgoId, 10)}}
+			if err := e.c.signed(ctx, http.MethodDelete, algoOrderPath, params, nil); err != nil {
+				log.Printf("[BinanceSwap] 撤销条件单 %s 失败: %v", old.ClientAlgoId, err)
+			}
+		}
+	}
+	return placed, nil
+}
@@ -0,0 +1,144 @@
+package exchange
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	"edgeflow/pkg/exchange/hyperliquid"
+	"errors"
+	"fmt"
+
+	"github.com/nntaoli-project/goex/v2/model"
+)
+
+var (
+	_ Exchange         = (*HyperliquidExchange)(nil)
+	_ LotRulesProvider = (*HyperliquidExchange)(nil)
+	_ QuoteProvider    = (*HyperliquidExchange)(nil)
+)
+
+// HyperliquidExchange hyperliquid 永续合约，接口语义与 OkxExchange 一致：
+// symbol 使用 BTC/USDT（计价币忽略），K线从新到旧，止盈止损通过 AmendAlgoOrder 修改。只支持 swap
+type HyperliquidExchange struct {
+	swap *hyperliquid.Hyperliquid
+}
+
+// privateKey 为签名私钥，opts 可以切换测试网、指定主账户地址或替换接口地址
+func NewHyperliquidExchange(privateKey string, opts ...hyperliquid.Option) (*HyperliquidExchange, error) {
+	swap, err := hyperliquid.NewHyperliquid(privateKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &HyperliquidExchange{swap: swap}, nil
+}
+
+func (e *HyperliquidExchange) getApi(marketType model2.OrderTradeType) (*hyperliquid.Hyperliquid, error) {
+	if marketType != model2.OrderTradeSwap {
+		return nil, fmt.Errorf("unsupported market type: %s", marketType)
+	}
+	return e.swap, nil
+}
+
+func (e *HyperliquidExchange) Account(tradeType model2.OrderTradeType) (Account, error) {
+	return e.getApi(tradeType)
+}
+
+func (e *HyperliquidExchange) GetLastPrice(symbol string, tradingType model2.OrderTradeType) (float64, error) {
+	api, err := e.getApi(tradingType)
+	if err != nil {
+		return 0, err
+	}
+	return api.GetLastPrice(symbol)
+}
+
+// GetBestQuote 盘口买一卖一价
+func (e *HyperliquidExchange) GetBestQuote(symbol string, tradeType model2.OrderTradeType) (bid, ask float64, err error) {
+	api, err := e.getApi(tradeType)
+	if err != nil {
+		return 0, 0, err
+	}
+	return api.GetBestQuote(symbol)
+}
+
+// 下单购买，数量单位为币
+func (e *HyperliquidExchange) PlaceOrder(ctx context.Context, order *model2.Order) (*model2.OrderResponse, error) {
+	api, err := e.getApi(order.TradeType)
+	if err != nil {
+		return nil, err
+	}
+	return api.PlaceOrder(ctx, order)
+}
+
+func (e *HyperliquidExchange) CancelOrder(orderID, symbol string, tradingType model2.OrderTradeType) error {
+	api, err := e.getApi(tradingType)
+	if err != nil {
+		return err
+	}
+	return api.CancelOrder(orderID, symbol)
+}
+
+func (e *HyperliquidExchange) GetOrderStatus(orderID string, symbol string, tradingType model2.OrderTradeType) (*model2.OrderStatus, error) {
+	api, err := e.getApi(tradingType)
+	if err != nil {
+		return nil, err
+	}
+	return api.GetOrderStatus(orderID, symbol)
+}
+
+// GetLotRules 交易对的下单精度
+func (e *HyperliquidExchange) GetLotRules(symbol string, tradeType model2.OrderTradeType) (*model2.LotRules, error) {
+	api, err := e.getApi(tradeType)
+	if err != nil {
+		return nil, err
+	}
+	return api.GetLotRules(symbol)
+}
+
+// SetLeverage 设置合约杠杆
+func (e *HyperliquidExchange) SetLeverage(symbol string, leverage int, marginMode, posSide string, tradeType model2.OrderTradeType) error {
+	if tradeType != model2.OrderTradeSwap {
+		return errors.New("当前交易类型不支持设置杠杆倍数SetLeverage")
+	}
+	return e.swap.SetLeverage(symbol, leverage, marginMode, posSide)
+}
+
+// 平仓函数
+func (e *HyperliquidExchange) ClosePosition(symbol string, side string, quantity float64, tdMode string, tradeType model2.OrderTradeType) (*model2.OrderResponse, error) {
+	if tradeType != model2.OrderTradeSwap {
+		return nil, errors.New("当前交易类型不支持关闭仓位ClosePosition")
+	}
+	return e.swap.ClosePosition(symbol, side, quantity, tdMode)
+}
+
+// 查询是否有持仓
+func (e *HyperliquidExchange) GetPosition(symbol string, tradeType model2.OrderTradeType) (long *model2.PositionInfo, short *model2.PositionInfo, err error) {
+	if tradeType != model2.OrderTradeSwap {
+		return nil, nil, errors.New("当前交易类型不支持获取仓位GetPosition")
+	}
+	return e.swap.GetPosition(symbol)
+}
+
+// AmendAlgoOrder 修改止损（止盈）触发单的触发价，algoId 来自 GetPosition
+func (e *HyperliquidExchange) AmendAlgoOrder(instId string, tradeType model2.OrderTradeType, algoId string, newSlTriggerPx, newTpTriggerPx float64) ([]byte, error) {
+	if tradeType != model2.OrderTradeSwap {
+		return nil, errors.New("当前交易类型不支持修改止盈止损AmendAlgoOrder")
+	}
+	return e.swap.AmendAlgoOrder(instId, algoId, newSlTriggerPx, newTpTriggerPx)
+}
+
+// GetKlineRecords 顺序与 OkxExchange 一致，从新到旧
+func (e *HyperliquidExchange) GetKlineRecords(symbol string, period model.KlinePeriod, size int, start, end int64, tradeType model2.OrderTradeType, includeUnclosed bool) ([]model2.Kline, error) {
+	api, err := e.getApi(tradeType)
+	if err != nil {
+		return nil, err
+	}
+	klines, err := api.GetKlineRecords(symbol, period, size, start, end, includeUnclosed)
+	if err != nil {
+		return nil, err
+	}
+
+	reLines := make([]model2.Kline, len(klines))
+	for i := 0; i < len(klines); i++ {
+		reLines[i] = klines[len(klines)-1-i] // 最新 -> 最前
+	}
+	return reLines, nil
+}
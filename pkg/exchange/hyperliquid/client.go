@@ -0,0 +1,183 @@
+package hyperliquid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	MainnetBaseURL = "https://api.hyperliquid.xyz"
+	TestnetBaseURL = "https://api.hyperliquid-testnet.xyz"
+)
+
+// APIError /exchange 返回 status=err 或单个订单返回 error
+type APIError struct {
+	Status int
+	Msg    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("hyperliquid error: status=%d msg=%s", e.Status, e.Msg)
+}
+
+// RateLimited 按地址或 IP 限频时返回 429
+func (e *APIError) RateLimited() bool {
+	return e.Status == http.StatusTooManyRequests
+}
+
+// Temporary 服务端错误，可以重试
+func (e *APIError) Temporary() bool {
+	return e.Status >= http.StatusInternalServerError
+}
+
+// Option 修改默认的接口地址和 http 客户端，测试时指向本地服务
+type Option func(*options)
+
+type options struct {
+	baseURL    string
+	mainnet    bool
+	account    string
+	httpClient *http.Client
+}
+
+// WithTestnet 使用测试网，签名的 source 也随之改变
+func WithTestnet() Option {
+	return func(o *options) {
+		o.baseURL = TestnetBaseURL
+		o.mainnet = false
+	}
+}
+
+func WithBaseURL(u string) Option {
+	return func(o *options) { o.baseURL = strings.TrimRight(u, "/") }
+}
+
+// WithAccountAddress 使用 API 钱包签名时，查询仓位和余额需要主账户地址
+func WithAccountAddress(addr string) Option {
+	return func(o *options) { o.account = strings.ToLower(addr) }
+}
+
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) { o.httpClient = c }
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		baseURL:    MainnetBaseURL,
+		mainnet:    true,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// client /info 查询和 /exchange 签名请求
+type client struct {
+	baseURL string
+	http    *http.Client
+	signer  *signer
+	nonces  *nonceManager
+	now     func() time.Time
+}
+
+func newClient(o options, s *signer) *client {
+	c := &client{baseURL: o.baseURL, http: o.httpClient, signer: s, now: time.Now}
+	c.nonces = &nonceManager{now: func() time.Time { return c.now() }}
+	return c
+}
+
+// info 公共查询，req 为 {"type": ...}
+func (c *client) info(ctx context.Context, req any, out any) error {
+	return c.post(ctx, "/info", req, out)
+}
+
+// exchangeResponse {"status":"ok","response":{"type":"order","data":{...}}}，失败时 response 为错误信息
+type exchangeResponse struct {
+	Status   string          `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+type responseBody struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// exchange 签名并提交交易动作，out 为 response.data
+func (c *client) exchange(ctx context.Context, action any, out any) error {
+	if c.signer == nil {
+		return errors.New("hyperliquid: private key not configured")
+	}
+	nonce := c.nonces.next()
+	sig, err := c.signer.signL1Action(action, nonce)
+	if err != nil {
+		return err
+	}
+	payload := map[string]any{
+		"action":       action,
+		"nonce":        nonce,
+		"signature":    sig,
+		"vaultAddress": nil,
+	}
+	var resp exchangeResponse
+	if err := c.post(ctx, "/exchange", payload, &resp); err != nil {
+		return err
+	}
+	if resp.Status != "ok" {
+		var msg string
+		if json.Unmarshal(resp.Response, &msg) != nil {
+			msg = string(resp.Response)
+		}
+		return &APIError{Status: http.StatusOK, Msg: msg}
+	}
+	if out == nil {
+		return nil
+	}
+	var body responseBody
+	if err := json.Unmarshal(resp.Response, &body); err != nil {
+		return fmt.Errorf("decode exchange response: %w", err)
+	}
+	if len(body.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body.Data, out)
+}
+
+func (c *client) post(ctx context.Context, path string, in any, out any) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return &APIError{Status: resp.StatusCode, Msg: string(body)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode %s response: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,986 @@
+package hyperliquid
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	"edgeflow/pkg/account"
+	"edgeflow/pkg/hype/types"
+	"edgeflow/pkg/utils"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nntaoli-project/goex/v2/model"
+)
+
+// 与 goex 一致，交易对不存在时不再重试
+var ErrSymbolNotFound = errors.New("not found currency pair")
+
+const (
+	// 默认杠杆，与 OkxSwap 一致，超过币种的最大杠杆时取最大杠杆
+	defaultLeverage = 20
+	// hyperliquid 没有市价单，市价单按中间价加滑点挂 IOC 限价单，与官方 SDK 一致
+	marketSlippage = 0.05
+	// 止盈止损触发后按市价成交，限价只作为滑点保护
+	triggerSlippage = 0.1
+	// 永续合约价格的小数位数上限为 6 - szDecimals
+	maxPerpDecimals = 6
+)
+
+// assetInfo 永续合约的资产编号和精度，编号为 meta.universe 中的下标
+type assetInfo struct {
+	name        string
+	index       int
+	szDecimals  int
+	maxLeverage int
+	onlyIso     bool
+}
+
+// Hyperliquid 永续合约，单向持仓，数量单位为币，计价和保证金为 USDC。
+// 下单、撤单和改杠杆需要私钥签名，查询使用主账户地址
+type Hyperliquid struct {
+	c       *client
+	account string
+
+	mu     sync.Mutex
+	assets map[string]assetInfo // BTC -> 资产信息
+}
+
+// NewHyperliquid privateKey 可以是主钱包或 API 钱包的私钥，API 钱包需要通过 WithAccountAddress 指定主账户
+func NewHyperliquid(privateKey string, opts ...Option) (*Hyperliquid, error) {
+	o := newOptions(opts)
+	var s *signer
+	if privateKey != "" {
+		var err error
+		if s, err = newSigner(privateKey, o.mainnet); err != nil {
+			return nil, err
+		}
+		if o.account == "" {
+			o.account = s.address
+		}
+	}
+	return &Hyperliquid{c: newClient(o, s), account: o.account}, nil
+}
+
+// ToCoin "BTC/USDT"、"BTC-USDT-SWAP"、"BTCUSDC" 统一转换为 hyperliquid 的币名 BTC
+func ToCoin(symbol string) string {
+	s := strings.ToUpper(strings.TrimSpace(symbol))
+	s = strings.TrimSuffix(s, "-SWAP")
+	s = strings.ReplaceAll(s, "-", "/")
+	base, _, _ := strings.Cut(utils.FormatSymbol(s), "/")
+	return base
+}
+
+// asset 首次使用时加载全部永续合约，币名不区分大小写（如 kPEPE）
+func (h *Hyperliquid) asset(symbol string) (assetInfo, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.assets == nil {
+		var meta types.Universe
+		if err := h.c.info(context.Background(), map[string]string{"type": "meta"}, &meta); err != nil {
+			return assetInfo{}, err
+		}
+		assets := make(map[string]assetInfo, len(meta.Universe))
+		for i, u := range meta.Universe {
+			assets[strings.ToUpper(u.Name)] = assetInfo{
+				name:        u.Name,
+				index:       i,
+				szDecimals:  u.SzDecimals,
+				maxLeverage: u.MaxLeverage,
+				onlyIso:     u.OnlyIsolated,
+			}
+		}
+		h.assets = assets
+	}
+	a, ok := h.assets[ToCoin(symbol)]
+	if !ok {
+		return assetInfo{}, ErrSymbolNotFound
+	}
+	return a, nil
+}
+
+// GetLotRules 数量步长为 10^-szDecimals，价格按最大小数位数给出步长（另有 5 位有效数字的限制，下单时处理）
+func (h *Hyperliquid) GetLotRules(symbol string) (*model2.LotRules, error) {
+	a, err := h.asset(symbol)
+	if err != nil {
+		return nil, err
+	}
+	lot := math.Pow10(-a.szDecimals)
+	return &model2.LotRules{
+		LotSize:  lot,
+		TickSize: math.Pow10(-(maxPerpDecimals - a.szDecimals)),
+		MinQty:   lot,
+	}, nil
+}
+
+// 获取最新价格，使用中间价
+func (h *Hyperliquid) GetLastPrice(symbol string) (float64, error) {
+	a, err := h.asset(symbol)
+	if err != nil {
+		return 0, err
+	}
+	var mids map[string]string
+	if err := h.c.info(context.Background(), map[string]string{"type": "allMids"}, &mids); err != nil {
+		return 0, err
+	}
+	price := parseFloat(mids[a.name])
+	if price <= 0 {
+		return 0, errors.New("failed to get ticker")
+	}
+	return price, nil
+}
+
+// 获取买一卖一价
+func (h *Hyperliquid) GetBestQuote(symbol string) (bid, ask float64, err error) {
+	a, err := h.asset(symbol)
+	if err != nil {
+		return 0, 0, err
+	}
+	var book struct {
+		Levels [][]struct {
+			Px string `json:"px"`
+			Sz string `json:"sz"`
+		} `json:"levels"`
+	}
+	req := map[string]string{"type": "l2Book", "coin": a.name}
+	if err = h.c.info(context.Background(), req, &book); err != nil {
+		return 0, 0, err
+	}
+	if len(book.Levels) == 2 && len(book.Levels[0]) > 0 && len(book.Levels[1]) > 0 {
+		bid, ask = parseFloat(book.Levels[0][0].Px), parseFloat(book.Levels[1][0].Px)
+	}
+	if bid <= 0 || ask <= 0 {
+		return 0, 0, fmt.Errorf("invalid quote for %s: %v/%v", symbol, bid, ask)
+	}
+	return bid, ask, nil
+}
+
+// GetKlineRecords hyperliquid 返回的顺序是从旧到新，由 HyperliquidExchange 转换为从新到旧
+func (h *Hyperliquid) GetKlineRecords(symbol string, period model.KlinePeriod, size int, start, end int64, includeUnclosed bool) ([]model2.Kline, error) {
+	a, err := h.asset(symbol)
+	if err != nil {
+		return nil, err
+	}
+	interval, tf := klineInterval(period)
+	now := h.c.now()
+	if end <= 0 {
+		end = now.UnixMilli()
+	}
+	if start <= 0 {
+		n := size
+		if n <= 0 {
+			n = 100
+		}
+		start = end - int64(n+1)*tf.Milliseconds()
+	}
+	req := map[string]any{
+		"type": "candleSnapshot",
+		"req": map[string]any{
+			"coin":      a.name,
+			"interval":  interval,
+			"startTime": start,
+			"endTime":   end,
+		},
+	}
+	var rows []struct {
+		OpenTime  int64  `json:"t"`
+		CloseTime int64  `json:"T"`
+		Open      string `json:"o"`
+		Close     string `json:"c"`
+		High      string `json:"h"`
+		Low       string `json:"l"`
+		Vol       string `json:"v"`
+	}
+	if err := h.c.info(context.Background(), req, &rows); err != nil {
+		return nil, err
+	}
+	items := make([]model2.Kline, 0, len(rows))
+	for _, r := range rows {
+		// 过滤未收盘的k线
+		if !includeUnclosed && now.UnixMilli() <= r.CloseTime {
+			continue
+		}
+		k := model2.Kline{
+			Timestamp: time.UnixMilli(r.OpenTime),
+			Open:      parseFloat(r.Open),
+			High:      parseFloat(r.High),
+			Low:       parseFloat(r.Low),
+			Close:     parseFloat(r.Close),
+			Vol:       parseFloat(r.Vol),
+		}
+		// 没有成交额字段，按收盘价估算
+		k.VolCcy = k.Vol * k.Close
+		items = append(items, k)
+	}
+	if size > 0 && len(items) > size {
+		items = items[len(items)-size:]
+	}
+	return items, nil
+}
+
+// GetAccount 合约账户的余额，保证金为 USDC，传 USDT 时同样返回
+func (h *Hyperliquid) GetAccount(ctx context.Context, coin string) (*account.Account, error) {
+	switch strings.ToUpper(coin) {
+	case "USDC", "USDT", "USD":
+	default:
+		return nil, errors.New("account info not found for coin " + coin)
+	}
+	state, err := h.clearinghouseState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &account.Account{
+		Currency:  "USDC",
+		Total:     parseFloat(state.MarginSummary.AccountValue),
+		Available: parseFloat(state.Withdrawable),
+		Frozen:    parseFloat(state.MarginSummary.TotalMarginUsed),
+	}, nil
+}
+
+func (h *Hyperliquid) clearinghouseState(ctx context.Context) (*types.MarginData, error) {
+	if h.account == "" {
+		return nil, errors.New("hyperliquid: account address not configured")
+	}
+	var state types.MarginData
+	req := map[string]string{"type": "clearinghouseState", "user": h.account}
+	if err := h.c.info(ctx, req, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// 下单购买，数量单位为币。带止盈止损时与开仓单一起提交（normalTpsl），开仓单成交后生效，
+// 止盈止损失败不影响开仓结果，错误信息写入 Message
+func (h *Hyperliquid) PlaceOrder(ctx context.Context, order *model2.Order) (*model2.OrderResponse, error) {
+	a, err := h.asset(order.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	isBuy, err := orderSide(order.Side)
+	if err != nil {
+		return nil, err
+	}
+
+	cloid := toCloid(order.ClientOrderId)
+	if cloid != "" {
+		// 重试时先按 cloid 查询，订单已经存在则直接返回
+		if existing, err := h.queryOrder(ctx, cloid); err == nil {
+			return &model2.OrderResponse{
+				OrderId:   strconv.FormatInt(existing.Order.Oid, 10),
+				Status:    int(orderStatus(existing)),
+				Duplicate: true,
+			}, nil
+		} else if !errors.Is(err, errOrderNotFound) {
+			return nil, err
+		}
+	}
+
+	leverage := order.Leverage
+	if leverage <= 0 {
+		leverage = defaultLeverage
+	}
+	if a.maxLeverage > 0 && leverage > a.maxLeverage {
+		leverage = a.maxLeverage
+	}
+	isCross := order.MgnMode != model2.OrderMgnModeIsolated && !a.onlyIso
+	if err := h.updateLeverage(ctx, a, leverage, isCross); err != nil {
+		return nil, err
+	}
+
+	price, tif := order.Price, "Gtc"
+	switch order.OrderType {
+	case model2.Limit:
+	case model2.Market:
+		mid, err := h.GetLastPrice(order.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		price, tif = slippagePrice(mid, isBuy, marketSlippage), "Ioc"
+	default:
+		return nil, fmt.Errorf("unsupported order type: %s", order.OrderType)
+	}
+	if price <= 0 {
+		return nil, fmt.Errorf("invalid price: %v", price)
+	}
+
+	if order.QuantityPct > 0 {
+		acc, err := h.GetAccount(ctx, "USDC")
+		if err != nil {
+			return nil, err
+		}
+		order.Quantity = acc.Available * order.QuantityPct * 0.98 * float64(leverage) / price
+	}
+	size := floorSize(order.Quantity, a.szDecimals)
+	if size <= 0 {
+		return nil, fmt.Errorf("您的账户余额不足，Quantity:%v 不足以开仓", order.Quantity)
+	}
+	order.Quantity = size
+
+	action := orderAction{
+		Type: "order",
+		Orders: []orderWire{{
+			Asset:      a.index,
+			IsBuy:      isBuy,
+			LimitPx:    floatToWire(roundPrice(price, a.szDecimals)),
+			Size:       floatToWire(size),
+			ReduceOnly: false,
+			OrderType:  orderTypeWire{Limit: &limitWire{Tif: tif}},
+			Cloid:      cloid,
+		}},
+		Grouping: "na",
+	}
+	if order.SLPrice > 0 {
+		action.Orders = append(action.Orders, triggerOrder(a, !isBuy, size, order.SLPrice, "sl", derivedCloid(cloid, "sl")))
+	}
+	if order.TPPrice > 0 {
+		action.Orders = append(action.Orders, triggerOrder(a, !isBuy, size, order.TPPrice, "tp", derivedCloid(cloid, "tp")))
+	}
+	if len(action.Orders) > 1 {
+		action.Grouping = "normalTpsl"
+	}
+
+	statuses, err := h.placeOrders(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := statuses[0].response()
+	if err != nil {
+		return nil, err
+	}
+	var msgs []string
+	for _, st := range statuses[1:] {
+		if st.Error != "" {
+			msgs = append(msgs, st.Error)
+		}
+	}
+	if len(msgs) > 0 {
+		resp.Message = "止盈止损下单失败: " + strings.Join(msgs, "; ")
+	}
+	return resp, nil
+}
+
+// updateLeverage 设置杠杆和保证金模式，hyperliquid 按币种设置，不区分多空
+func (h *Hyperliquid) updateLeverage(ctx context.Context, a assetInfo, leverage int, isCross bool) error {
+	return h.c.exchange(ctx, updateLeverageAction{
+		Type:     "updateLeverage",
+		Asset:    a.index,
+		IsCross:  isCross,
+		Leverage: leverage,
+	}, nil)
+}
+
+// SetLeverage 设置合约杠杆，posSide 没有作用
+func (h *Hyperliquid) SetLeverage(symbol string, leverage int, marginMode, posSide string) error {
+	a, err := h.asset(symbol)
+	if err != nil {
+		return err
+	}
+	isCross := marginMode != model2.OrderMgnModeIsolated && !a.onlyIso
+	return h.updateLeverage(context.Background(), a, leverage, isCross)
+}
+
+// placeOrders 提交下单动作，返回与 orders 一一对应的结果
+func (h *Hyperliquid) placeOrders(ctx context.Context, action orderAction) ([]orderResult, error) {
+	var data struct {
+		Statuses []orderResult `json:"statuses"`
+	}
+	if err := h.c.exchange(ctx, action, &data); err != nil {
+		return nil, err
+	}
+	if len(data.Statuses) == 0 {
+		return nil, errors.New("hyperliquid: empty order statuses")
+	}
+	return data.Statuses, nil
+}
+
+// 平仓函数，市价（IOC）只减仓
+func (h *Hyperliquid) ClosePosition(symbol string, dir string, quantity float64, tdMode string) (*model2.OrderResponse, error) {
+	var isBuy bool
+	switch dir {
+	case "long":
+		isBuy = false
+	case "short":
+		isBuy = true
+	default:
+		return nil, fmt.Errorf("unknown side: %s", dir)
+	}
+	a, err := h.asset(symbol)
+	if err != nil {
+		return nil, err
+	}
+	mid, err := h.GetLastPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+	action := orderAction{
+		Type: "order",
+		Orders: []orderWire{{
+			Asset:      a.index,
+			IsBuy:      isBuy,
+			LimitPx:    floatToWire(roundPrice(slippagePrice(mid, isBuy, marketSlippage), a.szDecimals)),
+			Size:       floatToWire(roundSize(quantity, a.szDecimals)),
+			ReduceOnly: true,
+			OrderType:  orderTypeWire{Limit: &limitWire{Tif: "Ioc"}},
+		}},
+		Grouping: "na",
+	}
+	statuses, err := h.placeOrders(context.Background(), action)
+	if err != nil {
+		return nil, err
+	}
+	return statuses[0].response()
+}
+
+// 取消订单，orderID 为 oid 或 0x 开头的 cloid
+func (h *Hyperliquid) CancelOrder(orderID, symbol string) error {
+	a, err := h.asset(symbol)
+	if err != nil {
+		return err
+	}
+	var action any
+	if strings.HasPrefix(orderID, "0x") {
+		action = cancelByCloidAction{Type: "cancelByCloid", Cancels: []cancelByCloidWire{{Asset: a.index, Cloid: orderID}}}
+	} else {
+		oid, err := strconv.ParseInt(orderID, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid order id %s: %w", orderID, err)
+		}
+		action = cancelAction{Type: "cancel", Cancels: []cancelWire{{Asset: a.index, Oid: oid}}}
+	}
+	var data struct {
+		Statuses []json.RawMessage `json:"statuses"`
+	}
+	if err := h.c.exchange(context.Background(), action, &data); err != nil {
+		return err
+	}
+	for _, raw := range data.Statuses {
+		var st struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(raw, &st) == nil && st.Error != "" {
+			return &APIError{Status: http.StatusOK, Msg: st.Error}
+		}
+	}
+	return nil
+}
+
+var errOrderNotFound = errors.New("hyperliquid: order not found")
+
+// orderQuery orderStatus 查询结果中的订单
+type orderQuery struct {
+	Order struct {
+		Coin   string `json:"coin"`
+		Side   string `json:"side"`
+		Oid    int64  `json:"oid"`
+		Cloid  string `json:"cloid"`
+		Sz     string `json:"sz"`
+		OrigSz string `json:"origSz"`
+	} `json:"order"`
+	Status string `json:"status"`
+}
+
+// queryOrder 按 oid 或 cloid 查询订单，不存在时返回 errOrderNotFound
+func (h *Hyperliquid) queryOrder(ctx context.Context, orderID string) (*orderQuery, error) {
+	var oid any = orderID
+	if !strings.HasPrefix(orderID, "0x") {
+		n, err := strconv.ParseInt(orderID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid order id %s: %w", orderID, err)
+		}
+		oid = n
+	}
+	var resp struct {
+		Status string     `json:"status"`
+		Order  orderQuery `json:"order"`
+	}
+	req := map[string]any{"type": "orderStatus", "user": h.account, "oid": oid}
+	if err := h.c.info(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "order" {
+		return nil, errOrderNotFound
+	}
+	return &resp.Order, nil
+}
+
+// 获取订单状态，成交均价和手续费按订单的成交明细汇总
+func (h *Hyperliquid) GetOrderStatus(orderID string, symbol string) (*model2.OrderStatus, error) {
+	ctx := context.Background()
+	o, err := h.queryOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	origSz, sz := parseFloat(o.Order.OrigSz), parseFloat(o.Order.Sz)
+	st := &model2.OrderStatus{
+		OrderID:   strconv.FormatInt(o.Order.Oid, 10),
+		Status:    orderStatus(o).String(),
+		Filled:    origSz - sz,
+		Remaining: sz,
+	}
+	if st.Filled <= 0 {
+		return st, nil
+	}
+
+	var fills []struct {
+		Oid      int64  `json:"oid"`
+		Px       string `json:"px"`
+		Sz       string `json:"sz"`
+		Fee      string `json:"fee"`
+		FeeToken string `json:"feeToken"`
+	}
+	if err := h.c.info(ctx, map[string]string{"type": "userFills", "user": h.account}, &fills); err != nil {
+		return nil, err
+	}
+	var filled, notional float64
+	for _, f := range fills {
+		if f.Oid != o.Order.Oid {
+			continue
+		}
+		px, qty := parseFloat(f.Px), parseFloat(f.Sz)
+		filled += qty
+		notional += px * qty
+		st.Fee -= parseFloat(f.Fee)
+		if st.FeeCcy == "" {
+			st.FeeCcy = f.FeeToken
+		}
+	}
+	if filled > 0 {
+		st.AvgPrice = notional / filled
+	}
+	return st, nil
+}
+
+// openOrders 当前委托，包括止盈止损触发单
+func (h *Hyperliquid) openOrders(ctx context.Context) ([]types.UserOpenOrder, error) {
+	var orders []types.UserOpenOrder
+	req := map[string]string{"type": "frontendOpenOrders", "user": h.account}
+	if err := h.c.info(ctx, req, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// 查询是否有持仓，单向持仓只会返回多或空其中一个。
+// AlgoId 为该仓位止损单（没有时为止盈单）的 cloid 或 oid，用于 AmendAlgoOrder
+func (h *Hyperliquid) GetPosition(symbol string) (long *model2.PositionInfo, short *model2.PositionInfo, err error) {
+	a, err := h.asset(symbol)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx := context.Background()
+	state, err := h.clearinghouseState(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetPosition error: %w", err)
+	}
+	for _, ap := range state.AssetPositions {
+		p := ap.Position
+		szi := parseFloat(p.Szi)
+		if p.Coin != a.name || szi == 0 {
+			continue
+		}
+		dir := model2.OrderPosSide(model2.OrderPosSideLong)
+		if szi < 0 {
+			dir = model2.OrderPosSideShort
+		}
+		amount := math.Abs(szi)
+		markPx := parseFloat(p.PositionValue) / amount
+		item := &model2.PositionInfo{
+			Symbol:        symbol,
+			Dir:           dir,
+			Amount:        amount,
+			AvgPrice:      parseFloat(p.EntryPx),
+			MgnMode:       p.Leverage.Type,
+			LiqPx:         p.LiquidationPx,
+			UnrealizedPnl: p.UnrealizedPnl,
+			UplRatio:      p.ReturnOnEquity,
+			MarkPx:        strconv.FormatFloat(markPx, 'f', -1, 64),
+			Margin:        p.MarginUsed,
+			Lever:         strconv.Itoa(p.Leverage.Value),
+			NotionalUsd:   p.PositionValue,
+			Last:          markPx,
+		}
+
+		orders, ordErr := h.openOrders(ctx)
+		if ordErr != nil {
+			log.Printf("[Hyperliquid] 查询 %s 委托失败: %v", a.name, ordErr)
+		}
+		sl, tp := protectOrders(orders, a.name, dir == model2.OrderPosSideShort)
+		if sl != nil {
+			item.AlgoId = orderRef(sl)
+			item.SlTriggerPx = parseFloat(sl.TriggerPx)
+		} else if tp != nil {
+			item.AlgoId = orderRef(tp)
+		}
+
+		if dir == model2.OrderPosSideLong {
+			long = item
+		} else {
+			short = item
+		}
+	}
+	return long, short, nil
+}
+
+// AmendAlgoOrder 通过 batchModify 修改仓位的止损和止盈触发价，价格 <= 0 的一侧保持不变，
+// 对应的触发单不存在时按 algoId 所在订单的数量新挂一个。修改保留原来的 cloid，AlgoId 不变
+func (h *Hyperliquid) AmendAlgoOrder(instId, algoId string, newSlTriggerPx, newTpTriggerPx float64) ([]byte, error) {
+	a, err := h.asset(instId)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	orders, err := h.openOrders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ref *types.UserOpenOrder
+	for i := range orders {
+		if orders[i].Coin == a.name && orders[i].IsTrigger && orderRef(&orders[i]) == algoId {
+			ref = &orders[i]
+			break
+		}
+	}
+	if ref == nil {
+		return nil, fmt.Errorf("algoId %s 对应的条件单不存在", algoId)
+	}
+	// 平仓方向的买卖，B 为买入（空仓的止损）
+	isBuy := ref.Side == "B"
+	sl, tp := protectOrders(orders, a.name, isBuy)
+
+	var modifies []modifyWire
+	var placed []orderWire
+	legs := []struct {
+		px   float64
+		tpsl string
+		cur  *types.UserOpenOrder
+	}{{newSlTriggerPx, "sl", sl}, {newTpTriggerPx, "tp", tp}}
+	for _, leg := range legs {
+		if leg.px <= 0 {
+			continue
+		}
+		if leg.cur == nil {
+			placed = append(placed, triggerOrder(a, isBuy, parseFloat(ref.Sz), leg.px, leg.tpsl, derivedCloid(ref.Cloid, leg.tpsl)))
+			continue
+		}
+		var oid any = int64(leg.cur.Oid)
+		if leg.cur.Cloid != "" {
+			oid = leg.cur.Cloid
+		}
+		modifies = append(modifies, modifyWire{
+			Oid:   oid,
+			Order: triggerOrder(a, isBuy, parseFloat(leg.cur.Sz), leg.px, leg.tpsl, leg.cur.Cloid),
+		})
+	}
+
+	var results []orderResult
+	if len(modifies) > 0 {
+		var data struct {
+			Statuses []orderResult `json:"statuses"`
+		}
+		if err := h.c.exchange(ctx, batchModifyAction{Type: "batchModify", Modifies: modifies}, &data); err != nil {
+			return nil, err
+		}
+		results = append(results, data.Statuses...)
+	}
+	if len(placed) > 0 {
+		statuses, err := h.placeOrders(ctx, orderAction{Type: "order", Orders: placed, Grouping: "na"})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, statuses...)
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			return nil, &APIError{Status: http.StatusOK, Msg: r.Error}
+		}
+	}
+	return json.Marshal(results)
+}
+
+// protectOrders 仓位的止损和止盈触发单，isBuy 为平仓方向（空仓为买入）
+func protectOrders(orders []types.UserOpenOrder, coin string, isBuy bool) (sl, tp *types.UserOpenOrder) {
+	side := "A"
+	if isBuy {
+		side = "B"
+	}
+	for i := range orders {
+		o := &orders[i]
+		if o.Coin != coin || !o.IsTrigger || !o.ReduceOnly || o.Side != side {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(o.OrderType, "Stop") && sl == nil:
+			sl = o
+		case strings.HasPrefix(o.OrderType, "Take Profit") && tp == nil:
+			tp = o
+		}
+	}
+	return sl, tp
+}
+
+// orderRef 有 cloid 时使用 cloid，修改订单后保持不变
+func orderRef(o *types.UserOpenOrder) string {
+	if o.Cloid != "" {
+		return o.Cloid
+	}
+	return strconv.Itoa(o.Oid)
+}
+
+// triggerOrder 止盈止损触发单，触发后市价只减仓
+func triggerOrder(a assetInfo, isBuy bool, size, triggerPx float64, tpsl, cloid string) orderWire {
+	px := roundPrice(triggerPx, a.szDecimals)
+	return orderWire{
+		Asset:      a.index,
+		IsBuy:      isBuy,
+		LimitPx:    floatToWire(roundPrice(slippagePrice(px, isBuy, triggerSlippage), a.szDecimals)),
+		Size:       floatToWire(roundSize(size, a.szDecimals)),
+		ReduceOnly: true,
+		OrderType: orderTypeWire{Trigger: &triggerWire{
+			IsMarket:  true,
+			TriggerPx: floatToWire(px),
+			Tpsl:      tpsl,
+		}},
+		Cloid: cloid,
+	}
+}
+
+// ---- 请求结构，msgpack 编码的字段顺序与官方 SDK 一致，不能调整 ----
+
+type orderAction struct {
+	Type     string      `msgpack:"type" json:"type"`
+	Orders   []orderWire `msgpack:"orders" json:"orders"`
+	Grouping string      `msgpack:"grouping" json:"grouping"`
+}
+
+type orderWire struct {
+	Asset      int           `msgpack:"a" json:"a"`
+	IsBuy      bool          `msgpack:"b" json:"b"`
+	LimitPx    string        `msgpack:"p" json:"p"`
+	Size       string        `msgpack:"s" json:"s"`
+	ReduceOnly bool          `msgpack:"r" json:"r"`
+	OrderType  orderTypeWire `msgpack:"t" json:"t"`
+	Cloid      string        `msgpack:"c,omitempty" json:"c,omitempty"`
+}
+
+type orderTypeWire struct {
+	Limit   *limitWire   `msgpack:"limit,omitempty" json:"limit,omitempty"`
+	Trigger *triggerWire `msgpack:"trigger,omitempty" json:"trigger,omitempty"`
+}
+
+type limitWire struct {
+	Tif string `msgpack:"tif" json:"tif"`
+}
+
+type triggerWire struct {
+	IsMarket  bool   `msgpack:"isMarket" json:"isMarket"`
+	TriggerPx string `msgpack:"triggerPx" json:"triggerPx"`
+	Tpsl      string `msgpack:"tpsl" json:"tpsl"`
+}
+
+type cancelAction struct {
+	Type    string       `msgpack:"type" json:"type"`
+	Cancels []cancelWire `msgpack:"cancels" json:"cancels"`
+}
+
+type cancelWire struct {
+	Asset int   `msgpack:"a" json:"a"`
+	Oid   int64 `msgpack:"o" json:"o"`
+}
+
+type cancelByCloidAction struct {
+	Type    string              `msgpack:"type" json:"type"`
+	Cancels []cancelByCloidWire `msgpack:"cancels" json:"cancels"`
+}
+
+type cancelByCloidWire struct {
+	Asset int    `msgpack:"asset" json:"asset"`
+	Cloid string `msgpack:"cloid" json:"cloid"`
+}
+
+type updateLeverageAction struct {
+	Type     string `msgpack:"type" json:"type"`
+	Asset    int    `msgpack:"asset" json:"asset"`
+	IsCross  bool   `msgpack:"isCross" json:"isCross"`
+	Leverage int    `msgpack:"leverage" json:"leverage"`
+}
+
+type batchModifyAction struct {
+	Type     string       `msgpack:"type" json:"type"`
+	Modifies []modifyWire `msgpack:"modifies" json:"modifies"`
+}
+
+// modifyWire oid 为订单号或 cloid
+type modifyWire struct {
+	Oid   any       `msgpack:"oid" json:"oid"`
+	Order orderWire `msgpack:"order" json:"order"`
+}
+
+// orderResult 单个订单的结果：{"resting":{"oid":1}}、{"filled":{...}}、{"error":"..."}，
+// 止盈止损单在开仓单成交前为字符串 "waitingForFill"
+type orderResult struct {
+	Resting *struct {
+		Oid int64 `json:"oid"`
+	} `json:"resting,omitempty"`
+	Filled *struct {
+		Oid     int64  `json:"oid"`
+		TotalSz string `json:"totalSz"`
+		AvgPx   string `json:"avgPx"`
+	} `json:"filled,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Waiting string `json:"-"`
+}
+
+func (r *orderResult) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		r.Waiting = s
+		return nil
+	}
+	type plain orderResult
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+func (r orderResult) MarshalJSON() ([]byte, error) {
+	if r.Waiting != "" {
+		return json.Marshal(r.Waiting)
+	}
+	type plain orderResult
+	return json.Marshal(plain(r))
+}
+
+func (r *orderResult) response() (*model2.OrderResponse, error) {
+	switch {
+	case r.Error != "":
+		return nil, &APIError{Status: http.StatusOK, Msg: r.Error}
+	case r.Filled != nil:
+		return &model2.OrderResponse{OrderId: strconv.FormatInt(r.Filled.Oid, 10), Status: int(model.OrderStatus_Finished)}, nil
+	case r.Resting != nil:
+		return &model2.OrderResponse{OrderId: strconv.FormatInt(r.Resting.Oid, 10), Status: int(model.OrderStatus_Pending)}, nil
+	}
+	return nil, fmt.Errorf("unexpected order status: %s", r.Waiting)
+}
+
+// toCloid 客户端订单ID转换为 16 字节的 cloid。NewClientOrderId 生成的 "ef" + 30 位十六进制本身就是 32 位十六进制
+func toCloid(clientOrderId string) string {
+	if clientOrderId == "" {
+		return ""
+	}
+	if len(clientOrderId) == 32 {
+		if _, err := hex.DecodeString(clientOrderId); err == nil {
+			return "0x" + strings.ToLower(clientOrderId)
+		}
+	}
+	return "0x" + hex.EncodeToString(keccak256([]byte(clientOrderId))[:16])
+}
+
+// derivedCloid 开仓单的止盈止损单使用确定的 cloid，重试时不会重复挂单
+func derivedCloid(cloid, suffix string) string {
+	if cloid == "" {
+		return ""
+	}
+	return toCloid(cloid + ":" + suffix)
+}
+
+func orderStatus(o *orderQuery) model.OrderStatus {
+	switch o.Status {
+	case "open", "triggered":
+		if parseFloat(o.Order.Sz) < parseFloat(o.Order.OrigSz) {
+			return model.OrderStatus_PartFinished
+		}
+		return model.OrderStatus_Pending
+	case "filled":
+		return model.OrderStatus_Finished
+	}
+	// canceled、marginCanceled、rejected 等
+	if strings.HasSuffix(o.Status, "anceled") || strings.HasSuffix(o.Status, "ejected") {
+		return model.OrderStatus_Canceled
+	}
+	return model.OrderStatus(-1)
+}
+
+// orderSide 开仓方向，买入为 true
+func orderSide(side model2.OrderSide) (bool, error) {
+	switch strings.ToLower(string(side)) {
+	case "buy":
+		return true, nil
+	case "sell":
+		return false, nil
+	}
+	return false, errors.New("invalid order side")
+}
+
+func klineInterval(period model.KlinePeriod) (string, time.Duration) {
+	switch period {
+	case model.Kline_1min:
+		return "1m", time.Minute
+	case model.Kline_5min:
+		return "5m", 5 * time.Minute
+	case model.Kline_15min:
+		return "15m", 15 * time.Minute
+	case model.Kline_30min:
+		return "30m", 30 * time.Minute
+	case model.Kline_60min, model.Kline_1h:
+		return "1h", time.Hour
+	case model.Kline_4h:
+		return "4h", 4 * time.Hour
+	case model.Kline_6h:
+		// 没有 6h，使用 8h
+		return "8h", 8 * time.Hour
+	case model.Kline_1day:
+		return "1d", 24 * time.Hour
+	case model.Kline_1week:
+		return "1w", 7 * 24 * time.Hour
+	}
+	return string(period), time.Hour
+}
+
+// ---- 工具函数 ----
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// floatToWire 与官方 SDK 一致，保留 8 位小数后去掉末尾的 0
+func floatToWire(x float64) string {
+	s := strconv.FormatFloat(x, 'f', 8, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+// roundPrice 价格最多 5 位有效数字，且小数位数不超过 6 - szDecimals
+func roundPrice(px float64, szDecimals int) float64 {
+	if px <= 0 {
+		return 0
+	}
+	p, _ := strconv.ParseFloat(strconv.FormatFloat(px, 'g', 5, 64), 64)
+	f := math.Pow10(maxPerpDecimals - szDecimals)
+	return math.Round(p*f) / f
+}
+
+func slippagePrice(px float64, isBuy bool, slippage float64) float64 {
+	if isBuy {
+		return px * (1 + slippage)
+	}
+	return px * (1 - slippage)
+}
+
+func floorSize(sz float64, szDecimals int) float64 {
+	f := math.Pow10(szDecimals)
+	return math.Floor(sz*f+1e-9) / f
+}
+
+func roundSize(sz float64, szDecimals int) float64 {
+	f := math.Pow10(szDecimals)
+	return math.Round(sz*f) / f
+}
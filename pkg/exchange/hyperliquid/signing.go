@@ -0,0 +1,123 @@
+package hyperliquid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/vmihailenco/msgpack/v5"
+	"golang.org/x/crypto/sha3"
+)
+
+// Signature 交易动作的签名，r、s 与官方 SDK 一致为去掉前导 0 的十六进制
+type Signature struct {
+	R string `json:"r"`
+	S string `json:"s"`
+	V int    `json:"v"`
+}
+
+// signer 使用私钥（主钱包或 API 钱包）对 L1 动作做 EIP-712 签名
+type signer struct {
+	key     *secp256k1.PrivateKey
+	address string // 私钥对应的地址，小写
+	mainnet bool
+}
+
+func newSigner(privateKey string, mainnet bool) (*signer, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(privateKey), "0x"))
+	if err != nil || len(raw) != 32 {
+		return nil, errors.New("hyperliquid: invalid private key")
+	}
+	key := secp256k1.PrivKeyFromBytes(raw)
+	return &signer{key: key, address: pubKeyAddress(key.PubKey()), mainnet: mainnet}, nil
+}
+
+// pubKeyAddress 以太坊地址：未压缩公钥去掉首字节后 keccak256 的后 20 字节
+func pubKeyAddress(pub *secp256k1.PublicKey) string {
+	sum := keccak256(pub.SerializeUncompressed()[1:])
+	return "0x" + hex.EncodeToString(sum[12:])
+}
+
+// signL1Action 动作按 msgpack 编码后拼上 nonce 和 vault 标识得到 connectionId，
+// 再对 Agent{source, connectionId} 做 EIP-712 签名
+func (s *signer) signL1Action(action any, nonce int64) (Signature, error) {
+	hash, err := actionHash(action, nonce)
+	if err != nil {
+		return Signature{}, err
+	}
+	source := "b"
+	if s.mainnet {
+		source = "a"
+	}
+	digest := keccak256([]byte{0x19, 0x01}, domainSeparator(), agentHash(source, hash))
+
+	// [v, r, s]，未压缩公钥时 v 为 27 或 28
+	sig := ecdsa.SignCompact(s.key, digest, false)
+	return Signature{
+		R: "0x" + new(big.Int).SetBytes(sig[1:33]).Text(16),
+		S: "0x" + new(big.Int).SetBytes(sig[33:]).Text(16),
+		V: int(sig[0]),
+	}, nil
+}
+
+// actionHash keccak256(msgpack(action) + nonce(8 字节大端) + 0x00)，不使用 vault 地址
+func actionHash(action any, nonce int64) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	// 与 python msgpack 一致，整数按最短格式编码
+	enc.UseCompactInts(true)
+	if err := enc.Encode(action); err != nil {
+		return nil, err
+	}
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(nonce))
+	buf.Write(n[:])
+	buf.WriteByte(0)
+	return keccak256(buf.Bytes()), nil
+}
+
+// domainSeparator EIP712Domain{name: "Exchange", version: "1", chainId: 1337, verifyingContract: 0x0}
+func domainSeparator() []byte {
+	typeHash := keccak256([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	chainId := make([]byte, 32)
+	big.NewInt(1337).FillBytes(chainId)
+	return keccak256(typeHash, keccak256([]byte("Exchange")), keccak256([]byte("1")), chainId, make([]byte, 32))
+}
+
+func agentHash(source string, connectionId []byte) []byte {
+	typeHash := keccak256([]byte("Agent(string source,bytes32 connectionId)"))
+	return keccak256(typeHash, keccak256([]byte(source)), connectionId)
+}
+
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// nonceManager 同一个签名地址的 nonce 必须唯一，使用毫秒时间戳并保证严格递增
+type nonceManager struct {
+	mu   sync.Mutex
+	last int64
+	now  func() time.Time
+}
+
+func (m *nonceManager) next() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.now().UnixMilli()
+	if n <= m.last {
+		n = m.last + 1
+	}
+	m.last = n
+	return n
+}
@@ -0,0 +1,105 @@
+package hyperliquid
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// 官方 python SDK 的测试私钥和签名结果
+const testPrivateKey = "0x0123456789012345678901234567890123456789012345678901234567890123"
+
+func TestSignL1Action(t *testing.T) {
+	type dummyAction struct {
+		Type string `msgpack:"type"`
+		Num  int64  `msgpack:"num"`
+	}
+	action := dummyAction{Type: "dummy", Num: 100000000000} // float_to_int_for_hashing(1000)
+
+	cases := []struct {
+		mainnet bool
+		want    Signature
+	}{
+		{true, Signature{
+			R: "0x53749d5b30552aeb2fca34b530185976545bb22d0b3ce6f62e31be961a59298",
+			S: "0x755c40ba9bf05223521753995abb2f73ab3229be8ec921f350cb447e384d8ed8",
+			V: 27,
+		}},
+		{false, Signature{
+			R: "0x542af61ef1f429707e3c76c5293c80d01f74ef853e34b76efffcb57e574f9510",
+			S: "0x17b8b32f086e8cdede991f1e2c529f5dd5297cbe8128500e00cbaf766204a613",
+			V: 28,
+		}},
+	}
+	for _, c := range cases {
+		s, err := newSigner(testPrivateKey, c.mainnet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.signL1Action(action, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("mainnet=%v signature = %+v, want %+v", c.mainnet, got, c.want)
+		}
+	}
+}
+
+// 下单动作的签名可以恢复出签名地址
+func TestSignOrderRecover(t *testing.T) {
+	s, err := newSigner(testPrivateKey, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.address != "0x14791697260e4c9a71f18484c9f997b308e59325" {
+		t.Fatalf("address = %s", s.address)
+	}
+	action := orderAction{
+		Type: "order",
+		Orders: []orderWire{{
+			Asset: 4, IsBuy: true, LimitPx: "1100", Size: "0.2", ReduceOnly: false,
+			OrderType: orderTypeWire{Limit: &limitWire{Tif: "Gtc"}},
+			Cloid:     "0x00000000000000000000000000000001",
+		}},
+		Grouping: "na",
+	}
+	nonce := int64(1700000000000)
+	sig, err := s.signL1Action(action, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, _ := actionHash(action, nonce)
+	digest := keccak256([]byte{0x19, 0x01}, domainSeparator(), agentHash("a", hash))
+	compact := make([]byte, 65)
+	compact[0] = byte(sig.V)
+	r, _ := new(big.Int).SetString(sig.R[2:], 16)
+	sv, _ := new(big.Int).SetString(sig.S[2:], 16)
+	r.FillBytes(compact[1:33])
+	sv.FillBytes(compact[33:])
+	pub, _, err := ecdsa.RecoverCompact(compact, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr := pubKeyAddress(pub); addr != s.address {
+		t.Errorf("recovered %s, want %s", addr, s.address)
+	}
+}
+
+func TestNonceManager(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	m := &nonceManager{now: func() time.Time { return now }}
+	first := m.next()
+	second := m.next()
+	if first != 1700000000000 || second != first+1 {
+		t.Errorf("nonces = %d, %d", first, second)
+	}
+	// 时钟回拨时仍然递增
+	now = now.Add(-time.Second)
+	if third := m.next(); third != second+1 {
+		t.Errorf("nonce after clock skew = %d", third)
+	}
+}
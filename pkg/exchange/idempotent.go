@@ -0,0 +1,132 @@
+package exchange
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	_ Exchange         = (*IdempotentExchange)(nil)
+	_ LotRulesProvider = (*IdempotentExchange)(nil)
+	_ QuoteProvider    = (*IdempotentExchange)(nil)
+)
+
+// ErrOrderInFlight 相同 ClientOrderId 的订单正在提交，结果未知，调用方稍后重试即可
+var ErrOrderInFlight = errors.New("相同 clOrdId 的订单正在提交")
+
+// OrderDedupeStore 记录 ClientOrderId 对应的订单，多个实例共享
+type OrderDedupeStore interface {
+	// Reserve 占用 clOrdId，已经被占用时 reserved 为 false，orderId 为已有订单（提交中为空）
+	Reserve(ctx context.Context, clOrdId string) (reserved bool, orderId string, err error)
+	// Commit 下单成功后记录订单ID
+	Commit(ctx context.Context, clOrdId, orderId string) error
+	// Release 下单失败后释放，允许重试
+	Release(ctx context.Context, clOrdId string) error
+}
+
+// IdempotentExchange 幂等下单：带 ClientOrderId 的订单先在 store 中占用，
+// 已经提交过的直接返回已有订单并标记 Duplicate，其它接口直接转发
+type IdempotentExchange struct {
+	Exchange
+	store OrderDedupeStore
+}
+
+func NewIdempotentExchange(ex Exchange, store OrderDedupeStore) *IdempotentExchange {
+	return &IdempotentExchange{Exchange: ex, store: store}
+}
+
+func (e *IdempotentExchange) PlaceOrder(ctx context.Context, order *model2.Order) (*model2.OrderResponse, error) {
+	if order.ClientOrderId == "" || e.store == nil {
+		return e.Exchange.PlaceOrder(ctx, order)
+	}
+	reserved, orderId, err := e.store.Reserve(ctx, order.ClientOrderId)
+	if err != nil {
+		// store 不可用时仍然下单，交易所会拒绝重复的 clOrdId
+		log.Printf("[IdempotentExchange] 占用 clOrdId %s 失败: %v", order.ClientOrderId, err)
+		return e.Exchange.PlaceOrder(ctx, order)
+	}
+	if !reserved {
+		if orderId == "" {
+			return nil, ErrOrderInFlight
+		}
+		log.Printf("[IdempotentExchange] %s %s 重复下单，返回已有订单 %s", order.Symbol, order.ClientOrderId, orderId)
+		return &model2.OrderResponse{OrderId: orderId, Duplicate: true}, nil
+	}
+
+	resp, err := e.Exchange.PlaceOrder(ctx, order)
+	if err != nil {
+		if rErr := e.store.Release(ctx, order.ClientOrderId); rErr != nil {
+			log.Printf("[IdempotentExchange] 释放 clOrdId %s 失败: %v", order.ClientOrderId, rErr)
+		}
+		return nil, err
+	}
+	if resp != nil {
+		if cErr := e.store.Commit(ctx, order.ClientOrderId, resp.OrderId); cErr != nil {
+			log.Printf("[IdempotentExchange] 保存 clOrdId %s 失败: %v", order.ClientOrderId, cErr)
+		}
+	}
+	return resp, nil
+}
+
+// GetLotRules 内部交易所不支持时返回空的精度，与没有 LotRulesProvider 时一致
+func (e *IdempotentExchange) GetLotRules(symbol string, tradeType model2.OrderTradeType) (*model2.LotRules, error) {
+	if provider, ok := e.Exchange.(LotRulesProvider); ok {
+		return provider.GetLotRules(symbol, tradeType)
+	}
+	return &model2.LotRules{}, nil
+}
+
+// GetBestQuote 内部交易所不支持时买一卖一都使用最新价
+func (e *IdempotentExchange) GetBestQuote(symbol string, tradeType model2.OrderTradeType) (float64, float64, error) {
+	if provider, ok := e.Exchange.(QuoteProvider); ok {
+		return provider.GetBestQuote(symbol, tradeType)
+	}
+	last, err := e.Exchange.GetLastPrice(symbol, tradeType)
+	return last, last, err
+}
+
+// MemoryOrderDedupeStore 进程内的去重记录，用于模拟盘和测试
+type MemoryOrderDedupeStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu     sync.Mutex
+	orders map[string]dedupeEntry
+}
+
+type dedupeEntry struct {
+	orderId  string
+	expireAt time.Time
+}
+
+func NewMemoryOrderDedupeStore(ttl time.Duration) *MemoryOrderDedupeStore {
+	return &MemoryOrderDedupeStore{ttl: ttl, now: time.Now, orders: make(map[string]dedupeEntry)}
+}
+
+func (m *MemoryOrderDedupeStore) Reserve(ctx context.Context, clOrdId string) (bool, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.orders[clOrdId]; ok && m.now().Before(entry.expireAt) {
+		return false, entry.orderId, nil
+	}
+	m.orders[clOrdId] = dedupeEntry{expireAt: m.now().Add(m.ttl)}
+	return true, "", nil
+}
+
+func (m *MemoryOrderDedupeStore) Commit(ctx context.Context, clOrdId, orderId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.orders[clOrdId] = dedupeEntry{orderId: orderId, expireAt: m.now().Add(m.ttl)}
+	return nil
+}
+
+func (m *MemoryOrderDedupeStore) Release(ctx context.Context, clOrdId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.orders, clOrdId)
+	return nil
+}
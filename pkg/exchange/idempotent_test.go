@@ -0,0 +1,65 @@
+package exchange
+
+import (
+	"context"
+	"edgeflow/internal/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdempotentExchange(t *testing.T) {
+	ctx := context.Background()
+	paper, _ := newTestPaper(100)
+	store := NewMemoryOrderDedupeStore(time.Hour)
+	ex := NewIdempotentExchange(paper, store)
+
+	clOrdId := model.NewClientOrderId("id:42", "tv-level", "open")
+	if clOrdId != model.NewClientOrderId("id:42", "tv-level", "open") || len(clOrdId) != 32 {
+		t.Fatalf("clOrdId 应确定且不超过 32 位: %s", clOrdId)
+	}
+	if clOrdId == model.NewClientOrderId("id:42", "tv-level", "add") {
+		t.Fatalf("不同动作的 clOrdId 应不同")
+	}
+
+	// 下单失败后释放，允许重试
+	bad := &model.Order{Symbol: "BTC/USDT", Side: model.Buy, OrderType: model.Market, TradeType: model.OrderTradeSwap, ClientOrderId: clOrdId}
+	if _, err := ex.PlaceOrder(ctx, bad); err == nil {
+		t.Fatalf("数量为 0 应下单失败")
+	}
+
+	order := model.Order{Symbol: "BTC/USDT", Side: model.Buy, OrderType: model.Market, TradeType: model.OrderTradeSwap, Quantity: 1, ClientOrderId: clOrdId}
+	first := order
+	resp, err := ex.PlaceOrder(ctx, &first)
+	if err != nil || resp.Duplicate {
+		t.Fatalf("首次下单: %+v %v", resp, err)
+	}
+	retry := order
+	dup, err := ex.PlaceOrder(ctx, &retry)
+	if err != nil || !dup.Duplicate || dup.OrderId != resp.OrderId {
+		t.Fatalf("重复下单应返回已有订单: %+v %v", dup, err)
+	}
+	long, _, _ := paper.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	if long == nil || !almostEqual(long.Amount, 1) {
+		t.Fatalf("重复下单不应加仓: %+v", long)
+	}
+
+	// 其它请求正在提交
+	other := order
+	other.ClientOrderId = model.NewClientOrderId("id:43", "tv-level", "open")
+	store.Reserve(ctx, other.ClientOrderId)
+	if _, err := ex.PlaceOrder(ctx, &other); !errors.Is(err, ErrOrderInFlight) {
+		t.Fatalf("提交中应返回 ErrOrderInFlight: %v", err)
+	}
+
+	// 没有 store 时模拟盘与 okx 一样拒绝重复的 clOrdId
+	direct := order
+	direct.ClientOrderId = model.NewClientOrderId("id:44", "tv-level", "open")
+	a := direct
+	b := direct
+	r1, _ := paper.PlaceOrder(ctx, &a)
+	r2, err := paper.PlaceOrder(ctx, &b)
+	if err != nil || !r2.Duplicate || r2.OrderId != r1.OrderId {
+		t.Fatalf("模拟盘重复 clOrdId: %+v %v", r2, err)
+	}
+}
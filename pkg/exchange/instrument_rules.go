@@ -0,0 +1,337 @@
+package exchange
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	okx2 "edgeflow/pkg/exchange/okx"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	_ Exchange         = (*RulesExchange)(nil)
+	_ LotRulesProvider = (*RulesExchange)(nil)
+	_ QuoteProvider    = (*RulesExchange)(nil)
+	_ LotRulesProvider = (*InstrumentRegistry)(nil)
+)
+
+// 订单不符合交易对规则的原因，通过 errors.Is 判断，errors.As 取得 *OrderRuleError 查看具体数值
+var (
+	ErrUnknownInstrument   = errors.New("交易对不存在或已下线")
+	ErrInvalidPrice        = errors.New("价格无效")
+	ErrQuantityBelowMin    = errors.New("下单数量小于最小数量")
+	ErrQuantityAboveMax    = errors.New("市价单数量超过最大数量")
+	ErrLeverageAboveMax    = errors.New("杠杆倍数超过最大杠杆")
+	ErrRulesNotInitialized = errors.New("交易对规则尚未同步")
+)
+
+// OrderRuleError 订单校验失败，Field 为 model.Order 中的字段名
+type OrderRuleError struct {
+	Kind      error
+	Symbol    string
+	TradeType model2.OrderTradeType
+	Field     string
+	Value     float64
+	Limit     float64
+}
+
+func (e *OrderRuleError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s %s: %v", e.Symbol, e.TradeType, e.Kind)
+	}
+	return fmt.Sprintf("%s %s: %v, %s=%v 限制 %v", e.Symbol, e.TradeType, e.Kind, e.Field, e.Value, e.Limit)
+}
+
+func (e *OrderRuleError) Unwrap() error {
+	return e.Kind
+}
+
+// InstrumentRulesSource 拉取某个交易类型全部交易对的下单规则，key 为 BTC/USDT 格式
+type InstrumentRulesSource interface {
+	FetchRules(ctx context.Context, tradeType model2.OrderTradeType) (map[string]model2.LotRules, error)
+}
+
+// InstrumentRegistry 定期从交易所同步交易对的下单规则，下单前按此取整和校验。
+// 同步失败时保留上一次的结果
+type InstrumentRegistry struct {
+	src        InstrumentRulesSource
+	tradeTypes []model2.OrderTradeType
+
+	mu       sync.RWMutex
+	rules    map[model2.OrderTradeType]map[string]model2.LotRules
+	syncedAt time.Time
+	now      func() time.Time
+}
+
+// NewInstrumentRegistry tradeTypes 为空时同步现货和永续合约
+func NewInstrumentRegistry(src InstrumentRulesSource, tradeTypes ...model2.OrderTradeType) *InstrumentRegistry {
+	if len(tradeTypes) == 0 {
+		tradeTypes = []model2.OrderTradeType{model2.OrderTradeSpot, model2.OrderTradeSwap}
+	}
+	return &InstrumentRegistry{
+		src:        src,
+		tradeTypes: tradeTypes,
+		rules:      make(map[model2.OrderTradeType]map[string]model2.LotRules),
+		now:        time.Now,
+	}
+}
+
+// Run 先同步一次，之后按间隔同步，直到 ctx 结束
+func (r *InstrumentRegistry) Run(ctx context.Context, interval time.Duration) {
+	if err := r.Sync(ctx); err != nil {
+		log.Printf("[InstrumentRegistry] 同步交易对规则失败: %v", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Sync(ctx); err != nil {
+				log.Printf("[InstrumentRegistry] 同步交易对规则失败: %v", err)
+			}
+		}
+	}
+}
+
+// Sync 拉取全部交易类型的规则，单个交易类型失败不影响其它类型
+func (r *InstrumentRegistry) Sync(ctx context.Context) error {
+	var errs []error
+	for _, tradeType := range r.tradeTypes {
+		rules, err := r.src.FetchRules(ctx, tradeType)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tradeType, err))
+			continue
+		}
+		r.mu.Lock()
+		r.rules[tradeType] = rules
+		r.syncedAt = r.now()
+		r.mu.Unlock()
+		log.Printf("[InstrumentRegistry] 已同步 %d 个 %s 交易对规则", len(rules), tradeType)
+	}
+	return errors.Join(errs...)
+}
+
+// SyncedAt 最近一次同步成功的时间
+func (r *InstrumentRegistry) SyncedAt() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.syncedAt
+}
+
+// Rules 查询交易对规则，symbol 支持 BTC/USDT、BTC-USDT 和 BTC-USDT-SWAP
+func (r *InstrumentRegistry) Rules(symbol string, tradeType model2.OrderTradeType) (model2.LotRules, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	byType, ok := r.rules[tradeType]
+	if !ok {
+		return model2.LotRules{}, &OrderRuleError{Kind: ErrRulesNotInitialized, Symbol: symbol, TradeType: tradeType}
+	}
+	rules, ok := byType[instrumentKey(symbol)]
+	if !ok {
+		return model2.LotRules{}, &OrderRuleError{Kind: ErrUnknownInstrument, Symbol: symbol, TradeType: tradeType}
+	}
+	return rules, nil
+}
+
+func (r *InstrumentRegistry) GetLotRules(symbol string, tradeType model2.OrderTradeType) (*model2.LotRules, error) {
+	rules, err := r.Rules(symbol, tradeType)
+	if err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// NormalizeOrder 价格和止盈止损按价格步长取整，数量按数量步长向下取整，然后校验最小数量、
+// 市价单最大数量和杠杆。数量为 0 的按比例下单由交易所计算数量，不检查数量
+func NormalizeOrder(order *model2.Order, rules model2.LotRules) error {
+	fail := func(kind error, field string, value, limit float64) error {
+		return &OrderRuleError{Kind: kind, Symbol: order.Symbol, TradeType: order.TradeType, Field: field, Value: value, Limit: limit}
+	}
+
+	if order.Price < 0 || (order.OrderType == model2.Limit && order.Price == 0) {
+		return fail(ErrInvalidPrice, "Price", order.Price, 0)
+	}
+	if order.TPPrice < 0 {
+		return fail(ErrInvalidPrice, "TPPrice", order.TPPrice, 0)
+	}
+	if order.SLPrice < 0 {
+		return fail(ErrInvalidPrice, "SLPrice", order.SLPrice, 0)
+	}
+	order.Price = RoundToStep(order.Price, rules.TickSize)
+	order.TPPrice = RoundToStep(order.TPPrice, rules.TickSize)
+	order.SLPrice = RoundToStep(order.SLPrice, rules.TickSize)
+	if order.OrderType == model2.Limit && order.Price <= 0 {
+		// 价格小于半个步长
+		return fail(ErrInvalidPrice, "Price", order.Price, rules.TickSize)
+	}
+
+	// 现货市价买单的数量是 USDT 金额，不按币的数量步长处理
+	quoteQty := order.TradeType == model2.OrderTradeSpot && order.OrderType == model2.Market && order.Side == model2.Buy
+	if order.Quantity > 0 && !quoteQty {
+		qty := FloorToStep(order.Quantity, rules.LotSize)
+		if qty <= 0 || qty < rules.MinQty {
+			return fail(ErrQuantityBelowMin, "Quantity", order.Quantity, rules.MinQty)
+		}
+		if order.OrderType == model2.Market && rules.MaxMktQty > 0 && qty > rules.MaxMktQty {
+			return fail(ErrQuantityAboveMax, "Quantity", qty, rules.MaxMktQty)
+		}
+		order.Quantity = qty
+	}
+
+	// 现货不使用杠杆
+	if order.TradeType != model2.OrderTradeSpot && rules.MaxLeverage > 0 && order.Leverage > rules.MaxLeverage {
+		return fail(ErrLeverageAboveMax, "Leverage", float64(order.Leverage), float64(rules.MaxLeverage))
+	}
+	return nil
+}
+
+// RoundToStep 价格四舍五入到步长，按步长的小数位数格式化，避免 0.1*3 这类浮点误差传给交易所。
+// 仓位计算和下单校验共用，保证两边取整结果一致
+func RoundToStep(val, step float64) float64 {
+	if step <= 0 || val == 0 {
+		return val
+	}
+	return stepDecimals(math.Round(val/step)*step, step)
+}
+
+// FloorToStep 数量向下取整到步长，步长为 0 时保留 8 位小数
+func FloorToStep(val, step float64) float64 {
+	if step <= 0 {
+		return math.Floor(val*1e8) / 1e8
+	}
+	// 加上一个极小值，避免 0.3/0.1 这类浮点误差少算一个步长
+	return stepDecimals(math.Floor(val/step+1e-9)*step, step)
+}
+
+func stepDecimals(val, step float64) float64 {
+	decimals := 0
+	if s := strconv.FormatFloat(step, 'f', -1, 64); strings.Contains(s, ".") {
+		decimals = len(s) - strings.Index(s, ".") - 1
+	}
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(val, 'f', decimals, 64), 64)
+	return v
+}
+
+// instrumentKey BTC/USDT、BTC-USDT、BTC-USDT-SWAP 都转换为 BTC/USDT
+func instrumentKey(symbol string) string {
+	parts := strings.FieldsFunc(strings.ToUpper(symbol), func(r rune) bool { return r == '/' || r == '-' })
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, "/")
+}
+
+// RulesExchange 下单前按交易对规则取整和校验订单，order 会被原地修改，调用方下单后看到的是取整后的价格和数量；
+// 修改止盈止损时触发价同样按价格步长取整。规则尚未同步时直接转发，其它接口直接转发
+type RulesExchange struct {
+	Exchange
+	registry *InstrumentRegistry
+}
+
+func NewRulesExchange(ex Exchange, registry *InstrumentRegistry) *RulesExchange {
+	return &RulesExchange{Exchange: ex, registry: registry}
+}
+
+func (e *RulesExchange) PlaceOrder(ctx context.Context, order *model2.Order) (*model2.OrderResponse, error) {
+	rules, err := e.registry.Rules(order.Symbol, order.TradeType)
+	if errors.Is(err, ErrRulesNotInitialized) {
+		log.Printf("[RulesExchange] %s %s 交易对规则尚未同步，不校验直接下单", order.Symbol, order.TradeType)
+		return e.Exchange.PlaceOrder(ctx, order)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := NormalizeOrder(order, rules); err != nil {
+		return nil, err
+	}
+	return e.Exchange.PlaceOrder(ctx, order)
+}
+
+func (e *RulesExchange) AmendAlgoOrder(instId string, tradeType model2.OrderTradeType, algoId string, newSlTriggerPx, newTpTriggerPx float64) ([]byte, error) {
+	if rules, err := e.registry.Rules(instId, tradeType); err == nil {
+		// -1 表示不修改，不能取整
+		if newSlTriggerPx > 0 {
+			newSlTriggerPx = RoundToStep(newSlTriggerPx, rules.TickSize)
+		}
+		if newTpTriggerPx > 0 {
+			newTpTriggerPx = RoundToStep(newTpTriggerPx, rules.TickSize)
+		}
+	}
+	return e.Exchange.AmendAlgoOrder(instId, tradeType, algoId, newSlTriggerPx, newTpTriggerPx)
+}
+
+// GetLotRules 优先使用同步的规则，没有时再查询内部交易所
+func (e *RulesExchange) GetLotRules(symbol string, tradeType model2.OrderTradeType) (*model2.LotRules, error) {
+	if rules, err := e.registry.GetLotRules(symbol, tradeType); err == nil {
+		return rules, nil
+	}
+	if provider, ok := e.Exchange.(LotRulesProvider); ok {
+		return provider.GetLotRules(symbol, tradeType)
+	}
+	return &model2.LotRules{}, nil
+}
+
+// GetBestQuote 内部交易所不支持时买一卖一都使用最新价
+func (e *RulesExchange) GetBestQuote(symbol string, tradeType model2.OrderTradeType) (float64, float64, error) {
+	if provider, ok := e.Exchange.(QuoteProvider); ok {
+		return provider.GetBestQuote(symbol, tradeType)
+	}
+	last, err := e.Exchange.GetLastPrice(symbol, tradeType)
+	return last, last, err
+}
+
+// OkxInstrumentSource 通过 okx 公共接口 /api/v5/public/instruments 拉取规则，不需要 API Key
+type OkxInstrumentSource struct {
+	client *okx2.PublicClient
+}
+
+func NewOkxInstrumentSource(client *okx2.PublicClient) *OkxInstrumentSource {
+	if client == nil {
+		client = okx2.NewPublicClient()
+	}
+	return &OkxInstrumentSource{client: client}
+}
+
+func (s *OkxInstrumentSource) FetchRules(ctx context.Context, tradeType model2.OrderTradeType) (map[string]model2.LotRules, error) {
+	var instType string
+	switch tradeType {
+	case model2.OrderTradeSpot:
+		instType = "SPOT"
+	case model2.OrderTradeSwap:
+		instType = "SWAP"
+	default:
+		return nil, fmt.Errorf("不支持同步 %s 交易对规则", tradeType)
+	}
+	list, err := s.client.GetInstruments(ctx, instType)
+	if err != nil {
+		return nil, err
+	}
+	parse := func(v string) float64 {
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	out := make(map[string]model2.LotRules, len(list))
+	for _, inst := range list {
+		if inst.State != "live" {
+			continue
+		}
+		lever, _ := strconv.Atoi(inst.Lever)
+		out[instrumentKey(inst.InstId)] = model2.LotRules{
+			LotSize:     parse(inst.LotSz),
+			TickSize:    parse(inst.TickSz),
+			MinQty:      parse(inst.MinSz),
+			CtVal:       parse(inst.CtVal),
+			MaxLeverage: lever,
+			MaxMktQty:   parse(inst.MaxMktSz),
+		}
+	}
+	return out, nil
+}
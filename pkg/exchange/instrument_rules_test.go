@@ -0,0 +1,138 @@
+package exchange
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	okx2 "edgeflow/pkg/exchange/okx"
+	"edgeflow/pkg/exchange/okxtest"
+	"errors"
+	"testing"
+)
+
+func newTestRegistry(t *testing.T, srv *okxtest.Server) *InstrumentRegistry {
+	t.Helper()
+	r := NewInstrumentRegistry(NewOkxInstrumentSource(okx2.NewPublicClient().WithBaseURL(srv.URL)))
+	if err := r.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestInstrumentRegistry_Sync(t *testing.T) {
+	srv := okxtest.NewServer()
+	defer srv.Close()
+	r := newTestRegistry(t, srv)
+
+	sol, err := r.Rules("SOL-USDT-SWAP", model2.OrderTradeSwap)
+	want := model2.LotRules{LotSize: 0.01, TickSize: 0.01, MinQty: 0.01, CtVal: 1, MaxLeverage: 50, MaxMktQty: 30000}
+	if err != nil || sol != want {
+		t.Fatalf("SOL swap rules = %+v err=%v", sol, err)
+	}
+	if btc, _ := r.Rules("BTC/USDT", model2.OrderTradeSpot); btc.TickSize != 0.1 || btc.MinQty != 0.00001 || btc.CtVal != 0 {
+		t.Errorf("BTC spot rules = %+v", btc)
+	}
+	if _, err := r.Rules("XXX/USDT", model2.OrderTradeSwap); !errors.Is(err, ErrUnknownInstrument) {
+		t.Errorf("unknown instrument err = %v", err)
+	}
+	if _, err := r.Rules("BTC/USDT", model2.OrderTradeFutures); !errors.Is(err, ErrRulesNotInitialized) {
+		t.Errorf("futures not synced err = %v", err)
+	}
+
+	// 同步失败时保留上一次的结果
+	srv.Enqueue("GET", "/api/v5/public/instruments", okxtest.Unavailable())
+	if err := r.Sync(context.Background()); err == nil {
+		t.Fatal("sync should fail")
+	}
+	if _, err := r.Rules("SOL/USDT", model2.OrderTradeSpot); err != nil {
+		t.Errorf("previous rules should be kept: %v", err)
+	}
+}
+
+func TestNormalizeOrder(t *testing.T) {
+	swap := model2.LotRules{LotSize: 0.01, TickSize: 0.1, MinQty: 0.01, CtVal: 0.01, MaxLeverage: 100, MaxMktQty: 12000}
+
+	order := model2.Order{Symbol: "BTC/USDT", Side: model2.Buy, OrderType: model2.Limit, TradeType: model2.OrderTradeSwap,
+		Price: 106480.537, TPPrice: 110000.06, SLPrice: 104999.94, Quantity: 1.239, Leverage: 20}
+	if err := NormalizeOrder(&order, swap); err != nil {
+		t.Fatal(err)
+	}
+	if order.Price != 106480.5 || order.TPPrice != 110000.1 || order.SLPrice != 104999.9 || order.Quantity != 1.23 {
+		t.Errorf("normalized order = %+v", order)
+	}
+
+	cases := []struct {
+		name  string
+		order model2.Order
+		kind  error
+		field string
+	}{
+		{"数量不足一个步长", model2.Order{OrderType: model2.Market, TradeType: model2.OrderTradeSwap, Quantity: 0.009}, ErrQuantityBelowMin, "Quantity"},
+		{"市价单超过最大数量", model2.Order{OrderType: model2.Market, TradeType: model2.OrderTradeSwap, Quantity: 12000.5}, ErrQuantityAboveMax, "Quantity"},
+		{"杠杆超过上限", model2.Order{OrderType: model2.Market, TradeType: model2.OrderTradeSwap, QuantityPct: 0.2, Leverage: 125}, ErrLeverageAboveMax, "Leverage"},
+		{"限价单没有价格", model2.Order{OrderType: model2.Limit, TradeType: model2.OrderTradeSwap, Quantity: 1}, ErrInvalidPrice, "Price"},
+		{"止损价为负数", model2.Order{OrderType: model2.Market, TradeType: model2.OrderTradeSwap, Quantity: 1, SLPrice: -1}, ErrInvalidPrice, "SLPrice"},
+	}
+	for _, c := range cases {
+		err := NormalizeOrder(&c.order, swap)
+		var ruleErr *OrderRuleError
+		if !errors.Is(err, c.kind) || !errors.As(err, &ruleErr) || ruleErr.Field != c.field {
+			t.Errorf("%s: err = %v", c.name, err)
+		}
+	}
+
+	// 按比例下单和现货市价买单（数量为 USDT 金额）不检查数量
+	pct := model2.Order{OrderType: model2.Market, TradeType: model2.OrderTradeSwap, QuantityPct: 0.2, Leverage: 20}
+	spotBuy := model2.Order{Side: model2.Buy, OrderType: model2.Market, TradeType: model2.OrderTradeSpot, Quantity: 10.555, Leverage: 125}
+	if err := NormalizeOrder(&pct, swap); err != nil {
+		t.Errorf("pct order err = %v", err)
+	}
+	if err := NormalizeOrder(&spotBuy, swap); err != nil || spotBuy.Quantity != 10.555 {
+		t.Errorf("spot market buy = %+v err = %v", spotBuy, err)
+	}
+}
+
+func TestRulesExchange_PlaceOrder(t *testing.T) {
+	ctx := context.Background()
+	okxEx, srv := newTestOkx(t)
+
+	// 规则尚未同步时直接下单
+	registry := NewInstrumentRegistry(NewOkxInstrumentSource(okx2.NewPublicClient().WithBaseURL(srv.URL)))
+	ex := NewRulesExchange(okxEx, registry)
+	raw := &model2.Order{Symbol: "SOL/USDT", Side: model2.Buy, OrderType: model2.Limit, TradeType: model2.OrderTradeSwap, Price: 185.123, Quantity: 1}
+	if _, err := ex.PlaceOrder(ctx, raw); err != nil || raw.Price != 185.123 {
+		t.Fatalf("order before sync = %+v err=%v", raw, err)
+	}
+
+	if err := registry.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	order := &model2.Order{Symbol: "SOL/USDT", Side: model2.Buy, OrderType: model2.Limit, TradeType: model2.OrderTradeSwap,
+		Price: 185.123, TPPrice: 190.004, SLPrice: 180.006, Quantity: 1.2345}
+	if _, err := ex.PlaceOrder(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.Requests("POST", "/api/v5/trade/order")
+	body := reqs[len(reqs)-1].Body
+	algo := body["attachAlgoOrds"].([]any)[0].(map[string]any)
+	if body["px"] != "185.12" || body["sz"] != "1.23" || algo["tpTriggerPx"] != "190" || algo["slTriggerPx"] != "180.01" {
+		t.Errorf("order body = %+v", body)
+	}
+
+	// 校验失败时不提交
+	n := len(reqs)
+	_, err := ex.PlaceOrder(ctx, &model2.Order{Symbol: "SOL/USDT", Side: model2.Sell, OrderType: model2.Market, TradeType: model2.OrderTradeSwap, Quantity: 1, Leverage: 75})
+	if !errors.Is(err, ErrLeverageAboveMax) {
+		t.Errorf("leverage err = %v", err)
+	}
+	_, err = ex.PlaceOrder(ctx, &model2.Order{Symbol: "XXX/USDT", Side: model2.Sell, OrderType: model2.Market, TradeType: model2.OrderTradeSwap, Quantity: 1})
+	if !errors.Is(err, ErrUnknownInstrument) {
+		t.Errorf("unknown instrument err = %v", err)
+	}
+	if got := len(srv.Requests("POST", "/api/v5/trade/order")); got != n {
+		t.Errorf("invalid orders should not be sent: %d requests", got)
+	}
+
+	if rules, err := ex.GetLotRules("SOL/USDT", model2.OrderTradeSwap); err != nil || rules.MaxLeverage != 50 {
+		t.Errorf("lot rules = %+v err=%v", rules, err)
+	}
+}
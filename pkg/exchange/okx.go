@@ -9,6 +9,7 @@ import (
 	"github.com/nntaoli-project/goex/v2/model"
 	"github.com/nntaoli-project/goex/v2/options"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -18,6 +19,7 @@ type OkxExchange struct {
 	//swap    *okx.OkxSwap
 	//futures *okx.OkxFutures
 	apiConf []options.ApiOption
+	uriOpts []options.UriOption
 }
 
 // 构造函数只存储配置，不初始化接口
@@ -44,14 +46,27 @@ func NewOkxExchange(apiKey, apiSecret, passphrase string) *OkxExchange {
 	}
 }
 
+// WithBaseURL 替换 REST 接口地址，集成测试时指向本地的 okxtest 服务
+func (e *OkxExchange) WithBaseURL(baseURL string) *OkxExchange {
+	e.uriOpts = []options.UriOption{options.WithEndpoint(strings.TrimRight(baseURL, "/"))}
+	// 已经初始化的接口仍然指向旧地址
+	e.apiCache = make(map[model2.OrderTradeType]okx2.OkxService)
+	return e
+}
+
 func (e *OkxExchange) Account(tradeType model2.OrderTradeType) (acc Account, err error) {
 	api, err := e.getApi(tradeType)
 	if err != nil {
 		return nil, err
 	}
-	okxApi, ok := api.(*okx2.Okx)
-	if ok {
-		return okxApi.Account, nil
+	// 具体类型只是内嵌了 Okx，不能直接断言为 *okx2.Okx
+	switch v := api.(type) {
+	case *okx2.OkxSpot:
+		return v.Account, nil
+	case *okx2.OkxSwap:
+		return v.Account, nil
+	case *okx2.OkxFutures:
+		return v.Account, nil
 	}
 	return nil, errors.New("当前交易类型不支持获取账户余额")
 }
@@ -69,7 +84,7 @@ func (e *OkxExchange) getApi(marketType model2.OrderTradeType) (okx2.OkxService,
 
 	switch marketType {
 	case "spot":
-		spotApi = okx2.NewOkxSpot(e.apiConf)
+		spotApi = okx2.NewOkxSpot(e.apiConf, e.uriOpts...)
 
 		// 初始化时加载所有可交易币对
 		// 测试连接，创建订单时需要调用GetExchangeInfo获取pair
@@ -82,7 +97,7 @@ func (e *OkxExchange) getApi(marketType model2.OrderTradeType) (okx2.OkxService,
 			return spotApi, nil
 		}
 	case "swap":
-		swapApi = okx2.NewOkxSwap(e.apiConf)
+		swapApi = okx2.NewOkxSwap(e.apiConf, e.uriOpts...)
 		_, _, err := swapApi.GetExchangeInfo()
 		if err != nil {
 			fmt.Printf("GetExchangeInfo err : %v", err)
@@ -92,7 +107,7 @@ func (e *OkxExchange) getApi(marketType model2.OrderTradeType) (okx2.OkxService,
 			return swapApi, nil
 		}
 	case "futures":
-		fApi = okx2.NewOkxFutures(e.apiConf)
+		fApi = okx2.NewOkxFutures(e.apiConf, e.uriOpts...)
 		_, _, err := fApi.GetExchangeInfo()
 		if err != nil {
 			fmt.Printf("GetExchangeInfo err : %v", err)
@@ -114,6 +129,15 @@ func (e *OkxExchange) GetLastPrice(symbol string, tradingType model2.OrderTradeT
 	return api.GetLastPrice(symbol)
 }
 
+// GetBestQuote 盘口买一卖一价
+func (e *OkxExchange) GetBestQuote(symbol string, tradeType model2.OrderTradeType) (bid, ask float64, err error) {
+	api, err := e.getApi(tradeType)
+	if err != nil {
+		return 0, 0, err
+	}
+	return api.GetBestQuote(symbol)
+}
+
 // 下单购买
 // 注意限价和市价的Quantity单位不相同，当限价时Quantity的单位为币本身，当市价时Quantity的单位为USDT
 func (e *OkxExchange) PlaceOrder(ctx context.Context, order *model2.Order) (*model2.OrderResponse, error) {
@@ -140,6 +164,15 @@ func (e *OkxExchange) GetOrderStatus(orderID string, symbol string, tradingType
 	return api.GetOrderStatus(orderID, symbol)
 }
 
+// GetLotRules 交易对的下单精度
+func (e *OkxExchange) GetLotRules(symbol string, tradeType model2.OrderTradeType) (*model2.LotRules, error) {
+	api, err := e.getApi(tradeType)
+	if err != nil {
+		return nil, err
+	}
+	return api.GetLotRules(symbol)
+}
+
 // SetLeverage 设置合约杠杆
 // instId     例如 "BTC-USDT-SWAP"，如果传入的是BTC/USDT，会通过CurrencyPair去查找对应的的instId
 // leverage   杠杆倍数，例如 20、50
@@ -163,10 +196,10 @@ func (e *OkxExchange) SetLeverage(symbol string, leverage int, marginMode, posSi
 }
 
 // 平仓函数
-func (e *OkxExchange) ClosePosition(symbol string, side string, quantity float64, tdMode string, tradeType model2.OrderTradeType) error {
+func (e *OkxExchange) ClosePosition(symbol string, side string, quantity float64, tdMode string, tradeType model2.OrderTradeType) (*model2.OrderResponse, error) {
 	api, err := e.getApi(tradeType)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	switch v := api.(type) {
@@ -175,7 +208,7 @@ func (e *OkxExchange) ClosePosition(symbol string, side string, quantity float64
 	case *okx2.OkxSwap:
 		return v.ClosePosition(symbol, side, quantity, tdMode)
 	default:
-		return errors.New("当前交易类型不支持关闭仓位ClosePosition")
+		return nil, errors.New("当前交易类型不支持关闭仓位ClosePosition")
 	}
 }
 
@@ -0,0 +1,87 @@
+package okx
+
+import (
+	"bytes"
+	model2 "edgeflow/internal/model"
+	"errors"
+	"fmt"
+	"github.com/nntaoli-project/goex/v2/model"
+	"github.com/nntaoli-project/goex/v2/okx/common"
+	"github.com/nntaoli-project/goex/v2/okx/futures"
+	"github.com/nntaoli-project/goex/v2/okx/spot"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// okx 重复 clOrdId 的错误码
+const duplicateClOrdIdCode = "51016"
+
+// createOrder 下单，带 clOrdId 时交易所返回重复错误视为已经下过单，查询并返回已有订单
+func (e *Okx) createOrder(pair model.CurrencyPair, clOrdId string, qty, price float64, side model.OrderSide, orderType model.OrderType, opts ...model.OptionParameter) (*model2.OrderResponse, error) {
+	if clOrdId != "" {
+		opts = append(opts, model.OptionParameter{
+			Key:   model.Order_Client_ID__Opt_Key,
+			Value: clOrdId,
+		})
+	}
+	createdOrder, resp, err := e.prv.CreateOrder(pair, qty, price, side, orderType, opts...)
+	if err != nil {
+		if clOrdId == "" || !isDuplicateClOrdId(err, resp) {
+			fmt.Printf("CreateOrder error：%v", resp)
+			return nil, err
+		}
+		existing, qErr := e.getOrderByClOrdId(pair, clOrdId)
+		if qErr != nil {
+			return nil, fmt.Errorf("clOrdId %s 重复，查询已有订单失败: %w", clOrdId, qErr)
+		}
+		fmt.Printf("CreateOrder clOrdId %s 重复，返回已有订单 %s\n", clOrdId, existing.Id)
+		return &model2.OrderResponse{
+			OrderId:   existing.Id,
+			Status:    int(existing.Status),
+			Duplicate: true,
+		}, nil
+	}
+
+	return &model2.OrderResponse{
+		OrderId: createdOrder.Id,
+		Status:  int(createdOrder.Status),
+	}, nil
+}
+
+// getOrderByClOrdId goex 的 GetOrderInfo 只支持 ordId，这里直接按 clOrdId 查询
+func (e *Okx) getOrderByClOrdId(pair model.CurrencyPair, clOrdId string) (*model.Order, error) {
+	var prv *common.Prv
+	switch v := e.prv.(type) {
+	case *futures.PrvApi:
+		prv = v.Prv
+	case *spot.PrvApi:
+		prv = v.Prv
+	default:
+		return nil, errors.New("当前交易类型不支持按 clOrdId 查询订单")
+	}
+	reqUrl := fmt.Sprintf("%s%s", prv.UriOpts.Endpoint, prv.UriOpts.GetOrderUri)
+	params := url.Values{}
+	params.Set("instId", pair.Symbol)
+	params.Set("clOrdId", clOrdId)
+
+	data, _, err := prv.DoAuthRequest(http.MethodGet, reqUrl, &params, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 {
+		return nil, fmt.Errorf("订单 %s 不存在", clOrdId)
+	}
+	ord, err := prv.UnmarshalOpts.GetOrderInfoResponseUnmarshaler(data[1 : len(data)-1])
+	if err != nil {
+		return nil, err
+	}
+	ord.Pair = pair
+	return ord, nil
+}
+
+// isDuplicateClOrdId goex 只返回 sMsg，错误码需要从响应内容中判断
+func isDuplicateClOrdId(err error, resp []byte) bool {
+	return bytes.Contains(resp, []byte(`"sCode":"`+duplicateClOrdIdCode+`"`)) ||
+		strings.Contains(strings.ToLower(err.Error()), "duplicated clordid")
+}
@@ -13,8 +13,13 @@ type OkxFutures struct {
 	pub futures.Futures
 }
 
-func NewOkxFutures(conf []options.ApiOption) *OkxFutures {
+func NewOkxFutures(conf []options.ApiOption, uriOpts ...options.UriOption) *OkxFutures {
 	pub := goexv2.OKx.Futures
+	if len(uriOpts) > 0 {
+		// 自定义接口地址时不能修改全局实例
+		pub = futures.New()
+		pub.WithUriOption(uriOpts...)
+	}
 	return &OkxFutures{
 		FuturesCommon: FuturesCommon{Okx{
 			prv:     pub.NewPrvApi(conf...),
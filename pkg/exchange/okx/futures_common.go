@@ -49,6 +49,11 @@ func (e *FuturesCommon) getPosition(symbol string) ([]model2.PositionInfo, error
 			CTime       string `json:"cTime"`       // 开仓时间
 			UplRatio    string `json:"uplRatio"`    // 未实现的收益率
 			Last        string `json:"last"`        // 最后成交价
+			// 仓位的止盈止损
+			CloseOrderAlgo []struct {
+				AlgoId      string `json:"algoId"`
+				SlTriggerPx string `json:"slTriggerPx"`
+			} `json:"closeOrderAlgo"`
 		} `json:"data"`
 		Msg string `json:"msg"`
 	}
@@ -82,6 +87,11 @@ func (e *FuturesCommon) getPosition(symbol string) ([]model2.PositionInfo, error
 		item.MgnMode = data.MgnMode
 		item.LiqPx = data.LiqPx
 		item.AlgoId = data.AlgoId
+		for _, algo := range data.CloseOrderAlgo {
+			if algo.AlgoId == data.AlgoId {
+				item.SlTriggerPx, _ = strconv.ParseFloat(algo.SlTriggerPx, 64)
+			}
+		}
 		item.PositionId = data.PositionId
 		item.UnrealizedPnl = jsonData.Data[i].UPL
 		item.MarkPx = data.MarkPx
@@ -143,12 +153,12 @@ func (e *FuturesCommon) SetLeverage(symbol string, leverage int, marginMode, pos
 }
 
 // 平仓函数
-func (e *FuturesCommon) ClosePosition(symbol string, dir string, quantity float64, tdMode string) error {
+func (e *FuturesCommon) ClosePosition(symbol string, dir string, quantity float64, tdMode string) (*model2.OrderResponse, error) {
 
 	// 当传入的是BTC/USDT时，通过CurrencyPair匹配正确的instId
 	pair, err := e.toCurrencyPair(symbol)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var orderSide model.OrderSide
 
@@ -162,7 +172,7 @@ func (e *FuturesCommon) ClosePosition(symbol string, dir string, quantity float6
 		// 持有空单，平掉空单
 		orderSide = model.Futures_CloseSell
 	default:
-		return fmt.Errorf("unknown side: %s", dir)
+		return nil, fmt.Errorf("unknown side: %s", dir)
 	}
 
 	opts := []model.OptionParameter{
@@ -176,11 +186,14 @@ func (e *FuturesCommon) ClosePosition(symbol string, dir string, quantity float6
 	order, resp, err := e.prv.CreateOrder(pair, quantity, 0, orderSide, model.OrderType_Market, opts...)
 	if err != nil {
 		fmt.Printf("CreateOrder error：%v", resp)
-		return err
+		return nil, err
 	}
 
 	fmt.Printf("平仓成功，订单ID：%s\n", order.Id)
-	return nil
+	return &model2.OrderResponse{
+		OrderId: order.Id,
+		Status:  int(order.Status),
+	}, nil
 }
 
 // 查询是否有持仓
@@ -6,6 +6,7 @@ import (
 	"edgeflow/pkg/account"
 	"errors"
 	"fmt"
+	"math"
 	goexv2 "github.com/nntaoli-project/goex/v2"
 	"github.com/nntaoli-project/goex/v2/model"
 	"github.com/nntaoli-project/goex/v2/okx/common"
@@ -22,9 +23,11 @@ type OkxService interface {
 	GetOrderStatus(orderID string, symbol string) (*model2.OrderStatus, error)
 	CancelOrder(orderID, symbol string) error
 	GetLastPrice(symbol string) (float64, error)
+	GetBestQuote(symbol string) (bid, ask float64, err error)
 	GetExchangeInfo() (map[string]model.CurrencyPair, []byte, error)
 	AmendAlgoOrder(instId string, algoId string, newSlTriggerPx, newSlOrdPx, newTpTriggerPx, newTpOrdPx float64) ([]byte, error)
 	GetKlineRecords(symbol string, period model.KlinePeriod, size int, start, end int64, includeUnclosed bool) ([]model2.Kline, error)
+	GetLotRules(symbol string) (*model2.LotRules, error)
 }
 
 // OKX 三种交易的基础结构：swap、future、spot
@@ -65,6 +68,36 @@ func (e *Okx) GetLastPrice(symbol string) (float64, error) {
 	return ticker.Last, nil
 }
 
+// 获取买一卖一价
+func (e *Okx) GetBestQuote(symbol string) (bid, ask float64, err error) {
+	pair, err := e.toCurrencyPair(symbol)
+	if err != nil {
+		return 0, 0, err
+	}
+	ticker, _, err := e.getPub().GetTicker(pair)
+	if err != nil {
+		return 0, 0, err
+	}
+	if ticker == nil || ticker.Buy <= 0 || ticker.Sell <= 0 {
+		return 0, 0, errors.New("failed to get ticker")
+	}
+	return ticker.Buy, ticker.Sell, nil
+}
+
+// 交易对的下单精度，合约的数量单位为张
+func (e *Okx) GetLotRules(symbol string) (*model2.LotRules, error) {
+	pair, err := e.toCurrencyPair(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return &model2.LotRules{
+		LotSize:  math.Pow10(-pair.QtyPrecision),
+		TickSize: math.Pow10(-pair.PricePrecision),
+		MinQty:   pair.MinQty,
+		CtVal:    pair.ContractVal,
+	}, nil
+}
+
 // 取消订单
 func (e *Okx) CancelOrder(orderID, symbol string) error {
 	pair, err := e.toCurrencyPair(symbol)
@@ -91,7 +124,11 @@ func (e *Okx) GetOrderStatus(orderID string, symbol string) (*model2.OrderStatus
 		OrderID:   info.Id,
 		Status:    info.Status.String(),
 		Filled:    info.ExecutedQty,
-		Remaining: info.Qty - info.Qty,
+		Remaining: info.Qty - info.ExecutedQty,
+		AvgPrice:  info.PriceAvg,
+		Fee:       info.Fee,
+		FeeCcy:    info.FeeCcy,
+		CtVal:     pari.ContractVal,
 	}, nil
 }
 
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -29,6 +30,12 @@ func NewPublicClient() *PublicClient {
 	}
 }
 
+// WithBaseURL 替换接口地址，例如 https://www.okx.com，测试时指向本地的 okxtest 服务
+func (c *PublicClient) WithBaseURL(baseURL string) *PublicClient {
+	c.baseURL = strings.TrimRight(baseURL, "/") + "/api/v5"
+	return c
+}
+
 const maxRetries = 3
 
 // GetInstrumentsWithRetry 封装了 GetInstruments，并添加了重试逻辑
@@ -88,6 +95,53 @@ func (c *PublicClient) GetInstruments(ctx context.Context, instType string) ([]I
 	return instruments, nil
 }
 
+// GetFundingRate 永续合约当前和下一期的资金费率
+// instId: BTC-USDT-SWAP
+func (c *PublicClient) GetFundingRate(ctx context.Context, instId string) (*FundingRateRaw, error) {
+	endpoint := fmt.Sprintf("/public/funding-rate?instId=%s", instId)
+
+	var rates []FundingRateRaw
+	if err := c.doPublicGet(ctx, endpoint, &rates); err != nil {
+		return nil, fmt.Errorf("获取 %s 资金费率失败: %w", instId, err)
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("获取 %s 资金费率失败: 返回为空", instId)
+	}
+	return &rates[0], nil
+}
+
+// GetOpenInterest 某一类合约全部交易对的持仓量
+// instType: SWAP, FUTURES, OPTION
+func (c *PublicClient) GetOpenInterest(ctx context.Context, instType string) ([]OpenInterestRaw, error) {
+	endpoint := fmt.Sprintf("/public/open-interest?instType=%s", instType)
+
+	var list []OpenInterestRaw
+	if err := c.doPublicGet(ctx, endpoint, &list); err != nil {
+		return nil, fmt.Errorf("获取 %s 持仓量失败: %w", instType, err)
+	}
+	return list, nil
+}
+
+// GetTakerVolume 合约主动买入和卖出的成交量，结果从新到旧
+// period: 5m, 1H, 1D 等
+func (c *PublicClient) GetTakerVolume(ctx context.Context, instId, period string, limit int) ([]TakerVolumeRaw, error) {
+	endpoint := fmt.Sprintf("/rubik/stat/taker-volume-contract?instId=%s&period=%s&limit=%d", instId, period, limit)
+
+	// 返回的每一项是数组 [ts, sellVol, buyVol]
+	var rows [][]string
+	if err := c.doPublicGet(ctx, endpoint, &rows); err != nil {
+		return nil, fmt.Errorf("获取 %s 主动买卖量失败: %w", instId, err)
+	}
+	list := make([]TakerVolumeRaw, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 3 {
+			continue
+		}
+		list = append(list, TakerVolumeRaw{Ts: row[0], SellVol: row[1], BuyVol: row[2]})
+	}
+	return list, nil
+}
+
 // doPublicGet 执行通用的 GET 请求，处理 JSON 解析和错误
 func (c *PublicClient) doPublicGet(ctx context.Context, endpoint string, result interface{}) error {
 	url := fmt.Sprintf("%s%s", c.baseURL, endpoint)
@@ -150,6 +204,38 @@ type InstrumentRaw struct {
 	MinSz  string `json:"minSz"`  // 最小交易数量 (Quantity Precision)
 	LotSz  string `json:"lotSz"`  // 合约乘数/最小下单量 (Quantity Precision - 另一种形式，这里用 minSz)
 
+	// 合约面值和下单限制
+	CtVal    string `json:"ctVal"`    // 合约面值，现货为空
+	Lever    string `json:"lever"`    // 最大杠杆倍数
+	MaxMktSz string `json:"maxMktSz"` // 市价单的最大数量
+
 	// 其他不常用或与合约相关的字段 (仅用于接收，不一定存储)
 	Category string `json:"category"`
 }
+
+// FundingRateRaw 资金费率，nextFundingRate 为预测的下一期资金费率，部分交易对为空
+type FundingRateRaw struct {
+	InstId          string `json:"instId"`
+	FundingRate     string `json:"fundingRate"`
+	NextFundingRate string `json:"nextFundingRate"`
+	FundingTime     string `json:"fundingTime"`     // 本期资金费收取时间 (毫秒)
+	NextFundingTime string `json:"nextFundingTime"` // 下一期资金费收取时间 (毫秒)
+	Ts              string `json:"ts"`
+}
+
+// OpenInterestRaw 持仓量，oi 单位为张，oiCcy 单位为币，oiUsd 为美元价值
+type OpenInterestRaw struct {
+	InstId   string `json:"instId"`
+	InstType string `json:"instType"`
+	Oi       string `json:"oi"`
+	OiCcy    string `json:"oiCcy"`
+	OiUsd    string `json:"oiUsd"`
+	Ts       string `json:"ts"`
+}
+
+// TakerVolumeRaw 主动买入和卖出量，单位为张
+type TakerVolumeRaw struct {
+	Ts      string
+	SellVol string
+	BuyVol  string
+}
@@ -5,7 +5,6 @@ import (
 	model2 "edgeflow/internal/model"
 	"edgeflow/pkg/account"
 	"errors"
-	"fmt"
 	goexv2 "github.com/nntaoli-project/goex/v2"
 	"github.com/nntaoli-project/goex/v2/model"
 	"github.com/nntaoli-project/goex/v2/okx/spot"
@@ -20,8 +19,13 @@ type OkxSpot struct {
 	pub spot.Spot
 }
 
-func NewOkxSpot(conf []options.ApiOption) *OkxSpot {
+func NewOkxSpot(conf []options.ApiOption, uriOpts ...options.UriOption) *OkxSpot {
 	pub := goexv2.OKx.Spot
+	if len(uriOpts) > 0 {
+		// 自定义接口地址时不能修改全局实例
+		pub = spot.New()
+		pub.WithUriOption(uriOpts...)
+	}
 	return &OkxSpot{
 		Okx: Okx{
 			prv:     pub.NewPrvApi(conf...),
@@ -85,16 +89,7 @@ func (e *OkxSpot) PlaceOrder(ctx context.Context, order *model2.Order) (*model2.
 	}
 
 	// 创建订单
-	createdOrder, resp, err := e.prv.CreateOrder(pair, order.Quantity, order.Price, side, orderType, opts...)
-	if err != nil {
-		fmt.Printf("CreateOrder error：%v", resp)
-		return nil, err
-	}
-
-	return &model2.OrderResponse{
-		OrderId: createdOrder.Id,
-		Status:  int(createdOrder.Status),
-	}, nil
+	return e.createOrder(pair, order.ClientOrderId, order.Quantity, order.Price, side, orderType, opts...)
 }
 
 func (e *OkxSpot) getPub() goexv2.IPubRest {
@@ -22,8 +22,13 @@ type OkxSwap struct {
 	pub futures.Swap
 }
 
-func NewOkxSwap(conf []options.ApiOption) *OkxSwap {
+func NewOkxSwap(conf []options.ApiOption, uriOpts ...options.UriOption) *OkxSwap {
 	pub := goexv2.OKx.Swap
+	if len(uriOpts) > 0 {
+		// 自定义接口地址时不能修改全局实例
+		pub = futures.NewSwap()
+		pub.WithUriOption(uriOpts...)
+	}
 	return &OkxSwap{
 		FuturesCommon: FuturesCommon{Okx{
 			prv:     pub.NewPrvApi(conf...),
@@ -146,14 +151,5 @@ func (e *OkxSwap) PlaceOrder(ctx context.Context, order *model2.Order) (*model2.
 
 	// 创建订单
 	fmt.Printf("CreateOrder start: quantity:%v price:%v side:%v\n", order.Quantity, order.Price, order.Side)
-	createdOrder, _, err := e.prv.CreateOrder(pair, order.Quantity, order.Price, side, orderType, opts...)
-	if err != nil {
-		fmt.Printf("CreateOrder error：%v", err.Error())
-		return nil, err
-	}
-
-	return &model2.OrderResponse{
-		OrderId: createdOrder.Id,
-		Status:  int(createdOrder.Status),
-	}, nil
+	return e.createOrder(pair, order.ClientOrderId, order.Quantity, order.Price, side, orderType, opts...)
 }
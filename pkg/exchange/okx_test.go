@@ -2,255 +2,264 @@ package exchange
 
 import (
 	"context"
-	"edgeflow/conf"
 	"edgeflow/internal/model"
 	"edgeflow/pkg/exchange/okx"
+	"edgeflow/pkg/exchange/okxtest"
 	"fmt"
-	"github.com/nntaoli-project/goex/v2"
-	"log"
+	"strings"
 	"testing"
-)
 
-func loadOkxConf() (*conf.Okx, error) {
-	// 加载配置文件
-	err := conf.LoadConfig("../../conf/config.yaml")
-	if err != nil {
-		return nil, err
-	}
+	goexmodel "github.com/nntaoli-project/goex/v2/model"
+)
 
-	return &conf.AppConfig.Okx, nil
+// newTestOkx 指向本地 okxtest 服务的 OkxExchange，不需要真实的 API Key
+func newTestOkx(t *testing.T) (*OkxExchange, *okxtest.Server) {
+	srv := okxtest.NewServer()
+	t.Cleanup(srv.Close)
+	return NewOkxExchange("test-key", "test-secret", "test-passphrase").WithBaseURL(srv.URL), srv
 }
 
 func TestOkxExchange_GetLastPrice(t *testing.T) {
-
-	// 加载配置文件
-	okxConf, err := loadOkxConf()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
-
-	okxEx := NewOkxExchange(okxConf.ApiKey, okxConf.SecretKey, okxConf.Password)
+	okxEx, srv := newTestOkx(t)
 
 	price, err := okxEx.GetLastPrice("BTC/USDT", model.OrderTradeSpot)
-	fmt.Println("最新价格:", price, err)
-
-}
-
-// 测试限价下单
-func TestOkxExchange_PlaceOrder(t *testing.T) {
-	goex.DefaultHttpCli.SetHeaders("x-simulated-trading", "1") // 设置为模拟环境
-	// 加载配置文件
-	okxConf, err := loadOkxConf()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	if err != nil || price != 106500.1 {
+		t.Fatalf("spot price=%v err=%v", price, err)
+	}
+	bid, ask, err := okxEx.GetBestQuote("SOL/USDT", model.OrderTradeSwap)
+	if err != nil || bid != 185.28 || ask != 185.29 {
+		t.Fatalf("bid=%v ask=%v err=%v", bid, ask, err)
+	}
+	if _, err := okxEx.GetLastPrice("DOGE/USDT", model.OrderTradeSwap); err == nil {
+		t.Errorf("unknown symbol should fail")
 	}
 
-	okxEx := NewOkxExchange(okxConf.ApiKey, okxConf.SecretKey, okxConf.Password)
-	if err != nil {
-		panic(err)
+	// 限频错误可以被识别，经过 RateLimitedExchange 时自动重试
+	srv.Enqueue("GET", "/api/v5/market/ticker", okxtest.RateLimited())
+	if _, _, err := okxEx.GetBestQuote("SOL/USDT", model.OrderTradeSwap); !IsRateLimited(err) {
+		t.Fatalf("err = %v, want rate limited", err)
+	}
+	srv.Enqueue("GET", "/api/v5/market/ticker", okxtest.RateLimited(), okxtest.Unavailable())
+	limited := NewRateLimitedExchange(okxEx, nil)
+	if _, _, err := limited.GetBestQuote("SOL/USDT", model.OrderTradeSwap); err != nil {
+		t.Fatalf("retry err = %v", err)
+	}
+	if st := limited.Stats()[EndpointOrderBook]; st.Retries != 2 || st.RateLimited != 1 {
+		t.Errorf("stats = %+v", st)
 	}
+}
+
+// 测试限价下单永续合约，并且带有止盈止损
+func TestOkxExchange_PlaceOrderSwap(t *testing.T) {
+	ctx := context.Background()
+	okxEx, srv := newTestOkx(t)
 
 	order := model.Order{
-		Symbol:    "BTC/USDT",
+		Symbol:    "SOL/USDT",
 		Side:      model.Buy,
-		Price:     100,
-		Quantity:  1, // 市价Quantity 单位是USDT == 1USDT
-		OrderType: model.Market,
-		TPPrice:   0,
-		SLPrice:   0,
+		Price:     185.1,
+		Quantity:  1,
+		OrderType: model.Limit,
+		TPPrice:   190,
+		SLPrice:   180,
 		Strategy:  "Stragety1",
-		Comment:   "测试市价购买",
+		Comment:   "测试限价购买",
 		TradeType: model.OrderTradeSwap,
 	}
-
-	resp, err := okxEx.PlaceOrder(context.Background(), &order)
+	resp, err := okxEx.PlaceOrder(ctx, &order)
 	if err != nil || resp.OrderId == "" {
-		t.Errorf("Expected non-empty order ID")
-	} else {
-		t.Logf("Order ID: %s", resp.OrderId)
+		t.Fatalf("resp=%+v err=%v", resp, err)
+	}
 
-		// 获取订单状态
-		status, err := okxEx.GetOrderStatus(resp.OrderId, order.Symbol, order.TradeType)
-		if err != nil {
-			t.Logf("Order status: %v", status.Status)
-		}
+	// 下单前先设置杠杆，默认逐仓 20 倍
+	lever := srv.Requests("POST", "/api/v5/account/set-leverage")
+	if len(lever) != 1 || lever[0].Body["lever"] != "20" || lever[0].Body["mgnMode"] != "isolated" || lever[0].Body["posSide"] != "long" {
+		t.Fatalf("set-leverage = %+v", lever)
+	}
+	reqs := srv.Requests("POST", "/api/v5/trade/order")
+	body := reqs[0].Body
+	if body["instId"] != "SOL-USDT-SWAP" || body["side"] != "buy" || body["posSide"] != "long" || body["ordType"] != "limit" || body["tdMode"] != "isolated" {
+		t.Errorf("order body = %+v", body)
+	}
+	algo, _ := body["attachAlgoOrds"].([]any)
+	if len(algo) != 1 || algo[0].(map[string]any)["tpTriggerPx"] != "190" || algo[0].(map[string]any)["slTriggerPx"] != "180" {
+		t.Errorf("attachAlgoOrds = %v", body["attachAlgoOrds"])
+	}
+	if reqs[0].Header.Get("OK-ACCESS-KEY") != "test-key" || reqs[0].Header.Get("OK-ACCESS-SIGN") == "" {
+		t.Errorf("private request should be signed: %v", reqs[0].Header)
+	}
 
-		// 如果没有完成，测试撤单
-		if status.Status == "pending" {
-			err := okxEx.CancelOrder(resp.OrderId, order.Symbol, order.TradeType)
-			if err == nil {
-				t.Log("取消订单完成")
-			}
-		}
+	status, err := okxEx.GetOrderStatus(resp.OrderId, order.Symbol, order.TradeType)
+	if err != nil || status.Status != "pending" || status.CtVal != 1 {
+		t.Fatalf("status=%+v err=%v", status, err)
+	}
+	if err := okxEx.CancelOrder(resp.OrderId, order.Symbol, order.TradeType); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := okxEx.GetOrderStatus(resp.OrderId, order.Symbol, order.TradeType); status.Status != "canceled" {
+		t.Errorf("status after cancel = %s", status.Status)
+	}
+	if err := okxEx.CancelOrder(resp.OrderId, order.Symbol, order.TradeType); err == nil {
+		t.Errorf("cancel twice should fail")
 	}
 }
 
-// 测试市价下单现货，并且带有止盈止损
+// 测试市价下单现货
 func TestOkxExchange_PlaceOrderSpot(t *testing.T) {
-
-	// 加载配置文件
-	okxConf, err := loadOkxConf()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
-	if conf.AppConfig.Simulated {
-		goex.DefaultHttpCli.SetHeaders("x-simulated-trading", "1") // 设置为模拟环境
-	}
-	okxEx := NewOkxExchange(okxConf.ApiKey, okxConf.SecretKey, okxConf.Password)
+	okxEx, _ := newTestOkx(t)
 
 	order := model.Order{
 		Symbol:    "SOL/USDT",
 		Side:      model.Buy,
-		Price:     192.1,
-		Quantity:  1, // 限价Quantity 单位是币本身 == 1SOL
-		OrderType: model.Limit,
-		TPPrice:   192.2,
-		SLPrice:   190,
-		Strategy:  "Stragety1",
-		Comment:   "测试限价购买",
+		Quantity:  10, // 市价Quantity 单位是USDT
+		OrderType: model.Market,
 		TradeType: model.OrderTradeSpot,
 	}
+	resp, err := okxEx.PlaceOrder(context.Background(), &order)
+	if err != nil || resp.OrderId == "" {
+		t.Fatalf("resp=%+v err=%v", resp, err)
+	}
+	status, err := okxEx.GetOrderStatus(resp.OrderId, order.Symbol, order.TradeType)
+	if err != nil || status.Status != "finished" || status.AvgPrice != 185.32 || status.Filled != 10 {
+		t.Fatalf("status=%+v err=%v", status, err)
+	}
+}
 
-	// 获取市价
-	lastPrice, err := okxEx.GetLastPrice(order.Symbol, order.TradeType)
-	if err != nil {
-		order.SLPrice = 0
-		fmt.Printf("获取最新价格失败：%v", err)
-	} else {
-		// 订单的价格太偏离当前市场价，或止盈止损设置不符合规则，会触发okx的系统封控，报错"sCode": "51137",
-		order.Price = lastPrice
-		// 将止损价格设置低于最新价格一些，不然无法下单
-		if order.SLPrice > 0 {
-			order.SLPrice = lastPrice - 5
+// 相同 clOrdId 重复下单时返回已有订单
+func TestOkxExchange_PlaceOrderIdempotent(t *testing.T) {
+	ctx := context.Background()
+	okxEx, srv := newTestOkx(t)
+	order := func() *model.Order {
+		return &model.Order{
+			Symbol: "BTC/USDT", Side: model.Sell, OrderType: model.Market, Quantity: 0.5, TradeType: model.OrderTradeSwap,
+			ClientOrderId: model.NewClientOrderId("id:7", "tv-level", "open"),
 		}
 	}
 
-	resp, err := okxEx.PlaceOrder(context.Background(), &order)
+	first, err := okxEx.PlaceOrder(ctx, order())
+	if err != nil || first.Duplicate {
+		t.Fatalf("first=%+v err=%v", first, err)
+	}
+	second, err := okxEx.PlaceOrder(ctx, order())
+	if err != nil || !second.Duplicate || second.OrderId != first.OrderId {
+		t.Fatalf("second=%+v err=%v", second, err)
+	}
+	if n := len(srv.Requests("POST", "/api/v5/trade/order")); n != 2 {
+		t.Errorf("order requests = %d", n)
+	}
 
-	if resp.OrderId == "" {
-		t.Errorf("Expected non-empty order ID")
-	} else {
-		t.Logf("Order ID: %s", resp.OrderId)
+	// 其他下单错误原样返回
+	srv.Enqueue("POST", "/api/v5/trade/order", okxtest.OrderError("51008", "Order failed. Insufficient USDT margin in account"))
+	if _, err := okxEx.PlaceOrder(ctx, &model.Order{Symbol: "BTC/USDT", Side: model.Buy, OrderType: model.Market, Quantity: 1, TradeType: model.OrderTradeSwap}); err == nil || !strings.Contains(err.Error(), "Insufficient") {
+		t.Errorf("err = %v", err)
 	}
 }
 
-// 测试市价下单永续合约，并且带有止盈止损
-func TestOkxExchange_PlaceOrderSwap(t *testing.T) {
+func TestOkxChange_SetLeverage(t *testing.T) {
+	okxEx, srv := newTestOkx(t)
 
-	// 加载配置文件
-	okxConf, err := loadOkxConf()
+	err := okxEx.SetLeverage("SOL/USDT", 50, model.OrderMgnModeIsolated, model.OrderPosSideLong, model.OrderTradeSwap)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		t.Fatalf("SetLeverage error: %v", err)
 	}
-
-	if conf.AppConfig.Simulated {
-		goex.DefaultHttpCli.SetHeaders("x-simulated-trading", "1") // 设置为模拟环境
+	reqs := srv.Requests("POST", "/api/v5/account/set-leverage")
+	if len(reqs) != 1 || reqs[0].Body["instId"] != "SOL-USDT-SWAP" || reqs[0].Body["lever"] != "50" {
+		t.Errorf("set-leverage = %+v", reqs)
 	}
-
-	okxEx := NewOkxExchange(okxConf.ApiKey, okxConf.SecretKey, okxConf.Password)
-
-	order := model.Order{
-		Symbol:    "SOL/USDT",
-		Side:      model.Buy,
-		Price:     192.1,
-		Quantity:  1, // 限价Quantity 单位是币本身 == 1SOL
-		OrderType: model.Limit,
-		TPPrice:   192.2,
-		SLPrice:   190,
-		Strategy:  "Stragety1",
-		Comment:   "测试限价购买",
-		TradeType: model.OrderTradeSwap,
+	if err := okxEx.SetLeverage("SOL/USDT", 50, model.OrderMgnModeIsolated, "", model.OrderTradeSwap); err == nil {
+		t.Errorf("isolated without posSide should fail")
 	}
+}
 
-	// 获取市价
-	lastPrice, err := okxEx.GetLastPrice(order.Symbol, order.TradeType)
+func TestOkxExchange_GetPosition(t *testing.T) {
+	okxEx, srv := newTestOkx(t)
+	tradeType := model.OrderTradeSwap
+
+	long, short, err := okxEx.GetPosition("SOL/USDT", tradeType)
 	if err != nil {
-		order.SLPrice = 0
-		fmt.Printf("获取最新价格失败：%v", err)
-	} else {
-		// 订单的价格太偏离当前市场价，或止盈止损设置不符合规则，会触发okx的系统封控，报错"sCode": "51137",
-		order.Price = lastPrice
-		// 将止损价格设置低于最新价格一些，不然无法下单
-		if order.SLPrice > 0 {
-			order.SLPrice = lastPrice - 5
-		}
+		t.Fatalf("GetPosition error: %v", err)
 	}
-
-	resp, err := okxEx.PlaceOrder(context.Background(), &order)
-
-	if resp.OrderId == "" {
-		t.Errorf("Expected non-empty order ID")
-	} else {
-		t.Logf("Order ID: %s", resp.OrderId)
+	// 张数为 0 的空单忽略
+	if short != nil {
+		t.Errorf("short = %+v", short)
 	}
-}
-
-func TestOkxChange_SetLeverage(t *testing.T) {
-	goex.DefaultHttpCli.SetHeaders("x-simulated-trading", "1") // 设置为模拟环境
-	// 加载配置文件
-	okxConf, err := loadOkxConf()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	if long == nil || long.Amount != 1.2 || long.AvgPrice != 183.5 || long.AlgoId != "2045018394058174464" || long.SlTriggerPx != 178.1 || long.MgnMode != "isolated" || long.Last != 185.28 {
+		t.Fatalf("long = %+v", long)
 	}
 
-	okxEx := NewOkxExchange(okxConf.ApiKey, okxConf.SecretKey, okxConf.Password)
+	resp, err := okxEx.ClosePosition(long.Symbol, string(long.Dir), long.Amount, long.MgnMode, tradeType)
+	if err != nil || resp.OrderId == "" {
+		t.Fatalf("close resp=%+v err=%v", resp, err)
+	}
+	body := srv.Requests("POST", "/api/v5/trade/order")[0].Body
+	if body["side"] != "sell" || body["posSide"] != "long" || body["ordType"] != "market" || body["sz"] != "1.2" {
+		t.Errorf("close body = %+v", body)
+	}
 
-	err = okxEx.SetLeverage("SOL/USDT", 50, model.OrderMgnModeIsolated, model.OrderPosSideLong, model.OrderTradeSwap)
-	if err != nil {
-		t.Errorf("SetLeverage error: %v", err)
+	_, short, err = okxEx.GetPosition("BTC/USDT", tradeType)
+	if err != nil || short == nil || short.Amount != 0.5 || short.Lever != "10" {
+		t.Errorf("btc short=%+v err=%v", short, err)
 	}
 }
 
-func TestOkxExchange_GetPosition(t *testing.T) {
-	goex.DefaultHttpCli.SetHeaders("x-simulated-trading", "1") // 设置为模拟环境
-	// 加载配置文件
-	okxConf, err := loadOkxConf()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+func TestOkxExchange_AmendAlgoOrder(t *testing.T) {
+	okxEx, srv := newTestOkx(t)
+
+	if _, err := okxEx.AmendAlgoOrder("SOL-USDT-SWAP", model.OrderTradeSwap, "2045018394058174464", 184, 192); err != nil {
+		t.Fatalf("AmendAlgoOrder: %v", err)
+	}
+	body := srv.Requests("POST", "/api/v5/trade/amend-algo-order")[0].Body
+	if body["newSlTriggerPx"] != "184" || body["newTpTriggerPx"] != "192" {
+		t.Errorf("amend body = %+v", body)
 	}
+	if long, _, _ := okxEx.GetPosition("SOL/USDT", model.OrderTradeSwap); long == nil || long.SlTriggerPx != 184 {
+		t.Errorf("修改后止损触发价 = %+v", long)
+	}
+	if _, err := okxEx.AmendAlgoOrder("SOL-USDT-SWAP", model.OrderTradeSwap, "1", 184, 192); err == nil {
+		t.Errorf("unknown algoId should fail")
+	}
+}
 
-	okxEx := NewOkxExchange(okxConf.ApiKey, okxConf.SecretKey, okxConf.Password)
-	tradeType := model.OrderTradeSwap
-	long, short, err := okxEx.GetPosition("SOL/USDT", tradeType)
+func TestOkxExchange_GetKlineRecords(t *testing.T) {
+	okxEx, srv := newTestOkx(t)
 
+	lines, err := okxEx.GetKlineRecords("BTC/USDT", goexmodel.Kline_1h, 300, 0, 0, model.OrderTradeSwap, false)
 	if err != nil {
-		t.Errorf("GetPosition error: %v", err)
-	} else {
-		if long != nil {
-			err := okxEx.ClosePosition(long.Symbol, string(long.Side), long.Amount, long.MgnMode, tradeType)
-			if err != nil {
-				fmt.Printf("平多失败：%v", err)
-			} else {
-				fmt.Println("平多成功")
-			}
-		}
-		if short != nil {
-			err := okxEx.ClosePosition(short.Symbol, string(short.Side), short.Amount, short.MgnMode, tradeType)
-			if err != nil {
-				fmt.Printf("平空失败：%v", err)
-			} else {
-				fmt.Println("平空成功")
-			}
+		t.Fatal(err)
+	}
+	// 未收盘的一根被过滤
+	if len(lines) != 299 {
+		t.Fatalf("len = %d", len(lines))
+	}
+	for i := 1; i < len(lines); i++ {
+		if !lines[i].Timestamp.After(lines[i-1].Timestamp) {
+			t.Fatalf("klines should be oldest first at %d", i)
 		}
 	}
+	q := srv.Requests("GET", "/api/v5/market/candles")[0].Query
+	if q.Get("instId") != "BTC-USDT-SWAP" || q.Get("bar") != "1H" || q.Get("limit") != "300" {
+		t.Errorf("query = %v", q)
+	}
 
-}
-
-func TestOkxExchange_AmendAlgoOrder(t *testing.T) {
-	goex.DefaultHttpCli.SetHeaders("x-simulated-trading", "1") // 设置为模拟环境
-	// 加载配置文件
-	okxConf, err := loadOkxConf()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	srv.SetCandles("BTC-USDT-SWAP", "4H", [][]string{
+		{"1760688000000", "106000", "106600", "105900", "106480.5", "120", "1.2", "127776.6", "1"},
+	})
+	lines, err = okxEx.GetKlineRecords("BTC/USDT", goexmodel.Kline_4h, 10, 0, 0, model.OrderTradeSwap, false)
+	if err != nil || len(lines) != 1 || lines[0].Close != 106480.5 {
+		t.Errorf("lines=%+v err=%v", lines, err)
 	}
+}
 
-	okxEx := NewOkxExchange(okxConf.ApiKey, okxConf.SecretKey, okxConf.Password)
+func TestOkxExchange_Account(t *testing.T) {
+	okxEx, _ := newTestOkx(t)
 
-	_, err = okxEx.AmendAlgoOrder("ETH-USDT-SWAP", model.OrderTradeSwap, "2", 4445, 4530)
+	acc, err := okxEx.Account(model.OrderTradeSwap)
 	if err != nil {
-		fmt.Printf("AmendAlgoOrder：%v", err)
-	} else {
-		fmt.Println("AmendAlgoOrder 成功")
+		t.Fatal(err)
+	}
+	bal, err := acc.GetAccount(context.Background(), "USDT")
+	if err != nil || bal.Available != 9870.25 || bal.Total != 10004.83 || bal.Frozen != 134.58 {
+		t.Errorf("balance=%+v err=%v", bal, err)
 	}
 }
 
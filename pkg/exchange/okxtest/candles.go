@@ -0,0 +1,86 @@
+package okxtest
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// genCandles 生成以当前未收盘 K 线结尾的 n 根 K 线，从新到旧
+// 价格围绕最新价做正弦波动，同一时间戳的结果固定，多次请求之间保持一致
+// end 大于 0 时只生成 end 之前的 K 线
+func (s *Server) genCandles(instId, bar string, n int, end int64) [][]string {
+	d := barDuration(bar)
+	last, _ := strconv.ParseFloat(str(s.tickers[instId]["last"]), 64)
+	if d == 0 || last <= 0 {
+		return nil
+	}
+	cur := s.now().Truncate(d)
+	top := cur
+	if end > 0 {
+		if t := time.UnixMilli(end - 1).Truncate(d); t.Before(top) {
+			top = t
+		}
+	}
+	price := func(t time.Time) float64 {
+		k := float64(t.UnixMilli() / d.Milliseconds())
+		ref := float64(cur.UnixMilli() / d.Milliseconds())
+		wave := func(x float64) float64 { return 1 + 0.03*math.Sin(x/24) + 0.01*math.Sin(x/5) }
+		return last * wave(k) / wave(ref)
+	}
+
+	rows := make([][]string, 0, n)
+	for i := 0; i < n; i++ {
+		t := top.Add(-time.Duration(i) * d)
+		open, closePx := price(t.Add(-d)), price(t)
+		high := math.Max(open, closePx) * 1.001
+		low := math.Min(open, closePx) * 0.999
+		vol := 100 + float64(t.UnixMilli()/d.Milliseconds()%37)
+		confirm := "1"
+		if t.Equal(cur) {
+			confirm = "0"
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(t.UnixMilli(), 10),
+			formatPx(open), formatPx(high), formatPx(low), formatPx(closePx),
+			formatPx(vol), formatPx(vol), formatPx(vol * closePx),
+			confirm,
+		})
+	}
+	return rows
+}
+
+func formatPx(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+// barDuration OKX 的 K 线周期，小时及以上为大写
+func barDuration(bar string) time.Duration {
+	switch bar {
+	case "1m":
+		return time.Minute
+	case "3m":
+		return 3 * time.Minute
+	case "5m":
+		return 5 * time.Minute
+	case "15m":
+		return 15 * time.Minute
+	case "30m":
+		return 30 * time.Minute
+	case "1H":
+		return time.Hour
+	case "2H":
+		return 2 * time.Hour
+	case "4H":
+		return 4 * time.Hour
+	case "6H":
+		return 6 * time.Hour
+	case "12H":
+		return 12 * time.Hour
+	case "1D":
+		return 24 * time.Hour
+	case "1W":
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
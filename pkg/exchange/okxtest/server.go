@@ -0,0 +1,565 @@
+// Package okxtest 本地的 OKX 替身服务，集成测试不再需要真实的 API Key
+//
+// REST 接口按 testdata 中录制的响应返回（包括资金费率、持仓量和主动买卖量），下单、查单、撤单在内存中维护订单状态；
+// 公共 WS 支持 tickers 和 candle 频道，私有 WS 登录后支持 orders、positions、
+// balance_and_position 和 orders-algo 频道，订单和仓位变化会推送给订阅者。通过 Enqueue 可以按顺序预置错误码、限频等返回，
+// 通过 DropConnections、RejectDials 模拟断线和握手失败。
+package okxtest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed testdata/*.json
+var fixtures embed.FS
+
+// Reply 一次返回，Status 为 0 时按 200 处理
+type Reply struct {
+	Status int
+	Body   []byte
+}
+
+// OK 成功返回，data 为响应中的 data 数组
+func OK(data any) Reply {
+	return envelope(http.StatusOK, "0", "", data)
+}
+
+// Error 业务错误，例如 51001 交易对不存在
+func Error(code, msg string) Reply {
+	return envelope(http.StatusOK, code, msg, []any{})
+}
+
+// OrderError 下单类接口的失败，外层 code 为 1，具体原因在 data[0].sCode
+func OrderError(sCode, sMsg string) Reply {
+	return envelope(http.StatusOK, "1", "All operations failed", []map[string]string{
+		{"ordId": "", "clOrdId": "", "tag": "", "sCode": sCode, "sMsg": sMsg},
+	})
+}
+
+// RateLimited 触发限频，HTTP 429 + 50011
+func RateLimited() Reply {
+	return envelope(http.StatusTooManyRequests, "50011", "Too Many Requests", []any{})
+}
+
+// Unavailable 服务暂时不可用
+func Unavailable() Reply {
+	return envelope(http.StatusServiceUnavailable, "50001", "Service temporarily unavailable. Try again later", []any{})
+}
+
+func envelope(status int, code, msg string, data any) Reply {
+	body, _ := json.Marshal(map[string]any{"code": code, "msg": msg, "data": data})
+	return Reply{Status: status, Body: body}
+}
+
+// Request 服务端收到的 REST 请求，用于断言
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   map[string]any
+	Header http.Header
+}
+
+// Server 同一个端口上提供 REST 和 WS，URL 传给 OkxExchange.WithBaseURL
+type Server struct {
+	URL string
+	srv *httptest.Server
+
+	mu          sync.Mutex
+	instruments map[string][]json.RawMessage // instType(小写) -> 交易对
+	tickers     map[string]map[string]any    // instId -> ticker
+	positions   []map[string]any
+	balance     []json.RawMessage
+	orderTpl    map[string]any
+	candles     map[string][][]string // instId/bar -> 从新到旧
+	openInt     []map[string]any
+	funding     map[string]map[string]any // instId -> 资金费率
+	takerVol    map[string][][]string     // instId -> [ts, sellVol, buyVol]，从新到旧
+	orders      map[string]map[string]any
+	clOrdIds    map[string]string
+	nextOrdId   int64
+	scripts     map[string][]Reply
+	requests    []Request
+	now         func() time.Time
+
+	ws wsHub
+}
+
+// NewServer 启动本地服务，测试结束后调用 Close
+func NewServer() *Server {
+	s := &Server{
+		instruments: make(map[string][]json.RawMessage),
+		tickers:     make(map[string]map[string]any),
+		candles:     make(map[string][][]string),
+		funding:     make(map[string]map[string]any),
+		orders:      make(map[string]map[string]any),
+		clOrdIds:    make(map[string]string),
+		nextOrdId:   2045100000000000000,
+		scripts:     make(map[string][]Reply),
+		now:         time.Now,
+	}
+	s.ws.init()
+	s.loadFixtures()
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	return s
+}
+
+func (s *Server) Close() {
+	s.ws.closeAll()
+	s.srv.Close()
+}
+
+// PublicWSURL tickers 等公共频道
+func (s *Server) PublicWSURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/ws/v5/public"
+}
+
+// BusinessWSURL candle 频道
+func (s *Server) BusinessWSURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/ws/v5/business"
+}
+
+// PrivateWSURL 订单、仓位等私有频道，需要先登录
+func (s *Server) PrivateWSURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/ws/v5/private"
+}
+
+func (s *Server) loadFixtures() {
+	for _, instType := range []string{"spot", "swap"} {
+		s.instruments[instType] = mustFixture[[]json.RawMessage]("instruments_" + instType + ".json")
+	}
+	for _, t := range mustFixture[[]map[string]any]("tickers.json") {
+		s.tickers[t["instId"].(string)] = t
+	}
+	s.positions = mustFixture[[]map[string]any]("positions.json")
+	s.orderTpl = mustFixture[[]map[string]any]("order.json")[0]
+	s.balance = mustFixture[[]json.RawMessage]("balance.json")
+	s.openInt = mustFixture[[]map[string]any]("open_interest.json")
+	for _, f := range mustFixture[[]map[string]any]("funding_rates.json") {
+		s.funding[f["instId"].(string)] = f
+	}
+	s.takerVol = mustFixture[map[string][][]string]("taker_volume.json")
+}
+
+// mustFixture 解析录制的响应，返回其中的 data
+func mustFixture[T any](name string) T {
+	raw, err := fixtures.ReadFile("testdata/" + name)
+	if err != nil {
+		panic(err)
+	}
+	var resp struct {
+		Data T `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		panic(fmt.Sprintf("okxtest: decode %s: %v", name, err))
+	}
+	return resp.Data
+}
+
+// Enqueue 预置返回，method+path 的请求依次消费，用完后恢复默认行为
+func (s *Server) Enqueue(method, path string, replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := method + " " + path
+	s.scripts[key] = append(s.scripts[key], replies...)
+}
+
+// Requests 已收到的请求，path 为空时返回全部
+func (s *Server) Requests(method, path string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Request
+	for _, r := range s.requests {
+		if path == "" || (r.Method == method && r.Path == path) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// SetTicker 修改 tickers.json 中已有交易对的行情字段，例如 {"last": "101"}，并推送给 tickers 订阅者
+func (s *Server) SetTicker(instId string, fields map[string]string) {
+	s.mu.Lock()
+	t, ok := s.tickers[instId]
+	if !ok {
+		s.mu.Unlock()
+		panic("okxtest: unknown instId " + instId)
+	}
+	for k, v := range fields {
+		t[k] = v
+	}
+	t["ts"] = strconv.FormatInt(s.now().UnixMilli(), 10)
+	snapshot := copyMap(t)
+	s.mu.Unlock()
+
+	s.Push("tickers", instId, []any{snapshot})
+}
+
+// SetCandles 指定某个交易对和周期的 K 线，顺序为从新到旧，未指定时按最新价生成
+func (s *Server) SetCandles(instId, bar string, rows [][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candles[instId+"/"+bar] = rows
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/ws/") {
+		s.serveWS(w, r)
+		return
+	}
+
+	req := Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header.Clone()}
+	if body, _ := io.ReadAll(r.Body); len(body) > 0 {
+		_ = json.Unmarshal(body, &req.Body)
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	reply, scripted := s.popScript(r.Method + " " + r.URL.Path)
+	if !scripted {
+		reply = s.route(req)
+	}
+	s.mu.Unlock()
+
+	status := reply.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(reply.Body)
+}
+
+func (s *Server) popScript(key string) (Reply, bool) {
+	queue := s.scripts[key]
+	if len(queue) == 0 {
+		return Reply{}, false
+	}
+	s.scripts[key] = queue[1:]
+	return queue[0], true
+}
+
+// route 默认行为，调用时持有 s.mu
+func (s *Server) route(req Request) Reply {
+	private := strings.HasPrefix(req.Path, "/api/v5/trade/") || strings.HasPrefix(req.Path, "/api/v5/account/")
+	if private && req.Header.Get("OK-ACCESS-KEY") == "" {
+		return envelope(http.StatusUnauthorized, "50103", "Request header OK-ACCESS-KEY can not be empty.", []any{})
+	}
+
+	switch req.Method + " " + req.Path {
+	case "GET /api/v5/public/instruments":
+		return OK(s.instruments[strings.ToLower(req.Query.Get("instType"))])
+	case "GET /api/v5/market/ticker":
+		t, ok := s.tickers[req.Query.Get("instId")]
+		if !ok {
+			return Error("51001", "Instrument ID does not exist")
+		}
+		return OK([]any{t})
+	case "GET /api/v5/market/candles":
+		return s.getCandles(req.Query)
+	case "GET /api/v5/public/open-interest":
+		out := make([]map[string]any, 0, len(s.openInt))
+		for _, oi := range s.openInt {
+			if oi["instType"] == req.Query.Get("instType") {
+				out = append(out, oi)
+			}
+		}
+		return OK(out)
+	case "GET /api/v5/public/funding-rate":
+		f, ok := s.funding[req.Query.Get("instId")]
+		if !ok {
+			return Error("51001", "Instrument ID does not exist")
+		}
+		return OK([]any{f})
+	case "GET /api/v5/rubik/stat/taker-volume-contract":
+		rows, ok := s.takerVol[req.Query.Get("instId")]
+		if !ok {
+			return Error("51001", "Instrument ID does not exist")
+		}
+		if limit, _ := strconv.Atoi(req.Query.Get("limit")); limit > 0 && limit < len(rows) {
+			rows = rows[:limit]
+		}
+		return OK(rows)
+	case "POST /api/v5/trade/order":
+		return s.createOrder(req.Body)
+	case "GET /api/v5/trade/order":
+		ord := s.findOrder(req.Query.Get("ordId"), req.Query.Get("clOrdId"))
+		if ord == nil {
+			return Error("51603", "Order does not exist")
+		}
+		return OK([]any{ord})
+	case "POST /api/v5/trade/cancel-order":
+		ord := s.findOrder(str(req.Body["ordId"]), str(req.Body["clOrdId"]))
+		if ord == nil || ord["state"] == "filled" || ord["state"] == "canceled" {
+			return OrderError("51400", "Order cancellation failed as the order has been filled, canceled or does not exist")
+		}
+		ord["state"] = "canceled"
+		ord["uTime"] = strconv.FormatInt(s.now().UnixMilli(), 10)
+		s.pushOrder(ord)
+		return OK([]map[string]any{{"ordId": ord["ordId"], "clOrdId": ord["clOrdId"], "sCode": "0", "sMsg": ""}})
+	case "POST /api/v5/account/set-leverage":
+		return OK([]map[string]any{{
+			"instId": req.Body["instId"], "lever": req.Body["lever"], "mgnMode": req.Body["mgnMode"], "posSide": req.Body["posSide"],
+		}})
+	case "GET /api/v5/account/positions":
+		instId := req.Query.Get("instId")
+		out := make([]map[string]any, 0, len(s.positions))
+		for _, p := range s.positions {
+			if instId == "" || p["instId"] == instId {
+				out = append(out, p)
+			}
+		}
+		return OK(out)
+	case "GET /api/v5/account/balance":
+		return OK(s.balance)
+	case "POST /api/v5/trade/amend-algo-order":
+		algoId := str(req.Body["algoId"])
+		for _, p := range s.positions {
+			if algoId != "" && p["algoId"] == algoId {
+				if px := str(req.Body["newSlTriggerPx"]); px != "" {
+					setSlTrigger(p, algoId, px)
+				}
+				return OK([]map[string]any{{"algoId": algoId, "algoClOrdId": "", "reqId": "", "sCode": "0", "sMsg": ""}})
+			}
+		}
+		return OrderError("51603", "Order does not exist")
+	}
+	return envelope(http.StatusNotFound, "404", "Not Found", []any{})
+}
+
+// createOrder 市价单立即按最新价成交，限价单挂单；重复的 clOrdId 返回 51016
+func (s *Server) createOrder(body map[string]any) Reply {
+	instId := str(body["instId"])
+	ticker, ok := s.tickers[instId]
+	if !ok {
+		return OrderError("51001", "Instrument ID does not exist")
+	}
+	clOrdId := str(body["clOrdId"])
+	if _, dup := s.clOrdIds[clOrdId]; clOrdId != "" && dup {
+		return OrderError("51016", "Duplicated clOrdId")
+	}
+
+	s.nextOrdId++
+	ordId := strconv.FormatInt(s.nextOrdId, 10)
+	ord := copyMap(s.orderTpl)
+	for _, k := range []string{"instId", "clOrdId", "side", "posSide", "ordType", "px", "sz", "tdMode", "tag"} {
+		if v, ok := body[k]; ok {
+			ord[k] = v
+		}
+	}
+	ord["ordId"] = ordId
+	ord["instType"] = instType(instId)
+	ts := strconv.FormatInt(s.now().UnixMilli(), 10)
+	ord["cTime"], ord["uTime"] = ts, ts
+	if str(body["ordType"]) == "market" {
+		fillPx := str(ticker["last"])
+		sz, _ := strconv.ParseFloat(str(body["sz"]), 64)
+		px, _ := strconv.ParseFloat(fillPx, 64)
+		ord["state"] = "filled"
+		ord["accFillSz"], ord["fillSz"] = body["sz"], body["sz"]
+		ord["avgPx"], ord["fillPx"] = fillPx, fillPx
+		ord["fee"] = strconv.FormatFloat(-sz*px*0.0005, 'f', 8, 64)
+	} else {
+		ord["state"] = "live"
+		ord["accFillSz"], ord["avgPx"], ord["fee"] = "0", "", "0"
+	}
+	s.orders[ordId] = ord
+	if clOrdId != "" {
+		s.clOrdIds[clOrdId] = ordId
+	}
+	s.pushOrder(ord)
+	return OK([]map[string]any{{"ordId": ordId, "clOrdId": clOrdId, "tag": str(body["tag"]), "ts": ts, "sCode": "0", "sMsg": "Order placed"}})
+}
+
+// FillOrder 按 px 成交挂单，sz 为本次成交数量，为空时全部成交，并推送给 orders 订阅者
+func (s *Server) FillOrder(ordId, px, sz string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ord, ok := s.orders[ordId]
+	if !ok {
+		panic("okxtest: unknown ordId " + ordId)
+	}
+	total, _ := strconv.ParseFloat(str(ord["sz"]), 64)
+	filled, _ := strconv.ParseFloat(str(ord["accFillSz"]), 64)
+	fill := total - filled
+	if sz != "" {
+		fill, _ = strconv.ParseFloat(sz, 64)
+	}
+	price, _ := strconv.ParseFloat(px, 64)
+	avg, _ := strconv.ParseFloat(str(ord["avgPx"]), 64)
+	fee, _ := strconv.ParseFloat(str(ord["fee"]), 64)
+
+	avg = (avg*filled + price*fill) / (filled + fill)
+	filled += fill
+	ord["accFillSz"] = formatPx(filled)
+	ord["fillSz"], ord["fillPx"] = formatPx(fill), px
+	ord["avgPx"] = formatPx(avg)
+	ord["fee"] = strconv.FormatFloat(fee-fill*price*0.0002, 'f', 8, 64)
+	ord["state"] = "partially_filled"
+	if filled >= total {
+		ord["state"] = "filled"
+	}
+	ord["uTime"] = strconv.FormatInt(s.now().UnixMilli(), 10)
+	s.pushOrder(ord)
+}
+
+// SetPosition 修改 positions.json 中 instId+posSide 的仓位字段，例如 {"pos": "0"}，并推送给 positions 订阅者
+func (s *Server) SetPosition(instId, posSide string, fields map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.positions {
+		if p["instId"] == instId && p["posSide"] == posSide {
+			for k, v := range fields {
+				p[k] = v
+			}
+			p["uTime"] = strconv.FormatInt(s.now().UnixMilli(), 10)
+			s.Push("positions", "", []any{positionPush(p)})
+			return
+		}
+	}
+	panic("okxtest: unknown position " + instId + " " + posSide)
+}
+
+// pushOrder 调用时持有 s.mu
+func (s *Server) pushOrder(ord map[string]any) {
+	s.Push("orders", "", []any{copyMap(ord)})
+}
+
+// positionsSnapshot 订阅 positions 后的首次推送，与交易所一样不包含数量为 0 的仓位
+func (s *Server) positionsSnapshot() []any {
+	out := []any{}
+	for _, p := range s.positions {
+		if pos, _ := strconv.ParseFloat(str(p["pos"]), 64); pos != 0 {
+			out = append(out, positionPush(p))
+		}
+	}
+	return out
+}
+
+// setSlTrigger 修改仓位止盈止损中的止损触发价，没有 closeOrderAlgo 时补上
+func setSlTrigger(p map[string]any, algoId, px string) {
+	algos, _ := p["closeOrderAlgo"].([]any)
+	for _, a := range algos {
+		if algo, ok := a.(map[string]any); ok && str(algo["algoId"]) == algoId {
+			algo["slTriggerPx"] = px
+			return
+		}
+	}
+	p["closeOrderAlgo"] = append(algos, map[string]any{"algoId": algoId, "slTriggerPx": px})
+}
+
+// positionPush 推送中的止盈止损在 closeOrderAlgo 中
+func positionPush(p map[string]any) map[string]any {
+	out := copyMap(p)
+	if algos, ok := p["closeOrderAlgo"].([]any); ok {
+		list := make([]any, 0, len(algos))
+		for _, a := range algos {
+			if algo, ok := a.(map[string]any); ok {
+				list = append(list, copyMap(algo))
+			}
+		}
+		out["closeOrderAlgo"] = list
+		return out
+	}
+	out["closeOrderAlgo"] = []any{}
+	if algoId := str(p["algoId"]); algoId != "" {
+		out["closeOrderAlgo"] = []map[string]string{{"algoId": algoId}}
+	}
+	return out
+}
+
+// balanceSnapshot balance_and_position 推送，balData 来自 balance.json
+func (s *Server) balanceSnapshot() []any {
+	var acc struct {
+		UTime   string `json:"uTime"`
+		Details []struct {
+			Ccy     string `json:"ccy"`
+			CashBal string `json:"cashBal"`
+			UTime   string `json:"uTime"`
+		} `json:"details"`
+	}
+	if len(s.balance) == 0 || json.Unmarshal(s.balance[0], &acc) != nil {
+		return nil
+	}
+	balData := make([]map[string]string, 0, len(acc.Details))
+	for _, d := range acc.Details {
+		balData = append(balData, map[string]string{"ccy": d.Ccy, "cashBal": d.CashBal, "uTime": d.UTime})
+	}
+	return []any{map[string]any{"pTime": acc.UTime, "eventType": "snapshot", "balData": balData, "posData": []any{}}}
+}
+
+func instType(instId string) string {
+	if strings.HasSuffix(instId, "-SWAP") {
+		return "SWAP"
+	}
+	return "SPOT"
+}
+
+func (s *Server) findOrder(ordId, clOrdId string) map[string]any {
+	if ordId == "" {
+		ordId = s.clOrdIds[clOrdId]
+	}
+	return s.orders[ordId]
+}
+
+// getCandles 支持 bar/limit/after/before，结果从新到旧
+func (s *Server) getCandles(q url.Values) Reply {
+	instId := q.Get("instId")
+	if _, ok := s.tickers[instId]; !ok {
+		return Error("51001", "Instrument ID does not exist")
+	}
+	bar := q.Get("bar")
+	if bar == "" {
+		bar = "1m"
+	}
+	if barDuration(bar) == 0 {
+		return Error("51000", "Parameter bar error")
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 || limit > 300 {
+		limit = 100
+	}
+	after, _ := strconv.ParseInt(q.Get("after"), 10, 64)
+	before, _ := strconv.ParseInt(q.Get("before"), 10, 64)
+
+	rows, ok := s.candles[instId+"/"+bar]
+	if !ok {
+		rows = s.genCandles(instId, bar, 300, after)
+	}
+	out := make([][]string, 0, limit)
+	for _, row := range rows {
+		ts, _ := strconv.ParseInt(row[0], 10, 64)
+		if (after > 0 && ts >= after) || (before > 0 && ts <= before) {
+			continue
+		}
+		out = append(out, row)
+		if len(out) == limit {
+			break
+		}
+	}
+	return OK(out)
+}
+
+func str(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func copyMap(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,312 @@
+package okxtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+type wsHub struct {
+	mu     sync.Mutex
+	conns  map[*wsConn]struct{}
+	dials  int
+	reject int
+
+	rejectLogins int
+}
+
+type wsConn struct {
+	conn     *websocket.Conn
+	id       string
+	business bool
+	private  bool
+	loggedIn bool // 由 wsHub.mu 保护
+	wmu      sync.Mutex
+	subs     map[string]struct{} // channel/instId，由 wsHub.mu 保护
+}
+
+func (h *wsHub) init() {
+	h.conns = make(map[*wsConn]struct{})
+}
+
+func (h *wsHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.conns {
+		_ = c.conn.Close()
+	}
+}
+
+func (c *wsConn) write(v any) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if b, ok := v.([]byte); ok {
+		_ = c.conn.WriteMessage(websocket.TextMessage, b)
+		return
+	}
+	_ = c.conn.WriteJSON(v)
+}
+
+// DropConnections 服务端主动断开所有 WS 连接，返回断开的数量
+func (s *Server) DropConnections() int {
+	s.ws.mu.Lock()
+	defer s.ws.mu.Unlock()
+	n := len(s.ws.conns)
+	for c := range s.ws.conns {
+		_ = c.conn.Close()
+	}
+	return n
+}
+
+// RejectDials 之后的 n 次握手直接返回 503
+func (s *Server) RejectDials(n int) {
+	s.ws.mu.Lock()
+	defer s.ws.mu.Unlock()
+	s.ws.reject = n
+}
+
+// Dials 成功建立的 WS 连接次数，包括重连
+func (s *Server) Dials() int {
+	s.ws.mu.Lock()
+	defer s.ws.mu.Unlock()
+	return s.ws.dials
+}
+
+// Subscribed 当前是否有连接订阅了该频道
+func (s *Server) Subscribed(channel, instId string) bool {
+	s.ws.mu.Lock()
+	defer s.ws.mu.Unlock()
+	for c := range s.ws.conns {
+		if _, ok := c.subs[channel+"/"+instId]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitSubscribed 等待客户端完成订阅，重连后用来确认订阅已经恢复
+func (s *Server) WaitSubscribed(channel, instId string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if s.Subscribed(channel, instId) {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+// Push 向订阅了 channel/instId 的连接推送数据，data 为推送中的 data 数组。
+// 私有频道按 instType 订阅，instId 传空字符串
+func (s *Server) Push(channel, instId string, data any) {
+	msg, _ := json.Marshal(map[string]any{
+		"arg":  pushArg(channel, instId),
+		"data": data,
+	})
+	s.ws.mu.Lock()
+	var targets []*wsConn
+	for c := range s.ws.conns {
+		if _, ok := c.subs[channel+"/"+instId]; ok {
+			targets = append(targets, c)
+		}
+	}
+	s.ws.mu.Unlock()
+	for _, c := range targets {
+		c.write(msg)
+	}
+}
+
+// PushCandle 推送一根 K 线，row 为 [ts,o,h,l,c,vol,volCcy,volCcyQuote,confirm]
+func (s *Server) PushCandle(instId, bar string, row []string) {
+	s.Push("candle"+bar, instId, [][]string{row})
+}
+
+func pushArg(channel, instId string) map[string]string {
+	if instId == "" {
+		return map[string]string{"channel": channel, "instType": "ANY"}
+	}
+	return map[string]string{"channel": channel, "instId": instId}
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	s.ws.mu.Lock()
+	if s.ws.reject > 0 {
+		s.ws.reject--
+		s.ws.mu.Unlock()
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	s.ws.mu.Unlock()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.ws.mu.Lock()
+	s.ws.dials++
+	c := &wsConn{
+		conn:     conn,
+		id:       fmt.Sprintf("%08x", s.ws.dials),
+		business: strings.HasSuffix(r.URL.Path, "/business"),
+		private:  strings.HasSuffix(r.URL.Path, "/private"),
+		subs:     make(map[string]struct{}),
+	}
+	s.ws.conns[c] = struct{}{}
+	s.ws.mu.Unlock()
+
+	defer func() {
+		s.ws.mu.Lock()
+		delete(s.ws.conns, c)
+		s.ws.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.handleWS(c, msg)
+	}
+}
+
+type wsRequest struct {
+	Op   string              `json:"op"`
+	Args []map[string]string `json:"args"`
+}
+
+func (s *Server) handleWS(c *wsConn, msg []byte) {
+	if string(msg) == "ping" {
+		c.write([]byte("pong"))
+		return
+	}
+	var req wsRequest
+	if err := json.Unmarshal(msg, &req); err != nil || (req.Op != "subscribe" && req.Op != "unsubscribe" && req.Op != "login") {
+		c.write(map[string]string{"event": "error", "code": "60012", "msg": "Invalid request: " + string(msg), "connId": c.id})
+		return
+	}
+	if req.Op == "login" {
+		s.login(c, req.Args)
+		return
+	}
+
+	for _, arg := range req.Args {
+		channel, instId := arg["channel"], arg["instId"]
+		if c.private && !s.loggedIn(c) {
+			c.write(map[string]string{"event": "error", "code": "60011", "msg": "Please log in", "connId": c.id})
+			continue
+		}
+		if !s.validChannel(c, channel, instId) {
+			c.write(map[string]string{
+				"event":  "error",
+				"code":   "60018",
+				"msg":    fmt.Sprintf("Wrong URL or channel:%s,instId:%s doesn't exist. Please use the correct URL, channel and parameters referring to API document.", channel, instId),
+				"connId": c.id,
+			})
+			continue
+		}
+
+		s.ws.mu.Lock()
+		if req.Op == "subscribe" {
+			c.subs[channel+"/"+instId] = struct{}{}
+		} else {
+			delete(c.subs, channel+"/"+instId)
+		}
+		s.ws.mu.Unlock()
+		c.write(map[string]any{"event": req.Op, "arg": arg, "connId": c.id})
+
+		// 订阅成功后和交易所一样先推送一次当前数据
+		if req.Op == "subscribe" {
+			if snapshot := s.snapshot(channel, instId); snapshot != nil {
+				c.write(map[string]any{"arg": arg, "data": snapshot})
+			}
+		}
+	}
+}
+
+// login 不校验签名，只检查参数是否齐全；RejectLogins 之后的登录返回 60009
+func (s *Server) login(c *wsConn, args []map[string]string) {
+	s.ws.mu.Lock()
+	reject := s.ws.rejectLogins > 0
+	if reject {
+		s.ws.rejectLogins--
+	}
+	s.ws.mu.Unlock()
+
+	if len(args) != 1 || args[0]["apiKey"] == "" || args[0]["passphrase"] == "" || args[0]["timestamp"] == "" || args[0]["sign"] == "" {
+		c.write(map[string]string{"event": "error", "code": "60009", "msg": "Login failed.", "connId": c.id})
+		return
+	}
+	if reject {
+		c.write(map[string]string{"event": "error", "code": "60009", "msg": "Login failed.", "connId": c.id})
+		return
+	}
+	s.ws.mu.Lock()
+	c.loggedIn = true
+	s.ws.mu.Unlock()
+	c.write(map[string]string{"event": "login", "code": "0", "msg": "", "connId": c.id})
+}
+
+func (s *Server) loggedIn(c *wsConn) bool {
+	s.ws.mu.Lock()
+	defer s.ws.mu.Unlock()
+	return c.loggedIn
+}
+
+// RejectLogins 之后的 n 次私有频道登录失败
+func (s *Server) RejectLogins(n int) {
+	s.ws.mu.Lock()
+	defer s.ws.mu.Unlock()
+	s.ws.rejectLogins = n
+}
+
+func (s *Server) validChannel(c *wsConn, channel, instId string) bool {
+	if c.private {
+		switch channel {
+		case "orders", "positions", "balance_and_position", "orders-algo":
+			return true
+		}
+		return false
+	}
+	s.mu.Lock()
+	_, known := s.tickers[instId]
+	s.mu.Unlock()
+	if !known {
+		return false
+	}
+	if c.business {
+		return strings.HasPrefix(channel, "candle") && barDuration(strings.TrimPrefix(channel, "candle")) > 0
+	}
+	return channel == "tickers"
+}
+
+func (s *Server) snapshot(channel, instId string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch channel {
+	case "tickers":
+		return []any{copyMap(s.tickers[instId])}
+	case "positions":
+		return s.positionsSnapshot()
+	case "balance_and_position":
+		return s.balanceSnapshot()
+	case "orders", "orders-algo":
+		return nil
+	}
+	bar := strings.TrimPrefix(channel, "candle")
+	rows, ok := s.candles[instId+"/"+bar]
+	if !ok {
+		rows = s.genCandles(instId, bar, 1, 0)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return rows[:1]
+}
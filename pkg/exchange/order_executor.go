@@ -18,8 +18,8 @@ type Exchange interface {
 	GetOrderStatus(orderID string, symbol string, tradingType model.OrderTradeType) (*model.OrderStatus, error)
 	// 获取仓位
 	GetPosition(symbol string, tradeType model.OrderTradeType) (long *model.PositionInfo, short *model.PositionInfo, err error)
-	// 平仓
-	ClosePosition(symbol string, side string, quantity float64, tdMode string, tradeType model.OrderTradeType) error
+	// 平仓，返回平仓单的订单id
+	ClosePosition(symbol string, side string, quantity float64, tdMode string, tradeType model.OrderTradeType) (*model.OrderResponse, error)
 	Account(tradeType model.OrderTradeType) (Account, error)
 	AmendAlgoOrder(instId string, tradeType model.OrderTradeType, algoId string, newSlTriggerPx, newTpTriggerPx float64) ([]byte, error)
 	/*
@@ -35,6 +35,16 @@ type Exchange interface {
 	GetKlineRecords(symbol string, period model2.KlinePeriod, size int, start, end int64, tradeType model.OrderTradeType, includeUnclosed bool) ([]model.Kline, error)
 }
 
+// LotRulesProvider 提供交易对的下单精度，仓位计算按此取整
+type LotRulesProvider interface {
+	GetLotRules(symbol string, tradeType model.OrderTradeType) (*model.LotRules, error)
+}
+
+// QuoteProvider 提供盘口买一卖一价，限价单追价时使用，不支持时使用最新成交价
+type QuoteProvider interface {
+	GetBestQuote(symbol string, tradeType model.OrderTradeType) (bid, ask float64, err error)
+}
+
 // Account 账号结构接口
 type Account interface {
 	// 返回可用 USDT 余额
@@ -0,0 +1,652 @@
+package exchange
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	"edgeflow/pkg/account"
+	"edgeflow/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nntaoli-project/goex/v2/model"
+)
+
+// MarketData 模拟盘依赖的行情接口，OkxExchange 的公共行情接口不需要 API Key 即可满足
+type MarketData interface {
+	GetLastPrice(symbol string, tradingType model2.OrderTradeType) (float64, error)
+	GetKlineRecords(symbol string, period model.KlinePeriod, size int, start, end int64, tradeType model2.OrderTradeType, includeUnclosed bool) ([]model2.Kline, error)
+}
+
+var (
+	_ Exchange         = (*PaperExchange)(nil)
+	_ MarketData       = (*OkxExchange)(nil)
+	_ LotRulesProvider = (*PaperExchange)(nil)
+	_ LotRulesProvider = (*OkxExchange)(nil)
+	_ QuoteProvider    = (*OkxExchange)(nil)
+)
+
+// PaperConfig 模拟盘参数
+type PaperConfig struct {
+	Balance         float64 // 初始 USDT 余额
+	TakerFeeRate    float64 // 市价单/立即成交的手续费率
+	MakerFeeRate    float64 // 挂单成交的手续费率
+	Slippage        float64 // 市价单滑点比例
+	DefaultLeverage int     // 订单未指定杠杆时使用，与 OkxSwap 一致默认 20
+}
+
+func DefaultPaperConfig() PaperConfig {
+	return PaperConfig{
+		Balance:         10000,
+		TakerFeeRate:    0.0005,
+		MakerFeeRate:    0.0002,
+		Slippage:        0.0002,
+		DefaultLeverage: 20,
+	}
+}
+
+// 模拟仓位，双向持仓 + 逐仓保证金，合约面值视为 1 币
+type paperPosition struct {
+	symbol   string
+	dir      model2.OrderPosSide
+	qty      float64
+	avgPrice float64
+	margin   float64
+	leverage int
+	mgnMode  model2.OrderMgnMode
+	openTime time.Time
+	posId    string
+
+	// 仓位上的止盈止损，对应 OKX 的 attachAlgoOrds
+	algoId string
+	tp     float64
+	sl     float64
+}
+
+type paperOrder struct {
+	id        string
+	symbol    string
+	dir       model2.OrderPosSide
+	closing   bool // 平仓单
+	orderType model2.OrderType
+	price     float64
+	qty       float64
+	filled    float64
+	avgPx     float64
+	fee       float64
+	status    model.OrderStatus
+	leverage  int
+	mgnMode   model2.OrderMgnMode
+	frozen    float64 // 挂单冻结的保证金
+	tp        float64
+	sl        float64
+	createdAt time.Time
+}
+
+// PaperExchange 进程内模拟盘，实现 Exchange 接口
+// 行情来自 MarketData，订单在本地撮合：市价单立即成交，限价单在价格穿越时成交，并按最新价触发止盈止损
+type PaperExchange struct {
+	mu     sync.Mutex
+	market MarketData
+	cfg    PaperConfig
+
+	cash      float64 // 钱包余额（含已占用保证金）
+	positions map[string]*paperPosition
+	orders    map[string]*paperOrder
+	clOrdIds  map[string]string // clOrdId -> 订单ID，与 okx 一样拒绝重复的 clOrdId
+	prices    map[string]float64
+	seq       int64
+}
+
+func NewPaperExchange(market MarketData, cfg PaperConfig) *PaperExchange {
+	if cfg.DefaultLeverage <= 0 {
+		cfg.DefaultLeverage = 20
+	}
+	return &PaperExchange{
+		market:    market,
+		cfg:       cfg,
+		cash:      cfg.Balance,
+		positions: make(map[string]*paperPosition),
+		orders:    make(map[string]*paperOrder),
+		clOrdIds:  make(map[string]string),
+		prices:    make(map[string]float64),
+	}
+}
+
+// paperSymbol 统一为 BTC/USDT，兼容 BTC-USDT-SWAP 和 BTCUSDT
+func paperSymbol(symbol string) string {
+	if strings.Contains(symbol, "-") {
+		parts := strings.Split(symbol, "-")
+		return parts[0] + "/" + parts[1]
+	}
+	return utils.FormatSymbol(symbol)
+}
+
+func positionKey(symbol string, dir model2.OrderPosSide) string {
+	return symbol + ":" + string(dir)
+}
+
+func (e *PaperExchange) nextId(prefix string) string {
+	e.seq++
+	return fmt.Sprintf("%s-%d", prefix, e.seq)
+}
+
+// refreshPrice 拉取最新价并处理挂单和止盈止损
+func (e *PaperExchange) refreshPrice(symbol string) (float64, error) {
+	price, err := e.market.GetLastPrice(symbol, model2.OrderTradeSwap)
+	if err != nil {
+		return 0, err
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("invalid price for %s: %v", symbol, price)
+	}
+	e.OnPrice(symbol, price)
+	return price, nil
+}
+
+// OnPrice 推送最新价：成交穿价的限价单，触发止盈止损和强平
+// 可以直接接 ticker 推送，也可以由 Run 轮询驱动
+func (e *PaperExchange) OnPrice(symbol string, price float64) {
+	symbol = paperSymbol(symbol)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.prices[symbol] = price
+
+	for _, o := range e.orders {
+		if o.symbol != symbol || o.status != model.OrderStatus_Pending {
+			continue
+		}
+		// 买单价格跌到委托价以下成交，卖单价格涨到委托价以上成交
+		buy := o.dir == model2.OrderPosSideLong
+		if (buy && price <= o.price) || (!buy && price >= o.price) {
+			e.cash += o.frozen
+			o.frozen = 0
+			if err := e.fillOpenLocked(o, o.price, e.cfg.MakerFeeRate); err != nil {
+				o.status = model.OrderStatus_Canceled
+				log.Printf("[PaperExchange] 挂单 %s 成交失败，已撤销: %v", o.id, err)
+			}
+		}
+	}
+
+	for _, dir := range []model2.OrderPosSide{model2.OrderPosSideLong, model2.OrderPosSideShort} {
+		p := e.positions[positionKey(symbol, dir)]
+		if p == nil {
+			continue
+		}
+		long := dir == model2.OrderPosSideLong
+		switch {
+		case e.unrealized(p, price) <= -p.margin:
+			// 逐仓亏完保证金，按强平处理
+			log.Printf("[PaperExchange] %s %s 触发强平 price=%v", symbol, dir, price)
+			e.closeLocked(p, p.qty, price, "liquidation")
+		case p.sl > 0 && ((long && price <= p.sl) || (!long && price >= p.sl)):
+			e.closeLocked(p, p.qty, e.withSlippage(price, !long), "sl")
+		case p.tp > 0 && ((long && price >= p.tp) || (!long && price <= p.tp)):
+			e.closeLocked(p, p.qty, e.withSlippage(price, !long), "tp")
+		}
+	}
+}
+
+// Run 定时为有持仓或挂单的币种拉取最新价，直到 ctx 结束
+func (e *PaperExchange) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, symbol := range e.activeSymbols() {
+			if _, err := e.refreshPrice(symbol); err != nil {
+				log.Printf("[PaperExchange] 获取 %s 最新价失败: %v", symbol, err)
+			}
+		}
+	}
+}
+
+func (e *PaperExchange) activeSymbols() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	set := make(map[string]struct{})
+	for _, p := range e.positions {
+		set[p.symbol] = struct{}{}
+	}
+	for _, o := range e.orders {
+		if o.status == model.OrderStatus_Pending {
+			set[o.symbol] = struct{}{}
+		}
+	}
+	symbols := make([]string, 0, len(set))
+	for s := range set {
+		symbols = append(symbols, s)
+	}
+	return symbols
+}
+
+func (e *PaperExchange) GetLastPrice(symbol string, tradingType model2.OrderTradeType) (float64, error) {
+	return e.refreshPrice(paperSymbol(symbol))
+}
+
+func (e *PaperExchange) GetKlineRecords(symbol string, period model.KlinePeriod, size int, start, end int64, tradeType model2.OrderTradeType, includeUnclosed bool) ([]model2.Kline, error) {
+	return e.market.GetKlineRecords(symbol, period, size, start, end, tradeType, includeUnclosed)
+}
+
+// 下单，语义与 OkxSwap.PlaceOrder 一致：buy 开多，sell 开空，QuantityPct 按可用余额和杠杆计算数量
+func (e *PaperExchange) PlaceOrder(ctx context.Context, order *model2.Order) (*model2.OrderResponse, error) {
+	if order.TradeType == model2.OrderTradeSpot {
+		return nil, errors.New("模拟盘只支持合约交易")
+	}
+	symbol := paperSymbol(order.Symbol)
+
+	var dir model2.OrderPosSide
+	switch strings.ToLower(string(order.Side)) {
+	case "buy":
+		dir = model2.OrderPosSideLong
+	case "sell":
+		dir = model2.OrderPosSideShort
+	default:
+		return nil, errors.New("invalid order side")
+	}
+
+	last, err := e.refreshPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Leverage <= 0 {
+		order.Leverage = e.cfg.DefaultLeverage
+	}
+	if order.MgnMode == "" {
+		order.MgnMode = model2.OrderMgnModeIsolated
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if id, ok := e.clOrdIds[order.ClientOrderId]; ok && order.ClientOrderId != "" {
+		return &model2.OrderResponse{OrderId: id, Status: int(e.orders[id].status), Duplicate: true}, nil
+	}
+
+	price := order.Price
+	if order.OrderType != model2.Limit || price <= 0 {
+		price = last
+	}
+	if order.QuantityPct > 0 {
+		// 0.98 与 OkxSwap 一致，预留价差和手续费
+		order.Quantity = e.availableLocked() * order.QuantityPct * 0.98 * float64(order.Leverage) / price
+	}
+	if order.Quantity <= 0 {
+		return nil, fmt.Errorf("您的账户余额不足，Quantity:%v 不足以开仓", order.Quantity)
+	}
+
+	o := &paperOrder{
+		id:        e.nextId("paper"),
+		symbol:    symbol,
+		dir:       dir,
+		orderType: order.OrderType,
+		price:     price,
+		qty:       order.Quantity,
+		status:    model.OrderStatus_Pending,
+		leverage:  order.Leverage,
+		mgnMode:   order.MgnMode,
+		tp:        order.TPPrice,
+		sl:        order.SLPrice,
+		createdAt: time.Now(),
+	}
+
+	// 限价单未穿价时挂单，冻结保证金
+	buy := dir == model2.OrderPosSideLong
+	if order.OrderType == model2.Limit && ((buy && last > price) || (!buy && last < price)) {
+		frozen := o.qty * price / float64(o.leverage)
+		if frozen > e.availableLocked() {
+			return nil, errors.New("可用保证金不足")
+		}
+		e.cash -= frozen
+		o.frozen = frozen
+		e.saveOrderLocked(o, order.ClientOrderId)
+		return &model2.OrderResponse{OrderId: o.id, Status: int(o.status)}, nil
+	}
+
+	fillPx := e.withSlippage(last, buy)
+	if order.OrderType == model2.Limit {
+		// 限价单立即成交不会比委托价更差
+		fillPx = math.Min(last, price)
+		if !buy {
+			fillPx = math.Max(last, price)
+		}
+	}
+	if err := e.fillOpenLocked(o, fillPx, e.cfg.TakerFeeRate); err != nil {
+		return nil, err
+	}
+	e.saveOrderLocked(o, order.ClientOrderId)
+	return &model2.OrderResponse{OrderId: o.id, Status: int(o.status)}, nil
+}
+
+func (e *PaperExchange) saveOrderLocked(o *paperOrder, clOrdId string) {
+	e.orders[o.id] = o
+	if clOrdId != "" {
+		e.clOrdIds[clOrdId] = o.id
+	}
+}
+
+// fillOpenLocked 开仓单成交：扣手续费、占用保证金，合并到仓位并设置止盈止损
+func (e *PaperExchange) fillOpenLocked(o *paperOrder, px, feeRate float64) error {
+	margin := o.qty * px / float64(o.leverage)
+	fee := o.qty * px * feeRate
+	if margin+fee > e.availableLocked() {
+		return errors.New("可用保证金不足")
+	}
+	e.cash -= fee
+
+	key := positionKey(o.symbol, o.dir)
+	p := e.positions[key]
+	if p == nil {
+		p = &paperPosition{
+			symbol:   o.symbol,
+			dir:      o.dir,
+			leverage: o.leverage,
+			mgnMode:  o.mgnMode,
+			openTime: time.Now(),
+			posId:    e.nextId("paper-pos"),
+		}
+		e.positions[key] = p
+	}
+	p.avgPrice = (p.avgPrice*p.qty + px*o.qty) / (p.qty + o.qty)
+	p.qty += o.qty
+	p.margin += margin
+	if o.tp > 0 || o.sl > 0 {
+		// 新的止盈止损覆盖仓位上原有的
+		p.algoId = e.nextId("paper-algo")
+		p.tp = o.tp
+		p.sl = o.sl
+	}
+
+	o.filled = o.qty
+	o.avgPx = px
+	o.fee = fee
+	o.status = model.OrderStatus_Finished
+	return nil
+}
+
+// closeLocked 按成交价平掉 qty 数量，释放对应比例的保证金
+func (e *PaperExchange) closeLocked(p *paperPosition, qty, px float64, reason string) *paperOrder {
+	if qty > p.qty {
+		qty = p.qty
+	}
+	ratio := qty / p.qty
+	pnl := qty * (px - p.avgPrice)
+	if p.dir == model2.OrderPosSideShort {
+		pnl = -pnl
+	}
+	fee := qty * px * e.cfg.TakerFeeRate
+	releasedMargin := p.margin * ratio
+	if pnl < -releasedMargin {
+		// 逐仓最多亏掉保证金
+		pnl = -releasedMargin
+	}
+	e.cash += pnl - fee
+
+	p.qty -= qty
+	p.margin -= releasedMargin
+	if p.qty <= 1e-12 {
+		delete(e.positions, positionKey(p.symbol, p.dir))
+	}
+
+	o := &paperOrder{
+		id:        e.nextId("paper"),
+		symbol:    p.symbol,
+		dir:       p.dir,
+		closing:   true,
+		orderType: model2.Market,
+		price:     px,
+		qty:       qty,
+		filled:    qty,
+		avgPx:     px,
+		fee:       fee,
+		status:    model.OrderStatus_Finished,
+		leverage:  p.leverage,
+		mgnMode:   p.mgnMode,
+		createdAt: time.Now(),
+	}
+	e.orders[o.id] = o
+	log.Printf("[PaperExchange] 平仓 %s %s qty=%v px=%v pnl=%.4f fee=%.4f reason=%s", p.symbol, p.dir, qty, px, pnl, fee, reason)
+	return o
+}
+
+func (e *PaperExchange) CancelOrder(orderID, symbol string, tradingType model2.OrderTradeType) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	o, ok := e.orders[orderID]
+	if !ok {
+		return fmt.Errorf("order %s not found", orderID)
+	}
+	if o.status != model.OrderStatus_Pending {
+		return fmt.Errorf("order %s is %s, cannot cancel", orderID, o.status)
+	}
+	e.cash += o.frozen
+	o.frozen = 0
+	o.status = model.OrderStatus_Canceled
+	return nil
+}
+
+// GetLotRules 沿用行情源的下单精度，模拟盘数量单位为币，合约的张数换算成币
+func (e *PaperExchange) GetLotRules(symbol string, tradeType model2.OrderTradeType) (*model2.LotRules, error) {
+	provider, ok := e.market.(LotRulesProvider)
+	if !ok {
+		return &model2.LotRules{}, nil
+	}
+	rules, err := provider.GetLotRules(symbol, tradeType)
+	if err != nil {
+		return nil, err
+	}
+	out := *rules
+	if out.CtVal > 0 {
+		out.LotSize *= out.CtVal
+		out.MinQty *= out.CtVal
+		out.CtVal = 0
+	}
+	return &out, nil
+}
+
+func (e *PaperExchange) GetOrderStatus(orderID string, symbol string, tradingType model2.OrderTradeType) (*model2.OrderStatus, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	o, ok := e.orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("order %s not found", orderID)
+	}
+	return &model2.OrderStatus{
+		OrderID:   o.id,
+		Status:    o.status.String(),
+		Filled:    o.filled,
+		Remaining: o.qty - o.filled,
+		AvgPrice:  o.avgPx,
+		Fee:       -o.fee,
+		FeeCcy:    "USDT",
+	}, nil
+}
+
+func (e *PaperExchange) GetPosition(symbol string, tradeType model2.OrderTradeType) (long *model2.PositionInfo, short *model2.PositionInfo, err error) {
+	symbol = paperSymbol(symbol)
+	price, err := e.refreshPrice(symbol)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if p := e.positions[positionKey(symbol, model2.OrderPosSideLong)]; p != nil {
+		long = e.positionInfo(p, price)
+	}
+	if p := e.positions[positionKey(symbol, model2.OrderPosSideShort)]; p != nil {
+		short = e.positionInfo(p, price)
+	}
+	return long, short, nil
+}
+
+func (e *PaperExchange) positionInfo(p *paperPosition, price float64) *model2.PositionInfo {
+	upl := e.unrealized(p, price)
+	uplRatio := 0.0
+	if p.margin > 0 {
+		uplRatio = upl / p.margin
+	}
+	return &model2.PositionInfo{
+		Symbol:        p.symbol,
+		Dir:           p.dir,
+		Amount:        p.qty,
+		AvgPrice:      p.avgPrice,
+		MgnMode:       string(p.mgnMode),
+		LiqPx:         formatPx(e.liqPrice(p)),
+		AlgoId:        p.algoId,
+		SlTriggerPx:   p.sl,
+		PositionId:    p.posId,
+		UnrealizedPnl: formatPx(upl),
+		UplRatio:      formatPx(uplRatio),
+		MarkPx:        formatPx(price),
+		Margin:        formatPx(p.margin),
+		Lever:         strconv.Itoa(p.leverage),
+		NotionalUsd:   formatPx(p.qty * price),
+		Last:          price,
+		CTime:         strconv.FormatInt(p.openTime.UnixMilli(), 10),
+	}
+}
+
+func (e *PaperExchange) unrealized(p *paperPosition, price float64) float64 {
+	pnl := p.qty * (price - p.avgPrice)
+	if p.dir == model2.OrderPosSideShort {
+		return -pnl
+	}
+	return pnl
+}
+
+// liqPrice 亏完保证金时的价格
+func (e *PaperExchange) liqPrice(p *paperPosition) float64 {
+	if p.qty == 0 {
+		return 0
+	}
+	move := p.margin / p.qty
+	if p.dir == model2.OrderPosSideShort {
+		return p.avgPrice + move
+	}
+	return p.avgPrice - move
+}
+
+// 平仓，语义与 FuturesCommon.ClosePosition 一致：side 为持仓方向 long/short，市价平掉 quantity
+func (e *PaperExchange) ClosePosition(symbol string, side string, quantity float64, tdMode string, tradeType model2.OrderTradeType) (*model2.OrderResponse, error) {
+	symbol = paperSymbol(symbol)
+	var dir model2.OrderPosSide
+	switch side {
+	case "long":
+		dir = model2.OrderPosSideLong
+	case "short":
+		dir = model2.OrderPosSideShort
+	default:
+		return nil, fmt.Errorf("unknown side: %s", side)
+	}
+
+	price, err := e.refreshPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	p := e.positions[positionKey(symbol, dir)]
+	if p == nil {
+		return nil, fmt.Errorf("no %s position for %s", side, symbol)
+	}
+	if quantity <= 0 {
+		return nil, fmt.Errorf("invalid close quantity: %v", quantity)
+	}
+	o := e.closeLocked(p, quantity, e.withSlippage(price, dir == model2.OrderPosSideShort), "close")
+	return &model2.OrderResponse{OrderId: o.id, Status: int(o.status)}, nil
+}
+
+// AmendAlgoOrder 修改仓位上的止盈止损，价格 <=0 表示不修改
+func (e *PaperExchange) AmendAlgoOrder(instId string, tradeType model2.OrderTradeType, algoId string, newSlTriggerPx, newTpTriggerPx float64) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, p := range e.positions {
+		if p.algoId != algoId || algoId == "" {
+			continue
+		}
+		if newSlTriggerPx > 0 {
+			p.sl = newSlTriggerPx
+		}
+		if newTpTriggerPx > 0 {
+			p.tp = newTpTriggerPx
+		}
+		// 返回与 OKX 相同结构的响应
+		return json.Marshal(map[string]any{
+			"code": "0",
+			"msg":  "",
+			"data": []map[string]string{{"algoId": algoId, "sCode": "0", "sMsg": ""}},
+		})
+	}
+	return nil, fmt.Errorf("algo order %s not found", algoId)
+}
+
+func (e *PaperExchange) Account(tradeType model2.OrderTradeType) (Account, error) {
+	return &paperAccount{ex: e}, nil
+}
+
+// availableLocked 可用余额 = 钱包余额 - 仓位保证金，挂单冻结的部分已经从 cash 中扣除
+func (e *PaperExchange) availableLocked() float64 {
+	avail := e.cash
+	for _, p := range e.positions {
+		avail -= p.margin
+	}
+	if avail < 0 {
+		return 0
+	}
+	return avail
+}
+
+func (e *PaperExchange) withSlippage(price float64, buy bool) float64 {
+	if buy {
+		return price * (1 + e.cfg.Slippage)
+	}
+	return price * (1 - e.cfg.Slippage)
+}
+
+type paperAccount struct {
+	ex *PaperExchange
+}
+
+func (a *paperAccount) GetAccount(ctx context.Context, coin string) (*account.Account, error) {
+	if coin != "USDT" {
+		return nil, errors.New("account info not found for coin " + coin)
+	}
+	e := a.ex
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	frozen := 0.0
+	for _, o := range e.orders {
+		frozen += o.frozen
+	}
+	total := e.cash + frozen
+	for _, p := range e.positions {
+		frozen += p.margin
+		if price, ok := e.prices[p.symbol]; ok {
+			total += e.unrealized(p, price)
+		}
+	}
+	return &account.Account{
+		Currency:  coin,
+		Total:     total,
+		Available: e.availableLocked(),
+		Frozen:    frozen,
+	}, nil
+}
+
+func formatPx(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
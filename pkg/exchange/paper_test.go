@@ -0,0 +1,172 @@
+package exchange
+
+import (
+	"context"
+	"edgeflow/internal/model"
+	"math"
+	"testing"
+
+	goexmodel "github.com/nntaoli-project/goex/v2/model"
+)
+
+// 固定价格的行情源，测试中手动改价
+type stubMarket struct {
+	price float64
+}
+
+func (m *stubMarket) GetLastPrice(symbol string, tradingType model.OrderTradeType) (float64, error) {
+	return m.price, nil
+}
+
+func (m *stubMarket) GetKlineRecords(symbol string, period goexmodel.KlinePeriod, size int, start, end int64, tradeType model.OrderTradeType, includeUnclosed bool) ([]model.Kline, error) {
+	return []model.Kline{{Close: m.price}}, nil
+}
+
+func newTestPaper(price float64) (*PaperExchange, *stubMarket) {
+	market := &stubMarket{price: price}
+	cfg := PaperConfig{Balance: 1000, TakerFeeRate: 0.001, MakerFeeRate: 0.0005, DefaultLeverage: 10}
+	return NewPaperExchange(market, cfg), market
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func paperBalance(t *testing.T, ex *PaperExchange) float64 {
+	acc, _ := ex.Account(model.OrderTradeSwap)
+	a, err := acc.GetAccount(context.Background(), "USDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.Total
+}
+
+func TestPaperExchange_OpenClose(t *testing.T) {
+	ex, market := newTestPaper(100)
+	order := &model.Order{Symbol: "BTC-USDT-SWAP", Side: model.Buy, OrderType: model.Market, TradeType: model.OrderTradeSwap, Quantity: 5}
+	resp, err := ex.PlaceOrder(context.Background(), order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, _ := ex.GetOrderStatus(resp.OrderId, "BTC/USDT", model.OrderTradeSwap)
+	if status.Status != "finished" || status.Filled != 5 {
+		t.Fatalf("市价单应立即成交: %+v", status)
+	}
+
+	// 名义价值500，10倍杠杆占用保证金50，开仓手续费0.5
+	market.price = 110
+	long, short, err := ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	if err != nil || long == nil || short != nil {
+		t.Fatalf("应只有多仓: %v %v %v", long, short, err)
+	}
+	if long.Amount != 5 || long.Margin != "50" || long.UnrealizedPnl != "50" || long.UplRatio != "1" {
+		t.Fatalf("持仓信息错误: %+v", long)
+	}
+
+	// 平掉一半，盈利25，手续费 2.5*110*0.001=0.275
+	if _, err := ex.ClosePosition("BTC/USDT", "long", 2.5, "isolated", model.OrderTradeSwap); err != nil {
+		t.Fatal(err)
+	}
+	long, _, _ = ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	if long.Amount != 2.5 || long.Margin != "25" {
+		t.Fatalf("部分平仓错误: %+v", long)
+	}
+	if _, err := ex.ClosePosition("BTC/USDT", "long", long.Amount, "isolated", model.OrderTradeSwap); err != nil {
+		t.Fatal(err)
+	}
+	if got := paperBalance(t, ex); !almostEqual(got, 1000-0.5+50-0.55) {
+		t.Fatalf("平仓后余额错误: %v", got)
+	}
+}
+
+func TestPaperExchange_QuantityPct(t *testing.T) {
+	ex, _ := newTestPaper(100)
+	order := &model.Order{Symbol: "ETHUSDT", Side: model.Sell, OrderType: model.Market, TradeType: model.OrderTradeSwap, QuantityPct: 0.5}
+	if _, err := ex.PlaceOrder(context.Background(), order); err != nil {
+		t.Fatal(err)
+	}
+	// 1000*0.5*0.98*10/100
+	if !almostEqual(order.Quantity, 49) || order.MgnMode != model.OrderMgnModeIsolated {
+		t.Fatalf("按比例下单应回写数量和保证金模式: %+v", order)
+	}
+	_, short, _ := ex.GetPosition("ETH/USDT", model.OrderTradeSwap)
+	if short == nil || short.Amount != order.Quantity {
+		t.Fatalf("应开空仓: %+v", short)
+	}
+}
+
+func TestPaperExchange_TPSL(t *testing.T) {
+	ex, market := newTestPaper(100)
+	order := &model.Order{Symbol: "BTC/USDT", Side: model.Buy, OrderType: model.Market, TradeType: model.OrderTradeSwap, Quantity: 1, TPPrice: 120, SLPrice: 95}
+	if _, err := ex.PlaceOrder(context.Background(), order); err != nil {
+		t.Fatal(err)
+	}
+	long, _, _ := ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	if long.AlgoId == "" {
+		t.Fatalf("带止盈止损的订单应生成 AlgoId")
+	}
+
+	// 上移止损
+	if _, err := ex.AmendAlgoOrder("BTC-USDT-SWAP", model.OrderTradeSwap, long.AlgoId, 105, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ex.AmendAlgoOrder("BTC-USDT-SWAP", model.OrderTradeSwap, "none", 105, 0); err == nil {
+		t.Fatalf("不存在的 algoId 应报错")
+	}
+
+	market.price = 110
+	if long, _, _ = ex.GetPosition("BTC/USDT", model.OrderTradeSwap); long == nil {
+		t.Fatalf("未触发止盈止损前仓位应保留")
+	}
+	market.price = 104
+	if long, _, _ = ex.GetPosition("BTC/USDT", model.OrderTradeSwap); long != nil {
+		t.Fatalf("价格跌破修改后的止损应平仓: %+v", long)
+	}
+	// 盈利 4，开平手续费 0.1+0.104
+	if got := paperBalance(t, ex); !almostEqual(got, 1000+4-0.1-0.104) {
+		t.Fatalf("止损后余额错误: %v", got)
+	}
+}
+
+func TestPaperExchange_LimitOrder(t *testing.T) {
+	ex, _ := newTestPaper(100)
+	order := &model.Order{Symbol: "BTC/USDT", Side: model.Buy, OrderType: model.Limit, TradeType: model.OrderTradeSwap, Price: 90, Quantity: 2}
+	resp, err := ex.PlaceOrder(context.Background(), order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc, _ := ex.Account(model.OrderTradeSwap)
+	a, _ := acc.GetAccount(context.Background(), "USDT")
+	if a.Available != 1000-18 || a.Frozen != 18 {
+		t.Fatalf("挂单应冻结保证金: %+v", a)
+	}
+
+	ex.OnPrice("BTC/USDT", 91)
+	status, _ := ex.GetOrderStatus(resp.OrderId, "BTC/USDT", model.OrderTradeSwap)
+	if status.Status != "pending" {
+		t.Fatalf("未到委托价不应成交: %+v", status)
+	}
+	ex.OnPrice("BTC/USDT", 89)
+	status, _ = ex.GetOrderStatus(resp.OrderId, "BTC/USDT", model.OrderTradeSwap)
+	if status.Status != "finished" {
+		t.Fatalf("穿过委托价应成交: %+v", status)
+	}
+	long, _, _ := ex.GetPosition("BTC/USDT", model.OrderTradeSwap)
+	if long == nil || long.AvgPrice != 90 {
+		t.Fatalf("限价单应按委托价成交: %+v", long)
+	}
+
+	// 撤销未成交的挂单后释放冻结
+	order = &model.Order{Symbol: "BTC/USDT", Side: model.Sell, OrderType: model.Limit, TradeType: model.OrderTradeSwap, Price: 150, Quantity: 1}
+	resp, _ = ex.PlaceOrder(context.Background(), order)
+	if err := ex.CancelOrder(resp.OrderId, "BTC/USDT", model.OrderTradeSwap); err != nil {
+		t.Fatal(err)
+	}
+	if err := ex.CancelOrder(resp.OrderId, "BTC/USDT", model.OrderTradeSwap); err == nil {
+		t.Fatalf("已撤销的订单不能再次撤销")
+	}
+	a, _ = acc.GetAccount(context.Background(), "USDT")
+	if a.Frozen != 18 {
+		t.Fatalf("撤单后只剩仓位保证金: %+v", a)
+	}
+}
@@ -0,0 +1,433 @@
+package exchange
+
+import (
+	"context"
+	model2 "edgeflow/internal/model"
+	"edgeflow/pkg/account"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nntaoli-project/goex/v2/model"
+)
+
+var (
+	_ Exchange         = (*RateLimitedExchange)(nil)
+	_ LotRulesProvider = (*RateLimitedExchange)(nil)
+	_ QuoteProvider    = (*RateLimitedExchange)(nil)
+)
+
+// Endpoint 交易所接口分类，限频和统计按分类计算
+type Endpoint string
+
+const (
+	EndpointPlaceOrder    Endpoint = "place_order"
+	EndpointCancelOrder   Endpoint = "cancel_order"
+	EndpointOrderStatus   Endpoint = "order_status"
+	EndpointPosition      Endpoint = "position"
+	EndpointClosePosition Endpoint = "close_position"
+	EndpointAmendAlgo     Endpoint = "amend_algo"
+	EndpointTicker        Endpoint = "ticker"
+	EndpointOrderBook     Endpoint = "order_book"
+	EndpointKline         Endpoint = "kline"
+	EndpointBalance       Endpoint = "balance"
+	EndpointInstrument    Endpoint = "instrument"
+)
+
+// RateLimit 每个窗口内最多 Limit 次请求，与交易所文档的写法一致
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// OkxRateLimits okx v5 文档中的接口限频，交易类接口实际按交易对计算，这里按接口整体计算更保守
+func OkxRateLimits() map[Endpoint]RateLimit {
+	per2s := func(n int) RateLimit { return RateLimit{Limit: n, Window: 2 * time.Second} }
+	return map[Endpoint]RateLimit{
+		EndpointPlaceOrder:    per2s(60), // POST /api/v5/trade/order
+		EndpointCancelOrder:   per2s(60), // POST /api/v5/trade/cancel-order
+		EndpointOrderStatus:   per2s(60), // GET /api/v5/trade/order
+		EndpointPosition:      per2s(10), // GET /api/v5/account/positions
+		EndpointClosePosition: per2s(20), // POST /api/v5/trade/close-position
+		EndpointAmendAlgo:     per2s(20), // POST /api/v5/trade/amend-algos
+		EndpointTicker:        per2s(20), // GET /api/v5/market/ticker
+		EndpointOrderBook:     per2s(40), // GET /api/v5/market/books
+		EndpointKline:         per2s(20), // GET /api/v5/market/history-candles，candles 为 40 次
+		EndpointBalance:       per2s(10), // GET /api/v5/account/balance
+		EndpointInstrument:    per2s(20), // GET /api/v5/public/instruments
+	}
+}
+
+// RetryPolicy 重试次数和退避时间，每次等待 [0, min(MaxDelay, BaseDelay*2^n)) 的随机时间
+type RetryPolicy struct {
+	MaxAttempts int // 包括第一次请求
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 3 * time.Second}
+
+// EndpointStats 单个接口的调用统计
+type EndpointStats struct {
+	Calls       int64 `json:"calls"`        // 调用次数，不含重试
+	Retries     int64 `json:"retries"`      // 重试次数
+	Failures    int64 `json:"failures"`     // 重试后仍然失败
+	RateLimited int64 `json:"rate_limited"` // 交易所返回限频
+	Throttled   int64 `json:"throttled"`    // 本地令牌不足而排队
+	WaitMs      int64 `json:"wait_ms"`      // 排队总时长
+}
+
+// RateLimitedExchange 按接口限频排队，限频和临时错误按指数退避加随机抖动重试，其它接口语义不变。
+// 下单和平仓不是幂等的：只有被限频拒绝（请求没有被处理）或者带 ClientOrderId 的下单才会重试
+type RateLimitedExchange struct {
+	Exchange
+	retry   RetryPolicy
+	buckets map[Endpoint]*tokenBucket
+
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func(d time.Duration) time.Duration
+
+	mu    sync.Mutex
+	stats map[Endpoint]*EndpointStats
+}
+
+// limits 中没有的接口不限频，只重试
+func NewRateLimitedExchange(ex Exchange, limits map[Endpoint]RateLimit) *RateLimitedExchange {
+	e := &RateLimitedExchange{
+		Exchange: ex,
+		retry:    DefaultRetryPolicy,
+		buckets:  make(map[Endpoint]*tokenBucket, len(limits)),
+		now:      time.Now,
+		sleep:    sleepContext,
+		jitter:   func(d time.Duration) time.Duration { return time.Duration(rand.Int63n(int64(d) + 1)) },
+		stats:    make(map[Endpoint]*EndpointStats),
+	}
+	for ep, l := range limits {
+		if l.Limit > 0 && l.Window > 0 {
+			e.buckets[ep] = newTokenBucket(l)
+		}
+	}
+	return e
+}
+
+// WithRetry 修改重试策略，MaxAttempts <= 1 时不重试
+func (e *RateLimitedExchange) WithRetry(p RetryPolicy) *RateLimitedExchange {
+	e.retry = p
+	return e
+}
+
+// Stats 各接口的调用统计，e 为空时返回空的统计
+func (e *RateLimitedExchange) Stats() map[Endpoint]EndpointStats {
+	if e == nil {
+		return map[Endpoint]EndpointStats{}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	res := make(map[Endpoint]EndpointStats, len(e.stats))
+	for ep, s := range e.stats {
+		res[ep] = *s
+	}
+	return res
+}
+
+func (e *RateLimitedExchange) record(ep Endpoint, fn func(s *EndpointStats)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, ok := e.stats[ep]
+	if !ok {
+		s = &EndpointStats{}
+		e.stats[ep] = s
+	}
+	fn(s)
+}
+
+// do 排队取令牌后调用 fn，idempotent 为 false 时只在被限频拒绝时重试
+func (e *RateLimitedExchange) do(ctx context.Context, ep Endpoint, idempotent bool, fn func() error) error {
+	e.record(ep, func(s *EndpointStats) { s.Calls++ })
+	var err error
+	for attempt := 0; ; attempt++ {
+		if wErr := e.wait(ctx, ep); wErr != nil {
+			if err == nil {
+				err = wErr
+			}
+			break
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		limited := IsRateLimited(err)
+		if limited {
+			e.record(ep, func(s *EndpointStats) { s.RateLimited++ })
+		}
+		if !(limited || (idempotent && IsRetryable(err))) || attempt+1 >= e.retry.MaxAttempts {
+			break
+		}
+		delay := e.backoff(attempt)
+		e.record(ep, func(s *EndpointStats) { s.Retries++ })
+		log.Printf("[RateLimitedExchange] %s 第 %d 次请求失败，%v 后重试: %v", ep, attempt+1, delay, err)
+		if e.sleep(ctx, delay) != nil {
+			break
+		}
+	}
+	e.record(ep, func(s *EndpointStats) { s.Failures++ })
+	return err
+}
+
+// wait 取一个令牌，令牌不足时排队
+func (e *RateLimitedExchange) wait(ctx context.Context, ep Endpoint) error {
+	b, ok := e.buckets[ep]
+	if !ok {
+		return nil
+	}
+	d := b.reserve(e.now())
+	if d <= 0 {
+		return nil
+	}
+	e.record(ep, func(s *EndpointStats) {
+		s.Throttled++
+		s.WaitMs += d.Milliseconds()
+	})
+	return e.sleep(ctx, d)
+}
+
+func (e *RateLimitedExchange) backoff(attempt int) time.Duration {
+	d := e.retry.BaseDelay << attempt
+	if d <= 0 || (e.retry.MaxDelay > 0 && d > e.retry.MaxDelay) {
+		d = e.retry.MaxDelay
+	}
+	return e.jitter(d)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// 带 ClientOrderId 的订单交易所会拒绝重复提交，超时后可以安全重试
+func (e *RateLimitedExchange) PlaceOrder(ctx context.Context, order *model2.Order) (resp *model2.OrderResponse, err error) {
+	err = e.do(ctx, EndpointPlaceOrder, order.ClientOrderId != "", func() error {
+		resp, err = e.Exchange.PlaceOrder(ctx, order)
+		return err
+	})
+	return resp, err
+}
+
+func (e *RateLimitedExchange) GetLastPrice(symbol string, tradingType model2.OrderTradeType) (price float64, err error) {
+	err = e.do(context.Background(), EndpointTicker, true, func() error {
+		price, err = e.Exchange.GetLastPrice(symbol, tradingType)
+		return err
+	})
+	return price, err
+}
+
+// 撤单可以重复提交，订单已撤销时交易所返回业务错误，不会重试
+func (e *RateLimitedExchange) CancelOrder(orderID string, symbol string, tradingType model2.OrderTradeType) error {
+	return e.do(context.Background(), EndpointCancelOrder, true, func() error {
+		return e.Exchange.CancelOrder(orderID, symbol, tradingType)
+	})
+}
+
+func (e *RateLimitedExchange) GetOrderStatus(orderID string, symbol string, tradingType model2.OrderTradeType) (st *model2.OrderStatus, err error) {
+	err = e.do(context.Background(), EndpointOrderStatus, true, func() error {
+		st, err = e.Exchange.GetOrderStatus(orderID, symbol, tradingType)
+		return err
+	})
+	return st, err
+}
+
+func (e *RateLimitedExchange) GetPosition(symbol string, tradeType model2.OrderTradeType) (long *model2.PositionInfo, short *model2.PositionInfo, err error) {
+	err = e.do(context.Background(), EndpointPosition, true, func() error {
+		long, short, err = e.Exchange.GetPosition(symbol, tradeType)
+		return err
+	})
+	return long, short, err
+}
+
+// 平仓按数量市价成交，超时后结果未知，只在被限频拒绝时重试
+func (e *RateLimitedExchange) ClosePosition(symbol string, side string, quantity float64, tdMode string, tradeType model2.OrderTradeType) (resp *model2.OrderResponse, err error) {
+	err = e.do(context.Background(), EndpointClosePosition, false, func() error {
+		resp, err = e.Exchange.ClosePosition(symbol, side, quantity, tdMode, tradeType)
+		return err
+	})
+	return resp, err
+}
+
+// 修改为固定的触发价，重复提交结果相同
+func (e *RateLimitedExchange) AmendAlgoOrder(instId string, tradeType model2.OrderTradeType, algoId string, newSlTriggerPx, newTpTriggerPx float64) (data []byte, err error) {
+	err = e.do(context.Background(), EndpointAmendAlgo, true, func() error {
+		data, err = e.Exchange.AmendAlgoOrder(instId, tradeType, algoId, newSlTriggerPx, newTpTriggerPx)
+		return err
+	})
+	return data, err
+}
+
+func (e *RateLimitedExchange) GetKlineRecords(symbol string, period model.KlinePeriod, size int, start, end int64, tradeType model2.OrderTradeType, includeUnclosed bool) (klines []model2.Kline, err error) {
+	err = e.do(context.Background(), EndpointKline, true, func() error {
+		klines, err = e.Exchange.GetKlineRecords(symbol, period, size, start, end, tradeType, includeUnclosed)
+		return err
+	})
+	return klines, err
+}
+
+// Account 余额查询同样限频
+func (e *RateLimitedExchange) Account(tradeType model2.OrderTradeType) (Account, error) {
+	acc, err := e.Exchange.Account(tradeType)
+	if err != nil {
+		return nil, err
+	}
+	return &rateLimitedAccount{Account: acc, e: e}, nil
+}
+
+type rateLimitedAccount struct {
+	Account
+	e *RateLimitedExchange
+}
+
+func (a *rateLimitedAccount) GetAccount(ctx context.Context, coin string) (acc *account.Account, err error) {
+	err = a.e.do(ctx, EndpointBalance, true, func() error {
+		acc, err = a.Account.GetAccount(ctx, coin)
+		return err
+	})
+	return acc, err
+}
+
+// GetLotRules 内部交易所不支持时返回空的精度，与没有 LotRulesProvider 时一致
+func (e *RateLimitedExchange) GetLotRules(symbol string, tradeType model2.OrderTradeType) (rules *model2.LotRules, err error) {
+	provider, ok := e.Exchange.(LotRulesProvider)
+	if !ok {
+		return &model2.LotRules{}, nil
+	}
+	err = e.do(context.Background(), EndpointInstrument, true, func() error {
+		rules, err = provider.GetLotRules(symbol, tradeType)
+		return err
+	})
+	return rules, err
+}
+
+// GetBestQuote 内部交易所不支持时买一卖一都使用最新价
+func (e *RateLimitedExchange) GetBestQuote(symbol string, tradeType model2.OrderTradeType) (bid, ask float64, err error) {
+	provider, ok := e.Exchange.(QuoteProvider)
+	if !ok {
+		last, err := e.GetLastPrice(symbol, tradeType)
+		return last, last, err
+	}
+	err = e.do(context.Background(), EndpointOrderBook, true, func() error {
+		bid, ask, err = provider.GetBestQuote(symbol, tradeType)
+		return err
+	})
+	return bid, ask, err
+}
+
+// ---- 错误分类 ----
+
+// 交易所的错误类型可以实现这两个方法给出准确的分类，如 binance.APIError
+type rateLimitedError interface{ RateLimited() bool }
+type temporaryError interface{ Temporary() bool }
+
+// goex 的 okx 错误只有文本：http 状态行或者 okx 返回的 msg
+var (
+	rateLimitedMessages = []string{"429", "too many requests", "requests too frequent", "rate limit"}
+	temporaryMessages   = []string{
+		"500 internal server error", "502 bad gateway", "503 service unavailable", "504 gateway",
+		"system is busy", "service temporarily unavailable", "endpoint request timeout",
+		"connection reset", "connection refused", "broken pipe", "i/o timeout", "unexpected eof",
+	}
+)
+
+// IsRateLimited 请求被交易所限频拒绝，没有被处理，任何接口都可以重试
+func IsRateLimited(err error) bool {
+	if err == nil {
+		return false
+	}
+	var rl rateLimitedError
+	if errors.As(err, &rl) {
+		return rl.RateLimited()
+	}
+	return containsAny(strings.ToLower(err.Error()), rateLimitedMessages)
+}
+
+// IsRetryable 限频、网络超时、连接断开和交易所 5xx 等临时错误。
+// 取消的请求和交易对不存在等业务错误不重试
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if IsRateLimited(err) {
+		return true
+	}
+	var tmp temporaryError
+	if errors.As(err, &tmp) {
+		if _, isNet := tmp.(net.Error); !isNet {
+			return tmp.Temporary()
+		}
+	}
+	var netErr net.Error
+	if (errors.As(err, &netErr) && netErr.Timeout()) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	return containsAny(strings.ToLower(err.Error()), temporaryMessages)
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenBucket 令牌桶，容量为窗口内的请求数，按窗口匀速补充
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	rate     float64 // 每秒补充的令牌
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(l RateLimit) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(l.Limit),
+		rate:     float64(l.Limit) / l.Window.Seconds(),
+		tokens:   float64(l.Limit),
+	}
+}
+
+// reserve 预订一个令牌，返回需要等待的时间。令牌可以透支，后面的请求依次排队
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	if now.After(b.last) {
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
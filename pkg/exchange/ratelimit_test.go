@@ -0,0 +1,185 @@
+package exchange
+
+import (
+	"context"
+	"edgeflow/internal/model"
+	"edgeflow/pkg/exchange/binance"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// flakyExchange 前几次请求返回指定错误
+type flakyExchange struct {
+	*PaperExchange
+	errs  []error
+	calls int
+}
+
+func (f *flakyExchange) next() error {
+	f.calls++
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *flakyExchange) GetLastPrice(symbol string, tradingType model.OrderTradeType) (float64, error) {
+	if err := f.next(); err != nil {
+		return 0, err
+	}
+	return f.PaperExchange.GetLastPrice(symbol, tradingType)
+}
+
+func (f *flakyExchange) PlaceOrder(ctx context.Context, order *model.Order) (*model.OrderResponse, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return f.PaperExchange.PlaceOrder(ctx, order)
+}
+
+// newTestRateLimited 使用假时钟，sleep 只推进时钟并记录等待时间，抖动取上限
+func newTestRateLimited(inner Exchange, limits map[Endpoint]RateLimit) (*RateLimitedExchange, *[]time.Duration) {
+	now := time.Unix(1700000000, 0)
+	var sleeps []time.Duration
+	e := NewRateLimitedExchange(inner, limits)
+	e.now = func() time.Time { return now }
+	e.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+		return ctx.Err()
+	}
+	e.jitter = func(d time.Duration) time.Duration { return d }
+	return e, &sleeps
+}
+
+func TestRateLimitedExchange_Throttle(t *testing.T) {
+	paper, _ := newTestPaper(100)
+	ex, sleeps := newTestRateLimited(paper, map[Endpoint]RateLimit{EndpointTicker: {Limit: 2, Window: time.Second}})
+
+	for i := 0; i < 4; i++ {
+		if _, err := ex.GetLastPrice("BTC/USDT", model.OrderTradeSwap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 前两次使用桶内令牌，之后每 500ms 补充一个
+	if len(*sleeps) != 2 || (*sleeps)[0] != 500*time.Millisecond || (*sleeps)[1] != 500*time.Millisecond {
+		t.Fatalf("sleeps = %v", *sleeps)
+	}
+	st := ex.Stats()[EndpointTicker]
+	if st.Calls != 4 || st.Throttled != 2 || st.WaitMs != 1000 || st.Retries != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+	// 没有配置的接口不限频
+	if _, err := ex.GetKlineRecords("BTC/USDT", "1h", 1, 0, 0, model.OrderTradeSwap, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sleeps) != 2 {
+		t.Errorf("kline should not be throttled: %v", *sleeps)
+	}
+}
+
+func TestRateLimitedExchange_Retry(t *testing.T) {
+	paper, _ := newTestPaper(100)
+	timeout := &url.Error{Op: "Get", URL: "https://www.okx.com", Err: context.DeadlineExceeded}
+	flaky := &flakyExchange{PaperExchange: paper, errs: []error{errors.New("429 Too Many Requests"), timeout}}
+	ex, sleeps := newTestRateLimited(flaky, nil)
+
+	price, err := ex.GetLastPrice("BTC/USDT", model.OrderTradeSwap)
+	if err != nil || price != 100 || flaky.calls != 3 {
+		t.Fatalf("price=%v err=%v calls=%d", price, err, flaky.calls)
+	}
+	// 指数退避 200ms、400ms
+	if len(*sleeps) != 2 || (*sleeps)[0] != 200*time.Millisecond || (*sleeps)[1] != 400*time.Millisecond {
+		t.Errorf("sleeps = %v", *sleeps)
+	}
+	st := ex.Stats()[EndpointTicker]
+	if st.Calls != 1 || st.Retries != 2 || st.RateLimited != 1 || st.Failures != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+
+	// 业务错误不重试
+	flaky.calls, flaky.errs = 0, []error{errors.New("not found currency pair")}
+	if _, err := ex.GetLastPrice("XXX/USDT", model.OrderTradeSwap); err == nil || flaky.calls != 1 {
+		t.Errorf("err=%v calls=%d", err, flaky.calls)
+	}
+	// 超过重试次数
+	flaky.calls, flaky.errs = 0, []error{timeout, timeout, timeout, timeout}
+	if _, err := ex.GetLastPrice("BTC/USDT", model.OrderTradeSwap); err == nil || flaky.calls != 3 {
+		t.Errorf("err=%v calls=%d", err, flaky.calls)
+	}
+	if st := ex.Stats()[EndpointTicker]; st.Failures != 2 {
+		t.Errorf("failures = %d", st.Failures)
+	}
+}
+
+func TestRateLimitedExchange_PlaceOrderRetry(t *testing.T) {
+	ctx := context.Background()
+	paper, _ := newTestPaper(100)
+	timeout := &url.Error{Op: "Post", URL: "https://www.okx.com", Err: context.DeadlineExceeded}
+	flaky := &flakyExchange{PaperExchange: paper}
+	ex, _ := newTestRateLimited(flaky, OkxRateLimits())
+	order := func(clOrdId string) *model.Order {
+		return &model.Order{Symbol: "BTC/USDT", Side: model.Buy, OrderType: model.Market, TradeType: model.OrderTradeSwap, Quantity: 1, ClientOrderId: clOrdId}
+	}
+
+	// 没有 ClientOrderId 时超时结果未知，不重试
+	flaky.errs = []error{timeout}
+	if _, err := ex.PlaceOrder(ctx, order("")); err == nil || flaky.calls != 1 {
+		t.Fatalf("err=%v calls=%d", err, flaky.calls)
+	}
+	// 被限频拒绝时请求没有被处理，可以重试
+	flaky.calls, flaky.errs = 0, []error{errors.New("Too Many Requests")}
+	if _, err := ex.PlaceOrder(ctx, order("")); err != nil || flaky.calls != 2 {
+		t.Fatalf("err=%v calls=%d", err, flaky.calls)
+	}
+	// 带 ClientOrderId 时超时可以重试
+	flaky.calls, flaky.errs = 0, []error{timeout}
+	if _, err := ex.PlaceOrder(ctx, order(model.NewClientOrderId("id:1", "tv-level", "open"))); err != nil || flaky.calls != 2 {
+		t.Fatalf("err=%v calls=%d", err, flaky.calls)
+	}
+
+	// 余额查询同样经过限频
+	acc, err := ex.Account(model.OrderTradeSwap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acc.GetAccount(ctx, "USDT"); err != nil {
+		t.Fatal(err)
+	}
+	if st := ex.Stats()[EndpointBalance]; st.Calls != 1 {
+		t.Errorf("balance stats = %+v", st)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err       error
+		limited   bool
+		retryable bool
+	}{
+		{errors.New("429 Too Many Requests"), true, true},
+		{errors.New("Too Many Requests"), true, true},
+		{errors.New("System is busy, please try again later"), false, true},
+		{errors.New("503 Service Unavailable"), false, true},
+		{&url.Error{Op: "Get", URL: "x", Err: context.DeadlineExceeded}, false, true},
+		{fmt.Errorf("GetPosition error: %w", &binance.APIError{Status: 429, Code: -1003}), true, true},
+		{&binance.APIError{Status: 503, Code: -1001}, false, true},
+		{&binance.APIError{Status: 400, Code: -2019, Msg: "Margin is insufficient."}, false, false},
+		{errors.New("not found currency pair"), false, false},
+		{context.Canceled, false, false},
+		{ErrOrderInFlight, false, false},
+	}
+	for _, c := range cases {
+		if got := IsRateLimited(c.err); got != c.limited {
+			t.Errorf("IsRateLimited(%v) = %v", c.err, got)
+		}
+		if got := IsRetryable(c.err); got != c.retryable {
+			t.Errorf("IsRetryable(%v) = %v", c.err, got)
+		}
+	}
+}
@@ -61,6 +61,7 @@ type UserOpenOrder struct {
 	IsTrigger        bool   `json:"isTrigger"`
 	LimitPx          string `json:"limitPx"`
 	Oid              int    `json:"oid"`
+	Cloid            string `json:"cloid"` // 客户端订单ID，没有时为空
 	OrderType        string `json:"orderType"`
 	OrigSz           string `json:"origSz"`
 	ReduceOnly       bool   `json:"reduceOnly"`
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `derivative_metrics` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `inst_id` VARCHAR(30) NOT NULL COMMENT '永续合约 BTC-USDT-SWAP',
+    `funding_rate` DECIMAL(16,10) NOT NULL DEFAULT 0 COMMENT '当期资金费率',
+    `next_funding_rate` DECIMAL(16,10) NOT NULL DEFAULT 0 COMMENT '预测的下一期资金费率',
+    `funding_time` TIMESTAMP NULL COMMENT '当期资金费收取时间',
+    `open_interest` DECIMAL(30,8) NOT NULL DEFAULT 0 COMMENT '持仓量(张)',
+    `open_interest_ccy` DECIMAL(30,8) NOT NULL DEFAULT 0 COMMENT '持仓量(币)',
+    `open_interest_usd` DECIMAL(30,4) NOT NULL DEFAULT 0 COMMENT '持仓价值(美元)',
+    `long_short_ratio` DECIMAL(12,6) NOT NULL DEFAULT 0 COMMENT '主动买入量/主动卖出量',
+    `ts` TIMESTAMP NOT NULL COMMENT '采集时间',
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_derivative_inst_ts` (`inst_id`, `ts`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='永续合约资金费率、持仓量和主动买卖比';
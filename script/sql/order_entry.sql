@@ -0,0 +1,111 @@
+SET @entry_policy_exists = (
+    SELECT COUNT(*)
+    FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE()
+      AND TABLE_NAME = 'order_record'
+      AND COLUMN_NAME = 'entry_policy'
+);
+SET @entry_policy_sql = IF(
+    @entry_policy_exists = 0,
+    'ALTER TABLE `order_record` ADD COLUMN `entry_policy` VARCHAR(20) NOT NULL DEFAULT '''' COMMENT ''限价单入场策略''',
+    'SELECT 1'
+);
+PREPARE stmt FROM @entry_policy_sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @entry_outcome_exists = (
+    SELECT COUNT(*)
+    FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE()
+      AND TABLE_NAME = 'order_record'
+      AND COLUMN_NAME = 'entry_outcome'
+);
+SET @entry_outcome_sql = IF(
+    @entry_outcome_exists = 0,
+    'ALTER TABLE `order_record` ADD COLUMN `entry_outcome` VARCHAR(20) NOT NULL DEFAULT '''' COMMENT ''入场结果（filled/market-filled/partial/canceled/failed）''',
+    'SELECT 1'
+);
+PREPARE stmt FROM @entry_outcome_sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @entry_reason_exists = (
+    SELECT COUNT(*)
+    FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE()
+      AND TABLE_NAME = 'order_record'
+      AND COLUMN_NAME = 'entry_reason'
+);
+SET @entry_reason_sql = IF(
+    @entry_reason_exists = 0,
+    'ALTER TABLE `order_record` ADD COLUMN `entry_reason` VARCHAR(255) NOT NULL DEFAULT '''' COMMENT ''撤单或失败原因''',
+    'SELECT 1'
+);
+PREPARE stmt FROM @entry_reason_sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @entry_attempts_exists = (
+    SELECT COUNT(*)
+    FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE()
+      AND TABLE_NAME = 'order_record'
+      AND COLUMN_NAME = 'entry_attempts'
+);
+SET @entry_attempts_sql = IF(
+    @entry_attempts_exists = 0,
+    'ALTER TABLE `order_record` ADD COLUMN `entry_attempts` INT NOT NULL DEFAULT 0 COMMENT ''下单次数，含追价和改市价''',
+    'SELECT 1'
+);
+PREPARE stmt FROM @entry_attempts_sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @entry_order_id_exists = (
+    SELECT COUNT(*)
+    FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE()
+      AND TABLE_NAME = 'order_record'
+      AND COLUMN_NAME = 'entry_order_id'
+);
+SET @entry_order_id_sql = IF(
+    @entry_order_id_exists = 0,
+    'ALTER TABLE `order_record` ADD COLUMN `entry_order_id` VARCHAR(100) NOT NULL DEFAULT '''' COMMENT ''最后一笔订单ID''',
+    'SELECT 1'
+);
+PREPARE stmt FROM @entry_order_id_sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @entry_filled_exists = (
+    SELECT COUNT(*)
+    FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE()
+      AND TABLE_NAME = 'order_record'
+      AND COLUMN_NAME = 'entry_filled'
+);
+SET @entry_filled_sql = IF(
+    @entry_filled_exists = 0,
+    'ALTER TABLE `order_record` ADD COLUMN `entry_filled` DOUBLE NOT NULL DEFAULT 0 COMMENT ''实际成交数量''',
+    'SELECT 1'
+);
+PREPARE stmt FROM @entry_filled_sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @entry_avg_price_exists = (
+    SELECT COUNT(*)
+    FROM information_schema.COLUMNS
+    WHERE TABLE_SCHEMA = DATABASE()
+      AND TABLE_NAME = 'order_record'
+      AND COLUMN_NAME = 'entry_avg_price'
+);
+SET @entry_avg_price_sql = IF(
+    @entry_avg_price_exists = 0,
+    'ALTER TABLE `order_record` ADD COLUMN `entry_avg_price` DOUBLE NOT NULL DEFAULT 0 COMMENT ''实际成交均价''',
+    'SELECT 1'
+);
+PREPARE stmt FROM @entry_avg_price_sql;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
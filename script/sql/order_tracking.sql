@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS `order_tracking` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `order_id` VARCHAR(100) NOT NULL COMMENT '订单ID',
+    `symbol` VARCHAR(50) NOT NULL COMMENT '交易对',
+    `trade_type` VARCHAR(20) NOT NULL COMMENT '交易类型',
+    `strategy` VARCHAR(50) NOT NULL DEFAULT '' COMMENT '策略名',
+    `signal_id` BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT '关联信号ID',
+    `level` INT NOT NULL DEFAULT 0 COMMENT '信号级别',
+    `role` VARCHAR(10) NOT NULL COMMENT '用途（open/close）',
+    `pos_side` VARCHAR(10) NOT NULL COMMENT '仓位方向（long/short）',
+    `status` VARCHAR(20) NOT NULL COMMENT '订单状态',
+    `filled_qty` DOUBLE NOT NULL DEFAULT 0 COMMENT '已成交数量',
+    `avg_price` DOUBLE NOT NULL DEFAULT 0 COMMENT '成交均价',
+    `fee` DOUBLE NOT NULL DEFAULT 0 COMMENT '手续费（扣费为负）',
+    `fee_ccy` VARCHAR(20) NULL COMMENT '手续费币种',
+    `entry_price` DOUBLE NOT NULL DEFAULT 0 COMMENT '平仓单对应的开仓均价',
+    `ct_val` DOUBLE NOT NULL DEFAULT 0 COMMENT '合约面值',
+    `realized_pnl` DOUBLE NOT NULL DEFAULT 0 COMMENT '已实现盈亏（含手续费）',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '下单时间',
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '最后更新时间',
+    `finished_at` DATETIME NULL COMMENT '结束时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_order_tracking_order_id` (`order_id`),
+    KEY `idx_order_tracking_status` (`status`),
+    KEY `idx_order_tracking_strategy_created` (`strategy`, `created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单生命周期';
+
+CREATE TABLE IF NOT EXISTS `order_status_history` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `order_id` VARCHAR(100) NOT NULL COMMENT '订单ID',
+    `status` VARCHAR(20) NOT NULL COMMENT '订单状态',
+    `filled_qty` DOUBLE NOT NULL DEFAULT 0 COMMENT '已成交数量',
+    `avg_price` DOUBLE NOT NULL DEFAULT 0 COMMENT '成交均价',
+    `fee` DOUBLE NOT NULL DEFAULT 0 COMMENT '手续费',
+    `source` VARCHAR(10) NOT NULL COMMENT '来源（poll/push）',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '记录时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_order_status_history_order` (`order_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单状态变化记录';
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `position_discrepancy` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `symbol` VARCHAR(50) NOT NULL COMMENT '交易对',
+    `trade_type` VARCHAR(20) NOT NULL COMMENT '交易类型',
+    `kind` VARCHAR(32) NOT NULL COMMENT '差异类型',
+    `local_side` VARCHAR(10) NULL COMMENT '本地方向',
+    `local_size` DOUBLE NOT NULL DEFAULT 0 COMMENT '本地数量',
+    `exchange_side` VARCHAR(10) NULL COMMENT '交易所方向',
+    `exchange_size` DOUBLE NOT NULL DEFAULT 0 COMMENT '交易所数量',
+    `action` VARCHAR(32) NOT NULL COMMENT '修复动作',
+    `alerted` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否已提醒',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '发现时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_position_discrepancy_symbol_created` (`symbol`, `created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='仓位对账差异';
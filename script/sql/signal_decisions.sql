@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS `signal_decisions` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `source` VARCHAR(16) NOT NULL COMMENT '决策来源 manager/engine',
+    `strategy` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '策略名称',
+    `symbol` VARCHAR(30) NOT NULL COMMENT '交易对',
+    `signal_id` BIGINT UNSIGNED NULL COMMENT '关联signals表',
+    `level` INT NOT NULL DEFAULT 0 COMMENT '信号等级',
+    `side` VARCHAR(10) NOT NULL COMMENT '信号方向',
+    `price` DECIMAL(20,8) NOT NULL COMMENT '信号价格',
+    `signal_time` TIMESTAMP NOT NULL COMMENT '信号时间',
+    `signal_json` JSON NULL COMMENT '输入信号',
+    `trend_direction` VARCHAR(16) NOT NULL COMMENT '趋势方向',
+    `final_score` DECIMAL(8,4) NULL COMMENT '综合分',
+    `trend_score` DECIMAL(8,4) NULL COMMENT '趋势分',
+    `signal_score` DECIMAL(8,4) NULL COMMENT '信号分',
+    `score_30m` DECIMAL(8,4) NULL COMMENT '30分钟分数',
+    `score_1h` DECIMAL(8,4) NULL COMMENT '1小时分数',
+    `score_4h` DECIMAL(8,4) NULL COMMENT '4小时分数',
+    `slope` DECIMAL(12,6) NULL COMMENT '斜率',
+    `position_json` JSON NULL COMMENT '仓位快照',
+    `action` VARCHAR(16) NOT NULL COMMENT '决策动作',
+    `reason` VARCHAR(128) NOT NULL COMMENT '决策原因',
+    `reduce_percent` DECIMAL(6,4) NULL COMMENT '减仓比例',
+    `config_version` VARCHAR(64) NOT NULL COMMENT '配置版本',
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '决策时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_decision_symbol_created` (`symbol`, `created_at`),
+    KEY `idx_decision_strategy_created` (`strategy`, `created_at`),
+    KEY `idx_decision_action` (`action`),
+    KEY `idx_decision_signal_id` (`signal_id`),
+    KEY `idx_decision_symbol_signal_time` (`symbol`, `signal_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='交易决策审计记录';
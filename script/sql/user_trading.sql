@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS `user_exchange_credentials` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `user_id` BIGINT NOT NULL COMMENT '用户ID',
+    `exchange` VARCHAR(20) NOT NULL COMMENT '交易所',
+    `api_key` VARCHAR(255) NOT NULL COMMENT 'API Key（加密）',
+    `secret_key` VARCHAR(255) NOT NULL COMMENT 'Secret Key（加密）',
+    `passphrase` VARCHAR(255) NOT NULL COMMENT 'Passphrase（加密）',
+    `api_key_mask` VARCHAR(64) NOT NULL COMMENT '脱敏后的 API Key',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_user_exchange` (`user_id`, `exchange`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户交易所 API Key';
+
+CREATE TABLE IF NOT EXISTS `user_orders` (
+    `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '主键ID',
+    `user_id` BIGINT NOT NULL COMMENT '用户ID',
+    `exchange` VARCHAR(20) NOT NULL COMMENT '交易所',
+    `signal_id` BIGINT NOT NULL COMMENT '信号ID',
+    `order_id` VARCHAR(100) NOT NULL DEFAULT '' COMMENT '交易所订单ID',
+    `symbol` VARCHAR(30) NOT NULL COMMENT '交易对',
+    `side` VARCHAR(10) NOT NULL COMMENT '方向',
+    `price` DECIMAL(20,8) NOT NULL COMMENT '委托价格',
+    `tp_price` DECIMAL(20,8) NULL COMMENT '止盈价',
+    `sl_price` DECIMAL(20,8) NULL COMMENT '止损价',
+    `leverage` INT NOT NULL COMMENT '杠杆倍数',
+    `quantity_pct` DECIMAL(6,4) NOT NULL COMMENT '使用可用余额的比例',
+    `error` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '下单失败原因',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '下单时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_user_order_created` (`user_id`, `created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户信号下单记录';
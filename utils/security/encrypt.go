@@ -107,7 +107,16 @@ func pkcs7UnPadding(data []byte) ([]byte, error) {
 	if length == 0 {
 		return nil, errors.New("加密字符串错误！")
 	}
-	//获取填充的个数
+	//获取填充的个数，密钥错误或密文被篡改时可能超出范围
 	unPadding := int(data[length-1])
+	if unPadding < 1 || unPadding > aes.BlockSize || unPadding > length {
+		return nil, errors.New("加密字符串错误！")
+	}
+	//填充的每一位都应等于填充个数
+	for _, b := range data[length-unPadding:] {
+		if int(b) != unPadding {
+			return nil, errors.New("加密字符串错误！")
+		}
+	}
 	return data[:(length - unPadding)], nil
 }